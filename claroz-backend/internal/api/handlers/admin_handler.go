@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/mediagc"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+type AdminHandler struct {
+	userRepo       repository.UserRepositoryInterface
+	mediaCollector mediagc.CollectorInterface
+}
+
+func NewAdminHandler(userRepo repository.UserRepositoryInterface, mediaCollector mediagc.CollectorInterface) *AdminHandler {
+	return &AdminHandler{userRepo: userRepo, mediaCollector: mediaCollector}
+}
+
+// ListPendingRegistrations godoc
+// @Summary List pending registrations
+// @Description List accounts waiting in the registration approval queue, oldest first
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} models.User
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Admin access required"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /admin/registrations [get]
+func (h *AdminHandler) ListPendingRegistrations(c *gin.Context) {
+	users, err := h.userRepo.GetUsersByStatus(models.StatusPending)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch pending registrations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// ApproveRegistration godoc
+// @Summary Approve a pending registration
+// @Description Activate an account waiting in the approval queue
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string} "Invalid user ID or user is not pending"
+// @Failure 403 {object} object{error=string} "Admin access required"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /admin/registrations/{id}/approve [post]
+func (h *AdminHandler) ApproveRegistration(c *gin.Context) {
+	h.setRegistrationStatus(c, models.StatusActive)
+}
+
+// RejectRegistration godoc
+// @Summary Reject a pending registration
+// @Description Reject an account waiting in the approval queue
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string} "Invalid user ID or user is not pending"
+// @Failure 403 {object} object{error=string} "Admin access required"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /admin/registrations/{id}/reject [post]
+func (h *AdminHandler) RejectRegistration(c *gin.Context) {
+	h.setRegistrationStatus(c, models.StatusRejected)
+}
+
+// CollectMedia godoc
+// @Summary Collect unreferenced media
+// @Description Delete stored files that no media have referred to for the grace period, or with dry_run report what would be deleted
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param dry_run query bool false "Report without deleting"
+// @Success 200 {object} mediagc.Report
+// @Failure 400 {object} object{error=string} "Invalid dry_run"
+// @Failure 403 {object} object{error=string} "Admin access required"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /admin/media/gc [post]
+func (h *AdminHandler) CollectMedia(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+		return
+	}
+
+	report, err := h.mediaCollector.Run(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to collect media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *AdminHandler) setRegistrationStatus(c *gin.Context, status string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if user.Status != models.StatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user is not awaiting approval"})
+		return
+	}
+
+	user.Status = status
+	if err := h.userRepo.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
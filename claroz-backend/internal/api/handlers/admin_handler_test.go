@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/mediagc"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestAdminHandler_RegistrationQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userRepo := NewMockUserRepository()
+	handler := NewAdminHandler(userRepo, &MockMediaCollector{})
+
+	router := gin.New()
+	router.GET("/admin/registrations", handler.ListPendingRegistrations)
+	router.POST("/admin/registrations/:id/approve", handler.ApproveRegistration)
+	router.POST("/admin/registrations/:id/reject", handler.RejectRegistration)
+
+	pending := &models.User{ID: uuid.New(), Username: "pending", Email: "pending@example.com", Status: models.StatusPending}
+	spam := &models.User{ID: uuid.New(), Username: "spammer", Email: "spam@example.com", Status: models.StatusPending}
+	active := &models.User{ID: uuid.New(), Username: "active", Email: "active@example.com", Status: models.StatusActive}
+	userRepo.Create(pending)
+	userRepo.Create(spam)
+	userRepo.Create(active)
+
+	t.Run("list pending registrations", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin/registrations", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var users []models.User
+		if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("Expected 2 pending users, got %d", len(users))
+		}
+	})
+
+	t.Run("approve pending user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/registrations/"+pending.ID.String()+"/approve", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if pending.Status != models.StatusActive {
+			t.Errorf("Expected status %s, got %s", models.StatusActive, pending.Status)
+		}
+	})
+
+	t.Run("reject pending user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/registrations/"+spam.ID.String()+"/reject", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if spam.Status != models.StatusRejected {
+			t.Errorf("Expected status %s, got %s", models.StatusRejected, spam.Status)
+		}
+	})
+
+	t.Run("approve active user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/registrations/"+active.ID.String()+"/approve", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+// MockMediaCollector reports a fixed set of unreferenced files
+type MockMediaCollector struct {
+	err      error
+	deleted  int      // files actually deleted
+	released []string // keys of released files deleted
+}
+
+func (m *MockMediaCollector) Run(dryRun bool) (*mediagc.Report, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !dryRun {
+		m.deleted += 2
+	}
+	return &mediagc.Report{DryRun: dryRun, Deleted: []string{"a.jpg", "b.webp"}, Bytes: 300, Failed: []string{}}, nil
+}
+
+func (m *MockMediaCollector) DeleteReleased(keys []string, releasedAt time.Time) []string {
+	m.released = append(m.released, keys...)
+	return keys
+}
+
+func TestAdminHandler_CollectMedia(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector := &MockMediaCollector{}
+	handler := NewAdminHandler(NewMockUserRepository(), collector)
+
+	router := gin.New()
+	router.POST("/admin/media/gc", handler.CollectMedia)
+
+	collect := func(query string) (*httptest.ResponseRecorder, mediagc.Report) {
+		req := httptest.NewRequest("POST", "/admin/media/gc"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var report mediagc.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		w, report := collect("?dry_run=true")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if !report.DryRun || len(report.Deleted) != 2 || report.Bytes != 300 || collector.deleted != 0 {
+			t.Errorf("Expected a report of what would be deleted, got %+v", report)
+		}
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		w, report := collect("")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if report.DryRun || collector.deleted != 2 {
+			t.Errorf("Expected the files to be deleted, got %+v", report)
+		}
+	})
+
+	t.Run("invalid dry_run", func(t *testing.T) {
+		if w, _ := collect("?dry_run=maybe"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("collection failure", func(t *testing.T) {
+		collector.err = errors.New("database unavailable")
+		defer func() { collector.err = nil }()
+		if w, _ := collect(""); w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
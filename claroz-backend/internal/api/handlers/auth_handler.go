@@ -1,28 +1,49 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
 	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	userRepo repository.UserRepositoryInterface
+	userRepo       repository.UserRepositoryInterface
+	inviteRepo     repository.InviteRepositoryInterface
+	config         *config.RegistrationConfig
+	passwordPolicy *utils.PasswordPolicy
+	passwordHasher *utils.PasswordHasher
 }
 
-func NewAuthHandler(userRepo repository.UserRepositoryInterface) *AuthHandler {
-	return &AuthHandler{userRepo: userRepo}
+func NewAuthHandler(
+	userRepo repository.UserRepositoryInterface,
+	inviteRepo repository.InviteRepositoryInterface,
+	cfg *config.RegistrationConfig,
+	passwordPolicy *utils.PasswordPolicy,
+	passwordHasher *utils.PasswordHasher,
+) *AuthHandler {
+	return &AuthHandler{
+		userRepo:       userRepo,
+		inviteRepo:     inviteRepo,
+		config:         cfg,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+	}
 }
 
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required" example:"johndoe"`
-	Email    string `json:"email" binding:"required,email" example:"john@example.com"`
-	Password string `json:"password" binding:"required,min=6" example:"password123"`
-	FullName string `json:"full_name" example:"John Doe"`
+	Username   string `json:"username" binding:"required" example:"johndoe"`
+	Email      string `json:"email" binding:"required,email" example:"john@example.com"`
+	Password   string `json:"password" binding:"required" example:"password123"`
+	FullName   string `json:"full_name" example:"John Doe"`
+	InviteCode string `json:"invite_code,omitempty" example:"K7QX2M9PLA"`
 }
 
 // LoginRequest represents the login request body
@@ -37,49 +58,120 @@ type AuthResponse struct {
 	User  models.User `json:"user"`
 }
 
+// PendingRegistrationResponse is returned when a registration waits for admin approval
+type PendingRegistrationResponse struct {
+	Message string      `json:"message" example:"Registration received and awaiting approval"`
+	User    models.User `json:"user"`
+}
+
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user with username, email, and password
+// @Description Register a new user with username, email, and password. Depending on the server's
+// @Description registration mode an invite code may be required, or the account may be queued for approval.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body RegisterRequest true "Registration details"
 // @Success 201 {object} AuthResponse
-// @Failure 400 {object} object{error=string} "Invalid input or email already registered"
+// @Success 202 {object} PendingRegistrationResponse "Registration awaiting admin approval"
+// @Failure 400 {object} object{error=string} "Invalid input, weak password, or invalid invite code"
+// @Failure 403 {object} object{error=string} "Registration is closed"
+// @Failure 409 {object} object{error=string} "Username already taken or email already registered"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
+	if h.config.Mode == config.RegistrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+		return
+	}
+
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if email already exists
+	if err := utils.ValidateUsername(req.Username, h.config.ReservedUsernames); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the username and email are free up front; the unique indexes
+	// catch concurrent registrations that pass these checks
+	if _, err := h.userRepo.GetByUsername(req.Username); err == nil {
+		writeRegistrationConflict(c, repository.ErrUsernameTaken)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
+		return
+	}
 	if _, err := h.userRepo.GetByEmail(req.Email); err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already registered"})
+		writeRegistrationConflict(c, repository.ErrEmailTaken)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
 		return
 	}
 
 	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := h.passwordHasher.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
+	// Consume an invite use before creating the account so concurrent
+	// registrations cannot exceed the code's limit
+	var invite *models.InviteCode
+	if h.config.Mode == config.RegistrationInvite {
+		if req.InviteCode == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invite code is required"})
+			return
+		}
+		invite, err = h.inviteRepo.Redeem(req.InviteCode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite code"})
+			return
+		}
+	}
+
 	user := &models.User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: hashedPassword,
 		FullName: req.FullName,
+		Role:     models.RoleUser,
+		Status:   models.StatusActive,
+	}
+	if h.config.Mode == config.RegistrationApproval {
+		user.Status = models.StatusPending
 	}
 
 	if err := h.userRepo.Create(user); err != nil {
+		if invite != nil {
+			_ = h.inviteRepo.Release(invite.ID)
+		}
+		if errors.Is(err, repository.ErrUsernameTaken) || errors.Is(err, repository.ErrEmailTaken) {
+			writeRegistrationConflict(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
+	if user.Status == models.StatusPending {
+		c.JSON(http.StatusAccepted, PendingRegistrationResponse{
+			Message: "Registration received and awaiting approval",
+			User:    *user,
+		})
+		return
+	}
+
 	// Generate JWT token
 	token, err := utils.GenerateToken(user.ID)
 	if err != nil {
@@ -93,6 +185,16 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	})
 }
 
+// writeRegistrationConflict writes the response for a username or email
+// another account has
+func writeRegistrationConflict(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+}
+
 // Login godoc
 // @Summary Login user
 // @Description Authenticate user with email and password
@@ -103,6 +205,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} AuthResponse
 // @Failure 400 {object} object{error=string} "Invalid input"
 // @Failure 401 {object} object{error=string} "Invalid credentials"
+// @Failure 403 {object} object{error=string} "Account pending approval or rejected"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -118,11 +221,37 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	if err := utils.ComparePasswords(user.Password, req.Password); err != nil {
+	needsRehash, err := h.passwordHasher.Verify(user.Password, req.Password)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	if !user.IsActive() {
+		switch user.Status {
+		case models.StatusPending:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is awaiting approval"})
+		case models.StatusRejected:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account registration was rejected"})
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+		}
+		return
+	}
+
+	// Upgrade hashes made with a legacy algorithm or weaker parameters while
+	// the plaintext is available. Failing to do so must not block the login.
+	if needsRehash {
+		if hashedPassword, err := h.passwordHasher.Hash(req.Password); err != nil {
+			log.Printf("failed to rehash password for user %s: %v", user.ID, err)
+		} else {
+			user.Password = hashedPassword
+			if err := h.userRepo.Update(user); err != nil {
+				log.Printf("failed to store rehashed password for user %s: %v", user.ID, err)
+			}
+		}
+	}
+
 	token, err := utils.GenerateToken(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
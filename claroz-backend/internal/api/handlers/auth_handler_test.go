@@ -3,29 +3,42 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
 	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
 	"gorm.io/gorm"
 )
 
 // MockUserRepository implements UserRepositoryInterface for testing
 type MockUserRepository struct {
-	users map[string]*models.User
+	users     map[string]*models.User
+	media     *MockMediaRepository // uploads that can become avatars
+	createErr error                // returned by Create, as the unique indexes would on a lost race
+	lookupErr error                // returned by GetByUsername and GetByEmail
 }
 
 func NewMockUserRepository() *MockUserRepository {
 	return &MockUserRepository{
 		users: make(map[string]*models.User),
+		media: NewMockMediaRepository(),
 	}
 }
 
 func (m *MockUserRepository) Create(user *models.User) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
 	if user.ID == uuid.Nil {
 		user.ID = uuid.New()
 	}
@@ -43,12 +56,27 @@ func (m *MockUserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 }
 
 func (m *MockUserRepository) GetByEmail(email string) (*models.User, error) {
+	if m.lookupErr != nil {
+		return nil, m.lookupErr
+	}
 	if user, exists := m.users[email]; exists {
 		return user, nil
 	}
 	return nil, gorm.ErrRecordNotFound
 }
 
+func (m *MockUserRepository) GetByUsername(username string) (*models.User, error) {
+	if m.lookupErr != nil {
+		return nil, m.lookupErr
+	}
+	for _, user := range m.users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
 func (m *MockUserRepository) Update(user *models.User) error {
 	if _, exists := m.users[user.Email]; !exists {
 		return gorm.ErrRecordNotFound
@@ -57,6 +85,33 @@ func (m *MockUserRepository) Update(user *models.User) error {
 	return nil
 }
 
+func (m *MockUserRepository) SetAvatar(userID, mediaID uuid.UUID, variants models.ImageVariants) (*models.User, *repository.ReleasedBlobs, error) {
+	if _, err := m.GetByID(userID); err != nil {
+		return nil, nil, err
+	}
+	if _, err := m.media.attach(userID, []uuid.UUID{mediaID}); err != nil {
+		return nil, nil, err
+	}
+	return m.SetProfileImage(userID, models.ProfileAvatar, variants)
+}
+
+func (m *MockUserRepository) SetProfileImage(userID uuid.UUID, kind string, variants models.ImageVariants) (*models.User, *repository.ReleasedBlobs, error) {
+	user, err := m.GetByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	released := &repository.ReleasedBlobs{At: time.Now()}
+	switch kind {
+	case models.ProfileAvatar:
+		released.Keys = user.AvatarVariants.BlobKeys()
+		user.Avatar, user.AvatarVariants, user.AvatarMediaID = variants[models.VariantFull].URL, variants, nil
+	case models.ProfileBanner:
+		released.Keys = user.BannerVariants.BlobKeys()
+		user.Banner, user.BannerVariants = variants[models.VariantFull].URL, variants
+	}
+	return user, released, nil
+}
+
 func (m *MockUserRepository) Delete(id uuid.UUID) error {
 	for email, user := range m.users {
 		if user.ID == id {
@@ -95,16 +150,83 @@ func (m *MockUserRepository) GetRemoteUsers() ([]*models.User, error) {
 	return remoteUsers, nil
 }
 
+func (m *MockUserRepository) GetUsersByStatus(status string) ([]*models.User, error) {
+	var users []*models.User
+	for _, user := range m.users {
+		if user.Status == status {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// SearchUsers matches the query as a case-insensitive substring of username,
+// full name or handle, ranking exact username matches first
+func (m *MockUserRepository) SearchUsers(viewerID uuid.UUID, query, federationType string, cursor *repository.Cursor, limit int) ([]models.UserSummary, error) {
+	term := strings.ToLower(strings.TrimPrefix(query, "@"))
+	var results []models.UserSummary
+	for _, user := range m.users {
+		if federationType != "" && user.FederationType != federationType {
+			continue
+		}
+		username := strings.ToLower(user.Username)
+		if !strings.Contains(username, term) && !strings.Contains(strings.ToLower(user.FullName), term) &&
+			!strings.Contains(strings.ToLower(user.Handle), term) {
+			continue
+		}
+		summary := user.Summary()
+		summary.SearchRank = 1
+		if username == term {
+			summary.SearchRank = 10
+		}
+		results = append(results, summary)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].SearchRank != results[j].SearchRank {
+			return results[i].SearchRank > results[j].SearchRank
+		}
+		return results[i].ID.String() < results[j].ID.String()
+	})
+
+	page := []models.UserSummary{}
+	for _, summary := range results {
+		if cursor != nil && (summary.SearchRank > cursor.Rank ||
+			(summary.SearchRank == cursor.Rank && summary.ID.String() <= cursor.ID.String())) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, summary)
+	}
+	return page, nil
+}
+
 func setupTestRouter() (*gin.Engine, *MockUserRepository) {
+	router, mockRepo, _ := setupRegistrationTestRouter(config.RegistrationOpen)
+	return router, mockRepo
+}
+
+func setupRegistrationTestRouter(mode string) (*gin.Engine, *MockUserRepository, *MockInviteRepository) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	mockRepo := NewMockUserRepository()
-	authHandler := NewAuthHandler(mockRepo)
+	mockInviteRepo := NewMockInviteRepository()
+	cfg := config.NewConfig()
+	cfg.Registration.Mode = mode
+	authHandler := NewAuthHandler(
+		mockRepo,
+		mockInviteRepo,
+		&cfg.Registration,
+		utils.NewPasswordPolicy(&cfg.Password, staticBreachedList{"password1234": true}),
+		utils.NewPasswordHasher(&cfg.Password),
+	)
 
 	router.POST("/register", authHandler.Register)
 	router.POST("/login", authHandler.Login)
 
-	return router, mockRepo
+	return router, mockRepo, mockInviteRepo
 }
 
 func TestAuthHandler_Register(t *testing.T) {
@@ -294,8 +416,8 @@ func TestAuthHandler_DuplicateEmail(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, httpReq)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
 	}
 
 	var response gin.H
@@ -308,3 +430,362 @@ func TestAuthHandler_DuplicateEmail(t *testing.T) {
 		t.Errorf("Expected error message 'Email already registered', got %v", response["error"])
 	}
 }
+
+// staticBreachedList is a fixed set of breached passwords for testing
+type staticBreachedList map[string]bool
+
+func (l staticBreachedList) IsBreached(password string) bool {
+	return l[password]
+}
+
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthHandler_RegisterUsernameValidation(t *testing.T) {
+	router, mockRepo := setupTestRouter()
+
+	mockRepo.Create(&models.User{
+		Username: "ExistingUser",
+		Email:    "existing@example.com",
+		Password: "password123",
+	})
+
+	tests := []struct {
+		name         string
+		username     string
+		expectedCode int
+	}{
+		{
+			name:         "duplicate username",
+			username:     "existinguser",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "reserved username",
+			username:     "admin",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid characters",
+			username:     "bad name!",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "too short",
+			username:     "ab",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, "/register", RegisterRequest{
+				Username: tt.username,
+				Email:    "new@example.com",
+				Password: "password123",
+			})
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_RegisterConflicts(t *testing.T) {
+	router, mockRepo, inviteRepo := setupRegistrationTestRouter(config.RegistrationInvite)
+	inviteRepo.Create(&models.InviteCode{Code: "RACE", CreatedByID: uuid.New(), MaxUses: 1})
+
+	register := func() *httptest.ResponseRecorder {
+		return postJSON(router, "/register", RegisterRequest{
+			Username:   "racer",
+			Email:      "racer@example.com",
+			Password:   "password123",
+			InviteCode: "RACE",
+		})
+	}
+
+	t.Run("registrations losing a race to the unique indexes conflict", func(t *testing.T) {
+		for _, err := range []error{repository.ErrUsernameTaken, repository.ErrEmailTaken} {
+			mockRepo.createErr = err
+			if w := register(); w.Code != http.StatusConflict {
+				t.Errorf("Expected status code %d for %v, got %d", http.StatusConflict, err, w.Code)
+			}
+			if invite, _ := inviteRepo.GetByCode("RACE"); invite.Uses != 0 {
+				t.Errorf("Expected the invite to be released, got %d uses", invite.Uses)
+			}
+		}
+		mockRepo.createErr = nil
+	})
+
+	t.Run("failed lookups are not taken as available", func(t *testing.T) {
+		mockRepo.lookupErr = errors.New("connection reset")
+		defer func() { mockRepo.lookupErr = nil }()
+		if w := register(); w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+		if invite, _ := inviteRepo.GetByCode("RACE"); invite.Uses != 0 {
+			t.Errorf("Expected the invite not to be redeemed, got %d uses", invite.Uses)
+		}
+	})
+}
+
+func TestAuthHandler_RegisterClosed(t *testing.T) {
+	router, _, _ := setupRegistrationTestRouter(config.RegistrationClosed)
+
+	w := postJSON(router, "/register", RegisterRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAuthHandler_RegisterInviteOnly(t *testing.T) {
+	router, _, inviteRepo := setupRegistrationTestRouter(config.RegistrationInvite)
+
+	expired := time.Now().Add(-time.Hour)
+	inviteRepo.Create(&models.InviteCode{Code: "SINGLEUSE", CreatedByID: uuid.New(), MaxUses: 1})
+	inviteRepo.Create(&models.InviteCode{Code: "EXPIRED", CreatedByID: uuid.New(), MaxUses: 5, ExpiresAt: &expired})
+
+	tests := []struct {
+		name         string
+		username     string
+		inviteCode   string
+		expectedCode int
+	}{
+		{
+			name:         "missing invite code",
+			username:     "nocode",
+			inviteCode:   "",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown invite code",
+			username:     "unknowncode",
+			inviteCode:   "DOESNOTEXIST",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "expired invite code",
+			username:     "expiredcode",
+			inviteCode:   "EXPIRED",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "valid invite code typed in lower case",
+			username:     "invited",
+			inviteCode:   "singleuse",
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "used up invite code",
+			username:     "latecomer",
+			inviteCode:   "SINGLEUSE",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, "/register", RegisterRequest{
+				Username:   tt.username,
+				Email:      tt.username + "@example.com",
+				Password:   "password123",
+				InviteCode: tt.inviteCode,
+			})
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_RegisterApproval(t *testing.T) {
+	router, mockRepo, _ := setupRegistrationTestRouter(config.RegistrationApproval)
+
+	w := postJSON(router, "/register", RegisterRequest{
+		Username: "pendinguser",
+		Email:    "pending@example.com",
+		Password: "password123",
+	})
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	var response PendingRegistrationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.User.Status != models.StatusPending {
+		t.Errorf("Expected status %s, got %s", models.StatusPending, response.User.Status)
+	}
+
+	// Pending accounts cannot log in
+	w = postJSON(router, "/login", LoginRequest{
+		Email:    "pending@example.com",
+		Password: "password123",
+	})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	// Once approved, login succeeds
+	user, _ := mockRepo.GetByEmail("pending@example.com")
+	user.Status = models.StatusActive
+	w = postJSON(router, "/login", LoginRequest{
+		Email:    "pending@example.com",
+		Password: "password123",
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestAuthHandler_RegisterPasswordPolicy(t *testing.T) {
+	router, mockRepo := setupTestRouter()
+
+	tests := []struct {
+		name         string
+		username     string
+		email        string
+		password     string
+		expectedCode int
+	}{
+		{
+			name:         "breached password",
+			username:     "breached",
+			email:        "breached@example.com",
+			password:     "password1234",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "password contains username",
+			username:     "skywalker",
+			email:        "luke@example.com",
+			password:     "Skywalker2024!",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "password contains email local part",
+			username:     "someone",
+			email:        "mailbox@example.com",
+			password:     "mailbox-owner-xx",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "strong password",
+			username:     "strong",
+			email:        "strong@example.com",
+			password:     "correct-horse-battery",
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, "/register", RegisterRequest{
+				Username: tt.username,
+				Email:    tt.email,
+				Password: tt.password,
+			})
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("new accounts use argon2id", func(t *testing.T) {
+		user, err := mockRepo.GetByEmail("strong@example.com")
+		if err != nil {
+			t.Fatalf("Expected user to be created: %v", err)
+		}
+		if !strings.HasPrefix(user.Password, "$argon2id$") {
+			t.Errorf("Expected argon2id hash, got %q", user.Password)
+		}
+	})
+}
+
+func TestAuthHandler_RegisterPasswordMinLength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.NewConfig()
+	cfg.Password.MinLength = 4
+	handler := NewAuthHandler(
+		NewMockUserRepository(),
+		NewMockInviteRepository(),
+		&cfg.Registration,
+		utils.NewPasswordPolicy(&cfg.Password, staticBreachedList{}),
+		utils.NewPasswordHasher(&cfg.Password),
+	)
+	router := gin.New()
+	router.POST("/register", handler.Register)
+
+	t.Run("the policy's minimum applies below six characters", func(t *testing.T) {
+		w := postJSON(router, "/register", RegisterRequest{Username: "shortpw", Email: "shortpw@example.com", Password: "k9#q"})
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("short passwords get the policy's message", func(t *testing.T) {
+		w := postJSON(router, "/register", RegisterRequest{Username: "tooshort", Email: "tooshort@example.com", Password: "k9#"})
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		var response gin.H
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if msg, _ := response["error"].(string); !strings.Contains(msg, "at least 4 characters") {
+			t.Errorf("Expected the policy's message, got %q", msg)
+		}
+	})
+}
+
+func TestAuthHandler_LoginRehashesLegacyPassword(t *testing.T) {
+	router, mockRepo := setupTestRouter()
+
+	// Accounts created before argon2id was introduced have bcrypt hashes
+	legacyHash, _ := utils.HashPassword("password123")
+	mockRepo.Create(&models.User{
+		Username: "legacy",
+		Email:    "legacy@example.com",
+		Password: legacyHash,
+	})
+
+	w := postJSON(router, "/login", LoginRequest{Email: "legacy@example.com", Password: "password123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	user, _ := mockRepo.GetByEmail("legacy@example.com")
+	if !strings.HasPrefix(user.Password, "$argon2id$") {
+		t.Fatalf("Expected password to be rehashed with argon2id, got %q", user.Password)
+	}
+	upgradedHash := user.Password
+
+	// The upgraded hash keeps working and is not rehashed again
+	w = postJSON(router, "/login", LoginRequest{Email: "legacy@example.com", Password: "password123"})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if user.Password != upgradedHash {
+		t.Error("Expected upgraded hash to be left unchanged")
+	}
+
+	// A wrong password must not trigger a rehash
+	w = postJSON(router, "/login", LoginRequest{Email: "legacy@example.com", Password: "wrongpassword"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
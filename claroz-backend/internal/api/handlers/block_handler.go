@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// MuteRequest represents the optional body of a mute request
+type MuteRequest struct {
+	ExpiresAt *time.Time `json:"expires_at" example:"2024-02-26T00:35:27Z"`
+}
+
+// RelationshipResponse represents a blocked or muted user
+type RelationshipResponse struct {
+	User      models.UserSummary `json:"user"`
+	CreatedAt time.Time          `json:"created_at" example:"2024-01-26T00:35:27Z"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty" example:"2024-02-26T00:35:27Z"`
+}
+
+// BlockUser godoc
+// @Summary Block a user
+// @Description Block a user. Removes follows and follow requests in both directions, prevents
+// @Description new follows, comments and likes, and hides each user's content from the other.
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID to block"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID or cannot block yourself"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/block [post]
+func (h *PostHandler) BlockUser(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if userID.(uuid.UUID) == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot block yourself"})
+		return
+	}
+
+	if err := h.postRepo.BlockUser(userID.(uuid.UUID), targetID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to block user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "user blocked successfully"})
+}
+
+// UnblockUser godoc
+// @Summary Unblock a user
+// @Description Remove a block. Follows removed by the block are not restored.
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID to unblock"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/block [delete]
+func (h *PostHandler) UnblockUser(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.postRepo.UnblockUser(userID.(uuid.UUID), targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unblock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "user unblocked successfully"})
+}
+
+// GetBlockedUsers godoc
+// @Summary List blocked users
+// @Description List the users the current user has blocked, newest first
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} RelationshipResponse
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /blocks [get]
+func (h *PostHandler) GetBlockedUsers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	blocks, err := h.postRepo.GetBlockedUsers(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch blocked users"})
+		return
+	}
+
+	response := make([]RelationshipResponse, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, RelationshipResponse{
+			User:      block.Blocked.Summary(),
+			CreatedAt: block.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MuteUser godoc
+// @Summary Mute a user
+// @Description Hide a user's posts from the current user's feed without their knowledge.
+// @Description The mute lasts until expires_at, or indefinitely if it is omitted.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID to mute"
+// @Param mute body MuteRequest false "Mute expiry"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID, input or expiry"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/mute [post]
+func (h *PostHandler) MuteUser(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if userID.(uuid.UUID) == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot mute yourself"})
+		return
+	}
+
+	var input MuteRequest
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	if err := h.postRepo.MuteUser(userID.(uuid.UUID), targetID, input.ExpiresAt); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mute user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "user muted successfully"})
+}
+
+// UnmuteUser godoc
+// @Summary Unmute a user
+// @Description Remove a mute
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID to unmute"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/mute [delete]
+func (h *PostHandler) UnmuteUser(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.postRepo.UnmuteUser(userID.(uuid.UUID), targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unmute user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "user unmuted successfully"})
+}
+
+// GetMutedUsers godoc
+// @Summary List muted users
+// @Description List the users the current user has muted, newest first. Expired mutes are omitted.
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} RelationshipResponse
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /mutes [get]
+func (h *PostHandler) GetMutedUsers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	mutes, err := h.postRepo.GetMutedUsers(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch muted users"})
+		return
+	}
+
+	response := make([]RelationshipResponse, 0, len(mutes))
+	for _, mute := range mutes {
+		response = append(response, RelationshipResponse{
+			User:      mute.Muted.Summary(),
+			CreatedAt: mute.CreatedAt,
+			ExpiresAt: mute.ExpiresAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
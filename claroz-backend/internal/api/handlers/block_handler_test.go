@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestPostHandler_BlockAndMute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	alice := &models.User{ID: uuid.New(), Username: "alice"}
+	bob := &models.User{ID: uuid.New(), Username: "bob"}
+	carol := &models.User{ID: uuid.New(), Username: "carol"}
+	for _, user := range []*models.User{alice, bob, carol} {
+		mockRepo.AddUser(user)
+	}
+
+	bobPost := &models.Post{ID: uuid.New(), UserID: bob.ID, Caption: "Bob's post", ImageURL: "bob.jpg"}
+	carolPost := &models.Post{
+		ID:       uuid.New(),
+		UserID:   carol.ID,
+		Caption:  "Carol's post",
+		ImageURL: "carol.jpg",
+	}
+	mockRepo.posts[bobPost.ID] = bobPost
+	mockRepo.posts[carolPost.ID] = carolPost
+	mockRepo.comments[carolPost.ID] = []*models.Comment{
+		{ID: uuid.New(), PostID: carolPost.ID, UserID: bob.ID, Content: "From bob", CreatedAt: time.Now()},
+		{ID: uuid.New(), PostID: carolPost.ID, UserID: carol.ID, Content: "From carol", CreatedAt: time.Now()},
+	}
+	mockRepo.LikePost(carolPost.ID, bob.ID)
+
+	mockRepo.FollowUser(alice.ID, bob.ID)
+	mockRepo.FollowUser(bob.ID, alice.ID)
+
+	var currentUserID uuid.UUID
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Next()
+	})
+	router.GET("/posts", postHandler.GetPosts)
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.POST("/posts/:id/comments", postHandler.AddComment)
+	router.POST("/posts/:id/like", postHandler.LikePost)
+	router.GET("/users/:id/posts", postHandler.GetUserPosts)
+	router.POST("/users/:id/follow", postHandler.FollowUser)
+	router.POST("/users/:id/block", postHandler.BlockUser)
+	router.DELETE("/users/:id/block", postHandler.UnblockUser)
+	router.POST("/users/:id/mute", postHandler.MuteUser)
+	router.DELETE("/users/:id/mute", postHandler.UnmuteUser)
+	router.GET("/blocks", postHandler.GetBlockedUsers)
+	router.GET("/mutes", postHandler.GetMutedUsers)
+
+	do := func(as uuid.UUID, method, url string, body interface{}) *httptest.ResponseRecorder {
+		currentUserID = as
+		var req *http.Request
+		if body != nil {
+			jsonBody, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, url, bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, url, nil)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	feedFor := func(userID uuid.UUID) map[uuid.UUID]models.PostView {
+		var response PostListResponse
+		json.Unmarshal(do(userID, "GET", "/posts?limit=50", nil).Body.Bytes(), &response)
+		feed := make(map[uuid.UUID]models.PostView)
+		for _, post := range response.Items {
+			feed[post.ID] = post
+		}
+		return feed
+	}
+
+	t.Run("cannot block yourself", func(t *testing.T) {
+		if w := do(alice.ID, "POST", "/users/"+alice.ID.String()+"/block", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("block nonexistent user", func(t *testing.T) {
+		if w := do(alice.ID, "POST", "/users/"+uuid.New().String()+"/block", nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("block removes follows in both directions", func(t *testing.T) {
+		if w := do(alice.ID, "POST", "/users/"+bob.ID.String()+"/block", nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if following, _ := mockRepo.IsFollowing(alice.ID, bob.ID); following {
+			t.Error("Expected alice to no longer follow bob")
+		}
+		if following, _ := mockRepo.IsFollowing(bob.ID, alice.ID); following {
+			t.Error("Expected bob to no longer follow alice")
+		}
+
+		var blocks []RelationshipResponse
+		json.Unmarshal(do(alice.ID, "GET", "/blocks", nil).Body.Bytes(), &blocks)
+		if len(blocks) != 1 || blocks[0].User.ID != bob.ID {
+			t.Errorf("Expected bob in block list, got %+v", blocks)
+		}
+	})
+
+	t.Run("blocked users cannot interact", func(t *testing.T) {
+		// Posts of blocked users are hidden, so writes to them find nothing
+		tests := []struct {
+			name   string
+			method string
+			url    string
+			body   interface{}
+			want   int
+		}{
+			{"follow", "POST", "/users/" + alice.ID.String() + "/follow", nil, http.StatusForbidden},
+			{"like", "POST", "/posts/" + bobPost.ID.String() + "/like", nil, http.StatusNotFound},
+			{"comment", "POST", "/posts/" + bobPost.ID.String() + "/comments", CommentRequest{Content: "Hi"}, http.StatusNotFound},
+		}
+
+		for _, tt := range tests {
+			// The block applies in both directions
+			as := bob.ID
+			if tt.name != "follow" {
+				as = alice.ID
+			}
+			if w := do(as, tt.method, tt.url, tt.body); w.Code != tt.want {
+				t.Errorf("%s: expected status code %d, got %d", tt.name, tt.want, w.Code)
+			}
+		}
+	})
+
+	t.Run("content hidden in both directions", func(t *testing.T) {
+		if _, exists := feedFor(alice.ID)[bobPost.ID]; exists {
+			t.Error("Expected bob's post to be hidden from alice's feed")
+		}
+		if w := do(alice.ID, "GET", "/posts/"+bobPost.ID.String(), nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for GetPost, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := do(bob.ID, "GET", "/users/"+alice.ID.String()+"/posts", nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for GetUserPosts, got %d", http.StatusNotFound, w.Code)
+		}
+
+		var post models.PostView
+		json.Unmarshal(do(alice.ID, "GET", "/posts/"+carolPost.ID.String()+"?expand=comments,likes", nil).Body.Bytes(), &post)
+		if len(post.RecentComments) != 1 || post.RecentComments[0].User.ID != carol.ID {
+			t.Errorf("Expected only carol's comment in the preview, got %+v", post.RecentComments)
+		}
+		if len(post.Comments) != 1 || post.Comments[0].User.ID != carol.ID {
+			t.Errorf("Expected only carol's comment to be visible, got %+v", post.Comments)
+		}
+		if len(post.Likes) != 0 {
+			t.Errorf("Expected bob's like to be hidden, got %d likes", len(post.Likes))
+		}
+		if post.CommentCount != 2 || post.LikeCount != 1 {
+			t.Errorf("Expected counts to include every interaction, got %d comments and %d likes", post.CommentCount, post.LikeCount)
+		}
+	})
+
+	t.Run("unblock restores visibility", func(t *testing.T) {
+		if w := do(alice.ID, "DELETE", "/users/"+bob.ID.String()+"/block", nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if _, exists := feedFor(alice.ID)[bobPost.ID]; !exists {
+			t.Error("Expected bob's post to be visible after unblocking")
+		}
+		if following, _ := mockRepo.IsFollowing(alice.ID, bob.ID); following {
+			t.Error("Expected unblocking not to restore follows")
+		}
+	})
+
+	t.Run("mute hides posts only from the muter", func(t *testing.T) {
+		if w := do(alice.ID, "POST", "/users/"+carol.ID.String()+"/mute", nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if _, exists := feedFor(alice.ID)[carolPost.ID]; exists {
+			t.Error("Expected carol's post to be hidden from alice's feed")
+		}
+		if _, exists := feedFor(bob.ID)[carolPost.ID]; !exists {
+			t.Error("Expected carol's post to remain in bob's feed")
+		}
+		if w := do(alice.ID, "GET", "/posts/"+carolPost.ID.String(), nil); w.Code != http.StatusOK {
+			t.Errorf("Expected muted post to remain reachable directly, got %d", w.Code)
+		}
+
+		if w := do(alice.ID, "DELETE", "/users/"+carol.ID.String()+"/mute", nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if _, exists := feedFor(alice.ID)[carolPost.ID]; !exists {
+			t.Error("Expected carol's post to be visible after unmuting")
+		}
+	})
+
+	t.Run("mute expiry", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		if w := do(alice.ID, "POST", "/users/"+carol.ID.String()+"/mute", MuteRequest{ExpiresAt: &past}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for past expiry, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		future := time.Now().Add(time.Hour)
+		if w := do(alice.ID, "POST", "/users/"+carol.ID.String()+"/mute", MuteRequest{ExpiresAt: &future}); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var mutes []RelationshipResponse
+		json.Unmarshal(do(alice.ID, "GET", "/mutes", nil).Body.Bytes(), &mutes)
+		if len(mutes) != 1 || mutes[0].ExpiresAt == nil {
+			t.Fatalf("Expected one expiring mute, got %+v", mutes)
+		}
+
+		// Simulate the mute lapsing
+		mockRepo.mutes[alice.ID][carol.ID].ExpiresAt = &past
+		if _, exists := feedFor(alice.ID)[carolPost.ID]; !exists {
+			t.Error("Expected expired mute to no longer hide posts")
+		}
+		json.Unmarshal(do(alice.ID, "GET", "/mutes", nil).Body.Bytes(), &mutes)
+		if len(mutes) != 0 {
+			t.Errorf("Expected expired mute to be omitted, got %d", len(mutes))
+		}
+	})
+}
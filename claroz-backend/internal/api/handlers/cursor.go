@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// Page sizes for cursor-paginated lists
+const (
+	defaultPageLimit = 20
+	maxPostPageLimit = 50
+	maxUserPageLimit = 100
+)
+
+// PostListResponse represents a page of posts
+type PostListResponse struct {
+	Items      []models.PostView `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0wMS0yNlQwMDozNToyN1oifQ.c2lnbmF0dXJl"`
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Items      []models.UserSummary `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0wMS0yNlQwMDozNToyN1oifQ.c2lnbmF0dXJl"`
+}
+
+// CommentListResponse represents a page of comments
+type CommentListResponse struct {
+	Items      []models.CommentView `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0wMS0yNlQwMDozNToyN1oifQ.c2lnbmF0dXJl"`
+}
+
+// ReactionListResponse represents a page of reactions
+type ReactionListResponse struct {
+	Items      []models.ReactionView `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0wMS0yNlQwMDozNToyN1oifQ.c2lnbmF0dXJl"`
+}
+
+// cursorPage reads the cursor and limit query parameters, falling back to
+// the default limit for missing or out of range values. It writes an error
+// response and returns false if the cursor is invalid.
+func cursorPage(c *gin.Context, cursors *utils.CursorCodec, maxLimit int) (*repository.Cursor, int, bool) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if limit < 1 || limit > maxLimit {
+		limit = defaultPageLimit
+	}
+
+	value := c.Query("cursor")
+	if value == "" {
+		return nil, limit, true
+	}
+	var cursor repository.Cursor
+	if err := cursors.Decode(value, &cursor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return nil, 0, false
+	}
+	return &cursor, limit, true
+}
+
+// nextCursor returns the cursor for the page after a full page ending at
+// last, or an empty string if the page was not full
+func nextCursor(cursors *utils.CursorCodec, count, limit int, last func() repository.Cursor) string {
+	if count < limit {
+		return ""
+	}
+	return cursors.Encode(last())
+}
+
+// legacyPage reads the deprecated page and pageSize query parameters. It
+// returns false if neither is present, unless byDefault is set and neither
+// cursor nor limit is present either, for endpoints whose clients predate
+// cursor pagination. Otherwise it marks the response as deprecated and
+// returns the offset and page size to use.
+func legacyPage(c *gin.Context, byDefault bool) (int, int, bool) {
+	if c.Query("page") == "" && c.Query("pageSize") == "" &&
+		(!byDefault || c.Query("cursor") != "" || c.Query("limit") != "") {
+		return 0, 0, false
+	}
+	c.Header("Deprecation", "true")
+	c.Header("Link", `<`+c.Request.URL.Path+`>; rel="successor-version"`)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 10
+	}
+	return (page - 1) * pageSize, pageSize, true
+}
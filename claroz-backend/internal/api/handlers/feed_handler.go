@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetHomeFeed godoc
+// @Summary Get the home feed
+// @Description Retrieve posts by the current user and the accounts they follow, newest first,
+// @Description with cursor pagination. Posts by blocked and muted users are excluded.
+// @Tags feed
+// @Produce json
+// @Security Bearer
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 50)" minimum(1) maximum(50)
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} object{error=string} "Invalid cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /feed/home [get]
+func (h *PostHandler) GetHomeFeed(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxPostPageLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.timeline.GetHomeTimeline(viewerID.(uuid.UUID), cursor, limit)
+	h.writePostPage(c, viewerID.(uuid.UUID), posts, err, limit, "failed to fetch feed")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestPostHandler_GetHomeFeed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	viewer := &models.User{ID: uuid.New(), Username: "viewer"}
+	followed := &models.User{ID: uuid.New(), Username: "followed"}
+	muted := &models.User{ID: uuid.New(), Username: "muted"}
+	stranger := &models.User{ID: uuid.New(), Username: "stranger"}
+	for _, user := range []*models.User{viewer, followed, muted, stranger} {
+		mockRepo.AddUser(user)
+	}
+	mockRepo.FollowUser(viewer.ID, followed.ID)
+	mockRepo.FollowUser(viewer.ID, muted.ID)
+	mockRepo.MuteUser(viewer.ID, muted.ID, nil)
+
+	// Posts share a timestamp in pairs so the ID tiebreaker is exercised
+	base := time.Now()
+	expected := make(map[uuid.UUID]bool)
+	for i := 0; i < 6; i++ {
+		author := followed.ID
+		if i%3 == 0 {
+			author = viewer.ID
+		}
+		post := &models.Post{ID: uuid.New(), UserID: author, Caption: fmt.Sprintf("post %d", i), CreatedAt: base.Add(-time.Duration(i/2) * time.Minute)}
+		mockRepo.posts[post.ID] = post
+		expected[post.ID] = true
+	}
+	for _, author := range []uuid.UUID{muted.ID, stranger.ID} {
+		post := &models.Post{ID: uuid.New(), UserID: author, Caption: "excluded", CreatedAt: base}
+		mockRepo.posts[post.ID] = post
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", viewer.ID)
+		c.Next()
+	})
+	router.GET("/feed/home", postHandler.GetHomeFeed)
+
+	get := func(url string) (*httptest.ResponseRecorder, PostListResponse) {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response PostListResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("paginate through followed and own posts", func(t *testing.T) {
+		seen := make(map[uuid.UUID]bool)
+		var previous *models.PostView
+		url := "/feed/home?limit=4"
+		for pages := 0; url != ""; pages++ {
+			if pages > 3 {
+				t.Fatal("Too many pages")
+			}
+			w, response := get(url)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+			for i := range response.Items {
+				post := response.Items[i]
+				if !expected[post.ID] {
+					t.Errorf("Unexpected post %q in feed", post.Caption)
+				}
+				if seen[post.ID] {
+					t.Errorf("Post %q returned twice", post.Caption)
+				}
+				if previous != nil && post.CreatedAt.After(previous.CreatedAt) {
+					t.Error("Expected posts newest first")
+				}
+				seen[post.ID] = true
+				previous = &post
+			}
+
+			url = ""
+			if response.NextCursor != "" {
+				url = "/feed/home?limit=4&cursor=" + response.NextCursor
+			}
+		}
+
+		if len(seen) != len(expected) {
+			t.Errorf("Expected %d posts across pages, got %d", len(expected), len(seen))
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		if w, _ := get("/feed/home?cursor=not-a-cursor"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
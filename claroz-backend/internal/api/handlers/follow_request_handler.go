@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+// FollowRequestResponse represents a pending follow request and the other party
+type FollowRequestResponse struct {
+	ID        uuid.UUID          `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	User      models.UserSummary `json:"user"`
+	CreatedAt time.Time          `json:"created_at" example:"2024-01-26T00:35:27Z"`
+}
+
+// GetIncomingFollowRequests godoc
+// @Summary List incoming follow requests
+// @Description List pending requests to follow the current user, oldest first
+// @Tags follow-requests
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} FollowRequestResponse
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /follow-requests [get]
+func (h *PostHandler) GetIncomingFollowRequests(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	requests, err := h.postRepo.GetIncomingFollowRequests(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch follow requests"})
+		return
+	}
+
+	response := make([]FollowRequestResponse, 0, len(requests))
+	for _, request := range requests {
+		response = append(response, FollowRequestResponse{
+			ID:        request.ID,
+			User:      request.Requester.Summary(),
+			CreatedAt: request.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetOutgoingFollowRequests godoc
+// @Summary List outgoing follow requests
+// @Description List the current user's pending requests to follow private accounts, newest first
+// @Tags follow-requests
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} FollowRequestResponse
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /follow-requests/outgoing [get]
+func (h *PostHandler) GetOutgoingFollowRequests(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	requests, err := h.postRepo.GetOutgoingFollowRequests(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch follow requests"})
+		return
+	}
+
+	response := make([]FollowRequestResponse, 0, len(requests))
+	for _, request := range requests {
+		response = append(response, FollowRequestResponse{
+			ID:        request.ID,
+			User:      request.Target.Summary(),
+			CreatedAt: request.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ApproveFollowRequest godoc
+// @Summary Approve a follow request
+// @Description Approve a pending request to follow the current user
+// @Tags follow-requests
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Follow request ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid request ID"
+// @Failure 404 {object} object{error=string} "Follow request not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /follow-requests/{id}/approve [post]
+func (h *PostHandler) ApproveFollowRequest(c *gin.Context) {
+	request, ok := h.findFollowRequest(c, func(r *models.FollowRequest, userID uuid.UUID) bool {
+		return r.TargetID == userID
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.postRepo.ApproveFollowRequest(request.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve follow request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "follow request approved"})
+}
+
+// RejectFollowRequest godoc
+// @Summary Reject a follow request
+// @Description Reject a pending request to follow the current user
+// @Tags follow-requests
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Follow request ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid request ID"
+// @Failure 404 {object} object{error=string} "Follow request not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /follow-requests/{id}/reject [post]
+func (h *PostHandler) RejectFollowRequest(c *gin.Context) {
+	request, ok := h.findFollowRequest(c, func(r *models.FollowRequest, userID uuid.UUID) bool {
+		return r.TargetID == userID
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.postRepo.DeleteFollowRequest(request.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject follow request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "follow request rejected"})
+}
+
+// CancelFollowRequest godoc
+// @Summary Cancel a follow request
+// @Description Cancel one of the current user's pending follow requests
+// @Tags follow-requests
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Follow request ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid request ID"
+// @Failure 404 {object} object{error=string} "Follow request not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /follow-requests/{id} [delete]
+func (h *PostHandler) CancelFollowRequest(c *gin.Context) {
+	request, ok := h.findFollowRequest(c, func(r *models.FollowRequest, userID uuid.UUID) bool {
+		return r.RequesterID == userID
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.postRepo.DeleteFollowRequest(request.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel follow request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "follow request cancelled"})
+}
+
+// findFollowRequest loads the request named in the path and checks that the
+// current user is the party allowed to act on it. Requests belonging to other
+// users are reported as not found.
+func (h *PostHandler) findFollowRequest(c *gin.Context, allowed func(*models.FollowRequest, uuid.UUID) bool) (*models.FollowRequest, bool) {
+	userID, _ := c.Get("userID")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request ID"})
+		return nil, false
+	}
+
+	request, err := h.postRepo.GetFollowRequest(id)
+	if err != nil || request == nil || !allowed(request, userID.(uuid.UUID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "follow request not found"})
+		return nil, false
+	}
+
+	return request, true
+}
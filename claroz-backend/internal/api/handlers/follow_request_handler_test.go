@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestPostHandler_FollowRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	owner := &models.User{ID: uuid.New(), Username: "owner", IsPrivate: true}
+	requester := &models.User{ID: uuid.New(), Username: "requester"}
+	stranger := &models.User{ID: uuid.New(), Username: "stranger"}
+	mockRepo.AddUser(owner)
+	mockRepo.AddUser(requester)
+	mockRepo.AddUser(stranger)
+
+	privatePost := &models.Post{ID: uuid.New(), UserID: owner.ID, Caption: "Private post", ImageURL: "private.jpg"}
+	mockRepo.posts[privatePost.ID] = privatePost
+
+	var currentUserID uuid.UUID
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Next()
+	})
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.POST("/posts/:id/like", postHandler.LikePost)
+	router.POST("/posts/:id/comments", postHandler.AddComment)
+	router.PUT("/posts/:id/reactions/:emoji", postHandler.ReactToPost)
+	router.GET("/posts", postHandler.GetPosts)
+	router.GET("/users/:id/posts", postHandler.GetUserPosts)
+	router.POST("/users/:id/follow", postHandler.FollowUser)
+	router.GET("/follow-requests", postHandler.GetIncomingFollowRequests)
+	router.GET("/follow-requests/outgoing", postHandler.GetOutgoingFollowRequests)
+	router.POST("/follow-requests/:id/approve", postHandler.ApproveFollowRequest)
+	router.POST("/follow-requests/:id/reject", postHandler.RejectFollowRequest)
+	router.DELETE("/follow-requests/:id", postHandler.CancelFollowRequest)
+
+	do := func(as uuid.UUID, method, url string) *httptest.ResponseRecorder {
+		currentUserID = as
+		req := httptest.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	incomingFor := func(userID uuid.UUID) []FollowRequestResponse {
+		var response []FollowRequestResponse
+		json.Unmarshal(do(userID, "GET", "/follow-requests").Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("private posts hidden from non-followers", func(t *testing.T) {
+		if w := do(requester.ID, "GET", "/posts/"+privatePost.ID.String()); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for GetPost, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := do(requester.ID, "GET", "/users/"+owner.ID.String()+"/posts"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d for GetUserPosts, got %d", http.StatusForbidden, w.Code)
+		}
+		for _, write := range []struct{ method, url string }{
+			{"POST", "/posts/" + privatePost.ID.String() + "/like"},
+			{"POST", "/posts/" + privatePost.ID.String() + "/comments"},
+			{"PUT", "/posts/" + privatePost.ID.String() + "/reactions/laugh"},
+		} {
+			if w := do(requester.ID, write.method, write.url); w.Code != http.StatusNotFound {
+				t.Errorf("Expected status code %d for %s %s, got %d", http.StatusNotFound, write.method, write.url, w.Code)
+			}
+		}
+		if liked, _ := mockRepo.HasUserLikedPost(privatePost.ID, requester.ID); liked {
+			t.Error("Expected a non-follower not to be able to like a private post")
+		}
+
+		var feed PostListResponse
+		json.Unmarshal(do(requester.ID, "GET", "/posts?limit=50").Body.Bytes(), &feed)
+		if len(feed.Items) != 0 {
+			t.Errorf("Expected private post to be excluded from feed, got %d posts", len(feed.Items))
+		}
+	})
+
+	t.Run("owner can view own posts", func(t *testing.T) {
+		if w := do(owner.ID, "GET", "/posts/"+privatePost.ID.String()); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("follow creates a pending request", func(t *testing.T) {
+		w := do(requester.ID, "POST", "/users/"+owner.ID.String()+"/follow")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var response FollowResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response.Status != models.FollowStatusRequested {
+			t.Errorf("Expected status %q, got %q", models.FollowStatusRequested, response.Status)
+		}
+
+		if following, _ := mockRepo.IsFollowing(requester.ID, owner.ID); following {
+			t.Error("Expected no follow relationship before approval")
+		}
+
+		var outgoing []FollowRequestResponse
+		json.Unmarshal(do(requester.ID, "GET", "/follow-requests/outgoing").Body.Bytes(), &outgoing)
+		if len(outgoing) != 1 || outgoing[0].User.ID != owner.ID {
+			t.Errorf("Expected one outgoing request to owner, got %+v", outgoing)
+		}
+	})
+
+	t.Run("only the target can approve", func(t *testing.T) {
+		incoming := incomingFor(owner.ID)
+		if len(incoming) != 1 || incoming[0].User.ID != requester.ID {
+			t.Fatalf("Expected one incoming request from requester, got %+v", incoming)
+		}
+
+		if w := do(stranger.ID, "POST", "/follow-requests/"+incoming[0].ID.String()+"/approve"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := do(owner.ID, "POST", "/follow-requests/"+incoming[0].ID.String()+"/approve"); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(incomingFor(owner.ID)) != 0 {
+			t.Error("Expected approved request to be removed")
+		}
+	})
+
+	t.Run("approved follower can view posts", func(t *testing.T) {
+		if w := do(requester.ID, "GET", "/posts/"+privatePost.ID.String()); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for GetPost, got %d", http.StatusOK, w.Code)
+		}
+		if w := do(requester.ID, "GET", "/users/"+owner.ID.String()+"/posts"); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for GetUserPosts, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("reject request", func(t *testing.T) {
+		do(stranger.ID, "POST", "/users/"+owner.ID.String()+"/follow")
+		incoming := incomingFor(owner.ID)
+		if len(incoming) != 1 {
+			t.Fatalf("Expected one incoming request, got %d", len(incoming))
+		}
+
+		if w := do(owner.ID, "POST", "/follow-requests/"+incoming[0].ID.String()+"/reject"); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if following, _ := mockRepo.IsFollowing(stranger.ID, owner.ID); following {
+			t.Error("Expected rejected requester not to follow")
+		}
+	})
+
+	t.Run("cancel outgoing request", func(t *testing.T) {
+		do(stranger.ID, "POST", "/users/"+owner.ID.String()+"/follow")
+		incoming := incomingFor(owner.ID)
+		if len(incoming) != 1 {
+			t.Fatalf("Expected one incoming request, got %d", len(incoming))
+		}
+
+		if w := do(owner.ID, "DELETE", "/follow-requests/"+incoming[0].ID.String()); w.Code != http.StatusNotFound {
+			t.Errorf("Expected target cancel to return %d, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := do(stranger.ID, "DELETE", "/follow-requests/"+incoming[0].ID.String()); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(incomingFor(owner.ID)) != 0 {
+			t.Error("Expected cancelled request to be removed")
+		}
+	})
+
+	t.Run("invalid request ID", func(t *testing.T) {
+		if w := do(owner.ID, "POST", "/follow-requests/invalid-uuid/approve"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
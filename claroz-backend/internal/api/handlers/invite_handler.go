@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+type InviteHandler struct {
+	inviteRepo repository.InviteRepositoryInterface
+	userRepo   repository.UserRepositoryInterface
+	config     *config.RegistrationConfig
+}
+
+// CreateInviteRequest represents an invite code creation request
+type CreateInviteRequest struct {
+	MaxUses        int `json:"max_uses" binding:"omitempty,min=1" example:"1"`
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1" example:"168"`
+}
+
+func NewInviteHandler(inviteRepo repository.InviteRepositoryInterface, userRepo repository.UserRepositoryInterface, cfg *config.RegistrationConfig) *InviteHandler {
+	return &InviteHandler{
+		inviteRepo: inviteRepo,
+		userRepo:   userRepo,
+		config:     cfg,
+	}
+}
+
+// CreateInvite godoc
+// @Summary Create an invite code
+// @Description Create a registration invite code. Admins may create multi-use codes without limits;
+// @Description regular users are limited by the server's invite settings.
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CreateInviteRequest false "Invite options"
+// @Success 201 {object} models.InviteCode
+// @Failure 400 {object} object{error=string} "Invalid input"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Not allowed to create invites"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /invites [post]
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	user, err := h.userRepo.GetByID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !user.IsAdmin() && !h.config.AllowUserInvites {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can create invites"})
+		return
+	}
+
+	var req CreateInviteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = h.config.InviteMaxTTLHours
+	}
+
+	if !user.IsAdmin() {
+		if req.MaxUses > h.config.UserInviteMaxUses {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses exceeds the allowed limit"})
+			return
+		}
+		if h.config.InviteMaxTTLHours > 0 && req.ExpiresInHours > h.config.InviteMaxTTLHours {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours exceeds the allowed limit"})
+			return
+		}
+	}
+
+	code, err := utils.GenerateInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate invite code"})
+		return
+	}
+
+	invite := &models.InviteCode{
+		Code:        code,
+		CreatedByID: user.ID,
+		MaxUses:     req.MaxUses,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := h.inviteRepo.Create(invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListInvites godoc
+// @Summary List my invite codes
+// @Description List the invite codes created by the current user
+// @Tags invites
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} models.InviteCode
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /invites [get]
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	invites, err := h.inviteRepo.ListByCreator(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite godoc
+// @Summary Revoke an invite code
+// @Description Revoke an invite code (only by its creator or an admin)
+// @Tags invites
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Invite ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid invite ID"
+// @Failure 403 {object} object{error=string} "Forbidden"
+// @Failure 404 {object} object{error=string} "Invite not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /invites/{id} [delete]
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invite ID"})
+		return
+	}
+
+	invite, err := h.inviteRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+		return
+	}
+
+	if invite.CreatedByID != userID.(uuid.UUID) {
+		user, err := h.userRepo.GetByID(userID.(uuid.UUID))
+		if err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to revoke this invite"})
+			return
+		}
+	}
+
+	if err := h.inviteRepo.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "invite revoked successfully"})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// MockInviteRepository implements InviteRepositoryInterface for testing
+type MockInviteRepository struct {
+	invites map[string]*models.InviteCode
+}
+
+func NewMockInviteRepository() *MockInviteRepository {
+	return &MockInviteRepository{
+		invites: make(map[string]*models.InviteCode),
+	}
+}
+
+func (m *MockInviteRepository) Create(invite *models.InviteCode) error {
+	if invite.ID == uuid.Nil {
+		invite.ID = uuid.New()
+	}
+	invite.Code = models.NormalizeInviteCode(invite.Code)
+	m.invites[invite.Code] = invite
+	return nil
+}
+
+func (m *MockInviteRepository) GetByID(id uuid.UUID) (*models.InviteCode, error) {
+	for _, invite := range m.invites {
+		if invite.ID == id {
+			return invite, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockInviteRepository) GetByCode(code string) (*models.InviteCode, error) {
+	if invite, exists := m.invites[models.NormalizeInviteCode(code)]; exists {
+		return invite, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockInviteRepository) ListByCreator(userID uuid.UUID) ([]*models.InviteCode, error) {
+	var invites []*models.InviteCode
+	for _, invite := range m.invites {
+		if invite.CreatedByID == userID {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, nil
+}
+
+func (m *MockInviteRepository) Delete(id uuid.UUID) error {
+	for code, invite := range m.invites {
+		if invite.ID == id {
+			delete(m.invites, code)
+		}
+	}
+	return nil
+}
+
+func (m *MockInviteRepository) Redeem(code string) (*models.InviteCode, error) {
+	invite, exists := m.invites[models.NormalizeInviteCode(code)]
+	if !exists || invite.Uses >= invite.MaxUses || (invite.ExpiresAt != nil && !time.Now().Before(*invite.ExpiresAt)) {
+		return nil, repository.ErrInviteNotUsable
+	}
+	invite.Uses++
+	return invite, nil
+}
+
+func (m *MockInviteRepository) Release(id uuid.UUID) error {
+	for _, invite := range m.invites {
+		if invite.ID == id && invite.Uses > 0 {
+			invite.Uses--
+		}
+	}
+	return nil
+}
+
+func setupInviteTestRouter(currentUser *models.User) (*gin.Engine, *MockInviteRepository) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	userRepo := NewMockUserRepository()
+	userRepo.Create(currentUser)
+	inviteRepo := NewMockInviteRepository()
+	cfg := config.NewConfig().Registration
+	handler := NewInviteHandler(inviteRepo, userRepo, &cfg)
+
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUser.ID)
+		c.Next()
+	})
+
+	router.POST("/invites", handler.CreateInvite)
+	router.GET("/invites", handler.ListInvites)
+	router.DELETE("/invites/:id", handler.RevokeInvite)
+
+	return router, inviteRepo
+}
+
+func TestInviteHandler_CreateInvite(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "member", Email: "member@example.com", Role: models.RoleUser}
+	admin := &models.User{ID: uuid.New(), Username: "boss", Email: "boss@example.com", Role: models.RoleAdmin}
+
+	tests := []struct {
+		name         string
+		user         *models.User
+		request      CreateInviteRequest
+		expectedCode int
+	}{
+		{
+			name:         "user creates single-use invite",
+			user:         user,
+			request:      CreateInviteRequest{},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "user cannot create multi-use invite",
+			user:         user,
+			request:      CreateInviteRequest{MaxUses: 10},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "user cannot exceed maximum lifetime",
+			user:         user,
+			request:      CreateInviteRequest{ExpiresInHours: 24 * 365},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "admin creates multi-use invite",
+			user:         admin,
+			request:      CreateInviteRequest{MaxUses: 50, ExpiresInHours: 24 * 365},
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := setupInviteTestRouter(tt.user)
+			w := postJSON(router, "/invites", tt.request)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+
+			if w.Code == http.StatusCreated {
+				var invite models.InviteCode
+				if err := json.Unmarshal(w.Body.Bytes(), &invite); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if invite.Code == "" {
+					t.Error("Expected invite code to be set")
+				}
+				if invite.CreatedByID != tt.user.ID {
+					t.Errorf("Expected creator %v, got %v", tt.user.ID, invite.CreatedByID)
+				}
+				if invite.ExpiresAt == nil {
+					t.Error("Expected invite to have an expiry")
+				}
+			}
+		})
+	}
+}
+
+func TestInviteHandler_RevokeInvite(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "member", Email: "member@example.com", Role: models.RoleUser}
+	router, inviteRepo := setupInviteTestRouter(user)
+
+	own := &models.InviteCode{Code: "OWNCODE", CreatedByID: user.ID, MaxUses: 1}
+	other := &models.InviteCode{Code: "OTHERCODE", CreatedByID: uuid.New(), MaxUses: 1}
+	inviteRepo.Create(own)
+	inviteRepo.Create(other)
+
+	t.Run("revoke someone else's invite", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/invites/"+other.ID.String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("revoke own invite", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/invites/"+own.ID.String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if _, err := inviteRepo.GetByCode("OWNCODE"); err == nil {
+			t.Error("Expected invite to be deleted")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// saveMedia stores a post's images in carousel order, pairing each with the
+// alt text at the same index. Every image is validated before any is stored;
+// if one fails to store, those already stored are left unreferenced for the
+// garbage collector. It writes an error response and returns false if the
+// images cannot all be stored.
+func (h *PostHandler) saveMedia(c *gin.Context, files []*multipart.FileHeader, altTexts []string) ([]models.PostMedia, bool) {
+	media := make([]models.PostMedia, len(files))
+	for i, file := range files {
+		altText, ok := validAltText(c, h.media, altTexts, i)
+		if !ok {
+			return nil, false
+		}
+
+		info, err := utils.ReadImageInfo(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("image %d is not a supported image", i+1)})
+			return nil, false
+		}
+
+		media[i] = models.PostMedia{
+			Position: i,
+			Width:    info.Width,
+			Height:   info.Height,
+			MimeType: info.MimeType,
+			AltText:  altText,
+		}
+	}
+
+	for i, file := range files {
+		stored, err := saveImage(c.Request.Context(), h.storage, file)
+		if err != nil {
+			writeImageError(c, fmt.Sprintf("image %d", i+1), err)
+			return nil, false
+		}
+
+		// Processing turns images upright and may change their format
+		media[i].URL = stored.URL
+		media[i].Width = stored.Width
+		media[i].Height = stored.Height
+		media[i].MimeType = stored.MimeType
+		media[i].Variants = stored.Variants
+	}
+	return media, true
+}
+
+// validAltText returns the trimmed alt text for the image at index i. It
+// writes an error response and returns false if the alt text is missing but
+// required, or too long.
+func validAltText(c *gin.Context, cfg *config.MediaConfig, altTexts []string, i int) (string, bool) {
+	var altText string
+	if i < len(altTexts) {
+		altText = altTexts[i]
+	}
+	return checkAltText(c, cfg, altText, fmt.Sprintf("image %d", i+1))
+}
+
+// checkAltText returns the trimmed alt text of a media item, naming the item
+// as name in the error response it writes if the alt text is missing but
+// required, or too long
+func checkAltText(c *gin.Context, cfg *config.MediaConfig, altText, name string) (string, bool) {
+	altText = strings.TrimSpace(altText)
+	if altText == "" && cfg.RequireAltText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s needs alt text", name)})
+		return "", false
+	}
+	if utf8.RuneCountInString(altText) > cfg.MaxAltTextLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("alt text for %s is too long", name)})
+		return "", false
+	}
+	return altText, true
+}
+
+// saveImage stores an uploaded image
+func saveImage(ctx context.Context, storage utils.ImageStorageInterface, file *multipart.FileHeader) (*utils.StoredImage, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+	return storage.SaveImage(ctx, src)
+}
+
+// writeImageError writes the response for an image that could not be stored,
+// naming the image as name
+func writeImageError(c *gin.Context, name string, err error) {
+	switch {
+	case errors.Is(err, imaging.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is not a supported image", name)})
+	case errors.Is(err, imaging.ErrImageTooLarge), errors.Is(err, utils.ErrFileTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is too large", name)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// MediaHandler stores images ahead of the posts and profiles they are
+// attached to, so clients can show upload progress and retry failed uploads
+// without creating a post twice
+type MediaHandler struct {
+	mediaRepo repository.MediaRepositoryInterface
+	storage   utils.ImageStorageInterface
+	media     *config.MediaConfig
+}
+
+func NewMediaHandler(mediaRepo repository.MediaRepositoryInterface, storage utils.ImageStorageInterface, media *config.MediaConfig) *MediaHandler {
+	return &MediaHandler{
+		mediaRepo: mediaRepo,
+		storage:   storage,
+		media:     media,
+	}
+}
+
+// UploadImage godoc
+// @Summary Upload an image
+// @Description Process and store an image, returning pending media to attach to a post with
+// @Description media_id or to the profile with avatar_media_id. Pending media expire, and their
+// @Description files are deleted, unless they are attached by expires_at.
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param image formData file true "Image file"
+// @Success 201 {object} models.Media
+// @Failure 400 {object} object{error=string} "Missing, unsupported or too large image"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/image [post]
+func (h *MediaHandler) UploadImage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+
+	stored, err := saveImage(c.Request.Context(), h.storage, file)
+	if err != nil {
+		writeImageError(c, "image", err)
+		return
+	}
+
+	media := stored.Media(userID.(uuid.UUID), time.Now().Add(time.Duration(h.media.UploadExpiryMins)*time.Minute))
+	if err := h.mediaRepo.CreateMedia(media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, media)
+}
+
+// GetMedia godoc
+// @Summary Get uploaded media
+// @Description Get media the current user uploaded, to check whether it is still pending
+// @Tags media
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Media ID"
+// @Success 200 {object} models.Media
+// @Failure 400 {object} object{error=string} "Invalid media ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Media not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/{id} [get]
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media ID"})
+		return
+	}
+
+	media, err := h.mediaRepo.GetMedia(id, userID.(uuid.UUID))
+	if errors.Is(err, repository.ErrMediaNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, media)
+}
+
+// writeAttachError writes the response for uploaded media that could not be
+// attached
+func writeAttachError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrMediaNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "media not found or expired"})
+	case errors.Is(err, repository.ErrMediaAttached):
+		c.JSON(http.StatusConflict, gin.H{"error": "media already attached"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to attach media"})
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// MockMediaRepository implements MediaRepositoryInterface for testing
+type MockMediaRepository struct {
+	media map[uuid.UUID]*models.Media
+}
+
+func NewMockMediaRepository() *MockMediaRepository {
+	return &MockMediaRepository{media: make(map[uuid.UUID]*models.Media)}
+}
+
+func (m *MockMediaRepository) CreateMedia(media *models.Media) error {
+	if media.ID == uuid.Nil {
+		media.ID = uuid.New()
+	}
+	media.Status = models.MediaPending
+	m.media[media.ID] = media
+	return nil
+}
+
+func (m *MockMediaRepository) GetMedia(id, userID uuid.UUID) (*models.Media, error) {
+	media, exists := m.media[id]
+	if !exists || media.UserID != userID {
+		return nil, repository.ErrMediaNotFound
+	}
+	found := *media
+	return &found, nil
+}
+
+// attach marks a user's pending media as attached, like the repositories do
+func (m *MockMediaRepository) attach(userID uuid.UUID, ids []uuid.UUID) ([]models.Media, error) {
+	media := make([]models.Media, len(ids))
+	seen := map[uuid.UUID]bool{}
+	for i, id := range ids {
+		item, exists := m.media[id]
+		if !exists || item.UserID != userID || (item.Status == models.MediaPending && item.ExpiresAt.Before(time.Now())) {
+			return nil, repository.ErrMediaNotFound
+		}
+		if item.Status != models.MediaPending || seen[id] {
+			return nil, repository.ErrMediaAttached
+		}
+		seen[id] = true
+		media[i] = *item
+	}
+	now := time.Now()
+	for _, id := range ids {
+		m.media[id].Status, m.media[id].AttachedAt = models.MediaAttached, &now
+	}
+	return media, nil
+}
+
+// setupMediaTestRouter serves uploads and posts for a user, sharing uploaded
+// media between them
+func setupMediaTestRouter(userID uuid.UUID) (*gin.Engine, *MockMediaRepository, *MockImageStorage) {
+	gin.SetMode(gin.TestMode)
+	mediaRepo := NewMockMediaRepository()
+	postRepo := NewMockPostRepository()
+	postRepo.media = mediaRepo
+	storage := NewMockImageStorage()
+	mediaHandler := NewMediaHandler(mediaRepo, storage, testMedia)
+	postHandler := NewPostHandler(postRepo, storage, postRepo, testCursors, testMedia, testReactions)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	})
+	router.POST("/upload/image", mediaHandler.UploadImage)
+	router.GET("/upload/:id", mediaHandler.GetMedia)
+	router.POST("/posts", postHandler.CreatePost)
+	return router, mediaRepo, storage
+}
+
+// uploadTestImage uploads a 2x1 PNG
+func uploadTestImage(t *testing.T, router *gin.Engine) *httptest.ResponseRecorder {
+	t.Helper()
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writeTestImage(t, writer, "photo.png", 2, 1)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload/image", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMediaHandler_UploadImage(t *testing.T) {
+	userID := uuid.New()
+	router, mediaRepo, storage := setupMediaTestRouter(userID)
+
+	t.Run("upload is pending", func(t *testing.T) {
+		w := uploadTestImage(t, router)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var media models.Media
+		if err := json.Unmarshal(w.Body.Bytes(), &media); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if media.ID == uuid.Nil || media.Status != models.MediaPending || media.URL == "" || len(media.Variants) != 3 {
+			t.Errorf("Expected pending media with its variants, got %+v", media)
+		}
+		if expiry := time.Until(media.ExpiresAt); expiry < 23*time.Hour || expiry > 25*time.Hour {
+			t.Errorf("Expected the media to expire in a day, got %v", expiry)
+		}
+		if stored, exists := mediaRepo.media[media.ID]; !exists || stored.UserID != userID {
+			t.Errorf("Expected the media to be recorded for the uploader, got %+v", stored)
+		}
+
+		req := httptest.NewRequest("GET", "/upload/"+media.ID.String(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("invalid uploads", func(t *testing.T) {
+		count := len(mediaRepo.media)
+		storage.rejectAt = storage.attempts + 1
+		if w := uploadTestImage(t, router); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		storage.failAt = storage.attempts + 1
+		defer func() { storage.rejectAt, storage.failAt = 0, 0 }()
+		if w := uploadTestImage(t, router); w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+
+		req := httptest.NewRequest("POST", "/upload/image", strings.NewReader(""))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if len(mediaRepo.media) != count {
+			t.Errorf("Expected no media to be recorded, got %d", len(mediaRepo.media)-count)
+		}
+	})
+
+	t.Run("other users' media are not found", func(t *testing.T) {
+		other := &models.Media{UserID: uuid.New(), URL: "/uploads/other.jpg", ExpiresAt: time.Now().Add(time.Hour)}
+		mediaRepo.CreateMedia(other)
+		for _, id := range []string{other.ID.String(), uuid.New().String()} {
+			req := httptest.NewRequest("GET", "/upload/"+id, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+			}
+		}
+	})
+}
+
+func TestPostHandler_CreatePostWithMedia(t *testing.T) {
+	userID := uuid.New()
+	router, mediaRepo, storage := setupMediaTestRouter(userID)
+
+	upload := func(t *testing.T) uuid.UUID {
+		t.Helper()
+		w := uploadTestImage(t, router)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+		}
+		var media models.Media
+		json.Unmarshal(w.Body.Bytes(), &media)
+		return media.ID
+	}
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/posts", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("uploaded media are attached in order", func(t *testing.T) {
+		first, second := upload(t), upload(t)
+		saved := storage.saved
+		form := url.Values{"media_id": {second.String(), first.String()}, "alt_text": {"A dog"}, "caption": {"Two-phase"}}
+		w := post(form)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var created models.Post
+		json.Unmarshal(w.Body.Bytes(), &created)
+		if len(created.Media) != 2 || created.Media[0].URL != mediaRepo.media[second].URL || created.ImageURL != created.Media[0].URL {
+			t.Fatalf("Expected the media in the order sent, got %+v", created.Media)
+		}
+		if created.Media[0].AltText != "A dog" || created.Media[1].Position != 1 || len(created.Media[1].Variants) != 3 {
+			t.Errorf("Expected the media with their alt text and variants, got %+v", created.Media)
+		}
+		if mediaRepo.media[first].Status != models.MediaAttached || storage.saved != saved {
+			t.Errorf("Expected the uploaded media to be attached without storing images again")
+		}
+
+		// Retrying the request does not create the post again
+		if w := post(form); w.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("invalid media", func(t *testing.T) {
+		expired := &models.Media{UserID: userID, URL: "/uploads/expired.jpg", ExpiresAt: time.Now().Add(-time.Minute)}
+		others := &models.Media{UserID: uuid.New(), URL: "/uploads/other.jpg", ExpiresAt: time.Now().Add(time.Hour)}
+		mediaRepo.CreateMedia(expired)
+		mediaRepo.CreateMedia(others)
+		pending := upload(t)
+
+		tests := []struct {
+			name string
+			ids  []string
+			want int
+		}{
+			{"malformed ID", []string{"not-a-uuid"}, http.StatusBadRequest},
+			{"unknown media", []string{uuid.New().String()}, http.StatusBadRequest},
+			{"expired media", []string{expired.ID.String()}, http.StatusBadRequest},
+			{"another user's media", []string{others.ID.String()}, http.StatusBadRequest},
+			{"same media twice", []string{pending.String(), pending.String()}, http.StatusConflict},
+			{"too many media", []string{pending.String(), pending.String(), pending.String(), pending.String()}, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if w := post(url.Values{"media_id": tt.ids}); w.Code != tt.want {
+					t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+				}
+			})
+		}
+		if mediaRepo.media[pending].Status != models.MediaPending {
+			t.Error("Expected the media to stay pending after failed attempts")
+		}
+	})
+
+	t.Run("images and media IDs cannot be combined", func(t *testing.T) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writeTestImage(t, writer, "photo.png", 2, 1)
+		writer.WriteField("media_id", upload(t).String())
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/posts", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
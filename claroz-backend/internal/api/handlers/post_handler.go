@@ -1,24 +1,49 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/timeline"
 	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
 )
 
+// recentCommentsPreview is how many of each post's latest comments are included in post views
+const recentCommentsPreview = 3
+
 type PostHandler struct {
-	postRepo repository.PostRepositoryInterface
-	storage  utils.FileStorageInterface
+	postRepo  repository.PostRepositoryInterface
+	storage   utils.ImageStorageInterface
+	timeline  timeline.ServiceInterface
+	cursors   *utils.CursorCodec
+	media     *config.MediaConfig
+	reactions []string // reactions users may add, in display order
 }
 
 // CommentRequest represents a comment creation request
 type CommentRequest struct {
-	Content string `json:"content" binding:"required" example:"Great post!"`
+	Content  string     `json:"content" binding:"required" example:"Great post!"`
+	ParentID *uuid.UUID `json:"parent_id" example:"550e8400-e29b-41d4-a716-446655440000"` // the comment to reply to, if any
+}
+
+// UpdatePostRequest represents a post edit
+type UpdatePostRequest struct {
+	Caption  *string `json:"caption" binding:"required" example:"Sunset at the beach #travel #summer"`
+	Language string  `json:"language" example:"english"` // empty keeps the current language
+}
+
+// FollowResponse represents the result of a follow request
+type FollowResponse struct {
+	Status  string `json:"status" example:"following" enums:"following,requested"`
+	Message string `json:"message" example:"user followed successfully"`
 }
 
 // MessageResponse represents a simple message response
@@ -26,25 +51,44 @@ type MessageResponse struct {
 	Message string `json:"message" example:"Operation completed successfully"`
 }
 
-func NewPostHandler(postRepo repository.PostRepositoryInterface, storage utils.FileStorageInterface) *PostHandler {
+func NewPostHandler(postRepo repository.PostRepositoryInterface, storage utils.ImageStorageInterface, timeline timeline.ServiceInterface, cursors *utils.CursorCodec, media *config.MediaConfig, reactions *config.ReactionsConfig) *PostHandler {
+	// Likes are heart reactions, so hearts are allowed whatever the configuration
+	allowed := []string{models.HeartReaction}
+	for _, emoji := range reactions.Emoji {
+		if emoji != models.HeartReaction {
+			allowed = append(allowed, emoji)
+		}
+	}
 	return &PostHandler{
-		postRepo: postRepo,
-		storage:  storage,
+		postRepo:  postRepo,
+		storage:   storage,
+		timeline:  timeline,
+		cursors:   cursors,
+		media:     media,
+		reactions: allowed,
 	}
 }
 
 // CreatePost godoc
 // @Summary Create a new post
-// @Description Create a new post with an image and caption
+// @Description Create a new post with a carousel of images and a caption. Images are either sent
+// @Description with the post or uploaded beforehand and referenced by media_id, not both. Images
+// @Description are shown in the order they are sent, and the nth alt_text describes the nth image.
+// @Description If any image is invalid, no image is stored. Uploaded media can be attached once,
+// @Description so retrying a request that succeeded does not create the post again.
 // @Tags posts
 // @Accept multipart/form-data
 // @Produce json
 // @Security Bearer
-// @Param image formData file true "Image file"
-// @Param caption formData string false "Post caption"
+// @Param image formData []file false "Image files, in carousel order (max: 10 by default)" collectionFormat(multi)
+// @Param media_id formData []string false "IDs of uploaded media, in carousel order, instead of image files" collectionFormat(multi)
+// @Param alt_text formData []string false "Alt text for each image, in the same order; may be required by configuration" collectionFormat(multi)
+// @Param caption formData string false "Post caption; #hashtags are indexed for hashtag feeds"
+// @Param language formData string false "Caption language used for search, e.g. english (default: simple)"
 // @Success 201 {object} models.Post
-// @Failure 400 {object} object{error=string} "Invalid input"
+// @Failure 400 {object} object{error=string} "Invalid input, or uploaded media not found or expired"
 // @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 409 {object} object{error=string} "Uploaded media already attached"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /posts [post]
 func (h *PostHandler) CreatePost(c *gin.Context) {
@@ -54,26 +98,75 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	if err != nil {
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["image"]
+	}
+	mediaIDs := c.PostFormArray("media_id")
+	if len(files) == 0 && len(mediaIDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
 		return
 	}
+	if len(files) > 0 && len(mediaIDs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "images and media IDs cannot be combined"})
+		return
+	}
+	count := len(files) + len(mediaIDs)
+	if count > h.media.MaxItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a post can have at most %d images", h.media.MaxItems)})
+		return
+	}
+	altTexts := c.PostFormArray("alt_text")
+	if len(altTexts) > count {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "more alt texts than images"})
+		return
+	}
 
-	imageURL, err := h.storage.SaveFile(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
+	language, ok := postLanguage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported caption language"})
 		return
 	}
 
 	post := &models.Post{
 		UserID:   userID.(uuid.UUID),
 		Caption:  c.PostForm("caption"),
-		ImageURL: imageURL,
+		Language: language,
 	}
 
+	if len(mediaIDs) > 0 {
+		ids := make([]uuid.UUID, len(mediaIDs))
+		post.Media = make([]models.PostMedia, len(mediaIDs))
+		for i, value := range mediaIDs {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media ID"})
+				return
+			}
+			altText, ok := validAltText(c, h.media, altTexts, i)
+			if !ok {
+				return
+			}
+			ids[i] = id
+			post.Media[i] = models.PostMedia{Position: i, AltText: altText}
+		}
+
+		if err := h.postRepo.CreatePostWithMedia(post, ids); err != nil {
+			writeAttachError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, post)
+		return
+	}
+
+	media, ok := h.saveMedia(c, files, altTexts)
+	if !ok {
+		return
+	}
+	post.ImageURL = media[0].URL
+	post.Media = media
+
 	if err := h.postRepo.CreatePost(post); err != nil {
-		_ = h.storage.DeleteFile(imageURL)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create post"})
 		return
 	}
@@ -83,60 +176,159 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 
 // GetPost godoc
 // @Summary Get a post by ID
-// @Description Retrieve a single post by its ID
+// @Description Retrieve a single post by its ID with like and comment counts and its latest
+// @Description comments. Video posts that are processing or failed are only found by their author.
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Param id path string true "Post ID"
-// @Success 200 {object} models.Post
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Success 200 {object} models.PostView
 // @Failure 400 {object} object{error=string} "Invalid post ID"
 // @Failure 404 {object} object{error=string} "Post not found"
 // @Router /posts/{id} [get]
 func (h *PostHandler) GetPost(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	post, ok := h.viewablePost(c)
+	if !ok {
 		return
 	}
 
-	post, err := h.postRepo.GetPostByID(id)
-	if err != nil || post == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+	viewerID, _ := c.Get("userID")
+	views, err := h.postViews(c, viewerID.(uuid.UUID), []models.Post{*post})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch post"})
 		return
 	}
 
-	c.JSON(http.StatusOK, post)
+	c.JSON(http.StatusOK, views[0])
 }
 
 // GetPosts godoc
 // @Summary Get posts with pagination
-// @Description Retrieve a list of posts with pagination support
+// @Description Retrieve posts newest first with cursor pagination. Posts by blocked and muted
+// @Description users are excluded. The list envelope is returned when cursor or limit is
+// @Description passed. Otherwise, and with the deprecated page and pageSize parameters, a bare
+// @Description array of posts is returned, with a Deprecation header, for one release.
 // @Tags posts
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number (default: 1)" minimum(1)
-// @Param pageSize query int false "Page size (default: 10, max: 50)" minimum(1) maximum(50)
-// @Success 200 {array} models.Post
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 50)" minimum(1) maximum(50)
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Param page query int false "Deprecated: page number" minimum(1)
+// @Param pageSize query int false "Deprecated: page size (default: 10, max: 50)" minimum(1) maximum(50)
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} object{error=string} "Invalid cursor"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /posts [get]
 func (h *PostHandler) GetPosts(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	viewerID, _ := c.Get("userID")
+
+	if offset, pageSize, ok := legacyPage(c, true); ok {
+		posts, err := h.postRepo.GetPostsByOffset(viewerID.(uuid.UUID), offset, pageSize)
+		h.writeLegacyPosts(c, viewerID.(uuid.UUID), posts, err, "failed to fetch posts")
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxPostPageLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postRepo.GetPosts(viewerID.(uuid.UUID), cursor, limit)
+	h.writePostPage(c, viewerID.(uuid.UUID), posts, err, limit, "failed to fetch posts")
+}
+
+// UpdatePost godoc
+// @Summary Edit a post
+// @Description Replace a post's caption and optionally its language (only by post owner).
+// @Description The previous version is kept in the post's revisions and hashtags are reindexed.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param post body UpdatePostRequest true "New caption and language"
+// @Success 200 {object} models.PostView
+// @Failure 400 {object} object{error=string} "Invalid input"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Not the post owner"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id} [put]
+func (h *PostHandler) UpdatePost(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
 
-	if page < 1 {
-		page = 1
+	post, err := h.postRepo.GetPostByID(postID)
+	if err != nil || post == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
 	}
-	if pageSize < 1 || pageSize > 50 {
-		pageSize = 10
+	if post.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot edit this post"})
+		return
+	}
+
+	var input UpdatePostRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	language := post.Language
+	if input.Language != "" {
+		if !utils.IsSearchLanguage(input.Language) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported caption language"})
+			return
+		}
+		language = input.Language
+	}
+
+	updated, err := h.postRepo.UpdatePost(postID, *input.Caption, language)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update post"})
+		return
+	}
+
+	views, err := h.postViews(c, userID.(uuid.UUID), []models.Post{*updated})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, views[0])
+}
+
+// GetPostRevisions godoc
+// @Summary Get a post's edit history
+// @Description List the versions of a post that edits replaced, newest first
+// @Tags posts
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Success 200 {array} models.PostRevision
+// @Failure 400 {object} object{error=string} "Invalid post ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/revisions [get]
+func (h *PostHandler) GetPostRevisions(c *gin.Context) {
+	postID, ok := h.viewablePostID(c)
+	if !ok {
+		return
 	}
 
-	posts, err := h.postRepo.GetPosts(page, pageSize)
+	revisions, err := h.postRepo.GetPostRevisions(postID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch posts"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch revisions"})
 		return
 	}
 
-	c.JSON(http.StatusOK, posts)
+	c.JSON(http.StatusOK, revisions)
 }
 
 // DeletePost godoc
@@ -161,8 +353,7 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 		return
 	}
 
-	post, err := h.postRepo.GetPostByID(postID)
-	if err != nil {
+	if _, err := h.postRepo.GetPostByID(postID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
@@ -172,30 +363,30 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 		return
 	}
 
-	_ = h.storage.DeleteFile(post.ImageURL)
-
 	c.JSON(http.StatusOK, MessageResponse{Message: "post deleted successfully"})
 }
 
 // AddComment godoc
 // @Summary Add a comment to a post
-// @Description Add a new comment to a specific post
+// @Description Add a new comment to a specific post, or a reply to one of its comments.
+// @Description Replies can be nested up to three levels below a top-level comment.
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Param id path string true "Post ID"
-// @Param comment body CommentRequest true "Comment content"
-// @Success 201 {object} models.Comment
-// @Failure 400 {object} object{error=string} "Invalid input"
+// @Param comment body CommentRequest true "Comment content and the comment it replies to"
+// @Success 201 {object} models.CommentView
+// @Failure 400 {object} object{error=string} "Invalid input or reply nested too deeply"
 // @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Blocked by or blocking the post's author"
+// @Failure 404 {object} object{error=string} "Post or parent comment not found"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /posts/{id}/comments [post]
 func (h *PostHandler) AddComment(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	postID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	postID, ok := h.viewablePostID(c)
+	if !ok {
 		return
 	}
 
@@ -206,22 +397,81 @@ func (h *PostHandler) AddComment(c *gin.Context) {
 	}
 
 	comment := &models.Comment{
-		PostID:  postID,
-		UserID:  userID.(uuid.UUID),
-		Content: input.Content,
+		PostID:   postID,
+		UserID:   userID.(uuid.UUID),
+		ParentID: input.ParentID,
+		Content:  input.Content,
 	}
 
 	if err := h.postRepo.AddComment(comment); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add comment"})
+		switch {
+		case errors.Is(err, repository.ErrBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot comment on this post"})
+		case errors.Is(err, repository.ErrPostNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		case errors.Is(err, repository.ErrCommentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		case errors.Is(err, repository.ErrCommentTooDeep):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "replies cannot be nested this deeply"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add comment"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment.View())
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Delete a comment on a post. The comment's author and the post's author can
+// @Description delete it. A comment with replies stays in its thread without its author and content.
+// @Tags posts
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid post or comment ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Not the comment's or the post's author"
+// @Failure 404 {object} object{error=string} "Comment not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments/{commentId} [delete]
+func (h *PostHandler) DeleteComment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	comment, ok := h.postComment(c)
+	if !ok {
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return
+	}
+
+	if comment.UserID != userID.(uuid.UUID) {
+		post, err := h.postRepo.GetPostByID(comment.PostID)
+		if err != nil || post == nil || post.UserID != userID.(uuid.UUID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot delete this comment"})
+			return
+		}
+	}
+
+	if err := h.postRepo.DeleteComment(comment.ID); err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete comment"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, comment)
+	c.JSON(http.StatusOK, MessageResponse{Message: "comment deleted successfully"})
 }
 
 // LikePost godoc
 // @Summary Like a post
-// @Description Add a like to a specific post
+// @Description Add a like to a specific post. Likes are heart reactions.
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -230,13 +480,14 @@ func (h *PostHandler) AddComment(c *gin.Context) {
 // @Success 200 {object} MessageResponse
 // @Failure 400 {object} object{error=string} "Invalid post ID or already liked"
 // @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Blocked by or blocking the post's author"
+// @Failure 404 {object} object{error=string} "Post not found"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /posts/{id}/like [post]
 func (h *PostHandler) LikePost(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	postID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	postID, ok := h.viewablePostID(c)
+	if !ok {
 		return
 	}
 
@@ -251,13 +502,15 @@ func (h *PostHandler) LikePost(c *gin.Context) {
 		return
 	}
 
-	like := &models.Like{
-		PostID: postID,
-		UserID: userID.(uuid.UUID),
-	}
-
-	if err := h.postRepo.LikePost(like); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to like post"})
+	if err := h.postRepo.LikePost(postID, userID.(uuid.UUID)); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot like this post"})
+		case errors.Is(err, repository.ErrPostNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to like post"})
+		}
 		return
 	}
 
@@ -266,7 +519,7 @@ func (h *PostHandler) LikePost(c *gin.Context) {
 
 // UnlikePost godoc
 // @Summary Unlike a post
-// @Description Remove a like from a specific post
+// @Description Remove a like, the heart reaction, from a specific post
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -295,15 +548,17 @@ func (h *PostHandler) UnlikePost(c *gin.Context) {
 
 // FollowUser godoc
 // @Summary Follow a user
-// @Description Follow another user
+// @Description Follow another user. Following a private account sends a follow request instead.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Param id path string true "User ID to follow"
-// @Success 200 {object} MessageResponse
+// @Success 200 {object} FollowResponse
 // @Failure 400 {object} object{error=string} "Invalid user ID or cannot follow yourself"
 // @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Blocked by or blocking the user"
+// @Failure 404 {object} object{error=string} "User not found"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /users/{id}/follow [post]
 func (h *PostHandler) FollowUser(c *gin.Context) {
@@ -319,17 +574,31 @@ func (h *PostHandler) FollowUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.postRepo.FollowUser(followerID.(uuid.UUID), followingID); err != nil {
+	status, err := h.postRepo.FollowUser(followerID.(uuid.UUID), followingID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrBlocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot follow this user"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to follow user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, MessageResponse{Message: "user followed successfully"})
+	if status == models.FollowStatusRequested {
+		c.JSON(http.StatusOK, FollowResponse{Status: status, Message: "follow request sent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, FollowResponse{Status: status, Message: "user followed successfully"})
 }
 
 // UnfollowUser godoc
 // @Summary Unfollow a user
-// @Description Unfollow a previously followed user
+// @Description Unfollow a previously followed user, or cancel a pending follow request
 // @Tags users
 // @Accept json
 // @Produce json
@@ -358,27 +627,485 @@ func (h *PostHandler) UnfollowUser(c *gin.Context) {
 
 // GetUserPosts godoc
 // @Summary Get user's posts
-// @Description Retrieve all posts for a specific user
+// @Description Retrieve a user's posts, newest first, with cursor pagination. Posts of private
+// @Description accounts are only visible to the owner and approved followers.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param userId path string true "User ID"
-// @Success 200 {array} models.Post
-// @Failure 400 {object} object{error=string} "Invalid user ID"
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 50)" minimum(1) maximum(50)
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID or cursor"
+// @Failure 403 {object} object{error=string} "Account is private"
+// @Failure 404 {object} object{error=string} "User not found"
 // @Failure 500 {object} object{error=string} "Server error"
-// @Router /users/{userId}/posts [get]
+// @Router /users/{id}/posts [get]
 func (h *PostHandler) GetUserPosts(c *gin.Context) {
-	userID, err := uuid.Parse(c.Param("userId"))
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	posts, err := h.postRepo.GetUserPosts(userID)
+	cursor, limit, ok := cursorPage(c, h.cursors, maxPostPageLimit)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeProfileView(c, userID) {
+		return
+	}
+
+	viewerID, _ := c.Get("userID")
+	posts, err := h.postRepo.GetUserPosts(userID, cursor, limit)
+	h.writePostPage(c, viewerID.(uuid.UUID), posts, err, limit, "failed to fetch user posts")
+}
+
+// GetFollowers godoc
+// @Summary Get a user's followers
+// @Description List the users following a user, newest first, with cursor pagination
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID or cursor"
+// @Failure 403 {object} object{error=string} "Account is private"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/followers [get]
+func (h *PostHandler) GetFollowers(c *gin.Context) {
+	h.listFollows(c, h.postRepo.GetFollowers)
+}
+
+// GetFollowing godoc
+// @Summary Get the users a user follows
+// @Description List the users a user is following, newest first, with cursor pagination
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} object{error=string} "Invalid user ID or cursor"
+// @Failure 403 {object} object{error=string} "Account is private"
+// @Failure 404 {object} object{error=string} "User not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/{id}/following [get]
+func (h *PostHandler) GetFollowing(c *gin.Context) {
+	h.listFollows(c, h.postRepo.GetFollowing)
+}
+
+type followLister func(userID, viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.UserSummary, error)
+
+func (h *PostHandler) listFollows(c *gin.Context, list followLister) {
+	viewerID, _ := c.Get("userID")
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user posts"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, posts)
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeProfileView(c, userID) {
+		return
+	}
+
+	users, err := list(userID, viewerID.(uuid.UUID), cursor, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserListResponse{
+		Items: users,
+		NextCursor: nextCursor(h.cursors, len(users), limit, func() repository.Cursor {
+			last := users[len(users)-1]
+			return repository.Cursor{CreatedAt: *last.FollowedAt, ID: last.ID}
+		}),
+	})
+}
+
+// GetComments godoc
+// @Summary Get a post's comments
+// @Description List a post's top-level comments, oldest first, with cursor pagination. Each
+// @Description comment has its reply count; replies are listed separately. Comments by
+// @Description blocked users are excluded.
+// @Tags posts
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} CommentListResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments [get]
+func (h *PostHandler) GetComments(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+	postID, ok := h.viewablePostID(c)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	comments, err := h.postRepo.GetComments(postID, viewerID.(uuid.UUID), cursor, limit)
+	var page CommentListResponse
+	if err == nil {
+		page, err = h.commentPage(viewerID.(uuid.UUID), comments, limit)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetCommentReplies godoc
+// @Summary Get a comment's replies
+// @Description List the direct replies to a comment, oldest first, with cursor pagination.
+// @Description Replies by blocked users are excluded.
+// @Tags posts
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} CommentListResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID, comment ID or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post or comment not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments/{commentId}/replies [get]
+func (h *PostHandler) GetCommentReplies(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+	if _, ok := h.viewablePostID(c); !ok {
+		return
+	}
+	comment, ok := h.postComment(c)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	replies, err := h.postRepo.GetReplies(comment.ID, viewerID.(uuid.UUID), cursor, limit)
+	var page CommentListResponse
+	if err == nil {
+		page, err = h.commentPage(viewerID.(uuid.UUID), replies, limit)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch replies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetLikes godoc
+// @Summary Get a post's likes
+// @Description List the users who liked a post, newest first, with cursor pagination. Likes are
+// @Description heart reactions; likes by blocked users are excluded.
+// @Tags posts
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} ReactionListResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/likes [get]
+func (h *PostHandler) GetLikes(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+	postID, ok := h.viewablePostID(c)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	likes, err := h.postRepo.GetReactions(models.PostTarget(postID), viewerID.(uuid.UUID), models.HeartReaction, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch likes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.reactionPage(likes, limit))
+}
+
+// viewablePostID parses the post ID path parameter, writing an error
+// response and returning false if the post does not exist or the current
+// user may not see it
+func (h *PostHandler) viewablePostID(c *gin.Context) (uuid.UUID, bool) {
+	post, ok := h.viewablePost(c)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return post.ID, true
+}
+
+// viewablePost loads the post named by the post ID path parameter, writing
+// an error response and returning false if it does not exist or the current
+// user may not see it
+func (h *PostHandler) viewablePost(c *gin.Context) (*models.Post, bool) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return nil, false
+	}
+
+	post, err := h.postRepo.GetPostByID(postID)
+	if err != nil || post == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return nil, false
+	}
+
+	// Videos that are not ready are only shown to their author, who polls their status
+	viewerID, _ := c.Get("userID")
+	if (post.Status == models.PostProcessing || post.Status == models.PostFailed) && post.UserID != viewerID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return nil, false
+	}
+
+	// Posts of private accounts and of blocked users are hidden as if missing
+	canView, err := h.postRepo.CanViewPosts(viewerID.(uuid.UUID), post.UserID)
+	if err != nil || !canView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return nil, false
+	}
+	return post, true
+}
+
+// postComment loads the comment named by the commentId parameter, writing an
+// error response if the ID is invalid or the comment is not on the :id post
+func (h *PostHandler) postComment(c *gin.Context) (*models.Comment, bool) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return nil, false
+	}
+	commentID, err := uuid.Parse(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return nil, false
+	}
+
+	comment, err := h.postRepo.GetComment(commentID)
+	if err != nil || comment == nil || comment.PostID != postID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// commentPage returns a page of comments, with their reactions for the
+// viewer, in the list envelope with a cursor for the next page if the page is full
+func (h *PostHandler) commentPage(viewerID uuid.UUID, comments []models.Comment, limit int) (CommentListResponse, error) {
+	views := commentViews(comments)
+	if err := h.addCommentReactions(viewerID, views); err != nil {
+		return CommentListResponse{}, err
+	}
+	return CommentListResponse{
+		Items: views,
+		NextCursor: nextCursor(h.cursors, len(comments), limit, func() repository.Cursor {
+			last := comments[len(comments)-1]
+			return repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}),
+	}, nil
+}
+
+// writePostPage writes a page of posts in the list envelope, with a cursor
+// for the next page if the page is full
+func (h *PostHandler) writePostPage(c *gin.Context, viewerID uuid.UUID, posts []models.Post, err error, limit int, failure string) {
+	var views []models.PostView
+	if err == nil {
+		views, err = h.postViews(c, viewerID, posts)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+
+	c.JSON(http.StatusOK, PostListResponse{
+		Items: views,
+		NextCursor: nextCursor(h.cursors, len(posts), limit, func() repository.Cursor {
+			last := posts[len(posts)-1]
+			return repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID, Rank: last.SearchRank}
+		}),
+	})
+}
+
+// writeLegacyPosts writes a page of posts fetched with the deprecated page
+// parameters as a bare array
+func (h *PostHandler) writeLegacyPosts(c *gin.Context, viewerID uuid.UUID, posts []models.Post, err error, failure string) {
+	var views []models.PostView
+	if err == nil {
+		views, err = h.postViews(c, viewerID, posts)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+
+	c.JSON(http.StatusOK, views)
+}
+
+// postViews builds the list representation of posts for the viewer. The
+// expand query parameter, a comma-separated list of comments and likes,
+// includes those relations in full; other values are ignored.
+func (h *PostHandler) postViews(c *gin.Context, viewerID uuid.UUID, posts []models.Post) ([]models.PostView, error) {
+	ids := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	stats, err := h.postRepo.GetPostStats(viewerID, ids, recentCommentsPreview)
+	if err != nil {
+		return nil, err
+	}
+
+	var expandComments, expandLikes bool
+	for _, relation := range strings.Split(c.Query("expand"), ",") {
+		switch strings.TrimSpace(relation) {
+		case "comments":
+			expandComments = true
+		case "likes":
+			expandLikes = true
+		}
+	}
+	var comments map[uuid.UUID][]models.Comment
+	var likes map[uuid.UUID][]models.Reaction
+	if expandComments || expandLikes {
+		if comments, likes, err = h.postRepo.GetPostInteractions(viewerID, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	views := make([]models.PostView, len(posts))
+	var commentLists [][]models.CommentView
+	for i, post := range posts {
+		view := post.View()
+		if s := stats[post.ID]; s != nil {
+			view.LikeCount = s.LikeCount
+			view.CommentCount = s.CommentCount
+			view.ViewerHasLiked = s.ViewerHasLiked
+			view.Reactions = s.Reactions
+			view.ViewerReactions = s.ViewerReactions
+			view.RecentComments = commentViews(s.RecentComments)
+		}
+		if expandComments {
+			view.Comments = commentViews(comments[post.ID])
+		}
+		if expandLikes {
+			view.Likes = make([]models.ReactionView, len(likes[post.ID]))
+			for j, like := range likes[post.ID] {
+				view.Likes[j] = like.View()
+			}
+		}
+		views[i] = view
+		commentLists = append(commentLists, view.RecentComments, view.Comments)
+	}
+	if err := h.addCommentReactions(viewerID, commentLists...); err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// commentViews returns the list representation of comments without their
+// reactions, which are filled in with addCommentReactions
+func commentViews(comments []models.Comment) []models.CommentView {
+	views := make([]models.CommentView, len(comments))
+	for i, comment := range comments {
+		views[i] = comment.View()
+	}
+	return views
+}
+
+// addCommentReactions fills in the reactions to each listed comment, and
+// the viewer's, with a single lookup
+func (h *PostHandler) addCommentReactions(viewerID uuid.UUID, lists ...[]models.CommentView) error {
+	var ids []uuid.UUID
+	for _, views := range lists {
+		for _, view := range views {
+			ids = append(ids, view.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	summaries, err := h.postRepo.GetCommentReactions(viewerID, ids)
+	if err != nil {
+		return err
+	}
+	for _, views := range lists {
+		for i := range views {
+			if s := summaries[views[i].ID]; s != nil {
+				views[i].Reactions = s.Reactions
+				views[i].ViewerReactions = s.ViewerReactions
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeProfileView writes an error response and returns false if the
+// current user may not see the profile's posts and connections. Profiles
+// are reported as not found when either user has blocked the other.
+func (h *PostHandler) authorizeProfileView(c *gin.Context, userID uuid.UUID) bool {
+	viewerID, _ := c.Get("userID")
+	blocked, err := h.postRepo.IsBlocked(viewerID.(uuid.UUID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check account visibility"})
+		return false
+	}
+	if blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return false
+	}
+
+	canView, err := h.postRepo.CanViewPosts(viewerID.(uuid.UUID), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check account visibility"})
+		return false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return false
+	}
+	return true
 }
@@ -2,34 +2,75 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"gorm.io/gorm"
 )
 
+// testCursors signs pagination cursors in handler tests
+var testCursors = utils.NewCursorCodec("test-cursor-secret")
+
+// testMedia limits carousels in handler tests
+var testMedia = &config.MediaConfig{MaxItems: 3, MaxAltTextLength: 20, UploadExpiryMins: 24 * 60}
+
+// testReactions is the reaction set handler tests allow, in addition to hearts
+var testReactions = &config.ReactionsConfig{Emoji: []string{"laugh", "clap"}}
+
 // MockPostRepository implements necessary methods for testing
 type MockPostRepository struct {
-	posts    map[uuid.UUID]*models.Post
-	likes    map[uuid.UUID]map[uuid.UUID]bool // postID -> userID -> liked
-	follows  map[uuid.UUID]map[uuid.UUID]bool // followerID -> followingID -> following
-	comments map[uuid.UUID][]*models.Comment  // postID -> comments
+	posts      map[uuid.UUID]*models.Post
+	reactions  []*models.Reaction
+	follows    map[uuid.UUID]map[uuid.UUID]time.Time // followerID -> followingID -> followed at
+	comments   map[uuid.UUID][]*models.Comment       // postID -> comments
+	users      map[uuid.UUID]*models.User
+	requests   map[uuid.UUID]*models.FollowRequest
+	blocks     map[uuid.UUID]map[uuid.UUID]time.Time        // blockerID -> blockedID -> blocked at
+	mutes      map[uuid.UUID]map[uuid.UUID]*models.UserMute // muterID -> mutedID -> mute
+	revisions  map[uuid.UUID][]models.PostRevision          // postID -> revisions, oldest first
+	media      *MockMediaRepository                         // uploads that can be attached to posts
+	followTime time.Time
 }
 
 func NewMockPostRepository() *MockPostRepository {
 	return &MockPostRepository{
-		posts:    make(map[uuid.UUID]*models.Post),
-		likes:    make(map[uuid.UUID]map[uuid.UUID]bool),
-		follows:  make(map[uuid.UUID]map[uuid.UUID]bool),
-		comments: make(map[uuid.UUID][]*models.Comment),
+		posts:      make(map[uuid.UUID]*models.Post),
+		follows:    make(map[uuid.UUID]map[uuid.UUID]time.Time),
+		comments:   make(map[uuid.UUID][]*models.Comment),
+		users:      make(map[uuid.UUID]*models.User),
+		requests:   make(map[uuid.UUID]*models.FollowRequest),
+		blocks:     make(map[uuid.UUID]map[uuid.UUID]time.Time),
+		mutes:      make(map[uuid.UUID]map[uuid.UUID]*models.UserMute),
+		revisions:  make(map[uuid.UUID][]models.PostRevision),
+		media:      NewMockMediaRepository(),
+		followTime: time.Now(),
 	}
 }
 
+// AddUser registers a user so follow operations can reference it
+func (m *MockPostRepository) AddUser(user *models.User) {
+	m.users[user.ID] = user
+}
+
 func (m *MockPostRepository) CreatePost(post *models.Post) error {
 	if post.ID == uuid.Nil {
 		post.ID = uuid.New()
@@ -38,19 +79,67 @@ func (m *MockPostRepository) CreatePost(post *models.Post) error {
 	return nil
 }
 
+func (m *MockPostRepository) CreatePostWithMedia(post *models.Post, mediaIDs []uuid.UUID) error {
+	media, err := m.media.attach(post.UserID, mediaIDs)
+	if err != nil {
+		return err
+	}
+	items := make([]models.PostMedia, len(media))
+	for i := range media {
+		items[i] = media[i].PostMedia(i, post.Media[i].AltText)
+	}
+	post.Media, post.ImageURL = items, items[0].URL
+	return m.CreatePost(post)
+}
+
 func (m *MockPostRepository) GetPostByID(id uuid.UUID) (*models.Post, error) {
 	if post, exists := m.posts[id]; exists {
-		return post, nil
+		found := *post
+		return &found, nil
 	}
 	return nil, nil
 }
 
-func (m *MockPostRepository) GetPosts(page, pageSize int) ([]models.Post, error) {
+func (m *MockPostRepository) UpdatePost(id uuid.UUID, caption, language string) (*models.Post, error) {
+	post, exists := m.posts[id]
+	if !exists {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if post.Caption != caption || post.Language != language {
+		now := time.Now()
+		revision := models.PostRevision{ID: uuid.New(), PostID: id, Caption: post.Caption, Language: post.Language, CreatedAt: post.CreatedAt, ReplacedAt: now}
+		if post.EditedAt != nil {
+			revision.CreatedAt = *post.EditedAt
+		}
+		m.revisions[id] = append(m.revisions[id], revision)
+		post.Caption, post.Language, post.EditedAt = caption, language, &now
+	}
+	return m.GetPostByID(id)
+}
+
+func (m *MockPostRepository) GetPostRevisions(postID uuid.UUID) ([]models.PostRevision, error) {
+	revisions := []models.PostRevision{}
+	for i := len(m.revisions[postID]) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.revisions[postID][i])
+	}
+	return revisions, nil
+}
+
+func (m *MockPostRepository) GetPosts(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
 	var posts []models.Post
 	for _, post := range m.posts {
-		posts = append(posts, *post)
+		canView, _ := m.CanViewPosts(viewerID, post.UserID)
+		mute, muted := m.mutes[viewerID][post.UserID]
+		if canView && !(muted && muteActive(mute)) {
+			posts = append(posts, *post)
+		}
 	}
-	return posts, nil
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetPostsByOffset(viewerID uuid.UUID, offset, limit int) ([]models.Post, error) {
+	posts, _ := m.GetPosts(viewerID, nil, len(m.posts))
+	return offsetPage(posts, offset, limit), nil
 }
 
 func (m *MockPostRepository) DeletePost(id uuid.UUID, userID uuid.UUID) error {
@@ -62,239 +151,1657 @@ func (m *MockPostRepository) DeletePost(id uuid.UUID, userID uuid.UUID) error {
 }
 
 func (m *MockPostRepository) AddComment(comment *models.Comment) error {
+	post, exists := m.posts[comment.PostID]
+	if !exists {
+		return repository.ErrPostNotFound
+	}
+	if blocked, _ := m.IsBlocked(comment.UserID, post.UserID); blocked {
+		return repository.ErrBlocked
+	}
+	comment.Depth = 0
+	if comment.ParentID != nil {
+		parent := m.findComment(*comment.ParentID)
+		if parent == nil || parent.PostID != comment.PostID || parent.DeletedAt != nil {
+			return repository.ErrCommentNotFound
+		}
+		if parent.Depth >= models.MaxCommentDepth {
+			return repository.ErrCommentTooDeep
+		}
+		comment.Depth = parent.Depth + 1
+		parent.ReplyCount++
+	}
 	if comment.ID == uuid.Nil {
 		comment.ID = uuid.New()
 	}
+	if user, exists := m.users[comment.UserID]; exists {
+		comment.User = *user
+	}
 	m.comments[comment.PostID] = append(m.comments[comment.PostID], comment)
 	return nil
 }
 
-func (m *MockPostRepository) DeleteComment(id uuid.UUID, userID uuid.UUID) error {
+func (m *MockPostRepository) findComment(id uuid.UUID) *models.Comment {
+	for _, comments := range m.comments {
+		for _, comment := range comments {
+			if comment.ID == id {
+				return comment
+			}
+		}
+	}
 	return nil
 }
 
-func (m *MockPostRepository) LikePost(like *models.Like) error {
-	if _, exists := m.likes[like.PostID]; !exists {
-		m.likes[like.PostID] = make(map[uuid.UUID]bool)
+func (m *MockPostRepository) GetComment(id uuid.UUID) (*models.Comment, error) {
+	comment := m.findComment(id)
+	if comment == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *comment
+	if user, exists := m.users[comment.UserID]; exists {
+		found.User = *user
+	}
+	return &found, nil
+}
+
+// GetComments returns a post's top-level comments oldest first, excluding those by blocked users
+func (m *MockPostRepository) GetComments(postID, viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Comment, error) {
+	return m.pageComments(postID, viewerID, cursor, limit, func(comment *models.Comment) bool {
+		return comment.ParentID == nil
+	}), nil
+}
+
+// GetReplies returns a comment's direct replies oldest first, excluding those by blocked users
+func (m *MockPostRepository) GetReplies(commentID, viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Comment, error) {
+	comment := m.findComment(commentID)
+	if comment == nil {
+		return []models.Comment{}, nil
+	}
+	return m.pageComments(comment.PostID, viewerID, cursor, limit, func(reply *models.Comment) bool {
+		return reply.ParentID != nil && *reply.ParentID == commentID
+	}), nil
+}
+
+// pageComments returns the post's comments that match, oldest first, excluding those by blocked users
+func (m *MockPostRepository) pageComments(postID, viewerID uuid.UUID, cursor *repository.Cursor, limit int, match func(*models.Comment) bool) []models.Comment {
+	var comments []models.Comment
+	for _, comment := range m.comments[postID] {
+		if !match(comment) {
+			continue
+		}
+		if blocked, _ := m.IsBlocked(viewerID, comment.UserID); !blocked {
+			withUser := *comment
+			if user, exists := m.users[comment.UserID]; exists {
+				withUser.User = *user
+			}
+			comments = append(comments, withUser)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+		}
+		return comments[i].ID.String() < comments[j].ID.String()
+	})
+
+	page := []models.Comment{}
+	for _, comment := range comments {
+		if cursor != nil && (comment.CreatedAt.Before(cursor.CreatedAt) ||
+			(comment.CreatedAt.Equal(cursor.CreatedAt) && comment.ID.String() <= cursor.ID.String())) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, comment)
+	}
+	return page
+}
+
+// DeleteComment keeps comments with replies as tombstones and removes the
+// rest, along with tombstoned ancestors left without replies
+func (m *MockPostRepository) DeleteComment(id uuid.UUID) error {
+	comment := m.findComment(id)
+	if comment == nil {
+		return repository.ErrCommentNotFound
+	}
+	if comment.DeletedAt != nil {
+		return nil
+	}
+	if comment.ReplyCount > 0 {
+		now := time.Now()
+		comment.Content, comment.DeletedAt = "", &now
+		return nil
+	}
+	for comment != nil {
+		remaining := m.comments[comment.PostID][:0]
+		for _, other := range m.comments[comment.PostID] {
+			if other.ID != comment.ID {
+				remaining = append(remaining, other)
+			}
+		}
+		m.comments[comment.PostID] = remaining
+		if comment.ParentID == nil {
+			break
+		}
+		parent := m.findComment(*comment.ParentID)
+		parent.ReplyCount--
+		comment = nil
+		if parent.DeletedAt != nil && parent.ReplyCount == 0 {
+			comment = parent
+		}
 	}
-	m.likes[like.PostID][like.UserID] = true
 	return nil
 }
 
+func (m *MockPostRepository) LikePost(postID, userID uuid.UUID) error {
+	return m.AddReaction(models.PostTarget(postID), userID, models.HeartReaction)
+}
+
 func (m *MockPostRepository) UnlikePost(postID, userID uuid.UUID) error {
-	if likes, exists := m.likes[postID]; exists {
-		delete(likes, userID)
-		if len(likes) == 0 {
-			delete(m.likes, postID)
+	return m.RemoveReaction(models.PostTarget(postID), userID, models.HeartReaction)
+}
+
+func (m *MockPostRepository) HasUserLikedPost(postID, userID uuid.UUID) (bool, error) {
+	return m.findReaction(models.PostTarget(postID), userID, models.HeartReaction) != nil, nil
+}
+
+func (m *MockPostRepository) GetPostLikes(postID uuid.UUID) (int64, error) {
+	likes, _ := m.GetReactions(models.PostTarget(postID), uuid.Nil, models.HeartReaction, nil, len(m.reactions))
+	return int64(len(likes)), nil
+}
+
+func (m *MockPostRepository) AddReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error {
+	var authorIDs []uuid.UUID
+	if target.Kind == models.ReactionOnComment {
+		comment := m.findComment(target.ID)
+		if comment == nil || comment.DeletedAt != nil {
+			return repository.ErrCommentNotFound
+		}
+		authorIDs = append(authorIDs, comment.UserID)
+		if post, exists := m.posts[comment.PostID]; exists {
+			authorIDs = append(authorIDs, post.UserID)
+		}
+	} else {
+		post, exists := m.posts[target.ID]
+		if !exists {
+			return repository.ErrPostNotFound
+		}
+		authorIDs = append(authorIDs, post.UserID)
+	}
+	for _, authorID := range authorIDs {
+		if blocked, _ := m.IsBlocked(userID, authorID); blocked {
+			return repository.ErrBlocked
 		}
 	}
+	if m.findReaction(target, userID, emoji) != nil {
+		return nil
+	}
+
+	reaction := &models.Reaction{ID: uuid.New(), UserID: userID, Emoji: emoji, CreatedAt: time.Now()}
+	if target.Kind == models.ReactionOnComment {
+		reaction.CommentID = &target.ID
+	} else {
+		reaction.PostID = &target.ID
+	}
+	m.reactions = append(m.reactions, reaction)
 	return nil
 }
 
-func (m *MockPostRepository) HasUserLikedPost(postID, userID uuid.UUID) (bool, error) {
-	if likes, exists := m.likes[postID]; exists {
-		return likes[userID], nil
+func (m *MockPostRepository) RemoveReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error {
+	remaining := m.reactions[:0]
+	for _, reaction := range m.reactions {
+		if !(reactsTo(reaction, target) && reaction.UserID == userID && reaction.Emoji == emoji) {
+			remaining = append(remaining, reaction)
+		}
+	}
+	m.reactions = remaining
+	return nil
+}
+
+func (m *MockPostRepository) findReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) *models.Reaction {
+	for _, reaction := range m.reactions {
+		if reactsTo(reaction, target) && reaction.UserID == userID && reaction.Emoji == emoji {
+			return reaction
+		}
+	}
+	return nil
+}
+
+// reactsTo reports whether a reaction is on the target
+func reactsTo(reaction *models.Reaction, target models.ReactionTarget) bool {
+	id := reaction.PostID
+	if target.Kind == models.ReactionOnComment {
+		id = reaction.CommentID
+	}
+	return id != nil && *id == target.ID
+}
+
+// GetReactions returns a target's reactions newest first, excluding those by blocked users
+func (m *MockPostRepository) GetReactions(target models.ReactionTarget, viewerID uuid.UUID, emoji string, cursor *repository.Cursor, limit int) ([]models.Reaction, error) {
+	var reactions []models.Reaction
+	for _, reaction := range m.reactions {
+		if !reactsTo(reaction, target) || (emoji != "" && reaction.Emoji != emoji) {
+			continue
+		}
+		if blocked, _ := m.IsBlocked(viewerID, reaction.UserID); blocked {
+			continue
+		}
+		withUser := *reaction
+		if user, exists := m.users[reaction.UserID]; exists {
+			withUser.User = *user
+		}
+		reactions = append(reactions, withUser)
+	}
+	sort.Slice(reactions, func(i, j int) bool {
+		if !reactions[i].CreatedAt.Equal(reactions[j].CreatedAt) {
+			return reactions[i].CreatedAt.After(reactions[j].CreatedAt)
+		}
+		return reactions[i].ID.String() > reactions[j].ID.String()
+	})
+
+	page := []models.Reaction{}
+	for _, reaction := range reactions {
+		if cursor != nil && (reaction.CreatedAt.After(cursor.CreatedAt) ||
+			(reaction.CreatedAt.Equal(cursor.CreatedAt) && reaction.ID.String() >= cursor.ID.String())) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, reaction)
+	}
+	return page, nil
+}
+
+func (m *MockPostRepository) GetCommentReactions(viewerID uuid.UUID, commentIDs []uuid.UUID) (map[uuid.UUID]*repository.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID]*repository.ReactionSummary, len(commentIDs))
+	for _, id := range commentIDs {
+		summaries[id] = m.reactionSummary(models.CommentTarget(id), viewerID)
+	}
+	return summaries, nil
+}
+
+// reactionSummary counts a target's reactions, most used first, and lists the viewer's
+func (m *MockPostRepository) reactionSummary(target models.ReactionTarget, viewerID uuid.UUID) *repository.ReactionSummary {
+	summary := &repository.ReactionSummary{Reactions: []models.ReactionCount{}, ViewerReactions: []string{}}
+	counts := make(map[string]int64)
+	for _, reaction := range m.reactions {
+		if reactsTo(reaction, target) {
+			counts[reaction.Emoji]++
+			if reaction.UserID == viewerID {
+				summary.ViewerReactions = append(summary.ViewerReactions, reaction.Emoji)
+			}
+		}
+	}
+	for emoji, count := range counts {
+		summary.Reactions = append(summary.Reactions, models.ReactionCount{Emoji: emoji, Count: count})
+	}
+	sort.Slice(summary.Reactions, func(i, j int) bool {
+		if summary.Reactions[i].Count != summary.Reactions[j].Count {
+			return summary.Reactions[i].Count > summary.Reactions[j].Count
+		}
+		return summary.Reactions[i].Emoji < summary.Reactions[j].Emoji
+	})
+	sort.Strings(summary.ViewerReactions)
+	return summary
+}
+
+func (m *MockPostRepository) GetPostStats(viewerID uuid.UUID, postIDs []uuid.UUID, previewSize int) (map[uuid.UUID]*repository.PostStats, error) {
+	stats := make(map[uuid.UUID]*repository.PostStats, len(postIDs))
+	for _, id := range postIDs {
+		liked, _ := m.HasUserLikedPost(id, viewerID)
+		likes, _ := m.GetPostLikes(id)
+		reactions := m.reactionSummary(models.PostTarget(id), viewerID)
+		comments := m.pageComments(id, viewerID, nil, len(m.comments[id]), func(comment *models.Comment) bool {
+			return comment.ParentID == nil && comment.DeletedAt == nil
+		})
+		if len(comments) > previewSize {
+			comments = comments[len(comments)-previewSize:]
+		}
+		var commentCount int64
+		for _, comment := range m.comments[id] {
+			if comment.DeletedAt == nil {
+				commentCount++
+			}
+		}
+		stats[id] = &repository.PostStats{
+			LikeCount:       likes,
+			CommentCount:    commentCount,
+			ViewerHasLiked:  liked,
+			Reactions:       reactions.Reactions,
+			ViewerReactions: reactions.ViewerReactions,
+			RecentComments:  comments,
+		}
+	}
+	return stats, nil
+}
+
+func (m *MockPostRepository) GetPostInteractions(viewerID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID][]models.Comment, map[uuid.UUID][]models.Reaction, error) {
+	comments := make(map[uuid.UUID][]models.Comment)
+	likes := make(map[uuid.UUID][]models.Reaction)
+	for _, id := range postIDs {
+		comments[id] = m.pageComments(id, viewerID, nil, len(m.comments[id]), func(*models.Comment) bool { return true })
+		likes[id], _ = m.GetReactions(models.PostTarget(id), viewerID, models.HeartReaction, nil, len(m.reactions))
+	}
+	return comments, likes, nil
+}
+
+// ReconcileCounters finds no drift, since the mock counts rows on every read
+func (m *MockPostRepository) ReconcileCounters() ([]repository.CounterDrift, error) {
+	return []repository.CounterDrift{}, nil
+}
+
+func (m *MockPostRepository) GetProcessingPostIDs() ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for id, post := range m.posts {
+		if post.Status == models.PostProcessing {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockPostRepository) GetProcessingVideo(postID uuid.UUID) (*models.PostMedia, error) {
+	post, exists := m.posts[postID]
+	if !exists || post.Status != models.PostProcessing || len(post.Media) == 0 {
+		return nil, repository.ErrPostNotFound
+	}
+	media := post.Media[0]
+	return &media, nil
+}
+
+func (m *MockPostRepository) CompleteVideo(postID uuid.UUID, media models.PostMedia, imageURL string) error {
+	if _, err := m.GetProcessingVideo(postID); err != nil {
+		return err
+	}
+	post := m.posts[postID]
+	media.AltText = post.Media[0].AltText
+	post.Media[0] = media
+	post.ImageURL = imageURL
+	post.Status = models.PostReady
+	return nil
+}
+
+func (m *MockPostRepository) FailVideo(postID uuid.UUID) error {
+	if _, err := m.GetProcessingVideo(postID); err != nil {
+		return err
+	}
+	m.posts[postID].Status = models.PostFailed
+	return nil
+}
+
+func (m *MockPostRepository) GetUserPosts(userID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range m.posts {
+		if post.UserID == userID {
+			posts = append(posts, *post)
+		}
+	}
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetUserPostsCount(userID uuid.UUID) (int64, error) {
+	posts, _ := m.GetUserPosts(userID, nil, len(m.posts))
+	return int64(len(posts)), nil
+}
+
+func (m *MockPostRepository) FollowUser(followerID, followingID uuid.UUID) (string, error) {
+	target, exists := m.users[followingID]
+	if !exists {
+		return "", repository.ErrUserNotFound
+	}
+	if blocked, _ := m.IsBlocked(followerID, followingID); blocked {
+		return "", repository.ErrBlocked
+	}
+	if following, _ := m.IsFollowing(followerID, followingID); target.IsPrivate && !following {
+		for _, request := range m.requests {
+			if request.RequesterID == followerID && request.TargetID == followingID {
+				return models.FollowStatusRequested, nil
+			}
+		}
+		request := &models.FollowRequest{ID: uuid.New(), RequesterID: followerID, TargetID: followingID}
+		m.requests[request.ID] = request
+		return models.FollowStatusRequested, nil
+	}
+	m.follow(followerID, followingID)
+	return models.FollowStatusFollowing, nil
+}
+
+func (m *MockPostRepository) follow(followerID, followingID uuid.UUID) {
+	if _, exists := m.follows[followerID]; !exists {
+		m.follows[followerID] = make(map[uuid.UUID]time.Time)
+	}
+	if _, exists := m.follows[followerID][followingID]; !exists {
+		// Advance the clock so follows have a stable order
+		m.followTime = m.followTime.Add(time.Second)
+		m.follows[followerID][followingID] = m.followTime
+	}
+}
+
+func (m *MockPostRepository) UnfollowUser(followerID, followingID uuid.UUID) error {
+	for id, request := range m.requests {
+		if request.RequesterID == followerID && request.TargetID == followingID {
+			delete(m.requests, id)
+		}
+	}
+	if follows, exists := m.follows[followerID]; exists {
+		delete(follows, followingID)
+	}
+	return nil
+}
+
+func (m *MockPostRepository) IsFollowing(followerID, followingID uuid.UUID) (bool, error) {
+	if follows, exists := m.follows[followerID]; exists {
+		_, following := follows[followingID]
+		return following, nil
 	}
 	return false, nil
 }
 
-func (m *MockPostRepository) GetPostLikes(postID uuid.UUID) (int64, error) {
-	if likes, exists := m.likes[postID]; exists {
-		return int64(len(likes)), nil
+func (m *MockPostRepository) GetFollowersCount(userID uuid.UUID) (int64, error) {
+	count := int64(0)
+	for _, follows := range m.follows {
+		if _, following := follows[userID]; following {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MockPostRepository) GetFollowingCount(userID uuid.UUID) (int64, error) {
+	if follows, exists := m.follows[userID]; exists {
+		return int64(len(follows)), nil
 	}
 	return 0, nil
 }
 
-func (m *MockPostRepository) GetUserPosts(userID uuid.UUID) ([]models.Post, error) {
-	var posts []models.Post
-	for _, post := range m.posts {
-		if post.UserID == userID {
-			posts = append(posts, *post)
+func (m *MockPostRepository) GetFollowers(userID, viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.UserSummary, error) {
+	if _, exists := m.users[userID]; !exists {
+		return nil, repository.ErrUserNotFound
+	}
+	var summaries []models.UserSummary
+	for followerID, follows := range m.follows {
+		if followedAt, following := follows[userID]; following {
+			summaries = append(summaries, m.summary(followerID, viewerID, followedAt))
+		}
+	}
+	return pageSummaries(summaries, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetFollowing(userID, viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.UserSummary, error) {
+	if _, exists := m.users[userID]; !exists {
+		return nil, repository.ErrUserNotFound
+	}
+	var summaries []models.UserSummary
+	for followingID, followedAt := range m.follows[userID] {
+		summaries = append(summaries, m.summary(followingID, viewerID, followedAt))
+	}
+	return pageSummaries(summaries, cursor, limit), nil
+}
+
+func (m *MockPostRepository) summary(userID, viewerID uuid.UUID, followedAt time.Time) models.UserSummary {
+	viewerFollows, _ := m.IsFollowing(viewerID, userID)
+	followsViewer, _ := m.IsFollowing(userID, viewerID)
+	summary := models.UserSummary{
+		ID:            userID,
+		FollowedAt:    &followedAt,
+		ViewerFollows: viewerFollows,
+		FollowsViewer: followsViewer,
+	}
+	if user, exists := m.users[userID]; exists {
+		summary.Username = user.Username
+	}
+	return summary
+}
+
+// CanViewPosts treats authors that were never registered with AddUser as public accounts
+func (m *MockPostRepository) CanViewPosts(viewerID, authorID uuid.UUID) (bool, error) {
+	if viewerID == authorID {
+		return true, nil
+	}
+	if blocked, _ := m.IsBlocked(viewerID, authorID); blocked {
+		return false, nil
+	}
+	author, exists := m.users[authorID]
+	if !exists || !author.IsPrivate {
+		return true, nil
+	}
+	return m.IsFollowing(viewerID, authorID)
+}
+
+func (m *MockPostRepository) GetFollowRequest(id uuid.UUID) (*models.FollowRequest, error) {
+	if request, exists := m.requests[id]; exists {
+		return request, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockPostRepository) GetIncomingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error) {
+	var requests []models.FollowRequest
+	for _, request := range m.requests {
+		if request.TargetID == userID {
+			withUser := *request
+			if requester, exists := m.users[request.RequesterID]; exists {
+				withUser.Requester = *requester
+			}
+			requests = append(requests, withUser)
+		}
+	}
+	return requests, nil
+}
+
+func (m *MockPostRepository) GetOutgoingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error) {
+	var requests []models.FollowRequest
+	for _, request := range m.requests {
+		if request.RequesterID == userID {
+			withUser := *request
+			if target, exists := m.users[request.TargetID]; exists {
+				withUser.Target = *target
+			}
+			requests = append(requests, withUser)
+		}
+	}
+	return requests, nil
+}
+
+func (m *MockPostRepository) ApproveFollowRequest(id uuid.UUID) error {
+	request, exists := m.requests[id]
+	if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	m.follow(request.RequesterID, request.TargetID)
+	delete(m.requests, id)
+	return nil
+}
+
+func (m *MockPostRepository) DeleteFollowRequest(id uuid.UUID) error {
+	delete(m.requests, id)
+	return nil
+}
+
+func (m *MockPostRepository) BlockUser(blockerID, blockedID uuid.UUID) error {
+	if _, exists := m.users[blockedID]; !exists {
+		return repository.ErrUserNotFound
+	}
+	if _, exists := m.blocks[blockerID]; !exists {
+		m.blocks[blockerID] = make(map[uuid.UUID]time.Time)
+	}
+	if _, exists := m.blocks[blockerID][blockedID]; !exists {
+		m.blocks[blockerID][blockedID] = time.Now()
+	}
+	m.UnfollowUser(blockerID, blockedID)
+	m.UnfollowUser(blockedID, blockerID)
+	return nil
+}
+
+func (m *MockPostRepository) UnblockUser(blockerID, blockedID uuid.UUID) error {
+	delete(m.blocks[blockerID], blockedID)
+	return nil
+}
+
+func (m *MockPostRepository) GetBlockedUsers(userID uuid.UUID) ([]models.UserBlock, error) {
+	var blocks []models.UserBlock
+	for blockedID, blockedAt := range m.blocks[userID] {
+		block := models.UserBlock{BlockerID: userID, BlockedID: blockedID, CreatedAt: blockedAt}
+		if user, exists := m.users[blockedID]; exists {
+			block.Blocked = *user
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (m *MockPostRepository) IsBlocked(userID, otherID uuid.UUID) (bool, error) {
+	_, blocked := m.blocks[userID][otherID]
+	_, blockedBy := m.blocks[otherID][userID]
+	return blocked || blockedBy, nil
+}
+
+func (m *MockPostRepository) MuteUser(muterID, mutedID uuid.UUID, expiresAt *time.Time) error {
+	if _, exists := m.users[mutedID]; !exists {
+		return repository.ErrUserNotFound
+	}
+	if _, exists := m.mutes[muterID]; !exists {
+		m.mutes[muterID] = make(map[uuid.UUID]*models.UserMute)
+	}
+	m.mutes[muterID][mutedID] = &models.UserMute{MuterID: muterID, MutedID: mutedID, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *MockPostRepository) UnmuteUser(muterID, mutedID uuid.UUID) error {
+	delete(m.mutes[muterID], mutedID)
+	return nil
+}
+
+func (m *MockPostRepository) GetMutedUsers(userID uuid.UUID) ([]models.UserMute, error) {
+	var mutes []models.UserMute
+	for _, mute := range m.mutes[userID] {
+		if !muteActive(mute) {
+			continue
+		}
+		withUser := *mute
+		if user, exists := m.users[mute.MutedID]; exists {
+			withUser.Muted = *user
+		}
+		mutes = append(mutes, withUser)
+	}
+	return mutes, nil
+}
+
+// SearchPosts matches the query as a case-insensitive substring of the caption
+func (m *MockPostRepository) SearchPosts(viewerID uuid.UUID, params repository.PostSearchParams, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range m.posts {
+		if canView, _ := m.CanViewPosts(viewerID, post.UserID); !canView {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(post.Caption), strings.ToLower(params.Query)) ||
+			(params.Language != "" && post.Language != params.Language) ||
+			(params.AuthorID != nil && post.UserID != *params.AuthorID) ||
+			(params.From != nil && post.CreatedAt.Before(*params.From)) ||
+			(params.To != nil && !post.CreatedAt.Before(*params.To)) ||
+			(params.Hashtag != "" && !hasHashtag(post, params.Hashtag)) {
+			continue
+		}
+		posts = append(posts, *post)
+	}
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) SearchPostsByOffset(viewerID uuid.UUID, params repository.PostSearchParams, offset, limit int) ([]models.Post, error) {
+	posts, _ := m.SearchPosts(viewerID, params, nil, len(m.posts))
+	return offsetPage(posts, offset, limit), nil
+}
+
+func (m *MockPostRepository) GetHashtagPosts(viewerID uuid.UUID, tag string, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	feed, _ := m.GetPosts(viewerID, nil, len(m.posts))
+	var posts []models.Post
+	for _, post := range feed {
+		if hasHashtag(&post, tag) {
+			posts = append(posts, post)
+		}
+	}
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetHashtagPostsByOffset(viewerID uuid.UUID, tag string, offset, limit int) ([]models.Post, error) {
+	posts, _ := m.GetHashtagPosts(viewerID, tag, nil, len(m.posts))
+	return offsetPage(posts, offset, limit), nil
+}
+
+func hasHashtag(post *models.Post, tag string) bool {
+	for _, name := range utils.ExtractHashtags(post.Caption) {
+		if name == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHomeTimeline returns posts by the viewer and followed users, ordered by (created_at, id) descending
+func (m *MockPostRepository) GetHomeTimeline(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range m.posts {
+		if m.inHomeTimeline(viewerID, post) {
+			posts = append(posts, *post)
+		}
+	}
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetTimelinePostsByIDs(viewerID uuid.UUID, ids []uuid.UUID) ([]models.Post, error) {
+	var posts []models.Post
+	for _, id := range ids {
+		if post, ok := m.posts[id]; ok && m.inHomeTimeline(viewerID, post) {
+			posts = append(posts, *post)
+		}
+	}
+	return pageFeed(posts, nil, len(ids)), nil
+}
+
+func (m *MockPostRepository) GetPostsByAuthors(viewerID uuid.UUID, authorIDs []uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range m.posts {
+		for _, authorID := range authorIDs {
+			if post.UserID == authorID {
+				posts = append(posts, *post)
+			}
+		}
+	}
+	return pageFeed(posts, cursor, limit), nil
+}
+
+func (m *MockPostRepository) GetFollowerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for followerID, following := range m.follows {
+		if _, ok := following[userID]; ok {
+			ids = append(ids, followerID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockPostRepository) GetLargeFollowedAccounts(userID uuid.UUID, minFollowers int64) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for followingID := range m.follows[userID] {
+		if count, _ := m.GetFollowersCount(followingID); count > minFollowers {
+			ids = append(ids, followingID)
+		}
+	}
+	return ids, nil
+}
+
+// muteActive reports whether a mute is still in effect
+func muteActive(mute *models.UserMute) bool {
+	return mute.ExpiresAt == nil || mute.ExpiresAt.After(time.Now())
+}
+
+// inHomeTimeline reports whether a post is by the viewer or a followed user who is neither blocked nor muted
+func (m *MockPostRepository) inHomeTimeline(viewerID uuid.UUID, post *models.Post) bool {
+	following, _ := m.IsFollowing(viewerID, post.UserID)
+	blocked, _ := m.IsBlocked(viewerID, post.UserID)
+	mute, muted := m.mutes[viewerID][post.UserID]
+	return (post.UserID == viewerID || following) && !blocked && !(muted && muteActive(mute))
+}
+
+// pageFeed orders posts by (created_at, id) descending and applies the cursor and limit
+func pageFeed(posts []models.Post, cursor *repository.Cursor, limit int) []models.Post {
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID.String() > posts[j].ID.String()
+	})
+
+	page := []models.Post{}
+	for _, post := range posts {
+		if cursor != nil && (post.CreatedAt.After(cursor.CreatedAt) ||
+			(post.CreatedAt.Equal(cursor.CreatedAt) && post.ID.String() >= cursor.ID.String())) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, post)
+	}
+	return page
+}
+
+// offsetPage returns up to limit posts starting at offset
+func offsetPage(posts []models.Post, offset, limit int) []models.Post {
+	if offset >= len(posts) {
+		return []models.Post{}
+	}
+	if offset+limit > len(posts) {
+		return posts[offset:]
+	}
+	return posts[offset : offset+limit]
+}
+
+// pageSummaries orders summaries newest follow first and applies the cursor and limit
+func pageSummaries(summaries []models.UserSummary, cursor *repository.Cursor, limit int) []models.UserSummary {
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].FollowedAt.Equal(*summaries[j].FollowedAt) {
+			return summaries[i].FollowedAt.After(*summaries[j].FollowedAt)
+		}
+		return summaries[i].ID.String() > summaries[j].ID.String()
+	})
+	page := []models.UserSummary{}
+	for _, summary := range summaries {
+		if cursor != nil && (summary.FollowedAt.After(cursor.CreatedAt) ||
+			(summary.FollowedAt.Equal(cursor.CreatedAt) && summary.ID.String() >= cursor.ID.String())) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, summary)
+	}
+	return page
+}
+
+// MockImageStorage implements necessary methods for testing
+type MockImageStorage struct {
+	saved    int
+	attempts int
+	failAt   int // number of the save attempt that fails, counting from 1; 0 for none
+	rejectAt int // number of the save attempt whose image does not decode; 0 for none
+}
+
+func NewMockImageStorage() *MockImageStorage {
+	return &MockImageStorage{}
+}
+
+func (m *MockImageStorage) SaveImage(ctx context.Context, r io.Reader) (*utils.StoredImage, error) {
+	m.attempts++
+	if m.attempts == m.failAt {
+		return nil, fmt.Errorf("failed to save image %d", m.attempts)
+	}
+	if m.attempts == m.rejectAt {
+		return nil, fmt.Errorf("%w: cannot decode image %d", imaging.ErrInvalidImage, m.attempts)
+	}
+	m.saved++
+	variants := models.ImageVariants{}
+	for _, name := range []string{models.VariantThumbnail, models.VariantFeed, models.VariantFull} {
+		url := fmt.Sprintf("/uploads/%d-%s.jpg", m.saved, name)
+		variants[name] = models.ImageVariant{URL: url, Width: 2, Height: 1, MimeType: "image/jpeg"}
+	}
+	full := variants[models.VariantFull]
+	return &utils.StoredImage{URL: full.URL, Width: full.Width, Height: full.Height, MimeType: full.MimeType, Variants: variants}, nil
+}
+
+func (m *MockImageStorage) SaveAvatar(ctx context.Context, r io.Reader) (*utils.StoredImage, error) {
+	return m.saveProfileImage("avatar", 2, 2)
+}
+
+func (m *MockImageStorage) SaveStoredAvatar(ctx context.Context, url string) (*utils.StoredImage, error) {
+	return m.saveProfileImage("avatar", 2, 2)
+}
+
+func (m *MockImageStorage) SaveBanner(ctx context.Context, r io.Reader) (*utils.StoredImage, error) {
+	return m.saveProfileImage("banner", 3, 1)
+}
+
+// saveProfileImage returns width x height profile image variants, failing
+// like SaveImage
+func (m *MockImageStorage) saveProfileImage(kind string, width, height int) (*utils.StoredImage, error) {
+	m.attempts++
+	if m.attempts == m.failAt {
+		return nil, fmt.Errorf("failed to save image %d", m.attempts)
+	}
+	if m.attempts == m.rejectAt {
+		return nil, fmt.Errorf("%w: cannot decode image %d", imaging.ErrInvalidImage, m.attempts)
+	}
+	m.saved++
+	variants := models.ImageVariants{}
+	for _, name := range []string{models.VariantThumbnail, models.VariantFull} {
+		url := fmt.Sprintf("/uploads/%d-%s-%s.jpg", m.saved, kind, name)
+		variants[name] = models.ImageVariant{URL: url, Width: width, Height: height, MimeType: "image/jpeg"}
+	}
+	full := variants[models.VariantFull]
+	return &utils.StoredImage{URL: full.URL, Width: full.Width, Height: full.Height, MimeType: full.MimeType, Variants: variants}, nil
+}
+
+func setupPostTestRouter() (*gin.Engine, *MockPostRepository, *MockImageStorage) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	mockRepo := NewMockPostRepository()
+	mockStorage := NewMockImageStorage()
+	postHandler := NewPostHandler(mockRepo, mockStorage, mockRepo, testCursors, testMedia, testReactions)
+
+	// Add middleware to set test user ID
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", uuid.New())
+		c.Next()
+	})
+
+	router.POST("/posts", postHandler.CreatePost)
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.GET("/posts", postHandler.GetPosts)
+	router.DELETE("/posts/:id", postHandler.DeletePost)
+	router.POST("/posts/:id/comments", postHandler.AddComment)
+	router.POST("/posts/:id/like", postHandler.LikePost)
+	router.DELETE("/posts/:id/like", postHandler.UnlikePost)
+	router.POST("/users/:id/follow", postHandler.FollowUser)
+	router.DELETE("/users/:id/follow", postHandler.UnfollowUser)
+	router.GET("/users/:id/posts", postHandler.GetUserPosts)
+
+	return router, mockRepo, mockStorage
+}
+
+// writeTestImage adds a width x height PNG to a multipart form as an image field
+func writeTestImage(t *testing.T, writer *multipart.Writer, filename string, width, height int) {
+	t.Helper()
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="image"; filename="%s"`, filename))
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if err := png.Encode(part, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+}
+
+func TestPostHandler_CreatePost(t *testing.T) {
+	router, _, _ := setupPostTestRouter()
+
+	t.Run("create post with valid data", func(t *testing.T) {
+		// Create multipart form data
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writeTestImage(t, writer, "test.png", 4, 3)
+		writer.WriteField("caption", "Test caption")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/posts", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		var response models.Post
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		if response.Caption != "Test caption" {
+			t.Errorf("Expected caption %s, got %s", "Test caption", response.Caption)
+		}
+		if response.ImageURL == "" {
+			t.Error("Expected image URL to be set")
+		}
+	})
+
+	t.Run("create post without image", func(t *testing.T) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writer.WriteField("caption", "Test caption")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/posts", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("create post with unsupported language", func(t *testing.T) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writeTestImage(t, writer, "test.png", 4, 3)
+		writer.WriteField("caption", "Test caption")
+		writer.WriteField("language", "klingon")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/posts", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestPostHandler_CreateCarousel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	mockStorage := NewMockImageStorage()
+	postHandler := NewPostHandler(mockRepo, mockStorage, mockRepo, testCursors, testMedia, testReactions)
+	strict := NewPostHandler(mockRepo, mockStorage, mockRepo, testCursors, &config.MediaConfig{MaxItems: 3, RequireAltText: true, MaxAltTextLength: 20}, testReactions)
+
+	author := &models.User{ID: uuid.New(), Username: "author"}
+	mockRepo.AddUser(author)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", author.ID)
+		c.Next()
+	})
+	router.POST("/posts", postHandler.CreatePost)
+	router.POST("/strict/posts", strict.CreatePost)
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.DELETE("/posts/:id", postHandler.DeletePost)
+
+	// upload posts a form with a 2x1 PNG per filename, or a text file for names ending in .txt
+	upload := func(url string, filenames []string, altTexts []string) *httptest.ResponseRecorder {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		for _, filename := range filenames {
+			if strings.HasSuffix(filename, ".txt") {
+				part, _ := writer.CreateFormFile("image", filename)
+				part.Write([]byte("not an image"))
+				continue
+			}
+			writeTestImage(t, writer, filename, 2, 1)
+		}
+		for _, altText := range altTexts {
+			writer.WriteField("alt_text", altText)
+		}
+		writer.WriteField("caption", "Carousel")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", url, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("images are kept in order with their alt text", func(t *testing.T) {
+		saved := mockStorage.saved
+		w := upload("/posts", []string{"first.png", "second.png", "third.png"}, []string{"A sunset", "", " A dog "})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var created models.Post
+		json.Unmarshal(w.Body.Bytes(), &created)
+
+		req := httptest.NewRequest("GET", "/posts/"+created.ID.String(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var view models.PostView
+		json.Unmarshal(w.Body.Bytes(), &view)
+		if len(view.Media) != 3 || view.ImageURL != view.Media[0].URL {
+			t.Fatalf("Expected 3 media items with the first as the image, got %+v", view)
+		}
+		first := view.Media[0]
+		if first.Width != 2 || first.Height != 1 || first.MimeType != "image/jpeg" || first.AltText != "A sunset" || first.URL != fmt.Sprintf("/uploads/%d-full.jpg", saved+1) {
+			t.Errorf("Expected the processed full-size image with its alt text, got %+v", first)
+		}
+		if len(first.Variants) != 3 || first.Variants[models.VariantThumbnail].URL == "" || first.Srcset == "" {
+			t.Errorf("Expected the image's variants, got %+v", first)
+		}
+		if view.Media[2].URL != fmt.Sprintf("/uploads/%d-full.jpg", saved+3) || view.Media[1].AltText != "" || view.Media[2].AltText != "A dog" {
+			t.Errorf("Expected the images in order with their alt text, got %+v", view.Media)
+		}
+
+		req = httptest.NewRequest("DELETE", "/posts/"+created.ID.String(), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("invalid carousels store nothing", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			url       string
+			filenames []string
+			altTexts  []string
+		}{
+			{"too many images", "/posts", []string{"a.png", "b.png", "c.png", "d.png"}, nil},
+			{"more alt texts than images", "/posts", []string{"a.png"}, []string{"one", "two"}},
+			{"alt text too long", "/posts", []string{"a.png", "b.png"}, []string{"", strings.Repeat("x", 21)}},
+			{"missing required alt text", "/strict/posts", []string{"a.png", "b.png"}, []string{"A cat"}},
+			{"one image is not an image", "/posts", []string{"a.png", "b.txt", "c.png"}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				saved := mockStorage.saved
+				if w := upload(tt.url, tt.filenames, tt.altTexts); w.Code != http.StatusBadRequest {
+					t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+				}
+				if mockStorage.saved != saved {
+					t.Errorf("Expected no image to be stored, got %d", mockStorage.saved-saved)
+				}
+			})
+		}
+	})
+
+	t.Run("no post is created when an image fails to store", func(t *testing.T) {
+		mockStorage.failAt = mockStorage.attempts + 3
+		defer func() { mockStorage.failAt = 0 }()
+		posts := len(mockRepo.posts)
+		if w := upload("/posts", []string{"a.png", "b.png", "c.png"}, nil); w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+		if len(mockRepo.posts) != posts {
+			t.Errorf("Expected no post to be created, got %d", len(mockRepo.posts)-posts)
+		}
+	})
+
+	t.Run("images that fail processing are rejected", func(t *testing.T) {
+		mockStorage.rejectAt = mockStorage.attempts + 2
+		defer func() { mockStorage.rejectAt = 0 }()
+		if w := upload("/posts", []string{"a.png", "b.png"}, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("required alt text", func(t *testing.T) {
+		if w := upload("/strict/posts", []string{"a.png"}, []string{"A cat"}); w.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+		}
+	})
+}
+
+func TestPostHandler_GetPost(t *testing.T) {
+	router, mockRepo, _ := setupPostTestRouter()
+
+	// Create a test post
+	testPost := &models.Post{
+		ID:       uuid.New(),
+		UserID:   uuid.New(),
+		Caption:  "Test post",
+		ImageURL: "test.jpg",
+	}
+	mockRepo.CreatePost(testPost)
+
+	t.Run("get existing post", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/posts/"+testPost.ID.String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var response models.PostView
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		if response.ID != testPost.ID {
+			t.Errorf("Expected post ID %v, got %v", testPost.ID, response.ID)
+		}
+	})
+
+	t.Run("get non-existent post", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/posts/"+uuid.New().String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestPostHandler_UpdatePost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	owner := &models.User{ID: uuid.New(), Username: "owner"}
+	other := &models.User{ID: uuid.New(), Username: "other"}
+	mockRepo.AddUser(owner)
+	mockRepo.AddUser(other)
+
+	testPost := &models.Post{
+		ID:        uuid.New(),
+		UserID:    owner.ID,
+		Caption:   "First draft #draft",
+		Language:  models.DefaultPostLanguage,
+		ImageURL:  "test.jpg",
+		CreatedAt: time.Now().Add(-time.Hour),
+	}
+	mockRepo.CreatePost(testPost)
+
+	var currentUserID uuid.UUID
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Next()
+	})
+	router.PUT("/posts/:id", postHandler.UpdatePost)
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.GET("/posts/:id/revisions", postHandler.GetPostRevisions)
+	router.GET("/hashtags/:tag/posts", postHandler.GetHashtagPosts)
+
+	do := func(as uuid.UUID, method, url string, body interface{}) *httptest.ResponseRecorder {
+		currentUserID = as
+		var req *http.Request
+		if body != nil {
+			jsonBody, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, url, bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, url, nil)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	caption := func(s string) *string { return &s }
+	url := "/posts/" + testPost.ID.String()
+
+	t.Run("unedited post", func(t *testing.T) {
+		var post models.PostView
+		json.Unmarshal(do(other.ID, "GET", url, nil).Body.Bytes(), &post)
+		if post.EditedAt != nil {
+			t.Errorf("Expected no edited_at on an unedited post, got %v", post.EditedAt)
+		}
+	})
+
+	t.Run("only the owner can edit", func(t *testing.T) {
+		w := do(other.ID, "PUT", url, UpdatePostRequest{Caption: caption("Hijacked")})
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		tests := []struct {
+			name string
+			url  string
+			body interface{}
+			code int
+		}{
+			{"missing caption", url, map[string]string{"language": "english"}, http.StatusBadRequest},
+			{"unsupported language", url, UpdatePostRequest{Caption: caption("Hi"), Language: "klingon"}, http.StatusBadRequest},
+			{"invalid ID", "/posts/invalid", UpdatePostRequest{Caption: caption("Hi")}, http.StatusBadRequest},
+			{"nonexistent post", "/posts/" + uuid.New().String(), UpdatePostRequest{Caption: caption("Hi")}, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if w := do(owner.ID, "PUT", tt.url, tt.body); w.Code != tt.code {
+				t.Errorf("%s: expected status code %d, got %d", tt.name, tt.code, w.Code)
+			}
+		}
+	})
+
+	t.Run("edit keeps history and reindexes hashtags", func(t *testing.T) {
+		w := do(owner.ID, "PUT", url, UpdatePostRequest{Caption: caption("Final version #final"), Language: "english"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var post models.PostView
+		json.Unmarshal(w.Body.Bytes(), &post)
+		if post.Caption != "Final version #final" || post.Language != "english" || post.EditedAt == nil {
+			t.Errorf("Expected the edited caption, language and edited_at, got %+v", post)
+		}
+
+		// An empty caption is a valid edit
+		if w := do(owner.ID, "PUT", url, UpdatePostRequest{Caption: caption("")}); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var revisions []models.PostRevision
+		json.Unmarshal(do(other.ID, "GET", url+"/revisions", nil).Body.Bytes(), &revisions)
+		if len(revisions) != 2 {
+			t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+		}
+		if revisions[0].Caption != "Final version #final" || revisions[1].Caption != "First draft #draft" {
+			t.Errorf("Expected revisions newest first, got %+v", revisions)
+		}
+		if !revisions[1].CreatedAt.Equal(testPost.CreatedAt) || revisions[1].ReplacedAt.After(revisions[0].ReplacedAt) {
+			t.Errorf("Expected each revision to span from publication to replacement, got %+v", revisions)
+		}
+
+		var page PostListResponse
+		json.Unmarshal(do(other.ID, "GET", "/hashtags/draft/posts", nil).Body.Bytes(), &page)
+		if len(page.Items) != 0 {
+			t.Errorf("Expected the post to leave the hashtag feed it was edited out of, got %d posts", len(page.Items))
 		}
-	}
-	return posts, nil
-}
+	})
 
-func (m *MockPostRepository) FollowUser(followerID, followingID uuid.UUID) error {
-	if _, exists := m.follows[followerID]; !exists {
-		m.follows[followerID] = make(map[uuid.UUID]bool)
-	}
-	m.follows[followerID][followingID] = true
-	return nil
+	t.Run("revisions of missing post", func(t *testing.T) {
+		if w := do(other.ID, "GET", "/posts/"+uuid.New().String()+"/revisions", nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
 }
 
-func (m *MockPostRepository) UnfollowUser(followerID, followingID uuid.UUID) error {
-	if follows, exists := m.follows[followerID]; exists {
-		delete(follows, followingID)
+func TestPostHandler_GetPosts(t *testing.T) {
+	router, mockRepo, _ := setupPostTestRouter()
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		mockRepo.CreatePost(&models.Post{
+			UserID:    uuid.New(),
+			Caption:   fmt.Sprintf("Post %d", i),
+			CreatedAt: now.Add(-time.Duration(i) * time.Minute),
+		})
 	}
-	return nil
-}
 
-func (m *MockPostRepository) IsFollowing(followerID, followingID uuid.UUID) (bool, error) {
-	if follows, exists := m.follows[followerID]; exists {
-		return follows[followingID], nil
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
 	}
-	return false, nil
-}
 
-func (m *MockPostRepository) GetFollowersCount(userID uuid.UUID) (int64, error) {
-	count := int64(0)
-	for _, follows := range m.follows {
-		if follows[userID] {
-			count++
+	t.Run("paginate with cursor", func(t *testing.T) {
+		var page1, page2 PostListResponse
+		w := get("/posts?limit=3")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		json.Unmarshal(w.Body.Bytes(), &page1)
+		if len(page1.Items) != 3 || page1.NextCursor == "" {
+			t.Fatalf("Expected 3 items and a next cursor, got %d items, cursor %q", len(page1.Items), page1.NextCursor)
+		}
+		if page1.Items[0].Caption != "Post 0" {
+			t.Errorf("Expected newest post first, got %q", page1.Items[0].Caption)
 		}
-	}
-	return count, nil
-}
 
-func (m *MockPostRepository) GetFollowingCount(userID uuid.UUID) (int64, error) {
-	if follows, exists := m.follows[userID]; exists {
-		return int64(len(follows)), nil
-	}
-	return 0, nil
-}
+		json.Unmarshal(get("/posts?limit=3&cursor="+page1.NextCursor).Body.Bytes(), &page2)
+		if len(page2.Items) != 2 || page2.NextCursor != "" {
+			t.Fatalf("Expected 2 items and no next cursor, got %d items, cursor %q", len(page2.Items), page2.NextCursor)
+		}
+		if page2.Items[0].Caption != "Post 3" {
+			t.Errorf("Expected second page to continue after the cursor, got %q", page2.Items[0].Caption)
+		}
+	})
 
-// MockFileStorage implements necessary methods for testing
-type MockFileStorage struct {
-	files map[string][]byte
-}
+	t.Run("tampered cursor", func(t *testing.T) {
+		var page PostListResponse
+		json.Unmarshal(get("/posts?limit=3").Body.Bytes(), &page)
 
-func NewMockFileStorage() *MockFileStorage {
-	return &MockFileStorage{
-		files: make(map[string][]byte),
-	}
-}
+		// Swap in a cursor payload the server did not sign
+		forged := testCursors.Encode(repository.Cursor{CreatedAt: now.Add(time.Hour), ID: uuid.New()})
+		payload := strings.SplitN(forged, ".", 2)[0]
+		signature := strings.SplitN(page.NextCursor, ".", 2)[1]
+		if w := get("/posts?cursor=" + payload + "." + signature); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := get("/posts?cursor=" + utils.NewCursorCodec("other-secret").Encode(repository.Cursor{ID: uuid.New()})); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for cursor signed with another key, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
 
-func (m *MockFileStorage) SaveFile(file *multipart.FileHeader) (string, error) {
-	return "test-image-url.jpg", nil
-}
+	t.Run("deprecated page parameters", func(t *testing.T) {
+		w := get("/posts?page=2&pageSize=2")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get("Deprecation") != "true" {
+			t.Error("Expected a Deprecation header for page parameters")
+		}
 
-func (m *MockFileStorage) DeleteFile(path string) error {
-	delete(m.files, path)
-	return nil
+		var posts []models.PostView
+		if err := json.Unmarshal(w.Body.Bytes(), &posts); err != nil {
+			t.Fatalf("Expected a bare array of posts: %v", err)
+		}
+		if len(posts) != 2 || posts[0].Caption != "Post 2" {
+			t.Errorf("Expected posts 2 and 3, got %d posts", len(posts))
+		}
+	})
+
+	t.Run("requests without pagination parameters keep the bare array", func(t *testing.T) {
+		w := get("/posts")
+		var posts []models.PostView
+		if err := json.Unmarshal(w.Body.Bytes(), &posts); err != nil {
+			t.Fatalf("Expected a bare array of posts: %v", err)
+		}
+		if w.Header().Get("Deprecation") != "true" {
+			t.Error("Expected a Deprecation header without cursor or limit")
+		}
+	})
 }
 
-func setupPostTestRouter() (*gin.Engine, *MockPostRepository, *MockFileStorage) {
+func TestPostHandler_CommentsAndLikes(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
 	mockRepo := NewMockPostRepository()
-	mockStorage := NewMockFileStorage()
-	postHandler := NewPostHandler(mockRepo, mockStorage)
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	viewer := &models.User{ID: uuid.New(), Username: "viewer"}
+	author := &models.User{ID: uuid.New(), Username: "author"}
+	blocked := &models.User{ID: uuid.New(), Username: "blocked"}
+	private := &models.User{ID: uuid.New(), Username: "private", IsPrivate: true}
+	for _, user := range []*models.User{viewer, author, blocked, private} {
+		mockRepo.AddUser(user)
+	}
 
-	// Add middleware to set test user ID
+	post := &models.Post{ID: uuid.New(), UserID: author.ID, Caption: "Busy post"}
+	privatePost := &models.Post{ID: uuid.New(), UserID: private.ID, Caption: "Private post"}
+	mockRepo.CreatePost(post)
+	mockRepo.CreatePost(privatePost)
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		mockRepo.comments[post.ID] = append(mockRepo.comments[post.ID], &models.Comment{
+			ID: uuid.New(), PostID: post.ID, UserID: author.ID,
+			Content: fmt.Sprintf("Comment %d", i), CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	mockRepo.comments[post.ID] = append(mockRepo.comments[post.ID], &models.Comment{
+		ID: uuid.New(), PostID: post.ID, UserID: blocked.ID, Content: "Hidden", CreatedAt: now,
+	})
+	for _, user := range []*models.User{author, private, blocked} {
+		mockRepo.LikePost(post.ID, user.ID)
+	}
+	mockRepo.BlockUser(viewer.ID, blocked.ID)
+
+	router := gin.New()
 	router.Use(func(c *gin.Context) {
-		c.Set("userID", uuid.New())
+		c.Set("userID", viewer.ID)
 		c.Next()
 	})
-
-	router.POST("/posts", postHandler.CreatePost)
 	router.GET("/posts/:id", postHandler.GetPost)
-	router.GET("/posts", postHandler.GetPosts)
-	router.DELETE("/posts/:id", postHandler.DeletePost)
-	router.POST("/posts/:id/comments", postHandler.AddComment)
-	router.POST("/posts/:id/like", postHandler.LikePost)
-	router.DELETE("/posts/:id/like", postHandler.UnlikePost)
-	router.POST("/users/:id/follow", postHandler.FollowUser)
-	router.DELETE("/users/:id/follow", postHandler.UnfollowUser)
-	router.GET("/users/:userId/posts", postHandler.GetUserPosts)
-
-	return router, mockRepo, mockStorage
-}
-
-func TestPostHandler_CreatePost(t *testing.T) {
-	router, _, _ := setupPostTestRouter()
-
-	t.Run("create post with valid data", func(t *testing.T) {
-		// Create multipart form data
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("image", "test.jpg")
-		if err != nil {
-			t.Fatalf("Failed to create form file: %v", err)
-		}
-		part.Write([]byte("test image content"))
-		writer.WriteField("caption", "Test caption")
-		writer.Close()
+	router.GET("/posts/:id/comments", postHandler.GetComments)
+	router.GET("/posts/:id/likes", postHandler.GetLikes)
 
-		req := httptest.NewRequest("POST", "/posts", body)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+	get := func(url string, response interface{}) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
+		json.Unmarshal(w.Body.Bytes(), response)
+		return w
+	}
 
-		if w.Code != http.StatusCreated {
-			t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	t.Run("paginate comments oldest first", func(t *testing.T) {
+		var page1, page2 CommentListResponse
+		if w := get("/posts/"+post.ID.String()+"/comments?limit=3", &page1); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(page1.Items) != 3 || page1.NextCursor == "" {
+			t.Fatalf("Expected 3 items and a next cursor, got %d items, cursor %q", len(page1.Items), page1.NextCursor)
+		}
+		if page1.Items[0].Content != "Comment 0" {
+			t.Errorf("Expected oldest comment first, got %q", page1.Items[0].Content)
 		}
 
-		var response models.Post
-		err = json.Unmarshal(w.Body.Bytes(), &response)
-		if err != nil {
-			t.Fatalf("Failed to unmarshal response: %v", err)
+		get("/posts/"+post.ID.String()+"/comments?limit=3&cursor="+page1.NextCursor, &page2)
+		if len(page2.Items) != 1 || page2.Items[0].Content != "Comment 3" {
+			t.Errorf("Expected only Comment 3 on the second page, got %d items", len(page2.Items))
 		}
+	})
 
-		if response.Caption != "Test caption" {
-			t.Errorf("Expected caption %s, got %s", "Test caption", response.Caption)
+	t.Run("list likes excluding blocked users", func(t *testing.T) {
+		var response ReactionListResponse
+		if w := get("/posts/"+post.ID.String()+"/likes", &response); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
 		}
-		if response.ImageURL == "" {
-			t.Error("Expected image URL to be set")
+		if len(response.Items) != 2 {
+			t.Fatalf("Expected 2 likes, got %d", len(response.Items))
+		}
+		for _, like := range response.Items {
+			if like.User.ID == blocked.ID {
+				t.Error("Expected likes by blocked users to be excluded")
+			}
 		}
 	})
 
-	t.Run("create post without image", func(t *testing.T) {
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		writer.WriteField("caption", "Test caption")
-		writer.Close()
+	t.Run("post view counts and viewer state", func(t *testing.T) {
+		var view models.PostView
+		if w := get("/posts/"+post.ID.String(), &view); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if view.LikeCount != 3 || view.CommentCount != 5 {
+			t.Errorf("Expected 3 likes and 5 comments, got %d and %d", view.LikeCount, view.CommentCount)
+		}
+		if view.ViewerHasLiked {
+			t.Error("Expected viewer_has_liked to be false")
+		}
+		if len(view.RecentComments) != recentCommentsPreview || view.RecentComments[recentCommentsPreview-1].Content != "Comment 3" {
+			t.Errorf("Expected the %d latest comments, oldest first, got %+v", recentCommentsPreview, view.RecentComments)
+		}
+		if view.RecentComments[0].User.Username != "author" {
+			t.Error("Expected comment authors as user summaries")
+		}
+		if view.Comments != nil || view.Likes != nil {
+			t.Error("Expected full relations only when expanded")
+		}
 
-		req := httptest.NewRequest("POST", "/posts", body)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
+		mockRepo.LikePost(post.ID, viewer.ID)
+		get("/posts/"+post.ID.String()+"?expand=likes", &view)
+		if !view.ViewerHasLiked {
+			t.Error("Expected viewer_has_liked after liking the post")
+		}
+		if len(view.Likes) != 3 {
+			t.Errorf("Expected 3 expanded likes excluding the blocked user, got %d", len(view.Likes))
+		}
+		mockRepo.UnlikePost(post.ID, viewer.ID)
+	})
 
-		if w.Code != http.StatusBadRequest {
+	t.Run("posts the viewer cannot see", func(t *testing.T) {
+		for _, url := range []string{
+			"/posts/" + privatePost.ID.String() + "/comments",
+			"/posts/" + privatePost.ID.String() + "/likes",
+			"/posts/" + uuid.New().String() + "/likes",
+		} {
+			if w := get(url, &struct{}{}); w.Code != http.StatusNotFound {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusNotFound, url, w.Code)
+			}
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		if w := get("/posts/"+post.ID.String()+"/comments?cursor=not-a-cursor", &struct{}{}); w.Code != http.StatusBadRequest {
 			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 		}
 	})
 }
 
-func TestPostHandler_GetPost(t *testing.T) {
-	router, mockRepo, _ := setupPostTestRouter()
+func TestPostHandler_CommentThreads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
 
-	// Create a test post
-	testPost := &models.Post{
-		ID:       uuid.New(),
-		UserID:   uuid.New(),
-		Caption:  "Test post",
-		ImageURL: "test.jpg",
+	author := &models.User{ID: uuid.New(), Username: "author"}
+	commenter := &models.User{ID: uuid.New(), Username: "commenter"}
+	other := &models.User{ID: uuid.New(), Username: "other"}
+	for _, user := range []*models.User{author, commenter, other} {
+		mockRepo.AddUser(user)
 	}
-	mockRepo.CreatePost(testPost)
+	post := &models.Post{ID: uuid.New(), UserID: author.ID, Caption: "Thread"}
+	otherPost := &models.Post{ID: uuid.New(), UserID: author.ID, Caption: "Other"}
+	mockRepo.CreatePost(post)
+	mockRepo.CreatePost(otherPost)
 
-	t.Run("get existing post", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/posts/"+testPost.ID.String(), nil)
+	var currentUserID uuid.UUID
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Next()
+	})
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.GET("/posts/:id/comments", postHandler.GetComments)
+	router.POST("/posts/:id/comments", postHandler.AddComment)
+	router.DELETE("/posts/:id/comments/:commentId", postHandler.DeleteComment)
+	router.GET("/posts/:id/comments/:commentId/replies", postHandler.GetCommentReplies)
+
+	do := func(as uuid.UUID, method, url string, body interface{}, response interface{}) *httptest.ResponseRecorder {
+		currentUserID = as
+		var req *http.Request
+		if body != nil {
+			jsonBody, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, url, bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, url, nil)
+		}
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
+		if response != nil {
+			json.Unmarshal(w.Body.Bytes(), response)
+		}
+		return w
+	}
+	comments := "/posts/" + post.ID.String() + "/comments"
+	reply := func(parentID *uuid.UUID) models.CommentView {
+		var view models.CommentView
+		w := do(commenter.ID, "POST", comments, CommentRequest{Content: "Reply", ParentID: parentID}, &view)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		return view
+	}
 
-		if w.Code != http.StatusOK {
-			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	root := reply(nil)
+	if root.User.Username != "commenter" || root.ParentID != nil {
+		t.Errorf("Expected a top-level comment view with its author, got %+v", root)
+	}
+	chain := []models.CommentView{root}
+	for i := 0; i < models.MaxCommentDepth; i++ {
+		chain = append(chain, reply(&chain[len(chain)-1].ID))
+	}
+
+	t.Run("reply errors", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			url        string
+			parentID   uuid.UUID
+			wantStatus int
+		}{
+			{"nested too deeply", comments, chain[len(chain)-1].ID, http.StatusBadRequest},
+			{"unknown parent", comments, uuid.New(), http.StatusNotFound},
+			{"parent on another post", "/posts/" + otherPost.ID.String() + "/comments", root.ID, http.StatusNotFound},
+			{"unknown post", "/posts/" + uuid.New().String() + "/comments", root.ID, http.StatusNotFound},
 		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := do(commenter.ID, "POST", tt.url, CommentRequest{Content: "Reply", ParentID: &tt.parentID}, nil)
+				if w.Code != tt.wantStatus {
+					t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+				}
+			})
+		}
+	})
 
-		var response models.Post
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		if err != nil {
-			t.Fatalf("Failed to unmarshal response: %v", err)
+	t.Run("list top-level comments and replies", func(t *testing.T) {
+		var top, replies CommentListResponse
+		do(other.ID, "GET", comments, nil, &top)
+		if len(top.Items) != 1 || top.Items[0].ID != root.ID || top.Items[0].ReplyCount != 1 {
+			t.Fatalf("Expected only the top-level comment with 1 reply, got %+v", top.Items)
 		}
+		if w := do(other.ID, "GET", comments+"/"+root.ID.String()+"/replies", nil, &replies); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(replies.Items) != 1 || replies.Items[0].ID != chain[1].ID || *replies.Items[0].ParentID != root.ID {
+			t.Errorf("Expected the direct reply, got %+v", replies.Items)
+		}
+		if w := do(other.ID, "GET", "/posts/"+otherPost.ID.String()+"/comments/"+root.ID.String()+"/replies", nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for a comment on another post, got %d", http.StatusNotFound, w.Code)
+		}
+	})
 
-		if response.ID != testPost.ID {
-			t.Errorf("Expected post ID %v, got %v", testPost.ID, response.ID)
+	t.Run("only the comment's or post's author can delete", func(t *testing.T) {
+		url := comments + "/" + chain[3].ID.String()
+		if w := do(other.ID, "DELETE", url, nil, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := do(author.ID, "DELETE", url, nil, nil); w.Code != http.StatusOK {
+			t.Errorf("Expected the post's author to delete the comment, got %d", w.Code)
+		}
+		if w := do(commenter.ID, "DELETE", url, nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for a deleted comment, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := do(commenter.ID, "DELETE", "/posts/"+otherPost.ID.String()+"/comments/"+root.ID.String(), nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for a comment on another post, got %d", http.StatusNotFound, w.Code)
 		}
 	})
 
-	t.Run("get non-existent post", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/posts/"+uuid.New().String(), nil)
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
+	t.Run("deleted comments with replies stay as tombstones", func(t *testing.T) {
+		if w := do(commenter.ID, "DELETE", comments+"/"+root.ID.String(), nil, nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var top CommentListResponse
+		do(other.ID, "GET", comments, nil, &top)
+		if len(top.Items) != 1 || !top.Items[0].Deleted || top.Items[0].Content != "" || top.Items[0].User.ID != uuid.Nil {
+			t.Fatalf("Expected a tombstone without author or content, got %+v", top.Items)
+		}
+		if w := do(commenter.ID, "POST", comments, CommentRequest{Content: "Reply", ParentID: &root.ID}, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected replying to a deleted comment to fail with %d, got %d", http.StatusNotFound, w.Code)
+		}
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		var view models.PostView
+		do(other.ID, "GET", "/posts/"+post.ID.String(), nil, &view)
+		if view.CommentCount != 2 || len(view.RecentComments) != 0 {
+			t.Errorf("Expected 2 live comments and no tombstones in the preview, got %d and %+v", view.CommentCount, view.RecentComments)
+		}
+
+		// Removing the last replies removes the tombstones above them
+		for _, comment := range []models.CommentView{chain[2], chain[1]} {
+			if w := do(commenter.ID, "DELETE", comments+"/"+comment.ID.String(), nil, nil); w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+		}
+		do(other.ID, "GET", comments, nil, &top)
+		if len(top.Items) != 0 {
+			t.Errorf("Expected the thread to be gone, got %+v", top.Items)
 		}
 	})
 }
@@ -302,8 +1809,8 @@ func TestPostHandler_GetPost(t *testing.T) {
 func TestPostHandler_LikeUnlike(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockRepo := NewMockPostRepository()
-	mockStorage := NewMockFileStorage()
-	postHandler := NewPostHandler(mockRepo, mockStorage)
+	mockStorage := NewMockImageStorage()
+	postHandler := NewPostHandler(mockRepo, mockStorage, mockRepo, testCursors, testMedia, testReactions)
 
 	testPost := &models.Post{
 		ID:       uuid.New(),
@@ -359,11 +1866,12 @@ func TestPostHandler_LikeUnlike(t *testing.T) {
 func TestPostHandler_FollowUnfollow(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockRepo := NewMockPostRepository()
-	mockStorage := NewMockFileStorage()
-	postHandler := NewPostHandler(mockRepo, mockStorage)
+	mockStorage := NewMockImageStorage()
+	postHandler := NewPostHandler(mockRepo, mockStorage, mockRepo, testCursors, testMedia, testReactions)
 
 	testUserID := uuid.New()
 	currentUserID := uuid.New()
+	mockRepo.AddUser(&models.User{ID: testUserID, Username: "followed"})
 
 	router := gin.New()
 	router.Use(func(c *gin.Context) {
@@ -390,6 +1898,31 @@ func TestPostHandler_FollowUnfollow(t *testing.T) {
 		}
 	})
 
+	t.Run("follow user twice", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users/"+testUserID.String()+"/follow", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		count, _ := mockRepo.GetFollowersCount(testUserID)
+		if count != 1 {
+			t.Errorf("Expected 1 follower, got %d", count)
+		}
+	})
+
+	t.Run("follow nonexistent user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users/"+uuid.New().String()+"/follow", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
 	t.Run("unfollow user", func(t *testing.T) {
 		req := httptest.NewRequest("DELETE", "/users/"+testUserID.String()+"/follow", nil)
 		w := httptest.NewRecorder()
@@ -406,3 +1939,104 @@ func TestPostHandler_FollowUnfollow(t *testing.T) {
 		}
 	})
 }
+
+func TestPostHandler_FollowLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	viewer := &models.User{ID: uuid.New(), Username: "viewer"}
+	target := &models.User{ID: uuid.New(), Username: "target"}
+	mockRepo.AddUser(viewer)
+	mockRepo.AddUser(target)
+
+	// Five followers of target; the viewer follows the first, the second follows the viewer
+	followers := make([]*models.User, 5)
+	for i := range followers {
+		followers[i] = &models.User{ID: uuid.New(), Username: fmt.Sprintf("follower%d", i)}
+		mockRepo.AddUser(followers[i])
+		mockRepo.FollowUser(followers[i].ID, target.ID)
+	}
+	mockRepo.FollowUser(viewer.ID, followers[0].ID)
+	mockRepo.FollowUser(followers[1].ID, viewer.ID)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", viewer.ID)
+		c.Next()
+	})
+	router.GET("/users/:id/followers", postHandler.GetFollowers)
+	router.GET("/users/:id/following", postHandler.GetFollowing)
+
+	get := func(url string) (*httptest.ResponseRecorder, UserListResponse) {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response UserListResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("paginate followers", func(t *testing.T) {
+		w, page1 := get("/users/" + target.ID.String() + "/followers?limit=3")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(page1.Items) != 3 || page1.NextCursor == "" {
+			t.Fatalf("Expected 3 items and a next cursor, got %d items, cursor %q", len(page1.Items), page1.NextCursor)
+		}
+
+		_, page2 := get("/users/" + target.ID.String() + "/followers?limit=3&cursor=" + page1.NextCursor)
+		if len(page2.Items) != 2 {
+			t.Fatalf("Expected 2 items on second page, got %d", len(page2.Items))
+		}
+		if page2.NextCursor != "" {
+			t.Error("Expected no next cursor on last page")
+		}
+
+		seen := make(map[uuid.UUID]models.UserSummary)
+		for _, item := range append(page1.Items, page2.Items...) {
+			if _, dup := seen[item.ID]; dup {
+				t.Errorf("User %s returned twice", item.Username)
+			}
+			seen[item.ID] = item
+		}
+		if len(seen) != 5 {
+			t.Errorf("Expected 5 distinct followers, got %d", len(seen))
+		}
+
+		if !seen[followers[0].ID].ViewerFollows {
+			t.Error("Expected viewer_follows for follower0")
+		}
+		if !seen[followers[1].ID].FollowsViewer {
+			t.Error("Expected follows_viewer for follower1")
+		}
+		if seen[followers[2].ID].ViewerFollows || seen[followers[2].ID].FollowsViewer {
+			t.Error("Expected no relationship flags for follower2")
+		}
+	})
+
+	t.Run("list following", func(t *testing.T) {
+		w, response := get("/users/" + followers[1].ID.String() + "/following")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(response.Items) != 2 {
+			t.Errorf("Expected 2 followed users, got %d", len(response.Items))
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		w, _ := get("/users/" + target.ID.String() + "/followers?cursor=not-a-cursor")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("nonexistent user", func(t *testing.T) {
+		w, _ := get("/users/" + uuid.New().String() + "/followers")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
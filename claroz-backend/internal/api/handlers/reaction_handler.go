@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// ReactionSetResponse lists the reactions users may add
+type ReactionSetResponse struct {
+	Emoji []string `json:"emoji" example:"heart,laugh,wow"`
+}
+
+// GetReactionSet godoc
+// @Summary List the available reactions
+// @Description List the names of the reactions users may add to posts and comments, in display order
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} ReactionSetResponse
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Router /reactions [get]
+func (h *PostHandler) GetReactionSet(c *gin.Context) {
+	c.JSON(http.StatusOK, ReactionSetResponse{Emoji: h.reactions})
+}
+
+// ReactToPost godoc
+// @Summary React to a post
+// @Description React to a post with an emoji. Reacting again with the same emoji has no effect;
+// @Description a user may react with several emoji. Reacting with "heart" likes the post.
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param emoji path string true "Reaction name" example(heart)
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID or unsupported reaction"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Blocked by or blocking the post's author"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/reactions/{emoji} [put]
+func (h *PostHandler) ReactToPost(c *gin.Context) {
+	postID, ok := h.viewablePostID(c)
+	if !ok {
+		return
+	}
+	h.addReaction(c, models.PostTarget(postID))
+}
+
+// RemovePostReaction godoc
+// @Summary Remove a reaction from a post
+// @Description Remove the current user's reaction with an emoji from a post, if there is one
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param emoji path string true "Reaction name" example(heart)
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/reactions/{emoji} [delete]
+func (h *PostHandler) RemovePostReaction(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+	h.removeReaction(c, models.PostTarget(postID))
+}
+
+// GetPostReactions godoc
+// @Summary Get a post's reactions
+// @Description List who reacted to a post and with what, newest first, with cursor pagination.
+// @Description Reactions by blocked users are excluded.
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param emoji query string false "Only list reactions with this emoji"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} ReactionListResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/reactions [get]
+func (h *PostHandler) GetPostReactions(c *gin.Context) {
+	postID, ok := h.viewablePostID(c)
+	if !ok {
+		return
+	}
+	h.listReactions(c, models.PostTarget(postID))
+}
+
+// ReactToComment godoc
+// @Summary React to a comment
+// @Description React to a comment with an emoji. Reacting again with the same emoji has no effect;
+// @Description a user may react with several emoji.
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Param emoji path string true "Reaction name" example(heart)
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid post or comment ID, or unsupported reaction"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Blocked by or blocking the comment's author"
+// @Failure 404 {object} object{error=string} "Post or comment not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments/{commentId}/reactions/{emoji} [put]
+func (h *PostHandler) ReactToComment(c *gin.Context) {
+	if _, ok := h.viewablePostID(c); !ok {
+		return
+	}
+	comment, ok := h.postComment(c)
+	if !ok {
+		return
+	}
+	h.addReaction(c, models.CommentTarget(comment.ID))
+}
+
+// RemoveCommentReaction godoc
+// @Summary Remove a reaction from a comment
+// @Description Remove the current user's reaction with an emoji from a comment, if there is one
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Param emoji path string true "Reaction name" example(heart)
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} object{error=string} "Invalid post or comment ID"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Comment not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments/{commentId}/reactions/{emoji} [delete]
+func (h *PostHandler) RemoveCommentReaction(c *gin.Context) {
+	comment, ok := h.postComment(c)
+	if !ok {
+		return
+	}
+	h.removeReaction(c, models.CommentTarget(comment.ID))
+}
+
+// GetCommentReactions godoc
+// @Summary Get a comment's reactions
+// @Description List who reacted to a comment and with what, newest first, with cursor pagination.
+// @Description Reactions by blocked users are excluded.
+// @Tags reactions
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Post ID"
+// @Param commentId path string true "Comment ID"
+// @Param emoji query string false "Only list reactions with this emoji"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} ReactionListResponse
+// @Failure 400 {object} object{error=string} "Invalid post ID, comment ID or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Post or comment not found"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/{id}/comments/{commentId}/reactions [get]
+func (h *PostHandler) GetCommentReactions(c *gin.Context) {
+	if _, ok := h.viewablePostID(c); !ok {
+		return
+	}
+	comment, ok := h.postComment(c)
+	if !ok {
+		return
+	}
+	h.listReactions(c, models.CommentTarget(comment.ID))
+}
+
+// addReaction adds the current user's reaction with the emoji path parameter to the target
+func (h *PostHandler) addReaction(c *gin.Context, target models.ReactionTarget) {
+	userID, _ := c.Get("userID")
+	emoji := c.Param("emoji")
+	if !h.isReaction(emoji) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported reaction"})
+		return
+	}
+
+	if err := h.postRepo.AddReaction(target, userID.(uuid.UUID), emoji); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot react to this " + target.Kind})
+		case errors.Is(err, repository.ErrPostNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		case errors.Is(err, repository.ErrCommentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add reaction"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "reaction added successfully"})
+}
+
+// removeReaction removes the current user's reaction with the emoji path
+// parameter from the target. Any emoji may be removed, so reactions outlive
+// changes to the configured set.
+func (h *PostHandler) removeReaction(c *gin.Context, target models.ReactionTarget) {
+	userID, _ := c.Get("userID")
+	if err := h.postRepo.RemoveReaction(target, userID.(uuid.UUID), c.Param("emoji")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove reaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "reaction removed successfully"})
+}
+
+// listReactions writes a page of the target's reactions, optionally filtered by the emoji query parameter
+func (h *PostHandler) listReactions(c *gin.Context, target models.ReactionTarget) {
+	viewerID, _ := c.Get("userID")
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	reactions, err := h.postRepo.GetReactions(target, viewerID.(uuid.UUID), c.Query("emoji"), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch reactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.reactionPage(reactions, limit))
+}
+
+// reactionPage returns a page of reactions in the list envelope, with a
+// cursor for the next page if the page is full
+func (h *PostHandler) reactionPage(reactions []models.Reaction, limit int) ReactionListResponse {
+	items := make([]models.ReactionView, len(reactions))
+	for i, reaction := range reactions {
+		items[i] = reaction.View()
+	}
+
+	return ReactionListResponse{
+		Items: items,
+		NextCursor: nextCursor(h.cursors, len(reactions), limit, func() repository.Cursor {
+			last := reactions[len(reactions)-1]
+			return repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}),
+	}
+}
+
+// isReaction reports whether emoji is one of the reactions users may add
+func (h *PostHandler) isReaction(emoji string) bool {
+	for _, allowed := range h.reactions {
+		if emoji == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestPostHandler_Reactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	author := &models.User{ID: uuid.New(), Username: "author"}
+	fan := &models.User{ID: uuid.New(), Username: "fan"}
+	blocked := &models.User{ID: uuid.New(), Username: "blocked"}
+	private := &models.User{ID: uuid.New(), Username: "private", IsPrivate: true}
+	for _, user := range []*models.User{author, fan, blocked, private} {
+		mockRepo.AddUser(user)
+	}
+	post := &models.Post{ID: uuid.New(), UserID: author.ID, Caption: "React to me"}
+	privatePost := &models.Post{ID: uuid.New(), UserID: private.ID, Caption: "Hidden"}
+	mockRepo.CreatePost(post)
+	mockRepo.CreatePost(privatePost)
+	comment := &models.Comment{PostID: post.ID, UserID: author.ID, Content: "First"}
+	mockRepo.AddComment(comment)
+	mockRepo.BlockUser(author.ID, blocked.ID)
+
+	var currentUserID uuid.UUID
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Next()
+	})
+	router.GET("/reactions", postHandler.GetReactionSet)
+	router.GET("/posts/:id", postHandler.GetPost)
+	router.POST("/posts/:id/like", postHandler.LikePost)
+	router.GET("/posts/:id/likes", postHandler.GetLikes)
+	router.GET("/posts/:id/comments", postHandler.GetComments)
+	router.GET("/posts/:id/reactions", postHandler.GetPostReactions)
+	router.PUT("/posts/:id/reactions/:emoji", postHandler.ReactToPost)
+	router.DELETE("/posts/:id/reactions/:emoji", postHandler.RemovePostReaction)
+	router.GET("/posts/:id/comments/:commentId/reactions", postHandler.GetCommentReactions)
+	router.PUT("/posts/:id/comments/:commentId/reactions/:emoji", postHandler.ReactToComment)
+	router.DELETE("/posts/:id/comments/:commentId/reactions/:emoji", postHandler.RemoveCommentReaction)
+
+	do := func(as uuid.UUID, method, url string, response interface{}) *httptest.ResponseRecorder {
+		currentUserID = as
+		req := httptest.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if response != nil {
+			json.Unmarshal(w.Body.Bytes(), response)
+		}
+		return w
+	}
+	postURL := "/posts/" + post.ID.String()
+	commentURL := postURL + "/comments/" + comment.ID.String()
+
+	t.Run("reaction set always includes hearts", func(t *testing.T) {
+		var response ReactionSetResponse
+		do(fan.ID, "GET", "/reactions", &response)
+		if len(response.Emoji) != 3 || response.Emoji[0] != models.HeartReaction {
+			t.Errorf("Expected heart followed by the configured reactions, got %v", response.Emoji)
+		}
+	})
+
+	t.Run("react to a post", func(t *testing.T) {
+		for _, emoji := range []string{"laugh", "laugh", models.HeartReaction} {
+			if w := do(fan.ID, "PUT", postURL+"/reactions/"+emoji, nil); w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+		}
+		do(author.ID, "PUT", postURL+"/reactions/laugh", nil)
+
+		var view models.PostView
+		do(fan.ID, "GET", postURL, &view)
+		if len(view.Reactions) != 2 || view.Reactions[0] != (models.ReactionCount{Emoji: "laugh", Count: 2}) {
+			t.Errorf("Expected 2 laughs and a heart, got %+v", view.Reactions)
+		}
+		if len(view.ViewerReactions) != 2 || !view.ViewerHasLiked || view.LikeCount != 1 {
+			t.Errorf("Expected the heart reaction to be a like, got %+v", view)
+		}
+
+		var likes, laughs ReactionListResponse
+		do(author.ID, "GET", postURL+"/likes", &likes)
+		if len(likes.Items) != 1 || likes.Items[0].User.ID != fan.ID || likes.Items[0].Emoji != models.HeartReaction {
+			t.Errorf("Expected the fan's heart in likes, got %+v", likes.Items)
+		}
+		do(author.ID, "GET", postURL+"/reactions?emoji=laugh&limit=1", &laughs)
+		if len(laughs.Items) != 1 || laughs.Items[0].User.ID != author.ID || laughs.NextCursor == "" {
+			t.Errorf("Expected the newest laugh and a next cursor, got %+v", laughs)
+		}
+	})
+
+	t.Run("likes and hearts are the same reaction", func(t *testing.T) {
+		if w := do(fan.ID, "POST", postURL+"/like", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected liking a hearted post to fail with %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		do(fan.ID, "DELETE", postURL+"/reactions/"+models.HeartReaction, nil)
+		if w := do(fan.ID, "POST", postURL+"/like", nil); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var view models.PostView
+		do(fan.ID, "GET", postURL, &view)
+		if view.LikeCount != 1 || len(view.ViewerReactions) != 2 {
+			t.Errorf("Expected the like to be a heart reaction, got %+v", view)
+		}
+	})
+
+	t.Run("react to a comment", func(t *testing.T) {
+		if w := do(fan.ID, "PUT", commentURL+"/reactions/clap", nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var comments CommentListResponse
+		do(fan.ID, "GET", postURL+"/comments", &comments)
+		if len(comments.Items) != 1 || len(comments.Items[0].Reactions) != 1 || comments.Items[0].ViewerReactions[0] != "clap" {
+			t.Errorf("Expected the comment's clap, got %+v", comments.Items)
+		}
+		var view models.PostView
+		do(author.ID, "GET", postURL, &view)
+		if len(view.RecentComments) != 1 || view.RecentComments[0].Reactions[0].Count != 1 || len(view.RecentComments[0].ViewerReactions) != 0 {
+			t.Errorf("Expected comment reactions in the preview, got %+v", view.RecentComments)
+		}
+
+		var reactions ReactionListResponse
+		do(author.ID, "GET", commentURL+"/reactions", &reactions)
+		if len(reactions.Items) != 1 || reactions.Items[0].User.ID != fan.ID {
+			t.Errorf("Expected the fan's clap, got %+v", reactions.Items)
+		}
+
+		do(fan.ID, "DELETE", commentURL+"/reactions/clap", nil)
+		do(author.ID, "GET", commentURL+"/reactions", &reactions)
+		if len(reactions.Items) != 0 {
+			t.Errorf("Expected the clap to be removed, got %+v", reactions.Items)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			as         uuid.UUID
+			method     string
+			url        string
+			wantStatus int
+		}{
+			{"unsupported reaction", fan.ID, "PUT", postURL + "/reactions/poop", http.StatusBadRequest},
+			{"blocked user", blocked.ID, "PUT", commentURL + "/reactions/clap", http.StatusNotFound},
+			{"private post", fan.ID, "PUT", "/posts/" + privatePost.ID.String() + "/reactions/clap", http.StatusNotFound},
+			{"unknown post", fan.ID, "PUT", "/posts/" + uuid.New().String() + "/reactions/clap", http.StatusNotFound},
+			{"unknown comment", fan.ID, "PUT", postURL + "/comments/" + uuid.New().String() + "/reactions/clap", http.StatusNotFound},
+			{"invalid post ID", fan.ID, "DELETE", "/posts/not-a-uuid/reactions/clap", http.StatusBadRequest},
+			{"invalid cursor", fan.ID, "GET", postURL + "/reactions?cursor=not-a-cursor", http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if w := do(tt.as, tt.method, tt.url, nil); w.Code != tt.wantStatus {
+					t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+const maxPostSearchQueryLength = 200
+
+// SearchPosts godoc
+// @Summary Search posts
+// @Description Full-text search over post captions, best match first. Supports quoted phrases,
+// @Description OR and -exclusions. Without lang, each caption is matched in its own language.
+// @Description The deprecated page and pageSize parameters return a bare array of posts for one release.
+// @Tags search
+// @Produce json
+// @Security Bearer
+// @Param q query string true "Search query" maxLength(200)
+// @Param lang query string false "Text search language, e.g. english; restricts results to posts in that language"
+// @Param author query string false "Only posts by this user ID"
+// @Param hashtag query string false "Only posts tagged with this hashtag"
+// @Param from query string false "Only posts created at or after this RFC 3339 time or YYYY-MM-DD date"
+// @Param to query string false "Only posts created before this RFC 3339 time, or on or before this YYYY-MM-DD date"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 50)" minimum(1) maximum(50)
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Param page query int false "Deprecated: page number" minimum(1)
+// @Param pageSize query int false "Deprecated: page size (default: 10, max: 50)" minimum(1) maximum(50)
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} object{error=string} "Invalid query, filter or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /search/posts [get]
+func (h *PostHandler) SearchPosts(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+
+	params := repository.PostSearchParams{
+		Query:    strings.TrimSpace(c.Query("q")),
+		Language: c.Query("lang"),
+	}
+	if params.Query == "" || utf8.RuneCountInString(params.Query) > maxPostSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "search query must be between 1 and 200 characters"})
+		return
+	}
+	if params.Language != "" && !utils.IsSearchLanguage(params.Language) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported search language"})
+		return
+	}
+
+	if author := c.Query("author"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid author ID"})
+			return
+		}
+		params.AuthorID = &authorID
+	}
+
+	if tag := c.Query("hashtag"); tag != "" {
+		normalized, ok := utils.NormalizeHashtag(tag)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hashtag"})
+			return
+		}
+		params.Hashtag = normalized
+	}
+
+	var err error
+	if params.From, err = parseSearchTime(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+		return
+	}
+	if params.To, err = parseSearchTime(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+		return
+	}
+
+	if offset, pageSize, ok := legacyPage(c, false); ok {
+		posts, err := h.postRepo.SearchPostsByOffset(viewerID.(uuid.UUID), params, offset, pageSize)
+		h.writeLegacyPosts(c, viewerID.(uuid.UUID), posts, err, "failed to search posts")
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxPostPageLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postRepo.SearchPosts(viewerID.(uuid.UUID), params, cursor, limit)
+	h.writePostPage(c, viewerID.(uuid.UUID), posts, err, limit, "failed to search posts")
+}
+
+// GetHashtagPosts godoc
+// @Summary Get posts with a hashtag
+// @Description Retrieve posts tagged with a hashtag, newest first, with cursor pagination. Posts by
+// @Description blocked and muted users are excluded. The deprecated page and pageSize parameters
+// @Description return a bare array of posts for one release.
+// @Tags search
+// @Produce json
+// @Security Bearer
+// @Param tag path string true "Hashtag, with or without the leading #"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 50)" minimum(1) maximum(50)
+// @Param expand query string false "Comma-separated relations to include in full: comments, likes"
+// @Param page query int false "Deprecated: page number" minimum(1)
+// @Param pageSize query int false "Deprecated: page size (default: 10, max: 50)" minimum(1) maximum(50)
+// @Success 200 {object} PostListResponse
+// @Failure 400 {object} object{error=string} "Invalid hashtag or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /hashtags/{tag}/posts [get]
+func (h *PostHandler) GetHashtagPosts(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+
+	tag, ok := utils.NormalizeHashtag(c.Param("tag"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hashtag"})
+		return
+	}
+
+	if offset, pageSize, ok := legacyPage(c, false); ok {
+		posts, err := h.postRepo.GetHashtagPostsByOffset(viewerID.(uuid.UUID), tag, offset, pageSize)
+		h.writeLegacyPosts(c, viewerID.(uuid.UUID), posts, err, "failed to fetch posts")
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxPostPageLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postRepo.GetHashtagPosts(viewerID.(uuid.UUID), tag, cursor, limit)
+	h.writePostPage(c, viewerID.(uuid.UUID), posts, err, limit, "failed to fetch posts")
+}
+
+// parseSearchTime parses an RFC 3339 time or a YYYY-MM-DD date. A date used
+// as an exclusive upper bound is moved to the end of that day so the day is included.
+func parseSearchTime(value string, upperBound bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if upperBound {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// postLanguage returns the caption language submitted with a new post, or
+// the default if none was given
+func postLanguage(c *gin.Context) (string, bool) {
+	language := c.PostForm("language")
+	if language == "" {
+		return models.DefaultPostLanguage, true
+	}
+	return language, utils.IsSearchLanguage(language)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestPostHandler_SearchPosts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := NewMockPostRepository()
+	postHandler := NewPostHandler(mockRepo, NewMockImageStorage(), mockRepo, testCursors, testMedia, testReactions)
+
+	viewer := &models.User{ID: uuid.New(), Username: "viewer"}
+	author := &models.User{ID: uuid.New(), Username: "author"}
+	blocker := &models.User{ID: uuid.New(), Username: "blocker"}
+	for _, user := range []*models.User{viewer, author, blocker} {
+		mockRepo.AddUser(user)
+	}
+
+	now := time.Now()
+	posts := []*models.Post{
+		{ID: uuid.New(), UserID: author.ID, Caption: "Sunset at the beach #Travel", Language: "english", CreatedAt: now.AddDate(0, 0, -10)},
+		{ID: uuid.New(), UserID: author.ID, Caption: "Another sunset #food", Language: "simple", CreatedAt: now},
+		{ID: uuid.New(), UserID: viewer.ID, Caption: "My sunset #travel", Language: "simple", CreatedAt: now},
+		{ID: uuid.New(), UserID: blocker.ID, Caption: "Hidden sunset #travel", Language: "simple", CreatedAt: now},
+	}
+	for _, post := range posts {
+		mockRepo.posts[post.ID] = post
+	}
+	mockRepo.BlockUser(blocker.ID, viewer.ID)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", viewer.ID)
+		c.Next()
+	})
+	router.GET("/search/posts", postHandler.SearchPosts)
+	router.GET("/hashtags/:tag/posts", postHandler.GetHashtagPosts)
+
+	get := func(url string) (*httptest.ResponseRecorder, []models.PostView) {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response PostListResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Items
+	}
+
+	t.Run("search with filters", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			url       string
+			wantCount int
+		}{
+			{"query only", "/search/posts?q=sunset", 3},
+			{"language", "/search/posts?q=sunset&lang=english", 1},
+			{"author", "/search/posts?q=sunset&author=" + author.ID.String(), 2},
+			{"hashtag", "/search/posts?q=sunset&hashtag=%23Travel", 2},
+			{"date range", "/search/posts?q=sunset&from=" + now.AddDate(0, 0, -1).Format("2006-01-02"), 2},
+			{"date-only upper bound includes the day", "/search/posts?q=sunset&to=" + now.AddDate(0, 0, -10).Format("2006-01-02"), 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w, response := get(tt.url)
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+				}
+				if len(response) != tt.wantCount {
+					t.Errorf("Expected %d posts, got %d", tt.wantCount, len(response))
+				}
+			})
+		}
+	})
+
+	t.Run("invalid search input", func(t *testing.T) {
+		tests := []struct {
+			name string
+			url  string
+		}{
+			{"missing query", "/search/posts"},
+			{"unsupported language", "/search/posts?q=sunset&lang=klingon"},
+			{"invalid author", "/search/posts?q=sunset&author=invalid-uuid"},
+			{"invalid hashtag", "/search/posts?q=sunset&hashtag=two%20words"},
+			{"invalid date", "/search/posts?q=sunset&from=yesterday"},
+			{"tampered cursor", "/search/posts?q=sunset&cursor=eyJ0IjoiMjAyNC0wMS0yNlQwMDozNToyN1oifQ.c2lnbmF0dXJl"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if w, _ := get(tt.url); w.Code != http.StatusBadRequest {
+					t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+				}
+			})
+		}
+	})
+
+	t.Run("hashtag feed", func(t *testing.T) {
+		w, response := get("/hashtags/%23TRAVEL/posts")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(response) != 2 {
+			t.Errorf("Expected 2 visible posts tagged travel, got %d", len(response))
+		}
+
+		if w, _ := get("/hashtags/123/posts"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for invalid hashtag, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("deprecated page parameters", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/search/posts?q=sunset&page=2&pageSize=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get("Deprecation") != "true" {
+			t.Error("Expected a Deprecation header for page parameters")
+		}
+
+		var posts []models.PostView
+		if err := json.Unmarshal(w.Body.Bytes(), &posts); err != nil {
+			t.Fatalf("Expected a bare array of posts: %v", err)
+		}
+		if len(posts) != 1 {
+			t.Errorf("Expected 1 post on the second page, got %d", len(posts))
+		}
+	})
+}
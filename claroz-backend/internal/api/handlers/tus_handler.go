@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/tus"
+)
+
+// TusHandler accepts images uploaded with the tus resumable upload protocol,
+// for clients on connections too unreliable to send an image in one request.
+// Completed uploads become pending media, attached like those from
+// UploadImage; the Media-Id header names them.
+type TusHandler struct {
+	uploads tus.ServiceInterface
+}
+
+func NewTusHandler(uploads tus.ServiceInterface) *TusHandler {
+	return &TusHandler{uploads: uploads}
+}
+
+// GetOptions godoc
+// @Summary Describe resumable uploads
+// @Description List the tus versions and extensions supported, and the largest upload accepted
+// @Tags media
+// @Success 204 "Tus-Version, Tus-Extension and Tus-Max-Size headers"
+// @Router /upload/tus [options]
+func (h *TusHandler) GetOptions(c *gin.Context) {
+	c.Header("Tus-Version", tus.Version)
+	c.Header("Tus-Extension", tus.Extensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.uploads.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+// @Summary Start a resumable upload
+// @Description Start a tus upload of Upload-Length bytes, or of a length sent with a later part
+// @Description if Upload-Defer-Length is 1. Unfinished uploads expire at Upload-Expires.
+// @Tags media
+// @Security Bearer
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Length header int false "Upload size in bytes"
+// @Param Upload-Defer-Length header int false "1 to declare the size later"
+// @Param Upload-Metadata header string false "Comma-separated keys and base64 values"
+// @Success 201 "Location header with the upload URL"
+// @Failure 400 {object} object{error=string} "Invalid headers"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 412 {object} object{error=string} "Unsupported tus version"
+// @Failure 413 {object} object{error=string} "Upload too large or over quota"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/tus [post]
+func (h *TusHandler) CreateUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	length, err := parseUploadLength(c.GetHeader("Upload-Length"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Length"})
+		return
+	}
+	deferred := c.GetHeader("Upload-Defer-Length")
+	if (length == nil) == (deferred == "") || deferred != "" && deferred != "1" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either Upload-Length or Upload-Defer-Length: 1 is required"})
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Metadata"})
+		return
+	}
+
+	upload, err := h.uploads.Create(userID.(uuid.UUID), length, metadata)
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID.String())
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset godoc
+// @Summary Get a resumable upload's offset
+// @Description Get how many bytes of a tus upload were received, to resume it from there.
+// @Description Completed uploads name the media they became with Media-Id.
+// @Tags media
+// @Security Bearer
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param id path string true "Upload ID"
+// @Success 200 "Upload-Offset and Upload-Length or Upload-Defer-Length headers"
+// @Failure 400 {object} object{error=string} "Completed upload is not a supported image"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Upload not found or expired"
+// @Failure 412 {object} object{error=string} "Unsupported tus version"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/tus/{id} [head]
+func (h *TusHandler) GetUploadOffset(c *gin.Context) {
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	writeUploadHeaders(c, upload)
+	if upload.Length != nil {
+		c.Header("Upload-Length", strconv.FormatInt(*upload.Length, 10))
+	} else {
+		c.Header("Upload-Defer-Length", "1")
+	}
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", encodeUploadMetadata(upload.Metadata))
+	}
+	c.Status(http.StatusOK)
+}
+
+// WriteUpload godoc
+// @Summary Continue a resumable upload
+// @Description Append the request body to a tus upload at Upload-Offset. If the request is
+// @Description interrupted, the bytes received are kept. Once every byte is received the upload
+// @Description becomes pending media, named by Media-Id.
+// @Tags media
+// @Accept application/offset+octet-stream
+// @Security Bearer
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Offset header int true "Offset the body starts at"
+// @Param Upload-Length header int false "Upload size in bytes, if it was deferred"
+// @Param id path string true "Upload ID"
+// @Success 204 "Upload-Offset header with the new offset"
+// @Failure 400 {object} object{error=string} "Invalid headers, or completed upload is not a supported image"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Upload not found or expired"
+// @Failure 409 {object} object{error=string} "Upload is not at Upload-Offset"
+// @Failure 412 {object} object{error=string} "Unsupported tus version"
+// @Failure 413 {object} object{error=string} "Upload too large or over quota"
+// @Failure 415 {object} object{error=string} "Wrong content type"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/tus/{id} [patch]
+func (h *TusHandler) WriteUpload(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Offset"})
+		return
+	}
+	length, err := parseUploadLength(c.GetHeader("Upload-Length"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Length"})
+		return
+	}
+
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+	upload, err = h.uploads.Write(c.Request.Context(), upload, offset, length, c.Request.Body)
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+
+	writeUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// TerminateUpload godoc
+// @Summary Terminate a resumable upload
+// @Description Delete a tus upload and the bytes received. Media a completed upload became are kept.
+// @Tags media
+// @Security Bearer
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param id path string true "Upload ID"
+// @Success 204
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 404 {object} object{error=string} "Upload not found or expired"
+// @Failure 412 {object} object{error=string} "Unsupported tus version"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /upload/tus/{id} [delete]
+func (h *TusHandler) TerminateUpload(c *gin.Context) {
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+	if err := h.uploads.Terminate(upload); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getUpload returns the current user's upload named by the path. It writes
+// an error response and returns false if there is none.
+func (h *TusHandler) getUpload(c *gin.Context) (*models.ResumableUpload, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+
+	// Unknown IDs are not found, as tus clients expect, rather than invalid
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return nil, false
+	}
+
+	upload, err := h.uploads.Get(id, userID.(uuid.UUID))
+	if err != nil {
+		writeUploadError(c, err)
+		return nil, false
+	}
+	return upload, true
+}
+
+// writeUploadHeaders sets the headers describing an upload's progress
+func writeUploadHeaders(c *gin.Context, upload *models.ResumableUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.MediaID != nil {
+		c.Header("Media-Id", upload.MediaID.String())
+	}
+}
+
+// writeUploadError writes the response for a resumable upload that could not
+// be created, read or written
+func writeUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+	case errors.Is(err, tus.ErrOffsetMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": "upload offset does not match"})
+	case errors.Is(err, tus.ErrLengthMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload length does not match"})
+	case errors.Is(err, tus.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload is too large"})
+	case errors.Is(err, repository.ErrUploadQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload quota exceeded"})
+	default:
+		writeImageError(c, "upload", err)
+	}
+}
+
+// parseUploadLength parses an optional Upload-Length header
+func parseUploadLength(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	length, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || length < 0 {
+		return nil, errors.New("invalid length")
+	}
+	return &length, nil
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// pairs of a key and a base64 value, which may be omitted
+func parseUploadMetadata(value string) (models.UploadMetadata, error) {
+	metadata := models.UploadMetadata{}
+	if strings.TrimSpace(value) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("invalid metadata pair")
+		}
+		if _, ok := metadata[fields[0]]; ok {
+			return nil, errors.New("duplicate metadata key")
+		}
+		var decoded []byte
+		if len(fields) == 2 {
+			var err error
+			if decoded, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+				return nil, err
+			}
+		}
+		metadata[fields[0]] = string(decoded)
+	}
+	return metadata, nil
+}
+
+// encodeUploadMetadata encodes metadata as an Upload-Metadata header
+func encodeUploadMetadata(metadata models.UploadMetadata) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+		} else {
+			pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/tus"
+)
+
+// MockTusService implements tus.ServiceInterface for testing, keeping
+// uploads in memory and completing them as media without processing
+type MockTusService struct {
+	uploads map[uuid.UUID]*models.ResumableUpload
+}
+
+func NewMockTusService() *MockTusService {
+	return &MockTusService{uploads: make(map[uuid.UUID]*models.ResumableUpload)}
+}
+
+func (m *MockTusService) MaxSize() int64 {
+	return 100
+}
+
+func (m *MockTusService) Create(userID uuid.UUID, length *int64, metadata models.UploadMetadata) (*models.ResumableUpload, error) {
+	if length != nil && *length > m.MaxSize() {
+		return nil, tus.ErrTooLarge
+	}
+	upload := &models.ResumableUpload{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	m.uploads[upload.ID] = upload
+	return upload, nil
+}
+
+func (m *MockTusService) Get(id, userID uuid.UUID) (*models.ResumableUpload, error) {
+	upload, exists := m.uploads[id]
+	if !exists || upload.UserID != userID {
+		return nil, repository.ErrUploadNotFound
+	}
+	found := *upload
+	return &found, nil
+}
+
+func (m *MockTusService) Write(ctx context.Context, upload *models.ResumableUpload, offset int64, length *int64, body io.Reader) (*models.ResumableUpload, error) {
+	if offset != upload.Offset {
+		return nil, tus.ErrOffsetMismatch
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	stored := m.uploads[upload.ID]
+	if stored.Length == nil {
+		stored.Length = length
+	}
+	if stored.Length != nil && stored.Offset+int64(len(data)) > *stored.Length {
+		return nil, tus.ErrTooLarge
+	}
+	stored.Offset += int64(len(data))
+	if stored.Complete() {
+		mediaID := uuid.New()
+		stored.MediaID = &mediaID
+	}
+	found := *stored
+	return &found, nil
+}
+
+func (m *MockTusService) Terminate(upload *models.ResumableUpload) error {
+	delete(m.uploads, upload.ID)
+	return nil
+}
+
+func setupTusTestRouter(userID uuid.UUID) (*gin.Engine, *MockTusService) {
+	gin.SetMode(gin.TestMode)
+	uploads := NewMockTusService()
+	handler := NewTusHandler(uploads)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	})
+	router.OPTIONS("/upload/tus", handler.GetOptions)
+	router.POST("/upload/tus", handler.CreateUpload)
+	router.HEAD("/upload/tus/:id", handler.GetUploadOffset)
+	router.PATCH("/upload/tus/:id", handler.WriteUpload)
+	router.DELETE("/upload/tus/:id", handler.TerminateUpload)
+	return router, uploads
+}
+
+// tusRequest sends a tus request with the given headers
+func tusRequest(router *gin.Engine, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTusHandler_GetOptions(t *testing.T) {
+	router, _ := setupTusTestRouter(uuid.New())
+
+	w := tusRequest(router, "OPTIONS", "/upload/tus", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Tus-Version"); got != "1.0.0" {
+		t.Errorf("Expected Tus-Version 1.0.0, got %q", got)
+	}
+	if got := w.Header().Get("Tus-Extension"); !strings.Contains(got, "termination") || !strings.Contains(got, "creation-defer-length") {
+		t.Errorf("Expected the supported extensions, got %q", got)
+	}
+	if got := w.Header().Get("Tus-Max-Size"); got != "100" {
+		t.Errorf("Expected Tus-Max-Size 100, got %q", got)
+	}
+}
+
+func TestTusHandler_CreateUpload(t *testing.T) {
+	userID := uuid.New()
+	router, uploads := setupTusTestRouter(userID)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"with length", map[string]string{"Upload-Length": "10"}, http.StatusCreated},
+		{"with deferred length", map[string]string{"Upload-Defer-Length": "1"}, http.StatusCreated},
+		{"missing length", map[string]string{}, http.StatusBadRequest},
+		{"both lengths", map[string]string{"Upload-Length": "10", "Upload-Defer-Length": "1"}, http.StatusBadRequest},
+		{"invalid deferred length", map[string]string{"Upload-Defer-Length": "0"}, http.StatusBadRequest},
+		{"negative length", map[string]string{"Upload-Length": "-1"}, http.StatusBadRequest},
+		{"invalid metadata", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename not-base64!"}, http.StatusBadRequest},
+		{"too large", map[string]string{"Upload-Length": "101"}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tusRequest(router, "POST", "/upload/tus", "", tt.headers)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			location := w.Header().Get("Location")
+			id, err := uuid.Parse(strings.TrimPrefix(location, "/upload/tus/"))
+			if err != nil || uploads.uploads[id] == nil {
+				t.Errorf("Expected Location to name the upload, got %q", location)
+			}
+			if _, err := http.ParseTime(w.Header().Get("Upload-Expires")); err != nil {
+				t.Errorf("Expected an Upload-Expires date, got %q", w.Header().Get("Upload-Expires"))
+			}
+		})
+	}
+
+	t.Run("metadata is decoded", func(t *testing.T) {
+		w := tusRequest(router, "POST", "/upload/tus", "", map[string]string{
+			"Upload-Length":   "10",
+			"Upload-Metadata": "filename cGhvdG8uanBn, is_private",
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+		}
+		id := uuid.MustParse(strings.TrimPrefix(w.Header().Get("Location"), "/upload/tus/"))
+		metadata := uploads.uploads[id].Metadata
+		if metadata["filename"] != "photo.jpg" || len(metadata) != 2 {
+			t.Errorf("Expected decoded metadata, got %v", metadata)
+		}
+
+		w = tusRequest(router, "HEAD", "/upload/tus/"+id.String(), "", nil)
+		if got := w.Header().Get("Upload-Metadata"); got != "filename cGhvdG8uanBn,is_private" {
+			t.Errorf("Expected the metadata to be returned, got %q", got)
+		}
+	})
+}
+
+func TestTusHandler_WriteUpload(t *testing.T) {
+	userID := uuid.New()
+	router, _ := setupTusTestRouter(userID)
+
+	w := tusRequest(router, "POST", "/upload/tus", "", map[string]string{"Upload-Defer-Length": "1"})
+	path := w.Header().Get("Location")
+	patch := func(offset, length, body string) *httptest.ResponseRecorder {
+		headers := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+		if length != "" {
+			headers["Upload-Length"] = length
+		}
+		return tusRequest(router, "PATCH", path, body, headers)
+	}
+
+	w = tusRequest(router, "HEAD", path, "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Defer-Length") != "1" {
+		t.Fatalf("Expected an empty upload of deferred length, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected offsets not to be cached, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	if w = tusRequest(router, "PATCH", path, "abc", map[string]string{"Content-Type": "application/octet-stream", "Upload-Offset": "0"}); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status code %d for the wrong content type, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+	if w = patch("", "", "abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d without an offset, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w = patch("0", "", "abc"); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "3" {
+		t.Fatalf("Expected the offset to advance to 3, got %d %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if w.Header().Get("Media-Id") != "" {
+		t.Errorf("Expected no media before the upload completes, got %q", w.Header().Get("Media-Id"))
+	}
+	if w = patch("0", "", "abc"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for a stale offset, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = patch("3", "5", "de")
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("Expected the upload to complete, got %d %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if _, err := uuid.Parse(w.Header().Get("Media-Id")); err != nil {
+		t.Errorf("Expected the media to be named, got %q", w.Header().Get("Media-Id"))
+	}
+
+	w = tusRequest(router, "HEAD", path, "", nil)
+	if w.Header().Get("Upload-Length") != "5" || w.Header().Get("Media-Id") == "" {
+		t.Errorf("Expected the completed upload to be described, got %v", w.Header())
+	}
+}
+
+func TestTusHandler_TerminateUpload(t *testing.T) {
+	userID := uuid.New()
+	router, uploads := setupTusTestRouter(userID)
+
+	w := tusRequest(router, "POST", "/upload/tus", "", map[string]string{"Upload-Length": "10"})
+	path := w.Header().Get("Location")
+
+	// Other users' uploads are not found
+	other, _ := uploads.Create(uuid.New(), nil, nil)
+	if w = tusRequest(router, "DELETE", "/upload/tus/"+other.ID.String(), "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w = tusRequest(router, "HEAD", "/upload/tus/not-a-uuid", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for an invalid ID, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w = tusRequest(router, "DELETE", path, "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w = tusRequest(router, "HEAD", path, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the terminated upload not to be found, got %d", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// UploadHandler serves uploads kept in a private bucket, which clients cannot
+// fetch from directly
+type UploadHandler struct {
+	storage utils.FileStorageInterface
+}
+
+func NewUploadHandler(storage utils.FileStorageInterface) *UploadHandler {
+	return &UploadHandler{storage: storage}
+}
+
+// GetUpload godoc
+// @Summary Fetch an uploaded file
+// @Description Redirect to a short-lived presigned URL for a file in private object storage
+// @Tags uploads
+// @Param filepath path string true "File name"
+// @Success 302 "Redirect to the file"
+// @Failure 404 {object} object{error=string} "Invalid file name"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /uploads/{filepath} [get]
+func (h *UploadHandler) GetUpload(c *gin.Context) {
+	key, err := utils.KeyOf(c.Param("filepath"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	url, err := h.storage.URL(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign upload URL"})
+		return
+	}
+
+	// Let clients reuse the redirect for a while, but not past the URL expiring
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Redirect(http.StatusFound, url)
+}
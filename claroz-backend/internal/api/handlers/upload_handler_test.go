@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// MockFileStorage implements necessary methods for testing, presigning URLs
+// for any key except failOn
+type MockFileStorage struct {
+	failOn string
+}
+
+func (m *MockFileStorage) Save(ctx context.Context, r io.Reader, meta utils.ObjectMeta) (utils.ObjectRef, error) {
+	return utils.ObjectRef{}, fmt.Errorf("not implemented")
+}
+
+func (m *MockFileStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, utils.ErrObjectNotFound
+}
+
+func (m *MockFileStorage) Stat(ctx context.Context, key string) (utils.ObjectRef, error) {
+	return utils.ObjectRef{}, utils.ErrObjectNotFound
+}
+
+func (m *MockFileStorage) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (m *MockFileStorage) URL(ctx context.Context, key string) (string, error) {
+	if key == m.failOn {
+		return "", fmt.Errorf("failed to sign %s", key)
+	}
+	return "https://bucket.example/" + key + "?X-Amz-Signature=test", nil
+}
+
+func TestUploadHandler_GetUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewUploadHandler(&MockFileStorage{failOn: "broken.jpg"})
+
+	router := gin.New()
+	router.GET("/uploads/*filepath", handler.GetUpload)
+
+	t.Run("redirects to a presigned URL", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/uploads/photo-full.jpg", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected status code %d, got %d", http.StatusFound, w.Code)
+		}
+		want := "https://bucket.example/photo-full.jpg?X-Amz-Signature=test"
+		if got := w.Header().Get("Location"); got != want {
+			t.Errorf("Expected redirect to %s, got %s", want, got)
+		}
+	})
+
+	t.Run("invalid file name", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/uploads/", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("signing failure", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/uploads/broken.jpg", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
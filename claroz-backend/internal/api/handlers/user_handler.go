@@ -1,20 +1,55 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/mediagc"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
 )
 
 type UserHandler struct {
-	userRepo *repository.UserRepository
+	userRepo  repository.UserRepositoryInterface
+	postRepo  repository.PostRepositoryInterface
+	mediaRepo repository.MediaRepositoryInterface
+	images    utils.ProfileImageStorageInterface
+	collector mediagc.CollectorInterface
+	cursors   *utils.CursorCodec
 }
 
-func NewUserHandler(userRepo *repository.UserRepository) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+// UserProfileResponse is a user with their follower, following and post counts
+type UserProfileResponse struct {
+	models.User
+	FollowersCount int64 `json:"followers_count" example:"120"`
+	FollowingCount int64 `json:"following_count" example:"87"`
+	PostsCount     int64 `json:"posts_count" example:"42"`
+}
+
+// UpdateUserRequest is a user's updated details, with an uploaded image to
+// make their avatar
+type UpdateUserRequest struct {
+	models.User
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+func NewUserHandler(userRepo repository.UserRepositoryInterface, postRepo repository.PostRepositoryInterface, mediaRepo repository.MediaRepositoryInterface, images utils.ProfileImageStorageInterface, collector mediagc.CollectorInterface, cursors *utils.CursorCodec) *UserHandler {
+	return &UserHandler{
+		userRepo:  userRepo,
+		postRepo:  postRepo,
+		mediaRepo: mediaRepo,
+		images:    images,
+		collector: collector,
+		cursors:   cursors,
+	}
 }
 
 // CreateUser godoc
@@ -45,12 +80,12 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 // GetUser godoc
 // @Summary Get user by ID
-// @Description Retrieves a user by their UUID
+// @Description Retrieves a user by their UUID, including follower, following and post counts
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID (UUID)"
-// @Success 200 {object} models.User
+// @Success 200 {object} UserProfileResponse
 // @Failure 400 {object} object{error=string} "Invalid user ID"
 // @Failure 404 {object} object{error=string} "User not found"
 // @Router /users/{id} [get]
@@ -67,22 +102,41 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	response := UserProfileResponse{User: *user}
+	if response.FollowersCount, err = h.postRepo.GetFollowersCount(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user counts"})
+		return
+	}
+	if response.FollowingCount, err = h.postRepo.GetFollowingCount(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user counts"})
+		return
+	}
+	if response.PostsCount, err = h.postRepo.GetUserPostsCount(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateUser godoc
 // @Summary Update user details
-// @Description Update an existing user's information
+// @Description Update an existing user's information. An image uploaded beforehand becomes the
+// @Description avatar, cropped to square variants, when its ID is sent as avatar_media_id, replacing
+// @Description any avatar URL sent. Local users cannot set their avatar to a URL, only clear it;
+// @Description banners are only uploaded.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Param id path string true "User ID (UUID)"
-// @Param user body models.User true "Updated user details"
+// @Param user body UpdateUserRequest true "Updated user details"
 // @Success 200 {object} models.User
-// @Failure 400 {object} object{error=string} "Invalid input or user ID"
+// @Failure 400 {object} object{error=string} "Invalid input or user ID, avatar URL for a local user, uploaded media not found or expired, or not a supported image"
 // @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 403 {object} object{error=string} "Avatar set for another user"
 // @Failure 404 {object} object{error=string} "User not found"
+// @Failure 409 {object} object{error=string} "Uploaded media already attached"
 // @Failure 500 {object} object{error=string} "Server error"
 // @Router /users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
@@ -98,16 +152,161 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(user); err != nil {
+	// Role, status and federation type are managed by the server and must not
+	// be set through profile updates, and uploaded profile images are only set
+	// by uploading them
+	role, status, federationType := user.Role, user.Status, user.FederationType
+	avatar, avatarVariants, banner, bannerVariants := user.Avatar, user.AvatarVariants, user.Banner, user.BannerVariants
+	var request UpdateUserRequest
+	if err := c.ShouldBindBodyWith(user, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user.Role, user.Status, user.FederationType = role, status, federationType
+	user.AvatarVariants, user.Banner, user.BannerVariants = avatarVariants, banner, bannerVariants
+
+	// Local users' avatars are uploaded, so they cannot point anywhere else
+	if user.Avatar != avatar && user.Avatar != "" && federationType != "remote" && request.AvatarMediaID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar must be uploaded"})
+		return
+	}
+
+	// Uploaded media can only become the uploader's own avatar, cropped like
+	// avatars uploaded directly
+	var released *repository.ReleasedBlobs
+	if request.AvatarMediaID != nil {
+		userID, _ := c.Get("userID")
+		if userID != id {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot set another user's avatar"})
+			return
+		}
+		var updated *models.User
+		updated, released, err = h.setAvatarFromMedia(c, id, *request.AvatarMediaID)
+		if err != nil {
+			return
+		}
+		user.Avatar, user.AvatarVariants = updated.Avatar, updated.AvatarVariants
+	}
 
 	if err := h.userRepo.Update(user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	// Delete the replaced avatar's files like uploads to /users/me/avatar do
+	if released != nil && len(released.Keys) > 0 {
+		h.collector.DeleteReleased(released.Keys, released.At)
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// setAvatarFromMedia crops a user's pending media to avatar variants and
+// makes them the user's avatar, writing the response if it fails
+func (h *UserHandler) setAvatarFromMedia(c *gin.Context, userID, mediaID uuid.UUID) (*models.User, *repository.ReleasedBlobs, error) {
+	media, err := h.mediaRepo.GetMedia(mediaID, userID)
+	if err == nil && media.Status != models.MediaPending {
+		err = repository.ErrMediaAttached
+	} else if err == nil && media.ExpiresAt.Before(time.Now()) {
+		err = repository.ErrMediaNotFound
+	}
+	if err != nil {
+		writeAttachError(c, err)
+		return nil, nil, err
+	}
+
+	stored, err := h.images.SaveStoredAvatar(c.Request.Context(), media.URL)
+	if err != nil {
+		writeImageError(c, models.ProfileAvatar, err)
+		return nil, nil, err
+	}
+	user, released, err := h.userRepo.SetAvatar(userID, mediaID, stored.Variants)
+	if err != nil {
+		writeAttachError(c, err)
+		return nil, nil, err
+	}
+	return user, released, nil
+}
+
+// UploadAvatar godoc
+// @Summary Upload an avatar
+// @Description Make an image the current user's avatar, cropped to square variants. The files
+// @Description of the avatar it replaces are deleted unless other images share them.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param image formData file true "Image file"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string} "Missing, unsupported or too large image"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/me/avatar [put]
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	h.uploadProfileImage(c, models.ProfileAvatar, h.images.SaveAvatar)
+}
+
+// UploadBanner godoc
+// @Summary Upload a profile banner
+// @Description Make an image the current user's profile banner, cropped to wide variants. The
+// @Description files of the banner it replaces are deleted unless other images share them.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param image formData file true "Image file"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string} "Missing, unsupported or too large image"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/me/banner [put]
+func (h *UserHandler) UploadBanner(c *gin.Context) {
+	h.uploadProfileImage(c, models.ProfileBanner, h.images.SaveBanner)
+}
+
+// uploadProfileImage stores the uploaded image with save and makes it the
+// current user's profile image of the given kind
+func (h *UserHandler) uploadProfileImage(c *gin.Context, kind string, save func(context.Context, io.Reader) (*utils.StoredImage, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+	defer src.Close()
+
+	stored, err := save(c.Request.Context(), src)
+	if err != nil {
+		writeImageError(c, kind, err)
+		return
+	}
+
+	user, released, err := h.userRepo.SetProfileImage(userID.(uuid.UUID), kind, stored.Variants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// Delete the replaced image's files now rather than after the collector's
+	// grace period; those other images share are kept
+	if len(released.Keys) > 0 {
+		h.collector.DeleteReleased(released.Keys, released.At)
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -165,3 +364,60 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+// SearchUsers godoc
+// @Summary Search users
+// @Description Search users by username, full name, handle and bio. Exact username or handle
+// @Description matches rank first, followed by accounts the current user follows.
+// @Tags users
+// @Produce json
+// @Security Bearer
+// @Param q query string true "Search query" maxLength(100)
+// @Param federation_type query string false "Federation type to include (default: local)" Enums(local, remote, all)
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} object{error=string} "Invalid query, federation type or cursor"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /users/search [get]
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	viewerID, _ := c.Get("userID")
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must be between 1 and 100 characters"})
+		return
+	}
+
+	federationType := c.DefaultQuery("federation_type", "local")
+	switch federationType {
+	case "local", "remote":
+	case "all":
+		federationType = ""
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid federation type"})
+		return
+	}
+
+	cursor, limit, ok := cursorPage(c, h.cursors, maxUserPageLimit)
+	if !ok {
+		return
+	}
+
+	users, err := h.userRepo.SearchUsers(viewerID.(uuid.UUID), query, federationType, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserListResponse{
+		Items: users,
+		NextCursor: nextCursor(h.cursors, len(users), limit, func() repository.Cursor {
+			last := users[len(users)-1]
+			return repository.Cursor{Rank: last.SearchRank, ID: last.ID}
+		}),
+	})
+}
+
+const maxSearchQueryLength = 100
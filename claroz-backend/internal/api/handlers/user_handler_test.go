@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+func TestUserHandler_GetUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userRepo := NewMockUserRepository()
+	postRepo := NewMockPostRepository()
+	handler := NewUserHandler(userRepo, postRepo, nil, nil, nil, testCursors)
+
+	router := gin.New()
+	router.GET("/users/:id", handler.GetUser)
+
+	user := &models.User{ID: uuid.New(), Username: "profile", Email: "profile@example.com"}
+	other := &models.User{ID: uuid.New(), Username: "other", Email: "other@example.com"}
+	userRepo.Create(user)
+	postRepo.AddUser(user)
+	postRepo.AddUser(other)
+
+	postRepo.FollowUser(other.ID, user.ID)
+	postRepo.FollowUser(user.ID, other.ID)
+	for i := 0; i < 2; i++ {
+		postRepo.CreatePost(&models.Post{UserID: user.ID, ImageURL: "test.jpg"})
+	}
+
+	t.Run("profile includes counts", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/"+user.ID.String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var response UserProfileResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Username != user.Username {
+			t.Errorf("Expected username %s, got %s", user.Username, response.Username)
+		}
+		if response.FollowersCount != 1 || response.FollowingCount != 1 {
+			t.Errorf("Expected 1 follower and 1 following, got %d and %d", response.FollowersCount, response.FollowingCount)
+		}
+		if response.PostsCount != 2 {
+			t.Errorf("Expected 2 posts, got %d", response.PostsCount)
+		}
+	})
+
+	t.Run("nonexistent user", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/"+uuid.New().String(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestUserHandler_SearchUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userRepo := NewMockUserRepository()
+	handler := NewUserHandler(userRepo, NewMockPostRepository(), nil, nil, nil, testCursors)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", uuid.New())
+		c.Next()
+	})
+	router.GET("/users/search", handler.SearchUsers)
+
+	users := []*models.User{
+		{Username: "alexander", Email: "alexander@example.com", FederationType: "local"},
+		{Username: "alex", Email: "alex@example.com", FederationType: "local"},
+		{Username: "alexis", Email: "alexis@example.com", FederationType: "local"},
+		{Username: "sam", FullName: "Alex Sam", Email: "sam@example.com", FederationType: "local"},
+		{Username: "remote_alex", Email: "remote@example.com", Handle: "@alex.bsky.social", FederationType: "remote"},
+	}
+	for _, user := range users {
+		userRepo.Create(user)
+	}
+
+	search := func(query string) (*httptest.ResponseRecorder, UserListResponse) {
+		req := httptest.NewRequest("GET", "/users/search?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response UserListResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("exact match ranks first", func(t *testing.T) {
+		w, response := search("q=Alex")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(response.Items) != 4 {
+			t.Fatalf("Expected 4 local matches, got %d", len(response.Items))
+		}
+		if response.Items[0].Username != "alex" {
+			t.Errorf("Expected exact match first, got %s", response.Items[0].Username)
+		}
+	})
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		_, page1 := search("q=alex&limit=2")
+		if len(page1.Items) != 2 || page1.NextCursor == "" {
+			t.Fatalf("Expected 2 items and a next cursor, got %d items, cursor %q", len(page1.Items), page1.NextCursor)
+		}
+
+		_, page2 := search("q=alex&limit=2&cursor=" + page1.NextCursor)
+		if len(page2.Items) != 2 {
+			t.Fatalf("Expected 2 items on second page, got %d", len(page2.Items))
+		}
+
+		seen := make(map[uuid.UUID]bool)
+		for _, item := range append(page1.Items, page2.Items...) {
+			if seen[item.ID] {
+				t.Errorf("User %s returned twice", item.Username)
+			}
+			seen[item.ID] = true
+		}
+	})
+
+	t.Run("federation type filter", func(t *testing.T) {
+		_, remote := search("q=alex&federation_type=remote")
+		if len(remote.Items) != 1 || remote.Items[0].Username != "remote_alex" {
+			t.Errorf("Expected only the remote user, got %+v", remote.Items)
+		}
+
+		_, all := search("q=alex&federation_type=all")
+		if len(all.Items) != 5 {
+			t.Errorf("Expected 5 matches across federation types, got %d", len(all.Items))
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query string
+		}{
+			{"missing query", "q=%20"},
+			{"query too long", "q=" + strings.Repeat("a", 101)},
+			{"invalid federation type", "q=alex&federation_type=other"},
+			{"invalid cursor", "q=alex&cursor=not-a-cursor"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if w, _ := search(tt.query); w.Code != http.StatusBadRequest {
+					t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+				}
+			})
+		}
+	})
+}
+
+func TestUserHandler_UpdateUserAvatar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userRepo := NewMockUserRepository()
+	storage := NewMockImageStorage()
+	collector := &MockMediaCollector{}
+	handler := NewUserHandler(userRepo, NewMockPostRepository(), userRepo.media, storage, collector, testCursors)
+
+	user := &models.User{ID: uuid.New(), Username: "avatar", Email: "avatar@example.com", Avatar: "https://example.com/old.jpg"}
+	other := &models.User{ID: uuid.New(), Username: "other", Email: "other@example.com"}
+	userRepo.Create(user)
+	userRepo.Create(other)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		c.Next()
+	})
+	router.PUT("/users/:id", handler.UpdateUser)
+
+	update := func(id uuid.UUID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/users/"+id.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	upload := func(userID uuid.UUID) uuid.UUID {
+		media := &models.Media{UserID: userID, URL: "/uploads/" + uuid.NewString() + ".jpg", ExpiresAt: time.Now().Add(time.Hour)}
+		userRepo.media.CreateMedia(media)
+		return media.ID
+	}
+
+	t.Run("uploaded media becomes the avatar, cropped", func(t *testing.T) {
+		mediaID := upload(user.ID)
+		w := update(user.ID, `{"full_name": "New Name", "avatar": "https://example.com/ignored.jpg", "avatar_media_id": "`+mediaID.String()+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var updated models.User
+		json.Unmarshal(w.Body.Bytes(), &updated)
+		full := updated.AvatarVariants[models.VariantFull]
+		if updated.Avatar != full.URL || full.Width != full.Height || len(updated.AvatarVariants) != 2 || updated.FullName != "New Name" {
+			t.Errorf("Expected square avatar variants and the other changes, got %+v", updated)
+		}
+		if userRepo.media.media[mediaID].Status != models.MediaAttached {
+			t.Error("Expected the media to be attached")
+		}
+		if stored, _ := userRepo.GetByID(user.ID); len(stored.AvatarVariants) != 2 {
+			t.Errorf("Expected the avatar variants to be stored, got %+v", stored.AvatarVariants)
+		}
+
+		// Replacing it deletes the previous avatar's files
+		if w := update(user.ID, `{"avatar_media_id": "`+upload(user.ID).String()+`"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if len(collector.released) != 2 {
+			t.Errorf("Expected the previous avatar's files to be deleted, got %v", collector.released)
+		}
+
+		if w := update(user.ID, `{"avatar_media_id": "`+mediaID.String()+`"}`); w.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("invalid avatars", func(t *testing.T) {
+		if w := update(user.ID, `{"avatar_media_id": "`+upload(other.ID).String()+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for another user's media, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := update(other.ID, `{"avatar_media_id": "`+upload(user.ID).String()+`"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d for another user's avatar, got %d", http.StatusForbidden, w.Code)
+		}
+		expired := &models.Media{UserID: user.ID, URL: "/uploads/expired.jpg", ExpiresAt: time.Now().Add(-time.Hour)}
+		userRepo.media.CreateMedia(expired)
+		if w := update(user.ID, `{"avatar_media_id": "`+expired.ID.String()+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for expired media, got %d", http.StatusBadRequest, w.Code)
+		}
+		storage.rejectAt = storage.attempts + 1
+		if w := update(user.ID, `{"avatar_media_id": "`+upload(user.ID).String()+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for media that is not an image, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := update(user.ID, `{"avatar_media_id": "not-a-uuid"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for a malformed ID, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("local users cannot set an avatar URL", func(t *testing.T) {
+		current, _ := userRepo.GetByID(user.ID)
+		if w := update(user.ID, `{"federation_type": "remote", "bio": "Still local"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		} else if updated, _ := userRepo.GetByID(user.ID); updated.FederationType == "remote" {
+			t.Error("Expected the federation type not to be set from the body")
+		}
+		if w := update(user.ID, `{"federation_type": "remote", "avatar": "https://attacker.example.com/pixel.gif"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for a local user claiming to be remote, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := update(user.ID, `{"avatar": "https://tracker.example.com/pixel.gif"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		// Profiles sent back unchanged are fine
+		if w := update(user.ID, `{"avatar": "`+current.Avatar+`", "bio": "Unchanged avatar"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if w := update(user.ID, `{"banner": "https://example.com/banner.jpg"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		} else if updated, _ := userRepo.GetByID(user.ID); updated.Banner != "" {
+			t.Errorf("Expected the banner not to be set from the body, got %q", updated.Banner)
+		}
+		if w := update(user.ID, `{"avatar": ""}`); w.Code != http.StatusOK {
+			t.Errorf("Expected the avatar to be cleared, got status code %d", w.Code)
+		}
+	})
+}
+
+func TestUserHandler_UploadProfileImages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userRepo := NewMockUserRepository()
+	storage := NewMockImageStorage()
+	collector := &MockMediaCollector{}
+	handler := NewUserHandler(userRepo, NewMockPostRepository(), nil, storage, collector, testCursors)
+
+	user := &models.User{ID: uuid.New(), Username: "profile", Email: "profile@example.com"}
+	userRepo.Create(user)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		c.Next()
+	})
+	router.PUT("/users/me/avatar", handler.UploadAvatar)
+	router.PUT("/users/me/banner", handler.UploadBanner)
+
+	upload := func(path string) (*httptest.ResponseRecorder, models.User) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writeTestImage(t, writer, "profile.png", 4, 4)
+		writer.Close()
+
+		req := httptest.NewRequest("PUT", path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var updated models.User
+		json.Unmarshal(w.Body.Bytes(), &updated)
+		return w, updated
+	}
+
+	t.Run("avatar replaces the previous one", func(t *testing.T) {
+		w, first := upload("/users/me/avatar")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		full := first.AvatarVariants[models.VariantFull]
+		if first.Avatar == "" || first.Avatar != full.URL || full.Width != full.Height || len(first.AvatarVariants) != 2 {
+			t.Errorf("Expected square avatar variants, got %+v", first)
+		}
+		if len(collector.released) != 0 {
+			t.Errorf("Expected nothing to be deleted for a first avatar, got %v", collector.released)
+		}
+
+		_, second := upload("/users/me/avatar")
+		if second.Avatar == first.Avatar {
+			t.Error("Expected the avatar to be replaced")
+		}
+		want := first.AvatarVariants.BlobKeys()
+		sort.Strings(want)
+		sort.Strings(collector.released)
+		if strings.Join(collector.released, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected the previous avatar's files %v to be deleted, got %v", want, collector.released)
+		}
+	})
+
+	t.Run("banner", func(t *testing.T) {
+		collector.released = nil
+		w, updated := upload("/users/me/banner")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		full := updated.BannerVariants[models.VariantFull]
+		if updated.Banner != full.URL || full.Width <= full.Height {
+			t.Errorf("Expected wide banner variants, got %+v", updated)
+		}
+		if updated.Avatar == "" {
+			t.Error("Expected the avatar to be kept")
+		}
+		if len(collector.released) != 0 {
+			t.Errorf("Expected nothing to be deleted for a first banner, got %v", collector.released)
+		}
+	})
+
+	t.Run("invalid uploads", func(t *testing.T) {
+		before, _ := userRepo.GetByID(user.ID)
+		avatar := before.Avatar
+		storage.rejectAt = storage.attempts + 1
+		if w, _ := upload("/users/me/avatar"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for an invalid image, got %d", http.StatusBadRequest, w.Code)
+		}
+		if after, _ := userRepo.GetByID(user.ID); after.Avatar != avatar {
+			t.Error("Expected the avatar to be kept")
+		}
+
+		req := httptest.NewRequest("PUT", "/users/me/banner", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d without an image, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"github.com/lukelittle/claroz/claroz-backend/internal/video"
+)
+
+// VideoHandler creates short video posts, which are transcoded in the
+// background and listed in feeds once they are ready
+type VideoHandler struct {
+	postRepo repository.PostRepositoryInterface
+	videos   video.ServiceInterface
+	media    *config.MediaConfig
+}
+
+func NewVideoHandler(postRepo repository.PostRepositoryInterface, videos video.ServiceInterface, media *config.MediaConfig) *VideoHandler {
+	return &VideoHandler{
+		postRepo: postRepo,
+		videos:   videos,
+		media:    media,
+	}
+}
+
+// CreateVideoPost godoc
+// @Summary Create a video post
+// @Description Create a post with a short video and a caption. The video is checked against the
+// @Description size, duration and dimension limits, then transcoded in the background to an H.264
+// @Description MP4 file and HLS renditions with a poster frame. Until then the post's status is
+// @Description processing and it is left out of feeds; poll the post for its status, which becomes
+// @Description ready, or failed if the video cannot be transcoded.
+// @Tags posts
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param video formData file true "Video file"
+// @Param alt_text formData string false "Description of the video; may be required by configuration"
+// @Param caption formData string false "Post caption; #hashtags are indexed for hashtag feeds"
+// @Param language formData string false "Caption language used for search, e.g. english (default: simple)"
+// @Success 202 {object} models.Post
+// @Failure 400 {object} object{error=string} "Missing, unsupported, too long or too large video, or invalid input"
+// @Failure 401 {object} object{error=string} "Unauthorized"
+// @Failure 500 {object} object{error=string} "Server error"
+// @Router /posts/video [post]
+func (h *VideoHandler) CreateVideoPost(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	file, err := c.FormFile("video")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video is required"})
+		return
+	}
+	altText, ok := checkAltText(c, h.media, c.PostForm("alt_text"), "the video")
+	if !ok {
+		return
+	}
+	language, ok := postLanguage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported caption language"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save video"})
+		return
+	}
+	defer src.Close()
+	media, err := h.videos.Save(c.Request.Context(), src)
+	if err != nil {
+		writeVideoError(c, err)
+		return
+	}
+	media.AltText = altText
+
+	post := &models.Post{
+		UserID:   userID.(uuid.UUID),
+		Caption:  c.PostForm("caption"),
+		Language: language,
+		ImageURL: media.URL,
+		Status:   models.PostProcessing,
+		Media:    []models.PostMedia{*media},
+	}
+	if err := h.postRepo.CreatePost(post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create post"})
+		return
+	}
+	// A post the queue has no room for is queued by the service's next sweep
+	h.videos.Enqueue(post.ID)
+
+	c.JSON(http.StatusAccepted, post)
+}
+
+// writeVideoError writes the response for a video that could not be stored
+func writeVideoError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, video.ErrInvalidVideo):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video is not a supported format"})
+	case errors.Is(err, video.ErrVideoTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video is too long"})
+	case errors.Is(err, video.ErrVideoTooLarge), errors.Is(err, utils.ErrFileTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video is too large"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save video"})
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"github.com/lukelittle/claroz/claroz-backend/internal/video"
+)
+
+// MockVideoService implements video.ServiceInterface for testing, accepting
+// uploads whose contents are "video" and recording the posts enqueued
+type MockVideoService struct {
+	enqueued []uuid.UUID
+}
+
+func (m *MockVideoService) Save(ctx context.Context, r io.Reader) (*models.PostMedia, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch string(data) {
+	case "video":
+		return &models.PostMedia{
+			URL:      "/uploads/upload.source",
+			Width:    320,
+			Height:   240,
+			MimeType: "video/mp4",
+			Duration: 2.5,
+			Variants: models.ImageVariants{video.RenditionSource: {URL: "/uploads/upload.source"}},
+		}, nil
+	case "long":
+		return nil, video.ErrVideoTooLong
+	case "large":
+		return nil, utils.ErrFileTooLarge
+	default:
+		return nil, video.ErrInvalidVideo
+	}
+}
+
+func (m *MockVideoService) Enqueue(postID uuid.UUID) bool {
+	m.enqueued = append(m.enqueued, postID)
+	return true
+}
+
+func setupVideoTestRouter(userID uuid.UUID) (*gin.Engine, *MockPostRepository, *MockVideoService) {
+	gin.SetMode(gin.TestMode)
+	postRepo := NewMockPostRepository()
+	videos := &MockVideoService{}
+	handler := NewVideoHandler(postRepo, videos, testMedia)
+	postHandler := NewPostHandler(postRepo, NewMockImageStorage(), postRepo, testCursors, testMedia, testReactions)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	})
+	router.POST("/posts/video", handler.CreateVideoPost)
+	router.GET("/posts/:id", postHandler.GetPost)
+	return router, postRepo, videos
+}
+
+// postVideo sends a video post with the given file contents
+func postVideo(t *testing.T, router *gin.Engine, contents string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	if contents != "" {
+		part, err := writer.CreateFormFile("video", "clip.mp4")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		part.Write([]byte(contents))
+	}
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/posts/video", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestVideoHandler_CreateVideoPost(t *testing.T) {
+	userID := uuid.New()
+	router, postRepo, videos := setupVideoTestRouter(userID)
+
+	t.Run("video posts are accepted for processing", func(t *testing.T) {
+		w := postVideo(t, router, "video", map[string]string{"caption": "Waves", "alt_text": "The sea"})
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+		}
+
+		var response models.Post
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Status != models.PostProcessing || response.Caption != "Waves" || response.UserID != userID {
+			t.Errorf("Expected a processing post by the user, got %+v", response)
+		}
+		if len(response.Media) != 1 || response.Media[0].AltText != "The sea" || response.Media[0].Duration != 2.5 {
+			t.Errorf("Expected the video with its alt text, got %+v", response.Media)
+		}
+		if len(videos.enqueued) != 1 || videos.enqueued[0] != response.ID {
+			t.Errorf("Expected the post to be enqueued, got %v", videos.enqueued)
+		}
+		if _, err := postRepo.GetPostByID(response.ID); err != nil {
+			t.Errorf("Expected the post to be created, got %v", err)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		contents string
+		fields   map[string]string
+	}{
+		{"missing video", "", nil},
+		{"unsupported video", "not a video", nil},
+		{"video too long", "long", nil},
+		{"video too large", "large", nil},
+		{"alt text too long", "video", map[string]string{"alt_text": "A very long description of the video"}},
+		{"unsupported language", "video", map[string]string{"language": "klingon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enqueued := len(videos.enqueued)
+			w := postVideo(t, router, tt.contents, tt.fields)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if len(videos.enqueued) != enqueued {
+				t.Error("Expected nothing to be enqueued")
+			}
+		})
+	}
+}
+
+func TestPostHandler_GetProcessingPost(t *testing.T) {
+	authorID := uuid.New()
+	author, postRepo, _ := setupVideoTestRouter(authorID)
+	other := gin.New()
+	other.Use(func(c *gin.Context) {
+		c.Set("userID", uuid.New())
+		c.Next()
+	})
+	otherHandler := NewPostHandler(postRepo, NewMockImageStorage(), postRepo, testCursors, testMedia, testReactions)
+	other.GET("/posts/:id", otherHandler.GetPost)
+	other.GET("/posts/:id/comments", otherHandler.GetComments)
+	other.GET("/posts/:id/likes", otherHandler.GetLikes)
+	other.GET("/posts/:id/revisions", otherHandler.GetPostRevisions)
+	other.GET("/posts/:id/reactions", otherHandler.GetPostReactions)
+	other.POST("/posts/:id/like", otherHandler.LikePost)
+
+	for _, status := range []string{models.PostProcessing, models.PostFailed} {
+		post := &models.Post{UserID: authorID, Status: status, ImageURL: "/uploads/upload.source"}
+		postRepo.CreatePost(post)
+
+		t.Run(status+" posts are shown to their author", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/posts/"+post.ID.String(), nil)
+			w := httptest.NewRecorder()
+			author.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+			var response models.PostView
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Status != status {
+				t.Errorf("Expected status %s, got %s", status, response.Status)
+			}
+		})
+
+		t.Run(status+" posts are hidden from others", func(t *testing.T) {
+			url := "/posts/" + post.ID.String()
+			for _, r := range []struct{ method, url string }{
+				{"GET", url},
+				{"GET", url + "/comments"},
+				{"GET", url + "/likes"},
+				{"GET", url + "/revisions"},
+				{"GET", url + "/reactions"},
+				{"POST", url + "/like"},
+			} {
+				req := httptest.NewRequest(r.method, r.url, nil)
+				w := httptest.NewRecorder()
+				other.ServeHTTP(w, req)
+
+				if w.Code != http.StatusNotFound {
+					t.Errorf("Expected status code %d for %s %s, got %d", http.StatusNotFound, r.method, r.url, w.Code)
+				}
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
 	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
 )
 
@@ -36,3 +38,25 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminMiddleware rejects requests from users without the admin role.
+// It must run after AuthMiddleware.
+func AdminMiddleware(userRepo repository.UserRepositoryInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		user, err := userRepo.GetByID(userID.(uuid.UUID))
+		if err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
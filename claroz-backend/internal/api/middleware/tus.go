@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lukelittle/claroz/claroz-backend/internal/tus"
+)
+
+// TusResumable marks responses as tus responses and rejects requests for
+// other versions of the protocol. OPTIONS requests, which clients send to
+// discover the supported versions, need not name one.
+func TusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tus.Version)
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tus.Version {
+			c.Header("Tus-Version", tus.Version)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported tus version"})
+			return
+		}
+		c.Next()
+	}
+}
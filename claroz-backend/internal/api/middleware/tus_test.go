@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTusResumable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/tus", TusResumable())
+	group.OPTIONS("", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	group.POST("", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	tests := []struct {
+		name       string
+		method     string
+		version    string
+		wantStatus int
+	}{
+		{"supported version", "POST", "1.0.0", http.StatusCreated},
+		{"missing version", "POST", "", http.StatusPreconditionFailed},
+		{"unsupported version", "POST", "0.2.2", http.StatusPreconditionFailed},
+		{"options need no version", "OPTIONS", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/tus", nil)
+			if tt.version != "" {
+				req.Header.Set("Tus-Resumable", tt.version)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Tus-Resumable"); got != "1.0.0" {
+				t.Errorf("Expected Tus-Resumable 1.0.0, got %q", got)
+			}
+			if tt.wantStatus == http.StatusPreconditionFailed && w.Header().Get("Tus-Version") != "1.0.0" {
+				t.Errorf("Expected the supported versions to be listed, got %q", w.Header().Get("Tus-Version"))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadTypes maps the extensions of stored uploads to their content types.
+// Stored files are only ever written with these extensions.
+var uploadTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".mp4":  "video/mp4",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+}
+
+// UploadHeaders guards the serving of uploaded files. Only the extensions of
+// images and transcoded videos are served, with a content type set from the extension rather than sniffed
+// from the content, and headers that stop browsers from sniffing or running
+// anything a file contains.
+func UploadHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentType, ok := uploadTypes[strings.ToLower(path.Ext(c.Request.URL.Path))]
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+		c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadHeaders(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"photo.jpg":     "\xFF\xD8\xFFjpeg data",
+		"page.html":     "<html><script>alert(1)</script></html>",
+		"image.svg":     "<svg xmlns=\"http://www.w3.org/2000/svg\"><script>alert(1)</script></svg>",
+		"sneaky.png":    "<html><script>alert(1)</script></html>",
+		"clip.mp4":      "\x00\x00\x00\x20ftypisom",
+		"clip.m3u8":     "#EXTM3U\n",
+		"upload.source": "\x00\x00\x00\x20ftypqt  ",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Group("/uploads", UploadHeaders()).Static("/", dir)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		contentType string
+	}{
+		{"image", "/uploads/photo.jpg", http.StatusOK, "image/jpeg"},
+		{"html is not served", "/uploads/page.html", http.StatusNotFound, ""},
+		{"svg is not served", "/uploads/image.svg", http.StatusNotFound, ""},
+		{"html with an image extension keeps the image type", "/uploads/sneaky.png", http.StatusOK, "image/png"},
+		{"video", "/uploads/clip.mp4", http.StatusOK, "video/mp4"},
+		{"playlist", "/uploads/clip.m3u8", http.StatusOK, "application/vnd.apple.mpegurl"},
+		{"untranscoded upload is not served", "/uploads/upload.source", http.StatusNotFound, ""},
+		{"directory", "/uploads/", http.StatusNotFound, ""},
+		{"missing file", "/uploads/missing.jpg", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.contentType, got)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("Expected X-Content-Type-Options nosniff, got %q", got)
+			}
+			if got := w.Header().Get("Content-Security-Policy"); got != "default-src 'none'; sandbox" {
+				t.Errorf("Expected a sandboxing Content-Security-Policy, got %q", got)
+			}
+		})
+	}
+}
@@ -1,40 +1,108 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/lukelittle/claroz/claroz-backend/internal/api/handlers"
 	"github.com/lukelittle/claroz/claroz-backend/internal/api/middleware"
 	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/counters"
 	"github.com/lukelittle/claroz/claroz-backend/internal/federation"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/mediagc"
 	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/timeline"
+	"github.com/lukelittle/claroz/claroz-backend/internal/tus"
 	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"github.com/lukelittle/claroz/claroz-backend/internal/video"
 	"gorm.io/gorm"
 )
 
 func SetupRoutes(router *gin.Engine, db *gorm.DB) {
+	cfg := config.NewConfig()
+
+	// Initialize the home timeline service, which reads through its own
+	// repository and is notified of changes by the one handlers use
+	timelineStore, err := timeline.NewStore(&cfg.Timeline, db)
+	if err != nil {
+		panic(err)
+	}
+	timelineService := timeline.NewService(timelineStore, repository.NewPostRepository(db), &cfg.Timeline)
+	timelineService.Start()
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
-	postRepo := repository.NewPostRepository(db)
+	postRepo := repository.NewPostRepository(db, timelineService)
+	inviteRepo := repository.NewInviteRepository(db)
+	mediaRepo := repository.NewMediaRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
 
-	// Initialize storage
-	cfg := config.NewConfig()
+	// Periodically repair engagement counters that have drifted
+	counters.NewReconciler(postRepo, &cfg.Counters).Start()
+
+	// Initialize storage, with the pool of workers that resize and strip uploaded images
+	images := imaging.NewPipeline(&cfg.Images)
+	images.Start()
 	storage, err := utils.NewFileStorage(&cfg.Storage)
 	if err != nil {
 		panic(err)
 	}
+	imageStorage := utils.NewImageStorage(storage, mediaRepo, images, cfg.Storage.MaxFileSize)
+
+	// Periodically delete stored files nothing refers to any more
+	mediaCollector := mediagc.NewCollector(mediaRepo, storage, &cfg.MediaGC)
+	mediaCollector.Start()
+
+	// Accept resumable uploads, periodically deleting those abandoned
+	mediaExpiry := time.Duration(cfg.Media.UploadExpiryMins) * time.Minute
+	tusService := tus.NewService(uploadRepo, mediaRepo, storage, imageStorage, cfg.Storage.MaxFileSize, mediaExpiry, &cfg.Tus)
+	tusService.Start()
+
+	// Transcode video posts in the background, resuming those left processing
+	videoService := video.NewService(postRepo, storage, mediaRepo, &cfg.Video)
+	videoService.Start()
+
+	// Initialize password handling
+	var breachedPasswords utils.BreachedPasswordChecker
+	if cfg.Password.BreachedHashesPath != "" {
+		list, err := utils.LoadBreachedPasswordList(cfg.Password.BreachedHashesPath)
+		if err != nil {
+			panic(err)
+		}
+		breachedPasswords = list
+	}
+	passwordPolicy := utils.NewPasswordPolicy(&cfg.Password, breachedPasswords)
+	passwordHasher := utils.NewPasswordHasher(&cfg.Password)
+
+	// Initialize pagination cursor signing
+	cursors := utils.NewCursorCodec(cfg.Pagination.CursorSecret)
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userRepo)
-	authHandler := handlers.NewAuthHandler(userRepo)
-	postHandler := handlers.NewPostHandler(postRepo, storage)
+	userHandler := handlers.NewUserHandler(userRepo, postRepo, mediaRepo, imageStorage, mediaCollector, cursors)
+	authHandler := handlers.NewAuthHandler(userRepo, inviteRepo, &cfg.Registration, passwordPolicy, passwordHasher)
+	inviteHandler := handlers.NewInviteHandler(inviteRepo, userRepo, &cfg.Registration)
+	adminHandler := handlers.NewAdminHandler(userRepo, mediaCollector)
+	postHandler := handlers.NewPostHandler(postRepo, imageStorage, timelineService, cursors, &cfg.Media, &cfg.Reactions)
+	mediaHandler := handlers.NewMediaHandler(mediaRepo, imageStorage, &cfg.Media)
+	tusHandler := handlers.NewTusHandler(tusService)
+	videoHandler := handlers.NewVideoHandler(postRepo, videoService, &cfg.Media)
 	atpClient, err := federation.NewATProtoClient(cfg.Federation.PDSHost)
 	if err != nil {
 		panic(err)
 	}
 	federationHandler := handlers.NewFederationHandler(userRepo, atpClient)
 
-	// Serve static files for uploads
-	router.Static("/uploads", cfg.Storage.LocalPath)
+	// Serve uploaded images, typed by extension and never sniffed by browsers.
+	// Uploads in a private bucket are served through presigned URLs, and those
+	// in a public one straight from the bucket.
+	uploads := router.Group("/uploads", middleware.UploadHeaders())
+	switch {
+	case cfg.Storage.Provider == "local":
+		uploads.Static("/", cfg.Storage.LocalPath)
+	case cfg.Storage.S3PublicURL == "":
+		uploads.GET("/*filepath", handlers.NewUploadHandler(storage).GetUpload)
+	}
 
 	// API routes group
 	api := router.Group("/api/v1")
@@ -53,6 +121,9 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			federation.POST("/sync/:did", federationHandler.SyncRemoteProfile)
 		}
 
+		// Resumable upload discovery, which clients may do before signing in
+		api.OPTIONS("/upload/tus", middleware.TusResumable(), tusHandler.GetOptions)
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware())
@@ -61,6 +132,9 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			users := protected.Group("/users")
 			{
 				users.GET("/me", userHandler.GetCurrentUser)
+				users.PUT("/me/avatar", userHandler.UploadAvatar)
+				users.PUT("/me/banner", userHandler.UploadBanner)
+				users.GET("/search", userHandler.SearchUsers)
 				users.GET("/:id", userHandler.GetUser)
 				users.PUT("/:id", userHandler.UpdateUser)
 				users.DELETE("/:id", userHandler.DeleteUser)
@@ -71,17 +145,92 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			posts := protected.Group("/posts")
 			{
 				posts.POST("", postHandler.CreatePost)
+				posts.POST("/video", videoHandler.CreateVideoPost)
 				posts.GET("", postHandler.GetPosts)
 				posts.GET("/:id", postHandler.GetPost)
+				posts.PUT("/:id", postHandler.UpdatePost)
 				posts.DELETE("/:id", postHandler.DeletePost)
+				posts.GET("/:id/revisions", postHandler.GetPostRevisions)
+				posts.GET("/:id/comments", postHandler.GetComments)
 				posts.POST("/:id/comments", postHandler.AddComment)
+				posts.DELETE("/:id/comments/:commentId", postHandler.DeleteComment)
+				posts.GET("/:id/comments/:commentId/replies", postHandler.GetCommentReplies)
+				posts.GET("/:id/comments/:commentId/reactions", postHandler.GetCommentReactions)
+				posts.PUT("/:id/comments/:commentId/reactions/:emoji", postHandler.ReactToComment)
+				posts.DELETE("/:id/comments/:commentId/reactions/:emoji", postHandler.RemoveCommentReaction)
+				posts.GET("/:id/likes", postHandler.GetLikes)
 				posts.POST("/:id/like", postHandler.LikePost)
 				posts.DELETE("/:id/like", postHandler.UnlikePost)
+				posts.GET("/:id/reactions", postHandler.GetPostReactions)
+				posts.PUT("/:id/reactions/:emoji", postHandler.ReactToPost)
+				posts.DELETE("/:id/reactions/:emoji", postHandler.RemovePostReaction)
 			}
 
+			// Upload routes, for images attached to posts and profiles later
+			upload := protected.Group("/upload")
+			{
+				upload.POST("/image", mediaHandler.UploadImage)
+				upload.GET("/:id", mediaHandler.GetMedia)
+
+				// Resumable uploads with the tus protocol
+				resumable := upload.Group("/tus", middleware.TusResumable())
+				resumable.POST("", tusHandler.CreateUpload)
+				resumable.HEAD("/:id", tusHandler.GetUploadOffset)
+				resumable.PATCH("/:id", tusHandler.WriteUpload)
+				resumable.DELETE("/:id", tusHandler.TerminateUpload)
+			}
+
+			// Reaction routes
+			protected.GET("/reactions", postHandler.GetReactionSet)
+
 			// Follow routes
 			users.POST("/:id/follow", postHandler.FollowUser)
 			users.DELETE("/:id/follow", postHandler.UnfollowUser)
+			users.GET("/:id/followers", postHandler.GetFollowers)
+			users.GET("/:id/following", postHandler.GetFollowing)
+
+			// Block and mute routes
+			users.POST("/:id/block", postHandler.BlockUser)
+			users.DELETE("/:id/block", postHandler.UnblockUser)
+			users.POST("/:id/mute", postHandler.MuteUser)
+			users.DELETE("/:id/mute", postHandler.UnmuteUser)
+			protected.GET("/blocks", postHandler.GetBlockedUsers)
+			protected.GET("/mutes", postHandler.GetMutedUsers)
+
+			// Feed routes
+			protected.GET("/feed/home", postHandler.GetHomeFeed)
+
+			// Search routes
+			protected.GET("/search/posts", postHandler.SearchPosts)
+			protected.GET("/hashtags/:tag/posts", postHandler.GetHashtagPosts)
+
+			// Follow request routes
+			followRequests := protected.Group("/follow-requests")
+			{
+				followRequests.GET("", postHandler.GetIncomingFollowRequests)
+				followRequests.GET("/outgoing", postHandler.GetOutgoingFollowRequests)
+				followRequests.POST("/:id/approve", postHandler.ApproveFollowRequest)
+				followRequests.POST("/:id/reject", postHandler.RejectFollowRequest)
+				followRequests.DELETE("/:id", postHandler.CancelFollowRequest)
+			}
+
+			// Invite routes
+			invites := protected.Group("/invites")
+			{
+				invites.POST("", inviteHandler.CreateInvite)
+				invites.GET("", inviteHandler.ListInvites)
+				invites.DELETE("/:id", inviteHandler.RevokeInvite)
+			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(userRepo))
+			{
+				admin.GET("/registrations", adminHandler.ListPendingRegistrations)
+				admin.POST("/registrations/:id/approve", adminHandler.ApproveRegistration)
+				admin.POST("/registrations/:id/reject", adminHandler.RejectRegistration)
+				admin.POST("/media/gc", adminHandler.CollectMedia)
+			}
 		}
 	}
 }
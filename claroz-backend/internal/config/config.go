@@ -1,10 +1,37 @@
 package config
 
 type Config struct {
-	Database   DatabaseConfig
-	Server     ServerConfig
-	Storage    StorageConfig
-	Federation FederationConfig
+	Database     DatabaseConfig
+	Server       ServerConfig
+	Storage      StorageConfig
+	Media        MediaConfig
+	Images       ImageConfig
+	Video        VideoConfig
+	Federation   FederationConfig
+	Registration RegistrationConfig
+	Password     PasswordConfig
+	Timeline     TimelineConfig
+	Pagination   PaginationConfig
+	Counters     CountersConfig
+	MediaGC      MediaGCConfig
+	Tus          TusConfig
+	Reactions    ReactionsConfig
+}
+
+// Registration modes
+const (
+	RegistrationOpen     = "open"     // anyone may sign up
+	RegistrationInvite   = "invite"   // a valid invite code is required
+	RegistrationApproval = "approval" // accounts wait in the admin queue until approved
+	RegistrationClosed   = "closed"   // no new sign-ups
+)
+
+type RegistrationConfig struct {
+	Mode              string   // one of the Registration* modes
+	AllowUserInvites  bool     // whether non-admin users may create invite codes
+	UserInviteMaxUses int      // maximum uses of a code created by a non-admin user
+	InviteMaxTTLHours int      // maximum lifetime of an invite code; 0 means no limit
+	ReservedUsernames []string // usernames that cannot be registered
 }
 
 type FederationConfig struct {
@@ -18,6 +45,115 @@ type StorageConfig struct {
 	S3Bucket    string // for S3 storage
 	S3Region    string // for S3 storage
 	MaxFileSize int64  // maximum file size in bytes
+
+	S3Endpoint          string // URL of an S3-compatible service, such as "http://localhost:9000"; empty for AWS
+	S3PathStyle         bool   // address the bucket in the URL path rather than the host name, as most S3-compatible services need
+	S3AccessKeyID       string // empty reads credentials from the environment, the AWS credentials file or the instance role
+	S3SecretAccessKey   string
+	S3PublicURL         string // base URL the bucket is publicly readable at; empty serves uploads through presigned URLs
+	S3PresignExpiryMins int    // how long presigned URLs stay valid
+	S3PartSize          uint64 // bytes per part of multipart uploads; S3 needs at least 5MB
+}
+
+type MediaConfig struct {
+	MaxItems         int  // most images in a post's carousel
+	RequireAltText   bool // whether every image needs alt text
+	MaxAltTextLength int  // in characters
+	UploadExpiryMins int  // how long uploaded images stay pending before they expire unless attached
+}
+
+type ImageConfig struct {
+	Workers       int   // images processed at once; further uploads wait for a free worker
+	ThumbnailSize int   // longest edge of the thumbnail variant in pixels
+	FeedSize      int   // longest edge of the feed variant in pixels
+	FullSize      int   // longest edge of the full-size variant in pixels; smaller images are never enlarged
+	JPEGQuality   int   // 1-100, for variants of opaque images; images with transparency are stored as lossless WebP
+	MaxEdge       int   // longest edge of an upload in pixels; larger uploads are rejected before decoding
+	MaxPixels     int64 // pixels in an upload; larger uploads are rejected before decoding
+
+	// Profile images are cropped around their centre to a standard shape
+	AvatarSize           int // edge of the square full-size avatar in pixels
+	AvatarThumbnailSize  int // edge of the square avatar thumbnail in pixels
+	BannerWidth          int // width of the full-size banner in pixels
+	BannerHeight         int // height of the full-size banner in pixels, setting the banner's aspect ratio
+	BannerThumbnailWidth int // width of the banner thumbnail in pixels
+}
+
+type VideoConfig struct {
+	FFmpegPath      string // ffmpeg binary; a bare name is looked up in PATH
+	FFprobePath     string // ffprobe binary; a bare name is looked up in PATH
+	MaxFileSize     int64  // maximum upload size in bytes
+	MaxDurationSecs int    // longest video accepted, in seconds
+	MaxEdge         int    // longest edge of an upload in pixels; larger uploads are rejected before transcoding
+	Workers         int    // videos transcoded at once
+	QueueSize       int    // videos waiting for a worker; those beyond it wait for the next sweep
+	SweepMins       int    // how often processing posts are queued, picking up those the queue had no room for; 0 only queues them at start
+	TimeoutMins     int    // longest a video may take to transcode before it fails
+	MP4Edge         int    // shorter edge of the MP4 rendition in pixels; smaller videos are never enlarged
+	HLSEdges        []int  // shorter edges of the HLS renditions in pixels, smallest first
+	HLSSegmentSecs  int    // target length of HLS segments
+	Preset          string // x264 preset, trading encoding speed for file size
+	CRF             int    // x264 constant rate factor, 0-51; lower is better quality
+	AudioBitrate    string // AAC bitrate, such as "128k"
+}
+
+// Password hashing algorithms
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+type PasswordConfig struct {
+	MinLength          int
+	MaxLength          int
+	BreachedHashesPath string // file of SHA-1 hashes, one "HASH" or "HASH:COUNT" per line; empty disables the check
+	Algorithm          string // algorithm for new hashes; legacy hashes are upgraded on login
+	BcryptCost         int
+	Argon2Memory       uint32 // in KiB
+	Argon2Iterations   uint32
+	Argon2Parallelism  uint8
+}
+
+// Timeline stores
+const (
+	TimelineStorePostgres = "postgres"
+	TimelineStoreMemory   = "memory" // not persisted; for tests and single-instance development
+)
+
+type TimelineConfig struct {
+	Store            string // one of the TimelineStore* values
+	FanoutThreshold  int64  // authors with more followers than this are merged at read time instead of fanned out
+	MaxEntries       int    // entries kept per timeline; older posts are read from the posts table
+	BackfillLimit    int    // recent posts copied into a timeline when the user follows someone
+	QueueSize        int    // pending fan-out jobs; events past this are dropped
+	Workers          int    // background fan-out workers
+	TrimIntervalMins int    // how often timelines are trimmed to MaxEntries; 0 disables trimming
+}
+
+type PaginationConfig struct {
+	CursorSecret string // key signing pagination cursors; empty derives one from the JWT signing key
+}
+
+type CountersConfig struct {
+	ReconcileIntervalMins int // how often stored counters are checked against the rows they count; 0 disables the job
+}
+
+type TusConfig struct {
+	UserQuotaBytes      int64 // most bytes a user's unfinished resumable uploads may declare or hold at once
+	ExpiryMins          int   // how long an unfinished upload is kept after it last received a part
+	CleanupIntervalMins int   // how often expired uploads are deleted; 0 disables the job
+	BatchSize           int   // most expired uploads deleted per run
+}
+
+type MediaGCConfig struct {
+	IntervalMins    int  // how often unreferenced files are collected; 0 disables the job
+	GracePeriodMins int  // how long a file stays unreferenced before it is deleted, leaving time for uploads to be posted
+	BatchSize       int  // most files deleted per run
+	DryRun          bool // report what scheduled runs would delete without deleting it
+}
+
+type ReactionsConfig struct {
+	Emoji []string // names of the reactions users may add; "heart" is always allowed, since likes are heart reactions
 }
 
 type DatabaseConfig struct {
@@ -54,10 +190,103 @@ func NewConfig() *Config {
 			S3Bucket:    "",
 			S3Region:    "",
 			MaxFileSize: 5 * 1024 * 1024, // 5MB
+
+			S3PathStyle:         false,
+			S3PresignExpiryMins: 60,
+			S3PartSize:          16 * 1024 * 1024, // 16MB
+		},
+		Media: MediaConfig{
+			MaxItems:         10,
+			RequireAltText:   false,
+			MaxAltTextLength: 1000,
+			UploadExpiryMins: 24 * 60,
+		},
+		Images: ImageConfig{
+			Workers:       2,
+			ThumbnailSize: 320,
+			FeedSize:      1080,
+			FullSize:      2048,
+			JPEGQuality:   85,
+			MaxEdge:       10000,
+			MaxPixels:     36000000, // 36 megapixels, about 144MB decoded
+
+			AvatarSize:           400,
+			AvatarThumbnailSize:  96,
+			BannerWidth:          1500,
+			BannerHeight:         500,
+			BannerThumbnailWidth: 600,
+		},
+		Video: VideoConfig{
+			FFmpegPath:      "ffmpeg",
+			FFprobePath:     "ffprobe",
+			MaxFileSize:     100 * 1024 * 1024, // 100MB
+			MaxDurationSecs: 60,
+			MaxEdge:         3840,
+			Workers:         1,
+			QueueSize:       100,
+			SweepMins:       1,
+			TimeoutMins:     15,
+			MP4Edge:         720,
+			HLSEdges:        []int{360, 720},
+			HLSSegmentSecs:  4,
+			Preset:          "veryfast",
+			CRF:             23,
+			AudioBitrate:    "128k",
 		},
 		Federation: FederationConfig{
 			PDSHost: "https://bsky.social",
 			Enabled: true,
 		},
+		Registration: RegistrationConfig{
+			Mode:              RegistrationOpen,
+			AllowUserInvites:  true,
+			UserInviteMaxUses: 1,
+			InviteMaxTTLHours: 24 * 30, // 30 days
+			ReservedUsernames: []string{
+				"admin", "administrator", "root", "system", "support",
+				"help", "api", "auth", "login", "register", "settings",
+				"me", "moderator", "claroz", "staff", "null", "undefined",
+			},
+		},
+		Password: PasswordConfig{
+			MinLength:          8,
+			MaxLength:          128,
+			BreachedHashesPath: "",
+			Algorithm:          HashArgon2id,
+			BcryptCost:         10,
+			Argon2Memory:       64 * 1024, // 64MB
+			Argon2Iterations:   3,
+			Argon2Parallelism:  2,
+		},
+		Timeline: TimelineConfig{
+			Store:            TimelineStorePostgres,
+			FanoutThreshold:  10000,
+			MaxEntries:       800,
+			BackfillLimit:    20,
+			QueueSize:        1024,
+			Workers:          4,
+			TrimIntervalMins: 60,
+		},
+		Pagination: PaginationConfig{
+			CursorSecret: "",
+		},
+		Counters: CountersConfig{
+			ReconcileIntervalMins: 60,
+		},
+		Tus: TusConfig{
+			UserQuotaBytes:      50 * 1024 * 1024, // 50MB
+			ExpiryMins:          24 * 60,
+			CleanupIntervalMins: 60,
+			BatchSize:           100,
+		},
+		MediaGC: MediaGCConfig{
+			IntervalMins:    60,
+			GracePeriodMins: 24 * 60,
+			BatchSize:       1000,
+			DryRun:          false,
+		},
+		Reactions: ReactionsConfig{
+			Emoji: []string{"heart", "laugh", "wow", "sad", "angry", "clap"},
+		},
 	}
 }
@@ -0,0 +1,82 @@
+package counters
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// Store recounts and repairs stored counters. It is implemented by
+// *repository.PostRepository.
+type Store interface {
+	ReconcileCounters() ([]repository.CounterDrift, error)
+}
+
+// Reconciler periodically repairs like, comment and follow counters that
+// have drifted from the rows they count, such as after a write that bypassed
+// the repository or a migration that added the counters to existing rows.
+type Reconciler struct {
+	store  Store
+	config *config.CountersConfig
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReconciler creates a counter reconciler. Call Start to run it periodically.
+func NewReconciler(store Store, cfg *config.CountersConfig) *Reconciler {
+	return &Reconciler{
+		store:  store,
+		config: cfg,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start reconciles the counters once in the background and then every
+// ReconcileIntervalMins, unless the interval is 0
+func (r *Reconciler) Start() {
+	if r.config.ReconcileIntervalMins <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(time.Duration(r.config.ReconcileIntervalMins) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if _, err := r.Run(); err != nil {
+				log.Printf("counters: reconciliation failed: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for a running reconciliation to finish and stops the job
+func (r *Reconciler) Stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// Run reconciles the counters once, logging and returning each repair
+func (r *Reconciler) Run() ([]repository.CounterDrift, error) {
+	drifts, err := r.store.ReconcileCounters()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range drifts {
+		log.Printf("counters: repaired %s of %s from %d to %d", d.Counter, d.ID, d.Stored, d.Actual)
+	}
+	if len(drifts) > 0 {
+		log.Printf("counters: repaired %d drifted counters", len(drifts))
+	}
+	return drifts, nil
+}
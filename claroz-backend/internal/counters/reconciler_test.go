@@ -0,0 +1,97 @@
+package counters
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// fakeStore returns queued reconciliation results and signals runs without blocking
+type fakeStore struct {
+	results [][]repository.CounterDrift
+	err     error
+	runs    chan struct{}
+}
+
+func (f *fakeStore) ReconcileCounters() ([]repository.CounterDrift, error) {
+	defer func() {
+		select {
+		case f.runs <- struct{}{}:
+		default:
+		}
+	}()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if len(f.results) == 0 {
+		return []repository.CounterDrift{}, nil
+	}
+	result := f.results[0]
+	f.results = f.results[1:]
+	return result, nil
+}
+
+func TestReconciler_Run(t *testing.T) {
+	drift := repository.CounterDrift{Counter: "posts.like_count", ID: uuid.New(), Stored: 5, Actual: 3}
+	store := &fakeStore{results: [][]repository.CounterDrift{{drift}}, runs: make(chan struct{}, 1)}
+	reconciler := NewReconciler(store, &config.CountersConfig{ReconcileIntervalMins: 60})
+
+	t.Run("reports repairs", func(t *testing.T) {
+		drifts, err := reconciler.Run()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(drifts) != 1 || drifts[0] != drift {
+			t.Errorf("Expected the drifted counter to be reported, got %+v", drifts)
+		}
+	})
+
+	t.Run("nothing to repair", func(t *testing.T) {
+		drifts, err := reconciler.Run()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(drifts) != 0 {
+			t.Errorf("Expected no repairs, got %+v", drifts)
+		}
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		store.err = errors.New("connection refused")
+		if _, err := reconciler.Run(); err == nil {
+			t.Error("Expected the store error to be returned")
+		}
+	})
+}
+
+func TestReconciler_Start(t *testing.T) {
+	t.Run("reconciles on start", func(t *testing.T) {
+		store := &fakeStore{runs: make(chan struct{}, 1)}
+		reconciler := NewReconciler(store, &config.CountersConfig{ReconcileIntervalMins: 60})
+		reconciler.Start()
+		defer reconciler.Stop()
+
+		select {
+		case <-store.runs:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the counters to be reconciled when the job starts")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		store := &fakeStore{runs: make(chan struct{}, 1)}
+		reconciler := NewReconciler(store, &config.CountersConfig{ReconcileIntervalMins: 0})
+		reconciler.Start()
+		reconciler.Stop()
+
+		select {
+		case <-store.runs:
+			t.Error("Expected a disabled job not to reconcile")
+		default:
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation inserts an Exif segment recording orientation into a JPEG
+func withOrientation(t *testing.T, jpegData []byte, orientation uint16, order binary.ByteOrder) []byte {
+	t.Helper()
+	tiff := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	for _, v := range []interface{}{
+		uint16(42), uint32(8), // TIFF header and the offset of the first IFD
+		uint16(2),                                               // entries
+		uint16(0x010F), uint16(2), uint32(4), []byte("Cam\x00"), // camera make, stored inline
+		uint16(exifOrientationTag), uint16(3), uint32(1), orientation, uint16(0),
+		uint32(0), // no next IFD
+	} {
+		if err := binary.Write(tiff, order, v); err != nil {
+			t.Fatalf("Failed to write Exif: %v", err)
+		}
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	out = append(out, payload...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	plain := encoded.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no metadata", plain, 1},
+		{"little endian", withOrientation(t, plain, 6, binary.LittleEndian), 6},
+		{"big endian", withOrientation(t, plain, 8, binary.BigEndian), 8},
+		{"out of range", withOrientation(t, plain, 9, binary.BigEndian), 1},
+		{"truncated", withOrientation(t, plain, 3, binary.BigEndian)[:30], 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.data); got != tt.want {
+				t.Errorf("Expected orientation %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image with its stored top-left pixel marked
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	marked := color.NRGBA{R: 255, A: 255}
+	src.Set(0, 0, marked)
+
+	tests := []struct {
+		orientation         int
+		width, height, x, y int // the upright size and where the marked pixel ends up
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+		if got.Bounds().Dx() != tt.width || got.Bounds().Dy() != tt.height {
+			t.Errorf("Orientation %d: expected %dx%d, got %v", tt.orientation, tt.width, tt.height, got.Bounds())
+			continue
+		}
+		if got.At(tt.x, tt.y) != marked {
+			t.Errorf("Orientation %d: expected the marked pixel at (%d, %d)", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF decoding
+	"image/jpeg"
+	_ "image/png" // register PNG decoding
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"golang.org/x/image/draw"
+)
+
+// ErrInvalidImage is returned for uploads that are not images in a supported format
+var ErrInvalidImage = errors.New("invalid image")
+
+// Variant is an encoded rendition of an image
+type Variant struct {
+	Name     string // one of the Variant* names
+	Data     []byte
+	Width    int
+	Height   int
+	MimeType string
+	Ext      string // file extension for the encoding, such as ".jpg"
+}
+
+// Result is a processed image's variants, from smallest to largest
+type Result struct {
+	Variants []Variant
+}
+
+// Full returns the full-size variant
+func (r *Result) Full() Variant {
+	return r.Variants[len(r.Variants)-1]
+}
+
+// Pipeline turns uploads into resized, re-encoded variants without their
+// metadata. Uploads are identified by their magic bytes, checked against the
+// pixel limits before their pixels are decoded, then fully decoded, rotated
+// upright according to their EXIF orientation and scaled to the configured
+// sizes. Opaque images are encoded as JPEG and images with transparency as
+// lossless WebP; animated GIFs keep only their first frame. At most Workers
+// images are processed at once.
+type Pipeline struct {
+	config *config.ImageConfig
+
+	jobs chan func()
+	wg   sync.WaitGroup
+}
+
+// NewPipeline creates an image pipeline. Call Start before processing images.
+func NewPipeline(cfg *config.ImageConfig) *Pipeline {
+	return &Pipeline{
+		config: cfg,
+		jobs:   make(chan func()),
+	}
+}
+
+// Start launches the workers
+func (p *Pipeline) Start() {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for run := range p.jobs {
+				run()
+			}
+		}()
+	}
+}
+
+// Stop waits for images being processed and stops the workers. The pipeline
+// must not process images afterwards.
+func (p *Pipeline) Stop() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// Process decodes an upload and generates its variants, waiting for a free
+// worker. It returns an error wrapping ErrInvalidImage if the upload is not
+// an image in a supported format, or ErrImageTooLarge if it exceeds the pixel
+// limits.
+func (p *Pipeline) Process(data []byte) (*Result, error) {
+	return p.run(data, []size{
+		{models.VariantFull, p.config.FullSize, p.config.FullSize, false},
+		{models.VariantFeed, p.config.FeedSize, p.config.FeedSize, false},
+		{models.VariantThumbnail, p.config.ThumbnailSize, p.config.ThumbnailSize, false},
+	})
+}
+
+// ProcessAvatar decodes an upload like Process and generates square full-size
+// and thumbnail variants, cropped around the image's centre
+func (p *Pipeline) ProcessAvatar(data []byte) (*Result, error) {
+	return p.run(data, []size{
+		{models.VariantFull, p.config.AvatarSize, p.config.AvatarSize, true},
+		{models.VariantThumbnail, p.config.AvatarThumbnailSize, p.config.AvatarThumbnailSize, true},
+	})
+}
+
+// ProcessBanner decodes an upload like Process and generates wide full-size
+// and thumbnail variants, cropped around the image's centre to the banner's
+// aspect ratio
+func (p *Pipeline) ProcessBanner(data []byte) (*Result, error) {
+	thumbnailHeight := max(1, p.config.BannerThumbnailWidth*p.config.BannerHeight/p.config.BannerWidth)
+	return p.run(data, []size{
+		{models.VariantFull, p.config.BannerWidth, p.config.BannerHeight, true},
+		{models.VariantThumbnail, p.config.BannerThumbnailWidth, thumbnailHeight, true},
+	})
+}
+
+// run generates the variants of an upload on a free worker
+func (p *Pipeline) run(data []byte, sizes []size) (*Result, error) {
+	var result *Result
+	var err error
+	done := make(chan struct{})
+	p.jobs <- func() {
+		defer close(done)
+		result, err = process(data, sizes, p.config)
+	}
+	<-done
+	return result, err
+}
+
+// size is the bounds of a variant. Variants fit within their bounds, keeping
+// the image's aspect ratio, unless they are cropped to the bounds' own.
+type size struct {
+	name          string
+	width, height int
+	crop          bool
+}
+
+// process generates the variants of an upload, in sizes from largest to
+// smallest
+func process(data []byte, sizes []size, cfg *config.ImageConfig) (*Result, error) {
+	info, err := Inspect(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(info, cfg.MaxEdge, cfg.MaxPixels); err != nil {
+		return nil, err
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if format == "jpeg" {
+		src = orient(src, exifOrientation(data))
+	}
+	opaque := isOpaque(src)
+
+	// Scale each variant from the next larger one, which is faster and no
+	// less sharp than scaling every variant from the original
+	variants := make([]Variant, len(sizes))
+	img := src
+	for i, size := range sizes {
+		if size.crop {
+			img = fill(img, size.width, size.height)
+		} else {
+			img = fit(img, size.width, size.height)
+		}
+		variant, err := encode(img, opaque, cfg.JPEGQuality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", size.name, err)
+		}
+		variant.Name = size.name
+		variants[len(sizes)-1-i] = variant
+	}
+	return &Result{Variants: variants}, nil
+}
+
+// fit scales an image down to fit within width x height, keeping its aspect
+// ratio. Images that already fit are returned as they are.
+func fit(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= width && h <= height {
+		return src
+	}
+
+	if w*height >= h*width {
+		w, h = width, max(1, h*width/w)
+	} else {
+		w, h = max(1, w*height/h), height
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// fill crops an image around its centre to the aspect ratio of width x
+// height and scales it down to that size. Images smaller than the size are
+// cropped but never enlarged.
+func fill(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	crop := bounds
+	if bounds.Dx()*height > bounds.Dy()*width {
+		w := max(1, bounds.Dy()*width/height)
+		crop.Min.X += (bounds.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := max(1, bounds.Dx()*height/width)
+		crop.Min.Y += (bounds.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	if crop == bounds && crop.Dx() <= width {
+		return src
+	}
+
+	w, h := crop.Dx(), crop.Dy()
+	if w > width || h > height {
+		w, h = width, height
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
+
+// encode encodes an image as JPEG if it is opaque, or as lossless WebP to
+// keep its transparency. Neither encoding carries metadata over.
+func encode(img image.Image, opaque bool, quality int) (Variant, error) {
+	var buf bytes.Buffer
+	variant := Variant{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if opaque {
+		variant.MimeType, variant.Ext = "image/jpeg", ".jpg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return Variant{}, err
+		}
+	} else {
+		variant.MimeType, variant.Ext = "image/webp", ".webp"
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return Variant{}, err
+		}
+	}
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
+
+// isOpaque reports whether an image has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
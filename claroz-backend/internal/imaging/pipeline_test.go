@@ -0,0 +1,225 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"sync"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+var testConfig = &config.ImageConfig{
+	Workers: 2, ThumbnailSize: 32, FeedSize: 64, FullSize: 128, JPEGQuality: 80, MaxEdge: 1000, MaxPixels: 250000,
+	AvatarSize: 64, AvatarThumbnailSize: 16, BannerWidth: 90, BannerHeight: 30, BannerThumbnailWidth: 30,
+}
+
+// encodePNG returns a width x height PNG, opaque unless transparent is set
+func encodePNG(t *testing.T, width, height int, transparent bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	if transparent {
+		img.Set(0, 0, color.NRGBA{})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPipeline_Process(t *testing.T) {
+	pipeline := NewPipeline(testConfig)
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	t.Run("variants are scaled down and re-encoded", func(t *testing.T) {
+		result, err := pipeline.Process(encodePNG(t, 300, 150, false))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		want := []struct {
+			name          string
+			width, height int
+		}{
+			{models.VariantThumbnail, 32, 16},
+			{models.VariantFeed, 64, 32},
+			{models.VariantFull, 128, 64},
+		}
+		if len(result.Variants) != len(want) || result.Full().Name != models.VariantFull {
+			t.Fatalf("Expected %d variants ending with the full size, got %+v", len(want), result.Variants)
+		}
+		for i, w := range want {
+			variant := result.Variants[i]
+			if variant.Name != w.name || variant.Width != w.width || variant.Height != w.height || variant.MimeType != "image/jpeg" || variant.Ext != ".jpg" {
+				t.Errorf("Expected a %dx%d JPEG %s, got %s %dx%d %s", w.width, w.height, w.name, variant.Name, variant.Width, variant.Height, variant.MimeType)
+			}
+			decoded, format, err := image.Decode(bytes.NewReader(variant.Data))
+			if err != nil || format != "jpeg" || decoded.Bounds().Dx() != w.width || decoded.Bounds().Dy() != w.height {
+				t.Errorf("%s variant does not decode as recorded: %v", w.name, err)
+			}
+		}
+	})
+
+	t.Run("small images are not enlarged", func(t *testing.T) {
+		result, err := pipeline.Process(encodePNG(t, 20, 40, false))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		want := map[string][2]int{models.VariantThumbnail: {16, 32}, models.VariantFeed: {20, 40}, models.VariantFull: {20, 40}}
+		for _, variant := range result.Variants {
+			if size := want[variant.Name]; variant.Width != size[0] || variant.Height != size[1] {
+				t.Errorf("Expected %s to be %dx%d, got %dx%d", variant.Name, size[0], size[1], variant.Width, variant.Height)
+			}
+		}
+	})
+
+	t.Run("transparent images become lossless WebP", func(t *testing.T) {
+		result, err := pipeline.Process(encodePNG(t, 40, 40, true))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		full := result.Full()
+		if full.MimeType != "image/webp" || full.Ext != ".webp" {
+			t.Fatalf("Expected WebP, got %s", full.MimeType)
+		}
+		decoded, format, err := image.Decode(bytes.NewReader(full.Data))
+		if err != nil || format != "webp" {
+			t.Fatalf("Full variant does not decode as WebP: %v", err)
+		}
+		if _, _, _, a := decoded.At(0, 0).RGBA(); a != 0 {
+			t.Error("Expected transparency to be kept")
+		}
+	})
+
+	t.Run("EXIF orientation is applied and metadata stripped", func(t *testing.T) {
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+			t.Fatalf("Failed to encode image: %v", err)
+		}
+		result, err := pipeline.Process(withOrientation(t, encoded.Bytes(), 6, binary.BigEndian))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		for _, variant := range result.Variants {
+			if bytes.Contains(variant.Data, []byte("Exif")) {
+				t.Errorf("%s variant kept the Exif metadata", variant.Name)
+			}
+		}
+		if full := result.Full(); full.Width != 20 || full.Height != 40 {
+			t.Errorf("Expected the image to be turned upright to 20x40, got %dx%d", full.Width, full.Height)
+		}
+	})
+
+	t.Run("uploads that do not decode are rejected", func(t *testing.T) {
+		for _, data := range [][]byte{[]byte("<svg></svg>"), encodePNG(t, 10, 10, false)[:40]} {
+			if _, err := pipeline.Process(data); !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("Expected ErrInvalidImage, got %v", err)
+			}
+		}
+	})
+
+	t.Run("concurrent uploads share the workers", func(t *testing.T) {
+		data := encodePNG(t, 50, 50, false)
+		var wg sync.WaitGroup
+		errs := make(chan error, 8)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := pipeline.Process(data)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Process() error = %v", err)
+			}
+		}
+	})
+}
+
+func TestPipeline_ProcessProfileImages(t *testing.T) {
+	pipeline := NewPipeline(testConfig)
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	tests := []struct {
+		name          string
+		process       func([]byte) (*Result, error)
+		width, height int
+		want          map[string][2]int
+	}{
+		{"avatar from a wide image", pipeline.ProcessAvatar, 300, 150, map[string][2]int{models.VariantFull: {64, 64}, models.VariantThumbnail: {16, 16}}},
+		{"avatar from a tall image", pipeline.ProcessAvatar, 100, 400, map[string][2]int{models.VariantFull: {64, 64}, models.VariantThumbnail: {16, 16}}},
+		{"small avatar is cropped but not enlarged", pipeline.ProcessAvatar, 40, 20, map[string][2]int{models.VariantFull: {20, 20}, models.VariantThumbnail: {16, 16}}},
+		{"banner from a square image", pipeline.ProcessBanner, 200, 200, map[string][2]int{models.VariantFull: {90, 30}, models.VariantThumbnail: {30, 10}}},
+		{"banner from a panorama", pipeline.ProcessBanner, 600, 100, map[string][2]int{models.VariantFull: {90, 30}, models.VariantThumbnail: {30, 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.process(encodePNG(t, tt.width, tt.height, false))
+			if err != nil {
+				t.Fatalf("Process error = %v", err)
+			}
+			if len(result.Variants) != len(tt.want) || result.Full().Name != models.VariantFull {
+				t.Fatalf("Expected %d variants ending with the full size, got %+v", len(tt.want), result.Variants)
+			}
+			for _, variant := range result.Variants {
+				size := tt.want[variant.Name]
+				if variant.Width != size[0] || variant.Height != size[1] {
+					t.Errorf("Expected %s to be %dx%d, got %dx%d", variant.Name, size[0], size[1], variant.Width, variant.Height)
+				}
+				decoded, _, err := image.Decode(bytes.NewReader(variant.Data))
+				if err != nil || decoded.Bounds().Dx() != size[0] || decoded.Bounds().Dy() != size[1] {
+					t.Errorf("%s variant does not decode as recorded: %v", variant.Name, err)
+				}
+			}
+		})
+	}
+
+	t.Run("the centre is kept", func(t *testing.T) {
+		// A wide image, black but for a white square in the middle
+		img := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 300; x++ {
+				c := color.NRGBA{A: 255}
+				if x >= 100 && x < 200 {
+					c = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+				img.Set(x, y, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("Failed to encode image: %v", err)
+		}
+
+		result, err := pipeline.ProcessAvatar(buf.Bytes())
+		if err != nil {
+			t.Fatalf("ProcessAvatar() error = %v", err)
+		}
+		decoded, _, err := image.Decode(bytes.NewReader(result.Full().Data))
+		if err != nil {
+			t.Fatalf("Failed to decode avatar: %v", err)
+		}
+		for _, p := range []image.Point{{0, 0}, {63, 63}, {32, 32}} {
+			if r, _, _, _ := decoded.At(p.X, p.Y).RGBA(); r < 0xe000 {
+				t.Errorf("Expected the white centre at %v, got %v", p, decoded.At(p.X, p.Y))
+			}
+		}
+	})
+}
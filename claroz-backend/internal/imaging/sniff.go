@@ -0,0 +1,88 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrImageTooLarge is returned for images with more pixels, or longer edges,
+// than the configured limits, which decoding could exhaust memory on
+var ErrImageTooLarge = errors.New("image is too large")
+
+// Info describes an image without decoding its pixels
+type Info struct {
+	Width    int
+	Height   int
+	MimeType string
+}
+
+// signatures are the magic bytes of the formats uploads may be in. The
+// client's Content-Type and file name are never trusted.
+var signatures = []struct {
+	mimeType string
+	format   string // the name image.Decode reports
+	matches  func(data []byte) bool
+}{
+	{"image/jpeg", "jpeg", func(data []byte) bool { return bytes.HasPrefix(data, []byte("\xFF\xD8\xFF")) }},
+	{"image/png", "png", func(data []byte) bool { return bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"image/gif", "gif", func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+	}},
+	{"image/webp", "webp", func(data []byte) bool {
+		return len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP"))
+	}},
+}
+
+// Sniff returns the MIME type of an image from its magic bytes, or an empty
+// string if it is not in a supported format
+func Sniff(data []byte) string {
+	for _, signature := range signatures {
+		if signature.matches(data) {
+			return signature.mimeType
+		}
+	}
+	return ""
+}
+
+// Inspect identifies an upload by its magic bytes and reads its dimensions
+// from its header, without decoding its pixels. It returns an error wrapping
+// ErrInvalidImage if the upload is not in a supported format or its header
+// does not decode as that format.
+func Inspect(data []byte) (*Info, error) {
+	mimeType := Sniff(data)
+	if mimeType == "" {
+		return nil, fmt.Errorf("%w: unrecognized format", ErrInvalidImage)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if format != formatOf(mimeType) {
+		return nil, fmt.Errorf("%w: %s data decoded as %s", ErrInvalidImage, mimeType, format)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("%w: empty image", ErrInvalidImage)
+	}
+	return &Info{Width: config.Width, Height: config.Height, MimeType: mimeType}, nil
+}
+
+// checkSize returns ErrImageTooLarge if an image exceeds the pixel limits
+func checkSize(info *Info, maxEdge int, maxPixels int64) error {
+	if info.Width > maxEdge || info.Height > maxEdge || int64(info.Width)*int64(info.Height) > maxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, info.Width, info.Height)
+	}
+	return nil
+}
+
+// formatOf returns the image.Decode format name for a sniffed MIME type
+func formatOf(mimeType string) string {
+	for _, signature := range signatures {
+		if signature.mimeType == mimeType {
+			return signature.format
+		}
+	}
+	return ""
+}
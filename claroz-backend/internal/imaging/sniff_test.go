@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// withPNGSize rewrites the dimensions in a PNG's header, keeping its pixel
+// data, the way a decompression bomb claims more pixels than it carries
+func withPNGSize(data []byte, width, height uint32) []byte {
+	out := append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(out[16:], width)
+	binary.BigEndian.PutUint32(out[20:], height)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func TestInspect(t *testing.T) {
+	pngData := encodePNG(t, 3, 2, false)
+
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 4, 4), []color.Color{color.Black}), nil); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	var webpData bytes.Buffer
+	if err := nativewebp.Encode(&webpData, image.NewNRGBA(image.Rect(0, 0, 5, 4)), nil); err != nil {
+		t.Fatalf("Failed to encode WebP: %v", err)
+	}
+
+	t.Run("supported formats", func(t *testing.T) {
+		tests := []struct {
+			name string
+			data []byte
+			want Info
+		}{
+			{"png", pngData, Info{Width: 3, Height: 2, MimeType: "image/png"}},
+			{"gif", gifData.Bytes(), Info{Width: 4, Height: 4, MimeType: "image/gif"}},
+			{"webp", webpData.Bytes(), Info{Width: 5, Height: 4, MimeType: "image/webp"}},
+		}
+		for _, tt := range tests {
+			info, err := Inspect(tt.data)
+			if err != nil {
+				t.Errorf("%s: Inspect() error = %v", tt.name, err)
+				continue
+			}
+			if *info != tt.want {
+				t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, *info)
+			}
+		}
+	})
+
+	// Files an attacker might upload in place of an image
+	malicious := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"html", []byte("<!DOCTYPE html><html><script>alert(document.cookie)</script></html>")},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`)},
+		{"html after a jpeg signature", append([]byte("\xFF\xD8\xFF"), "<html><script>alert(1)</script></html>"...)},
+		{"riff that is not webp", append([]byte("RIFF\x10\x00\x00\x00WAVEfmt "), make([]byte, 16)...)},
+		{"webp signature without a bitstream", []byte("RIFF\x04\x00\x00\x00WEBP")},
+		{"truncated png header", pngData[:20]},
+		{"zero-width png", withPNGSize(pngData, 0, 2)},
+	}
+	for _, tt := range malicious {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Inspect(tt.data); !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("Expected ErrInvalidImage, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPipeline_RejectsUnsafeUploads(t *testing.T) {
+	pngData := encodePNG(t, 3, 2, false)
+
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 4, 4), []color.Color{color.Black}), nil); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	hugeScreen := append([]byte(nil), gifData.Bytes()...)
+	binary.LittleEndian.PutUint16(hugeScreen[6:], 0xFFFF)
+	binary.LittleEndian.PutUint16(hugeScreen[8:], 0xFFFF)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"png bomb", withPNGSize(pngData, 50000, 50000), ErrImageTooLarge},
+		{"png wider than the longest edge", withPNGSize(pngData, 1001, 1), ErrImageTooLarge},
+		{"png over the pixel limit", withPNGSize(pngData, 600, 600), ErrImageTooLarge},
+		{"gif with a huge logical screen", hugeScreen, ErrImageTooLarge},
+		{"png with its pixel data cut off", pngData[:len(pngData)-20], ErrInvalidImage},
+		{"gif header followed by javascript", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;alert(1)//"), ErrInvalidImage},
+		{"html", []byte("<html><script>alert(1)</script></html>"), ErrInvalidImage},
+	}
+	pipeline := NewPipeline(testConfig)
+	pipeline.Start()
+	defer pipeline.Stop()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pipeline.Process(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package mediagc
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// Store expires pending uploads and finds and deletes unreferenced blobs. It
+// is implemented by *repository.MediaRepository.
+type Store interface {
+	ExpireMedia(now time.Time, limit int) (int, error)
+	UnreferencedBlobs(before time.Time, limit int) ([]models.MediaBlob, error)
+	DeleteBlob(key string, before time.Time, remove func() error) (bool, error)
+	DeleteReleasedBlob(key string, releasedAt time.Time, remove func() error) (bool, error)
+}
+
+// CollectorInterface runs collections on demand
+type CollectorInterface interface {
+	Run(dryRun bool) (*Report, error)
+	DeleteReleased(keys []string, releasedAt time.Time) []string
+}
+
+// Report describes a collection
+type Report struct {
+	DryRun  bool     `json:"dry_run"`
+	Expired int      `json:"expired"` // pending uploads deleted for not being attached in time
+	Deleted []string `json:"deleted"` // keys of the files deleted, or that a dry run would delete
+	Bytes   int64    `json:"bytes"`   // size of those files, where known
+	Failed  []string `json:"failed"`  // keys of the files that could not be deleted
+}
+
+// Collector periodically deletes stored files that no media have referred to
+// for the grace period, such as those of deleted posts and of uploads that
+// were never posted
+type Collector struct {
+	store  Store
+	files  utils.FileStorageInterface
+	config *config.MediaGCConfig
+
+	mu   sync.Mutex // serializes runs
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCollector creates a garbage collector for the blobs in store and their
+// files. Call Start to run it periodically.
+func NewCollector(store Store, files utils.FileStorageInterface, cfg *config.MediaGCConfig) *Collector {
+	return &Collector{
+		store:  store,
+		files:  files,
+		config: cfg,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start collects once in the background and then every IntervalMins, unless
+// the interval is 0
+func (c *Collector) Start() {
+	if c.config.IntervalMins <= 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(time.Duration(c.config.IntervalMins) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if _, err := c.Run(c.config.DryRun); err != nil {
+				log.Printf("mediagc: collection failed: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for a running collection to finish and stops the job
+func (c *Collector) Stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Run deletes up to BatchSize expired pending uploads, whose files then
+// start their grace period, and up to BatchSize files unreferenced for the
+// grace period, logging and reporting what it deleted. A dry run expires
+// nothing and only reports which files would be deleted. Files that fail to
+// delete keep their blobs, to be retried by the next run.
+func (c *Collector) Run(dryRun bool) (*Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := &Report{DryRun: dryRun, Deleted: []string{}, Failed: []string{}}
+	if !dryRun {
+		expired, err := c.store.ExpireMedia(time.Now(), c.config.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		report.Expired = expired
+	}
+
+	before := time.Now().Add(-time.Duration(c.config.GracePeriodMins) * time.Minute)
+	blobs, err := c.store.UnreferencedBlobs(before, c.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blob := range blobs {
+		if dryRun {
+			report.Deleted = append(report.Deleted, blob.Key)
+			report.Bytes += blob.Size
+			continue
+		}
+
+		deleted, err := c.store.DeleteBlob(blob.Key, before, func() error {
+			return c.files.Delete(context.Background(), blob.Key)
+		})
+		if err != nil {
+			log.Printf("mediagc: failed to delete %s: %v", blob.Key, err)
+			report.Failed = append(report.Failed, blob.Key)
+			continue
+		}
+		// Blobs referenced or reused since they were listed are kept
+		if deleted {
+			report.Deleted = append(report.Deleted, blob.Key)
+			report.Bytes += blob.Size
+		}
+	}
+
+	if report.Expired > 0 {
+		log.Printf("mediagc: expired %d pending uploads", report.Expired)
+	}
+	if len(report.Deleted) > 0 || len(report.Failed) > 0 {
+		verb := "deleted"
+		if dryRun {
+			verb = "would delete"
+		}
+		log.Printf("mediagc: %s %d unreferenced files (%d bytes), %d failed", verb, len(report.Deleted), report.Bytes, len(report.Failed))
+	}
+	return report, nil
+}
+
+// DeleteReleased deletes the files of blobs released at releasedAt, such as
+// those of a replaced profile image, without waiting for the grace period,
+// and returns the keys of the files it deleted. Files still referenced, or
+// reused since, are left to the collector. Failures are logged and also left
+// to the collector.
+func (c *Collector) DeleteReleased(keys []string, releasedAt time.Time) []string {
+	deleted := []string{}
+	for _, key := range keys {
+		ok, err := c.store.DeleteReleasedBlob(key, releasedAt, func() error {
+			return c.files.Delete(context.Background(), key)
+		})
+		if err != nil {
+			log.Printf("mediagc: failed to delete released %s: %v", key, err)
+			continue
+		}
+		if ok {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,204 @@
+package mediagc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// fakeStore keeps blobs in memory; keys in referenced are referenced again
+// between being listed and deleted. Expiring pending media releases the
+// blobs in pending.
+type fakeStore struct {
+	blobs      map[string]models.MediaBlob
+	referenced map[string]bool
+	pending    []string
+	before     time.Time
+}
+
+func (f *fakeStore) ExpireMedia(now time.Time, limit int) (int, error) {
+	expired := f.pending
+	f.pending = nil
+	for _, key := range expired {
+		blob := f.blobs[key]
+		blob.RefCount--
+		blob.UnreferencedAt = &now
+		f.blobs[key] = blob
+	}
+	return len(expired), nil
+}
+
+func (f *fakeStore) UnreferencedBlobs(before time.Time, limit int) ([]models.MediaBlob, error) {
+	f.before = before
+	blobs := []models.MediaBlob{}
+	for _, blob := range f.blobs {
+		if blob.RefCount == 0 && blob.UnreferencedAt.Before(before) && len(blobs) < limit {
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs, nil
+}
+
+func (f *fakeStore) DeleteBlob(key string, before time.Time, remove func() error) (bool, error) {
+	if f.referenced[key] {
+		return false, nil
+	}
+	if err := remove(); err != nil {
+		return false, err
+	}
+	delete(f.blobs, key)
+	return true, nil
+}
+
+func (f *fakeStore) DeleteReleasedBlob(key string, releasedAt time.Time, remove func() error) (bool, error) {
+	blob, ok := f.blobs[key]
+	if !ok || blob.RefCount > 0 || blob.UnreferencedAt == nil || !blob.UnreferencedAt.Equal(releasedAt) {
+		return false, nil
+	}
+	if err := remove(); err != nil {
+		return false, err
+	}
+	delete(f.blobs, key)
+	return true, nil
+}
+
+// failingDeletes fails to delete the file of one key
+type failingDeletes struct {
+	utils.FileStorageInterface
+	key string
+}
+
+func (s *failingDeletes) Delete(ctx context.Context, key string) error {
+	if key == s.key {
+		return errors.New("storage unavailable")
+	}
+	return s.FileStorageInterface.Delete(ctx, key)
+}
+
+func TestCollector_Run(t *testing.T) {
+	ctx := context.Background()
+	files, err := utils.NewLocalStorage(&config.StorageConfig{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now()
+	newStore := func(t *testing.T) *fakeStore {
+		t.Helper()
+		store := &fakeStore{blobs: map[string]models.MediaBlob{}, referenced: map[string]bool{}}
+		for _, blob := range []models.MediaBlob{
+			{Key: "old.jpg", Size: 10, UnreferencedAt: &old},
+			{Key: "failing.jpg", Size: 20, UnreferencedAt: &old},
+			{Key: "reused.jpg", Size: 30, UnreferencedAt: &old},
+			{Key: "recent.jpg", Size: 40, UnreferencedAt: &recent},
+			{Key: "posted.jpg", Size: 50, RefCount: 1},
+			{Key: "pending.jpg", Size: 60, RefCount: 1},
+		} {
+			store.blobs[blob.Key] = blob
+			if _, err := files.Save(ctx, bytes.NewReader([]byte(blob.Key)), utils.ObjectMeta{Key: blob.Key, Size: -1}); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+		}
+		store.referenced["reused.jpg"] = true
+		store.pending = []string{"pending.jpg"}
+		return store
+	}
+	cfg := &config.MediaGCConfig{GracePeriodMins: 24 * 60, BatchSize: 10}
+	stored := func(key string) bool {
+		_, err := files.Stat(ctx, key)
+		return err == nil
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		store := newStore(t)
+		report, err := NewCollector(store, files, cfg).Run(true)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if !report.DryRun || len(report.Deleted) != 3 || report.Bytes != 60 || report.Expired != 0 {
+			t.Errorf("Expected the 3 blobs past the grace period to be reported, got %+v", report)
+		}
+		if len(store.blobs) != 6 || len(store.pending) != 1 || !stored("old.jpg") {
+			t.Error("Expected a dry run to delete and expire nothing")
+		}
+		if since := time.Since(store.before); since < 24*time.Hour || since > 25*time.Hour {
+			t.Errorf("Expected the grace period to be applied, got %v", since)
+		}
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		store := newStore(t)
+		report, err := NewCollector(store, &failingDeletes{FileStorageInterface: files, key: "failing.jpg"}, cfg).Run(false)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(report.Deleted) != 1 || report.Deleted[0] != "old.jpg" || report.Bytes != 10 {
+			t.Errorf("Expected only the unreferenced blob to be deleted, got %+v", report)
+		}
+		if len(report.Failed) != 1 || report.Failed[0] != "failing.jpg" {
+			t.Errorf("Expected the failed deletion to be reported, got %+v", report)
+		}
+		// Files of expired uploads get the grace period before they are deleted
+		if report.Expired != 1 || store.blobs["pending.jpg"].RefCount != 0 {
+			t.Errorf("Expected the pending upload to expire, got %+v", report)
+		}
+		if stored("old.jpg") {
+			t.Error("Expected the unreferenced file to be deleted")
+		}
+		for _, key := range []string{"failing.jpg", "reused.jpg", "recent.jpg", "posted.jpg", "pending.jpg"} {
+			if _, ok := store.blobs[key]; !ok || !stored(key) {
+				t.Errorf("Expected %s to be kept", key)
+			}
+		}
+	})
+}
+
+func TestCollector_DeleteReleased(t *testing.T) {
+	ctx := context.Background()
+	files, err := utils.NewLocalStorage(&config.StorageConfig{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+
+	released := time.Now()
+	later := released.Add(time.Second)
+	store := &fakeStore{blobs: map[string]models.MediaBlob{}}
+	for _, blob := range []models.MediaBlob{
+		{Key: "released.jpg", UnreferencedAt: &released},
+		{Key: "failing.jpg", UnreferencedAt: &released},
+		{Key: "shared.jpg", RefCount: 1},            // still referred to by another user's image
+		{Key: "reused.jpg", UnreferencedAt: &later}, // registered again by an identical upload
+	} {
+		store.blobs[blob.Key] = blob
+		if _, err := files.Save(ctx, bytes.NewReader([]byte(blob.Key)), utils.ObjectMeta{Key: blob.Key, Size: -1}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	collector := NewCollector(store, &failingDeletes{FileStorageInterface: files, key: "failing.jpg"}, &config.MediaGCConfig{})
+	deleted := collector.DeleteReleased([]string{"released.jpg", "failing.jpg", "shared.jpg", "reused.jpg"}, released)
+	if len(deleted) != 1 || deleted[0] != "released.jpg" {
+		t.Errorf("Expected only the released file to be deleted, got %v", deleted)
+	}
+	if _, err := files.Stat(ctx, "released.jpg"); !errors.Is(err, utils.ErrObjectNotFound) {
+		t.Errorf("Expected the released file to be deleted, got %v", err)
+	}
+	for _, key := range []string{"failing.jpg", "shared.jpg", "reused.jpg"} {
+		if _, err := files.Stat(ctx, key); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", key, err)
+		}
+	}
+}
+
+func TestCollector_StartDisabled(t *testing.T) {
+	collector := NewCollector(&fakeStore{}, nil, &config.MediaGCConfig{IntervalMins: 0})
+	collector.Start()
+	collector.Stop()
+}
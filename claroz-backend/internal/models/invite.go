@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InviteCode is a registration code used when registration runs in invite mode
+type InviteCode struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Code        string     `json:"code" gorm:"uniqueIndex;not null" example:"K7QX2M9PLA"`
+	CreatedByID uuid.UUID  `json:"created_by_id" gorm:"type:uuid;not null;index"`
+	MaxUses     int        `json:"max_uses" gorm:"not null;default:1" example:"1"`
+	Uses        int        `json:"uses" gorm:"not null;default:0" example:"0"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" example:"2024-02-26T00:35:27Z"`
+	CreatedAt   time.Time  `json:"created_at" example:"2024-01-26T00:35:27Z"`
+}
+
+func (i *InviteCode) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// NormalizeInviteCode returns the canonical form of an invite code. Codes
+// are stored and matched in upper case, so users may type them in any case.
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Upload states
+const (
+	MediaPending  = "pending"  // uploaded and waiting to be attached; expires unless attached in time
+	MediaAttached = "attached" // attached to a post or profile
+)
+
+// Media is an image uploaded ahead of the post or profile it is attached to.
+// Pending media refer to their blobs until they are attached or expire.
+type Media struct {
+	ID         uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID     `json:"-" gorm:"type:uuid;not null;index"` // the uploader, the only user who can attach it
+	Status     string        `json:"status" gorm:"not null;default:'pending'" example:"pending"`
+	URL        string        `json:"url" gorm:"not null" example:"/uploads/9f86d081.jpg"` // the full-size variant
+	Width      int           `json:"width" gorm:"not null;default:0" example:"1080"`
+	Height     int           `json:"height" gorm:"not null;default:0" example:"1350"`
+	MimeType   string        `json:"mime_type" gorm:"not null" example:"image/jpeg"`
+	Variants   ImageVariants `json:"variants" gorm:"type:jsonb;not null;default:'{}'"`
+	ExpiresAt  time.Time     `json:"expires_at" gorm:"not null;index"` // when pending media are deleted
+	AttachedAt *time.Time    `json:"attached_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// TableName names the table explicitly, since media is already plural
+func (Media) TableName() string {
+	return "media"
+}
+
+// PostMedia returns the media as a post's media item
+func (m *Media) PostMedia(position int, altText string) PostMedia {
+	return PostMedia{
+		Position: position,
+		URL:      m.URL,
+		Width:    m.Width,
+		Height:   m.Height,
+		MimeType: m.MimeType,
+		AltText:  altText,
+		Variants: m.Variants,
+	}
+}
+
+// BlobKeys returns the keys of the blobs the media refers to
+func (m *Media) BlobKeys() []string {
+	item := m.PostMedia(0, "")
+	return item.BlobKeys()
+}
+
+func (m *Media) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// MediaBlob is a stored file. Processed uploads are keyed by the SHA-256 of
+// their content, so identical files share one blob. RefCount is the number of
+// media items referring to the blob; once it has been 0 for the collector's
+// grace period, the file and the blob are deleted.
+type MediaBlob struct {
+	Key            string     `gorm:"primaryKey;size:255"` // the file's storage key
+	Size           int64      `gorm:"not null;default:0"`  // in bytes; 0 if unknown
+	ContentType    string     `gorm:"not null;default:''"`
+	RefCount       int        `gorm:"not null;default:0"`
+	UnreferencedAt *time.Time `gorm:"index"` // when RefCount last became 0; nil while referenced
+	CreatedAt      time.Time
+}
+
+// TableName keeps the table name plural
+func (MediaBlob) TableName() string {
+	return "media_blobs"
+}
+
+// BlobKey returns the storage key of a stored file from its URL, the last
+// element of the URL whichever base it is served from
+func BlobKey(url string) string {
+	return path.Base(url)
+}
+
+// BlobKeys returns the keys of the blobs the variants refer to
+func (v ImageVariants) BlobKeys() []string {
+	keys := make([]string, 0, len(v))
+	for _, variant := range v {
+		keys = append(keys, BlobKey(variant.URL))
+	}
+	return keys
+}
+
+// BlobKeys returns the keys of the blobs the media item refers to
+func (m *PostMedia) BlobKeys() []string {
+	files := m.Files()
+	keys := make([]string, len(files))
+	for i, file := range files {
+		keys[i] = BlobKey(file)
+	}
+	return keys
+}
@@ -1,50 +1,397 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// MaxCommentDepth is the deepest a reply can be nested; top-level comments have depth 0
+const MaxCommentDepth = 3
+
 type Comment struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	PostID    uuid.UUID `gorm:"type:uuid;not null"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
-	Content   string    `gorm:"not null"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	User      User `gorm:"foreignKey:UserID"`
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID     uuid.UUID  `gorm:"type:uuid;not null"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null"`
+	ParentID   *uuid.UUID `gorm:"type:uuid"` // the comment this replies to, nil for top-level comments
+	Depth      int        `gorm:"not null;default:0"`
+	Content    string     `gorm:"not null"`
+	ReplyCount int64      `gorm:"<-:false;not null;default:0"` // maintained by the repository
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time // set when a comment with replies is deleted and kept as a tombstone
+	User       User       `gorm:"foreignKey:UserID"`
 }
 
-type Like struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	PostID    uuid.UUID `gorm:"type:uuid;not null"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+// HeartReaction is the reaction likes are stored as
+const HeartReaction = "heart"
+
+// Reaction is a user's emoji reaction to a post or a comment. Exactly one of
+// PostID and CommentID is set.
+type Reaction struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    *uuid.UUID `gorm:"type:uuid"`
+	CommentID *uuid.UUID `gorm:"type:uuid"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null"`
+	Emoji     string     `gorm:"type:varchar(32);not null"` // the reaction's name, such as "heart"
 	CreatedAt time.Time
 	User      User `gorm:"foreignKey:UserID"`
 }
 
+// Reaction target kinds
+const (
+	ReactionOnPost    = "post"
+	ReactionOnComment = "comment"
+)
+
+// ReactionTarget identifies the post or comment reactions are on
+type ReactionTarget struct {
+	Kind string // one of the ReactionOn* values
+	ID   uuid.UUID
+}
+
+// PostTarget returns the target for reactions on a post
+func PostTarget(id uuid.UUID) ReactionTarget {
+	return ReactionTarget{Kind: ReactionOnPost, ID: id}
+}
+
+// CommentTarget returns the target for reactions on a comment
+func CommentTarget(id uuid.UUID) ReactionTarget {
+	return ReactionTarget{Kind: ReactionOnComment, ID: id}
+}
+
+// Post processing states
+const (
+	PostProcessing = "processing" // a video is being transcoded; the post is hidden from feeds
+	PostReady      = "ready"      // the post's media can be shown
+	PostFailed     = "failed"     // a video could not be transcoded; the post stays hidden from feeds
+)
+
+// DefaultPostLanguage is the text search configuration used for posts that do not specify one
+const DefaultPostLanguage = "simple"
+
 type Post struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	UserID    uuid.UUID `gorm:"type:uuid;not null"`
 	Caption   string
+	Language  string `gorm:"type:regconfig;not null;default:'simple'"` // Postgres text search configuration for the caption
 	ImageURL  string `gorm:"not null"`
+	Status    string `gorm:"not null;default:'ready'"` // one of the Post* states; only ready posts are listed in feeds
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	EditedAt  *time.Time     // set when the caption or language was last edited
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 
-	User     User      `gorm:"foreignKey:UserID"`
-	Likes    []Like    `gorm:"foreignKey:PostID"`
-	Comments []Comment `gorm:"foreignKey:PostID"`
+	// Engagement counters, maintained by the repository rather than written with the post
+	LikeCount    int64 `gorm:"<-:false;not null;default:0"`
+	CommentCount int64 `gorm:"<-:false;not null;default:0"`
+
+	SearchRank float64 `gorm:"->;-:migration" json:"-"` // set in search results, used for pagination
+
+	User      User        `gorm:"foreignKey:UserID"`
+	Media     []PostMedia `gorm:"foreignKey:PostID"` // carousel items in order; ImageURL is the first item's URL, or a video's poster frame
+	Reactions []Reaction  `gorm:"foreignKey:PostID"`
+	Comments  []Comment   `gorm:"foreignKey:PostID"`
+}
+
+// PostMedia is an image in a post's carousel, or a post's video
+type PostMedia struct {
+	ID        uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_post_media_post_position"`
+	Position  int           `gorm:"not null;uniqueIndex:idx_post_media_post_position"` // 0-based order in the carousel
+	URL       string        `gorm:"not null"`
+	Width     int           `gorm:"not null;default:0"` // in pixels; 0 if unknown
+	Height    int           `gorm:"not null;default:0"` // in pixels; 0 if unknown
+	MimeType  string        `gorm:"not null"`
+	AltText   string        `gorm:"not null;default:''"`              // description for screen readers
+	Duration  float64       `gorm:"not null;default:0"`               // in seconds, for videos
+	Variants  ImageVariants `gorm:"type:jsonb;not null;default:'{}'"` // resized renditions by name; URL is the full-size one, or a video's MP4
+	CreatedAt time.Time
+}
+
+// ImageVariant is a resized rendition of an image
+type ImageVariant struct {
+	URL      string `json:"url" example:"/uploads/20240126-550e8400-feed.jpg"`
+	Width    int    `json:"width" example:"1080"`
+	Height   int    `json:"height" example:"1350"`
+	MimeType string `json:"mime_type" example:"image/jpeg"`
+}
+
+// Variant names, from smallest to largest
+const (
+	VariantThumbnail = "thumbnail"
+	VariantFeed      = "feed"
+	VariantFull      = "full"
+)
+
+// ImageVariants maps variant names, such as VariantThumbnail, VariantFeed
+// and VariantFull, to renditions. It is stored as a JSON object.
+type ImageVariants map[string]ImageVariant
+
+// Value stores the variants as JSON
+func (v ImageVariants) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Scan reads variants stored as JSON
+func (v *ImageVariants) Scan(value interface{}) error {
+	var data []byte
+	switch value := value.(type) {
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	case nil:
+		*v = ImageVariants{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into image variants", value)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Srcset returns the image variants as an HTML srcset attribute value,
+// narrowest first, listing each width once. A video's renditions are left
+// out, leaving its poster frame.
+func (v ImageVariants) Srcset() string {
+	variants := make([]ImageVariant, 0, len(v))
+	for _, variant := range v {
+		if strings.HasPrefix(variant.MimeType, "image/") || variant.MimeType == "" {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		if variants[i].Width != variants[j].Width {
+			return variants[i].Width < variants[j].Width
+		}
+		return variants[i].URL < variants[j].URL
+	})
+
+	candidates := make([]string, 0, len(variants))
+	for i, variant := range variants {
+		if i > 0 && variant.Width == variants[i-1].Width {
+			continue
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// TableName keeps "media" uncountable
+func (PostMedia) TableName() string {
+	return "post_media"
+}
+
+// PostView is the representation of a post in feeds and lists. Authors and
+// commenters are summaries, interactions are counted rather than listed, and
+// only the latest comments are included unless the full relations are expanded.
+// Likes are heart reactions, so LikeCount and ViewerHasLiked are also
+// reflected in Reactions and ViewerReactions.
+type PostView struct {
+	ID              uuid.UUID       `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	User            UserSummary     `json:"user"`
+	Caption         string          `json:"caption" example:"Sunset at the beach #travel"`
+	Language        string          `json:"language" example:"english"`
+	ImageURL        string          `json:"image_url" example:"/uploads/550e8400.jpg"` // the first media item's URL, or a video's poster frame
+	Media           []MediaView     `json:"media"`                                     // carousel items in order
+	Status          string          `json:"status" example:"ready" enums:"processing,ready,failed"`
+	CreatedAt       time.Time       `json:"created_at" example:"2024-01-26T00:35:27Z"`
+	UpdatedAt       time.Time       `json:"updated_at" example:"2024-01-26T00:35:27Z"`
+	EditedAt        *time.Time      `json:"edited_at" example:"2024-01-27T09:12:00Z"` // null unless the post was edited
+	LikeCount       int64           `json:"like_count" example:"42"`
+	CommentCount    int64           `json:"comment_count" example:"7"`
+	ViewerHasLiked  bool            `json:"viewer_has_liked" example:"true"`
+	Reactions       []ReactionCount `json:"reactions"`                        // most used first
+	ViewerReactions []string        `json:"viewer_reactions" example:"heart"` // the viewer's reactions
+	RecentComments  []CommentView   `json:"recent_comments"`                  // latest comments, oldest first
+	Comments        []CommentView   `json:"comments,omitempty"`               // set when expanded, oldest first
+	Likes           []ReactionView  `json:"likes,omitempty"`                  // heart reactions, set when expanded, newest first
+}
+
+// CommentView is the representation of a comment in lists. Deleted comments
+// that still have replies are included without their author and content.
+type CommentView struct {
+	ID              uuid.UUID       `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	PostID          uuid.UUID       `json:"post_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	ParentID        *uuid.UUID      `json:"parent_id" example:"550e8400-e29b-41d4-a716-446655440000"` // null for top-level comments
+	User            UserSummary     `json:"user"`
+	Content         string          `json:"content" example:"Great post!"`
+	ReplyCount      int64           `json:"reply_count" example:"3"`
+	Reactions       []ReactionCount `json:"reactions"`                        // most used first
+	ViewerReactions []string        `json:"viewer_reactions" example:"heart"` // the viewer's reactions
+	Deleted         bool            `json:"deleted" example:"false"`
+	CreatedAt       time.Time       `json:"created_at" example:"2024-01-26T00:35:27Z"`
+}
+
+// MediaView is the representation of a post's media item
+type MediaView struct {
+	URL      string                  `json:"url" example:"/uploads/550e8400.jpg"`
+	Width    int                     `json:"width" example:"1080"`
+	Height   int                     `json:"height" example:"1350"`
+	MimeType string                  `json:"mime_type" example:"image/jpeg"`
+	AltText  string                  `json:"alt_text" example:"A sunset over the sea"`
+	Duration float64                 `json:"duration,omitempty" example:"12.5"`                                         // in seconds, for videos
+	Variants map[string]ImageVariant `json:"variants"`                                                                  // renditions by name: thumbnail, feed and full for images; mp4, hls and poster for videos
+	Srcset   string                  `json:"srcset" example:"/uploads/a-thumbnail.jpg 320w, /uploads/a-feed.jpg 1080w"` // the variants for an img srcset attribute
+}
+
+// ReactionCount is how many users reacted to a post or comment with an emoji
+type ReactionCount struct {
+	Emoji string `json:"emoji" example:"heart"`
+	Count int64  `json:"count" example:"12"`
+}
+
+// ReactionView is the representation of a reaction in lists
+type ReactionView struct {
+	User      UserSummary `json:"user"`
+	Emoji     string      `json:"emoji" example:"heart"`
+	CreatedAt time.Time   `json:"created_at" example:"2024-01-26T00:35:27Z"`
+}
+
+// View returns the list representation of the post without interaction
+// counts and viewer state, which are filled in from GetPostStats
+func (p *Post) View() PostView {
+	media := make([]MediaView, len(p.Media))
+	for i, item := range p.Media {
+		media[i] = item.View()
+	}
+	return PostView{
+		ID:              p.ID,
+		User:            p.User.Summary(),
+		Caption:         p.Caption,
+		Language:        p.Language,
+		ImageURL:        p.ImageURL,
+		Media:           media,
+		Status:          p.Status,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		EditedAt:        p.EditedAt,
+		Reactions:       []ReactionCount{},
+		ViewerReactions: []string{},
+		RecentComments:  []CommentView{},
+	}
+}
+
+// View returns the list representation of the comment
+func (c *Comment) View() CommentView {
+	view := CommentView{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentID:        c.ParentID,
+		ReplyCount:      c.ReplyCount,
+		Reactions:       []ReactionCount{},
+		ViewerReactions: []string{},
+		CreatedAt:       c.CreatedAt,
+	}
+	if c.DeletedAt != nil {
+		view.Deleted = true
+		return view
+	}
+	view.User = c.User.Summary()
+	view.Content = c.Content
+	return view
+}
+
+// View returns the representation of the media item in post views
+func (m *PostMedia) View() MediaView {
+	variants := m.Variants
+	if variants == nil {
+		variants = ImageVariants{}
+	}
+	return MediaView{
+		URL:      m.URL,
+		Width:    m.Width,
+		Height:   m.Height,
+		MimeType: m.MimeType,
+		AltText:  m.AltText,
+		Duration: m.Duration,
+		Variants: variants,
+		Srcset:   variants.Srcset(),
+	}
+}
+
+// Files returns the URLs of the media item's stored files: its variants and,
+// for media stored before variants, its URL
+func (m *PostMedia) Files() []string {
+	files := []string{}
+	seen := map[string]bool{}
+	for _, variant := range m.Variants {
+		files = append(files, variant.URL)
+		seen[variant.URL] = true
+	}
+	if m.URL != "" && !seen[m.URL] {
+		files = append(files, m.URL)
+	}
+	return files
+}
+
+// View returns the list representation of the reaction
+func (r *Reaction) View() ReactionView {
+	return ReactionView{User: r.User.Summary(), Emoji: r.Emoji, CreatedAt: r.CreatedAt}
+}
+
+// PostRevision is a previous version of an edited post
+type PostRevision struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	PostID     uuid.UUID `json:"post_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Caption    string    `json:"caption" example:"Sunset at the beach"`
+	Language   string    `json:"language" gorm:"type:regconfig;not null" example:"english"`
+	CreatedAt  time.Time `json:"created_at" example:"2024-01-26T00:35:27Z"`  // when this version was published
+	ReplacedAt time.Time `json:"replaced_at" example:"2024-01-27T09:12:00Z"` // when an edit replaced it
+}
+
+func (r *PostRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Hashtag is a normalized hashtag, stored lowercase without the leading #
+type Hashtag struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name      string    `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time
+}
+
+// PostHashtag links a post to a hashtag in its caption
+type PostHashtag struct {
+	PostID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	HashtagID uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+}
+
+func (h *Hashtag) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
 }
 
 func (p *Post) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
 	}
-	if p.Likes == nil {
-		p.Likes = []Like{}
+	if p.Language == "" {
+		p.Language = DefaultPostLanguage
+	}
+	if p.Status == "" {
+		p.Status = PostReady
+	}
+	if p.Media == nil {
+		p.Media = []PostMedia{}
+	}
+	if p.Reactions == nil {
+		p.Reactions = []Reaction{}
 	}
 	if p.Comments == nil {
 		p.Comments = []Comment{}
@@ -59,9 +406,25 @@ func (c *Comment) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-func (l *Like) BeforeCreate(tx *gorm.DB) error {
-	if l.ID == uuid.Nil {
-		l.ID = uuid.New()
+func (m *PostMedia) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
 	}
 	return nil
 }
+
+func (r *Reaction) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// TimelineEntry is a post materialized into a user's home timeline. AuthorID
+// is denormalized so entries can be pruned on unfollow and block.
+type TimelineEntry struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PostID    uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	AuthorID  uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
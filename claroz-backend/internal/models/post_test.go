@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -108,26 +111,28 @@ func TestCommentBeforeCreate(t *testing.T) {
 	}
 }
 
-func TestLikeBeforeCreate(t *testing.T) {
+func TestReactionBeforeCreate(t *testing.T) {
 	tests := []struct {
 		name     string
-		like     *Like
+		reaction *Reaction
 		wantUUID bool
 	}{
 		{
 			name: "should generate UUID if nil",
-			like: &Like{
-				PostID: uuid.New(),
+			reaction: &Reaction{
+				PostID: ptr(uuid.New()),
 				UserID: uuid.New(),
+				Emoji:  HeartReaction,
 			},
 			wantUUID: true,
 		},
 		{
 			name: "should keep existing UUID",
-			like: &Like{
-				ID:     uuid.New(),
-				PostID: uuid.New(),
-				UserID: uuid.New(),
+			reaction: &Reaction{
+				ID:        uuid.New(),
+				CommentID: ptr(uuid.New()),
+				UserID:    uuid.New(),
+				Emoji:     HeartReaction,
 			},
 			wantUUID: false,
 		},
@@ -135,8 +140,8 @@ func TestLikeBeforeCreate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			originalID := tt.like.ID
-			err := tt.like.BeforeCreate(&gorm.DB{})
+			originalID := tt.reaction.ID
+			err := tt.reaction.BeforeCreate(&gorm.DB{})
 
 			if err != nil {
 				t.Errorf("BeforeCreate() error = %v", err)
@@ -144,11 +149,11 @@ func TestLikeBeforeCreate(t *testing.T) {
 			}
 
 			if tt.wantUUID {
-				if tt.like.ID == uuid.Nil {
+				if tt.reaction.ID == uuid.Nil {
 					t.Error("BeforeCreate() did not generate UUID")
 				}
 			} else {
-				if tt.like.ID != originalID {
+				if tt.reaction.ID != originalID {
 					t.Error("BeforeCreate() modified existing UUID")
 				}
 			}
@@ -164,7 +169,7 @@ func TestPostModel(t *testing.T) {
 		ImageURL:  "test.jpg",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Likes:     []Like{},
+		Reactions: []Reaction{},
 		Comments:  []Comment{},
 	}
 
@@ -185,8 +190,8 @@ func TestPostModel(t *testing.T) {
 	}
 
 	// Test relationships initialization
-	if post.Likes == nil {
-		t.Error("Likes should be initialized")
+	if post.Reactions == nil {
+		t.Error("Reactions should be initialized")
 	}
 	if post.Comments == nil {
 		t.Error("Comments should be initialized")
@@ -222,23 +227,183 @@ func TestCommentModel(t *testing.T) {
 	}
 }
 
-func TestLikeModel(t *testing.T) {
-	like := Like{
-		PostID:    uuid.New(),
+func TestReactionModel(t *testing.T) {
+	reaction := Reaction{
+		PostID:    ptr(uuid.New()),
 		UserID:    uuid.New(),
+		Emoji:     HeartReaction,
 		CreatedAt: time.Now(),
 	}
 
 	// Test required fields
-	if like.PostID == uuid.Nil {
+	if reaction.PostID == nil || *reaction.PostID == uuid.Nil {
 		t.Error("PostID should not be nil")
 	}
-	if like.UserID == uuid.Nil {
+	if reaction.UserID == uuid.Nil {
 		t.Error("UserID should not be nil")
 	}
+	if reaction.Emoji == "" {
+		t.Error("Emoji should not be empty")
+	}
 
 	// Test timestamp
-	if like.CreatedAt.IsZero() {
+	if reaction.CreatedAt.IsZero() {
 		t.Error("CreatedAt should not be zero")
 	}
+
+	view := reaction.View()
+	if view.Emoji != HeartReaction || !view.CreatedAt.Equal(reaction.CreatedAt) {
+		t.Errorf("View() did not describe the reaction: %+v", view)
+	}
+}
+
+// ptr returns a pointer to a copy of id
+func ptr(id uuid.UUID) *uuid.UUID {
+	return &id
+}
+
+func TestPostView(t *testing.T) {
+	author := User{ID: uuid.New(), Username: "author", Email: "author@example.com"}
+	commenter := User{ID: uuid.New(), Username: "commenter", Email: "commenter@example.com"}
+	post := &Post{ID: uuid.New(), UserID: author.ID, Caption: "Test post", User: author}
+	comment := &Comment{ID: uuid.New(), PostID: post.ID, UserID: commenter.ID, Content: "Nice", User: commenter}
+
+	view := post.View()
+	view.RecentComments = []CommentView{comment.View()}
+	if view.User.Username != "author" || view.RecentComments[0].User.Username != "commenter" {
+		t.Errorf("View() did not summarize the author and commenter: %+v", view)
+	}
+
+	data, err := json.Marshal(view)
+	if err != nil {
+		t.Fatalf("Failed to marshal view: %v", err)
+	}
+	if strings.Contains(string(data), "example.com") {
+		t.Errorf("View() exposed user emails: %s", data)
+	}
+	if !strings.Contains(string(data), `"recent_comments":[{`) || strings.Contains(string(data), `"likes"`) {
+		t.Errorf("View() should include the comment preview and omit unexpanded relations: %s", data)
+	}
+}
+
+func TestPostMediaView(t *testing.T) {
+	post := &Post{
+		ID:       uuid.New(),
+		ImageURL: "first-full.jpg",
+		Media: []PostMedia{
+			{
+				Position: 0, URL: "first-full.jpg", Width: 1080, Height: 1350, MimeType: "image/jpeg", AltText: "A sunset",
+				Variants: ImageVariants{
+					"full":      {URL: "first-full.jpg", Width: 1080, Height: 1350, MimeType: "image/jpeg"},
+					"feed":      {URL: "first-feed.jpg", Width: 1080, Height: 1350, MimeType: "image/jpeg"},
+					"thumbnail": {URL: "first-thumbnail.jpg", Width: 256, Height: 320, MimeType: "image/jpeg"},
+				},
+			},
+			{Position: 1, URL: "second.png", Width: 640, Height: 480, MimeType: "image/png"},
+		},
+	}
+	for i := range post.Media {
+		if err := post.Media[i].BeforeCreate(&gorm.DB{}); err != nil || post.Media[i].ID == uuid.Nil {
+			t.Fatalf("BeforeCreate() did not generate UUID: %v", err)
+		}
+	}
+
+	view := post.View()
+	if len(view.Media) != 2 || view.ImageURL != view.Media[0].URL {
+		t.Fatalf("View() should list the media with the first as the image: %+v", view)
+	}
+	first := view.Media[0]
+	if first.URL != "first-full.jpg" || first.Width != 1080 || first.Height != 1350 || first.MimeType != "image/jpeg" || first.AltText != "A sunset" {
+		t.Errorf("View() did not describe the media: %+v", first)
+	}
+	if len(first.Variants) != 3 || first.Variants["thumbnail"].Width != 256 {
+		t.Errorf("View() did not include the variants: %+v", first.Variants)
+	}
+	if want := "first-thumbnail.jpg 256w, first-feed.jpg 1080w"; first.Srcset != want {
+		t.Errorf("Expected srcset %q listing each width once, got %q", want, first.Srcset)
+	}
+	if second := view.Media[1]; second.URL != "second.png" || second.AltText != "" || second.Variants == nil || second.Srcset != "" {
+		t.Errorf("View() should describe media without variants: %+v", second)
+	}
+
+	empty := (&Post{ID: uuid.New()}).View()
+	if empty.Media == nil {
+		t.Error("View() should list no media as an empty array")
+	}
+}
+
+func TestImageVariants(t *testing.T) {
+	variants := ImageVariants{"feed": {URL: "a-feed.jpg", Width: 1080, Height: 720, MimeType: "image/jpeg"}}
+	value, err := variants.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+
+	var scanned ImageVariants
+	if err := scanned.Scan([]byte(value.(string))); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if scanned["feed"] != variants["feed"] {
+		t.Errorf("Expected %+v, got %+v", variants, scanned)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned == nil || len(scanned) != 0 {
+		t.Errorf("Expected NULL to scan as no variants, got %+v, %v", scanned, err)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("Expected an error scanning a number")
+	}
+	if value, _ := ImageVariants(nil).Value(); value != "{}" {
+		t.Errorf("Expected no variants to be stored as an empty object, got %v", value)
+	}
+
+	media := PostMedia{URL: "a-full.jpg", Variants: ImageVariants{
+		"full": {URL: "a-full.jpg"},
+		"feed": {URL: "a-feed.jpg"},
+	}}
+	if files := media.Files(); len(files) != 2 {
+		t.Errorf("Expected each stored file once, got %v", files)
+	}
+	legacy := PostMedia{URL: "old.jpg"}
+	if files := legacy.Files(); len(files) != 1 || files[0] != "old.jpg" {
+		t.Errorf("Expected the URL of media without variants, got %v", files)
+	}
+}
+
+func TestCommentView(t *testing.T) {
+	commenter := User{ID: uuid.New(), Username: "commenter"}
+	parentID := uuid.New()
+	comment := &Comment{ID: uuid.New(), ParentID: &parentID, Depth: 1, Content: "Nice", ReplyCount: 2, User: commenter}
+
+	view := comment.View()
+	if view.Deleted || view.Content != "Nice" || view.User.Username != "commenter" || *view.ParentID != parentID || view.ReplyCount != 2 {
+		t.Errorf("View() did not describe the comment: %+v", view)
+	}
+
+	now := time.Now()
+	comment.DeletedAt = &now
+	view = comment.View()
+	if !view.Deleted || view.Content != "" || view.User.Username != "" {
+		t.Errorf("View() should hide the author and content of a deleted comment: %+v", view)
+	}
+	if view.ReplyCount != 2 || *view.ParentID != parentID {
+		t.Errorf("View() should keep a deleted comment's place in its thread: %+v", view)
+	}
+}
+
+func TestPostMedia_BlobKeys(t *testing.T) {
+	media := PostMedia{
+		URL: "https://cdn.example.com/uploads/abc.jpg",
+		Variants: ImageVariants{
+			"full":      {URL: "https://cdn.example.com/uploads/abc.jpg"},
+			"thumbnail": {URL: "https://cdn.example.com/uploads/def.webp"},
+		},
+	}
+	keys := media.BlobKeys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "abc.jpg" || keys[1] != "def.webp" {
+		t.Errorf("Expected the distinct keys of the media's files, got %v", keys)
+	}
+	if key := BlobKey("/uploads/legacy.png"); key != "legacy.png" {
+		t.Errorf("Expected the key of a local URL, got %s", key)
+	}
 }
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ResumableUpload is a file uploaded in parts with the tus protocol, so an
+// interrupted upload can resume where it stopped. Each part is stored as its
+// own object until the upload is complete, when the parts are processed into
+// pending media and deleted.
+type ResumableUpload struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Length    *int64         `gorm:"column:upload_length"`                    // declared size in bytes; nil while deferred
+	Offset    int64          `gorm:"column:upload_offset;not null;default:0"` // bytes received
+	Metadata  UploadMetadata `gorm:"type:jsonb;not null;default:'{}'"`        // Upload-Metadata pairs, decoded
+	Parts     UploadParts    `gorm:"type:jsonb;not null;default:'[]'"`        // stored parts in order
+	MediaID   *uuid.UUID     `gorm:"type:uuid"`                               // the media the upload became, once complete
+	ExpiresAt time.Time      `gorm:"not null;index"`                          // pushed back by every part received
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// UploadPart is a stored part of a resumable upload
+type UploadPart struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// UploadParts are the stored parts of a resumable upload, in order
+type UploadParts []UploadPart
+
+// UploadMetadata is the metadata a client sent when creating an upload
+type UploadMetadata map[string]string
+
+// Complete reports whether every byte of the upload has been received
+func (u *ResumableUpload) Complete() bool {
+	return u.Length != nil && u.Offset == *u.Length
+}
+
+func (u *ResumableUpload) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
+
+// Value stores the parts as JSON
+func (p UploadParts) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(p)
+	return string(data), err
+}
+
+// Scan reads parts stored as JSON
+func (p *UploadParts) Scan(value interface{}) error {
+	if value == nil {
+		*p = UploadParts{}
+		return nil
+	}
+	return scanJSON(value, p, "upload parts")
+}
+
+// Value stores the metadata as JSON
+func (m UploadMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
+
+// Scan reads metadata stored as JSON
+func (m *UploadMetadata) Scan(value interface{}) error {
+	if value == nil {
+		*m = UploadMetadata{}
+		return nil
+	}
+	return scanJSON(value, m, "upload metadata")
+}
+
+// scanJSON decodes a JSON column into dest
+func scanJSON(value interface{}, dest interface{}, name string) error {
+	switch value := value.(type) {
+	case []byte:
+		return json.Unmarshal(value, dest)
+	case string:
+		return json.Unmarshal([]byte(value), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %s", value, name)
+	}
+}
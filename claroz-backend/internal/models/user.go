@@ -7,12 +7,76 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Profile images, uploaded and cropped to their standard shapes
+const (
+	ProfileAvatar = "avatar" // square
+	ProfileBanner = "banner" // wide
+)
+
+// User account statuses
+const (
+	StatusActive   = "active"
+	StatusPending  = "pending"  // waiting in the registration approval queue
+	StatusRejected = "rejected" // registration was rejected by an admin
+)
+
+// Follow statuses returned when following a user
+const (
+	FollowStatusFollowing = "following"
+	FollowStatusRequested = "requested" // the account is private and a follow request is pending
+)
+
 type UserFollow struct {
 	FollowerID  uuid.UUID `gorm:"type:uuid;not null"`
 	FollowingID uuid.UUID `gorm:"type:uuid;not null"`
 	CreatedAt   time.Time
 }
 
+// FollowRequest is a pending request to follow a private account
+type FollowRequest struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	RequesterID uuid.UUID `json:"requester_id" gorm:"type:uuid;not null;uniqueIndex:idx_follow_requests_pair"`
+	TargetID    uuid.UUID `json:"target_id" gorm:"type:uuid;not null;uniqueIndex:idx_follow_requests_pair;index"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Requester User `json:"-" gorm:"foreignKey:RequesterID"`
+	Target    User `json:"-" gorm:"foreignKey:TargetID"`
+}
+
+func (f *FollowRequest) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+// UserBlock records that BlockerID has blocked BlockedID. Blocks hide each
+// party's content from the other and prevent follows and interactions.
+type UserBlock struct {
+	BlockerID uuid.UUID `json:"blocker_id" gorm:"type:uuid;primaryKey"`
+	BlockedID uuid.UUID `json:"blocked_id" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Blocked User `json:"-" gorm:"foreignKey:BlockedID"`
+}
+
+// UserMute records that MuterID has muted MutedID. Mutes only hide the muted
+// user's content from the muter and lapse at ExpiresAt when it is set.
+type UserMute struct {
+	MuterID   uuid.UUID  `json:"muter_id" gorm:"type:uuid;primaryKey"`
+	MutedID   uuid.UUID  `json:"muted_id" gorm:"type:uuid;primaryKey"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Muted User `json:"-" gorm:"foreignKey:MutedID"`
+}
+
 // User represents a user in the system
 type User struct {
 	ID                 uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()" example:"550e8400-e29b-41d4-a716-446655440000"`
@@ -21,20 +85,46 @@ type User struct {
 	Password           string         `json:"-" gorm:"not null"` // "-" excludes from JSON
 	FullName           string         `json:"full_name" example:"John Doe"`
 	Bio                string         `json:"bio" example:"Software engineer and tech enthusiast"`
-	Avatar             string         `json:"avatar" example:"https://example.com/avatar.jpg"`
+	Avatar             string         `json:"avatar" example:"/uploads/9f86d081.jpg"`                           // uploaded for local users; remote users' come from their profile
+	AvatarVariants     ImageVariants  `json:"avatar_variants" gorm:"type:jsonb;not null;default:'{}';<-:false"` // the uploaded avatar's files, whose blobs it refers to; set by the repository
+	AvatarMediaID      *uuid.UUID     `json:"-" gorm:"type:uuid;<-:false"`                                      // the uploaded media an avatar from before avatars were cropped is; set by the repository
+	Banner             string         `json:"banner" gorm:"not null;default:'';<-:false" example:"/uploads/2c26b46b.jpg"`
+	BannerVariants     ImageVariants  `json:"banner_variants" gorm:"type:jsonb;not null;default:'{}';<-:false"` // the uploaded banner's files, whose blobs it refers to; set by the repository
 	DID                string         `json:"did" gorm:"uniqueIndex" example:"did:web:example.com"`
 	Handle             string         `json:"handle" gorm:"uniqueIndex" example:"@johndoe"`
 	FederationType     string         `json:"federation_type" gorm:"default:local" example:"local"`
+	Role               string         `json:"role" gorm:"default:user" example:"user"`
+	Status             string         `json:"status" gorm:"default:active" example:"active"`
+	IsPrivate          bool           `json:"is_private" gorm:"not null;default:false" example:"false"`
 	LastFederationSync time.Time      `json:"last_federation_sync" example:"2024-01-26T00:35:27Z"`
 	CreatedAt          time.Time      `json:"created_at" example:"2024-01-26T00:35:27Z"`
 	UpdatedAt          time.Time      `json:"updated_at" example:"2024-01-26T00:35:27Z"`
 	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Follow counters, maintained by the repository rather than written with the user
+	FollowersCount int64 `json:"-" gorm:"<-:false;not null;default:0"`
+	FollowingCount int64 `json:"-" gorm:"<-:false;not null;default:0"`
+
 	// Self-referential many-to-many relationships for followers/following
 	Followers []User `json:"followers,omitempty" gorm:"many2many:user_follows;foreignKey:ID;joinForeignKey:FollowingID;References:ID;joinReferences:FollowerID"`
 	Following []User `json:"following,omitempty" gorm:"many2many:user_follows;foreignKey:ID;joinForeignKey:FollowerID;References:ID;joinReferences:FollowingID"`
 }
 
+// UserSummary is a lightweight user representation for lists, with the
+// relationship between the listed user and the viewer
+type UserSummary struct {
+	ID             uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Username       string     `json:"username" example:"johndoe"`
+	FullName       string     `json:"full_name" example:"John Doe"`
+	Avatar         string     `json:"avatar" example:"https://example.com/avatar.jpg"`
+	Handle         string     `json:"handle" example:"@johndoe"`
+	FederationType string     `json:"federation_type" example:"local"`
+	FollowedAt     *time.Time `json:"followed_at,omitempty" example:"2024-01-26T00:35:27Z"` // set in follower and following lists
+	ViewerFollows  bool       `json:"viewer_follows" example:"true"`
+	FollowsViewer  bool       `json:"follows_viewer" example:"false"`
+	SearchRank     float64    `json:"-"` // set in search results, used for pagination
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
@@ -47,3 +137,25 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Summary returns the lightweight list representation of the user
+func (u *User) Summary() UserSummary {
+	return UserSummary{
+		ID:             u.ID,
+		Username:       u.Username,
+		FullName:       u.FullName,
+		Avatar:         u.Avatar,
+		Handle:         u.Handle,
+		FederationType: u.FederationType,
+	}
+}
+
+// IsActive reports whether the user is allowed to log in
+func (u *User) IsActive() bool {
+	return u.Status == "" || u.Status == StatusActive
+}
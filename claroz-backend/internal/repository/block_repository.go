@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBlocked is returned when an interaction is refused because one user has blocked the other
+var ErrBlocked = errors.New("user is blocked")
+
+// BlockUser blocks a user and removes any follows and pending follow
+// requests between the two users in either direction. Repeating a block is a no-op.
+func (r *PostRepository) BlockUser(blockerID, blockedID uuid.UUID) error {
+	if err := r.ensureUserExists(blockedID); err != nil {
+		return err
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		block := models.UserBlock{
+			BlockerID: blockerID,
+			BlockedID: blockedID,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+			return err
+		}
+
+		if err := removeFollow(tx, blockerID, blockedID); err != nil {
+			return err
+		}
+		if err := removeFollow(tx, blockedID, blockerID); err != nil {
+			return err
+		}
+
+		return tx.Where("(requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)",
+			blockerID, blockedID, blockedID, blockerID).
+			Delete(&models.FollowRequest{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.Blocked(blockerID, blockedID) })
+	return nil
+}
+
+// UnblockUser removes a block. Follows removed by the block are not restored.
+func (r *PostRepository) UnblockUser(blockerID, blockedID uuid.UUID) error {
+	return r.db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&models.UserBlock{}).Error
+}
+
+// GetBlockedUsers retrieves the users a user has blocked, newest first
+func (r *PostRepository) GetBlockedUsers(userID uuid.UUID) ([]models.UserBlock, error) {
+	var blocks []models.UserBlock
+	err := r.db.
+		Preload("Blocked").
+		Where("blocker_id = ?", userID).
+		Order("created_at DESC").
+		Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// IsBlocked checks if either user has blocked the other
+func (r *PostRepository) IsBlocked(userID, otherID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)",
+			userID, otherID, otherID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// MuteUser mutes a user until expiresAt, or indefinitely if expiresAt is
+// nil. Muting an already muted user replaces the expiry.
+func (r *PostRepository) MuteUser(muterID, mutedID uuid.UUID, expiresAt *time.Time) error {
+	if err := r.ensureUserExists(mutedID); err != nil {
+		return err
+	}
+
+	mute := models.UserMute{
+		MuterID:   muterID,
+		MutedID:   mutedID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "muter_id"}, {Name: "muted_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "created_at"}),
+	}).Create(&mute).Error
+}
+
+// UnmuteUser removes a mute
+func (r *PostRepository) UnmuteUser(muterID, mutedID uuid.UUID) error {
+	return r.db.Where("muter_id = ? AND muted_id = ?", muterID, mutedID).
+		Delete(&models.UserMute{}).Error
+}
+
+// GetMutedUsers retrieves a user's unexpired mutes, newest first
+func (r *PostRepository) GetMutedUsers(userID uuid.UUID) ([]models.UserMute, error) {
+	var mutes []models.UserMute
+	err := r.db.
+		Preload("Muted").
+		Where("muter_id = ? AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&mutes).Error
+	if err != nil {
+		return nil, err
+	}
+	return mutes, nil
+}
+
+// isBlockedWithPostAuthor checks if a user and the author of a post have blocked each other
+func (r *PostRepository) isBlockedWithPostAuthor(userID, postID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserBlock{}).
+		Joins("JOIN posts ON posts.id = ?", postID).
+		Where("(user_blocks.blocker_id = posts.user_id AND user_blocks.blocked_id = ?) OR (user_blocks.blocker_id = ? AND user_blocks.blocked_id = posts.user_id)",
+			userID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// notBlockedWith excludes rows whose userColumn is a user who has blocked,
+// or been blocked by, the viewer
+func notBlockedWith(viewerID uuid.UUID, userColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`NOT EXISTS (SELECT 1 FROM user_blocks ub
+			WHERE (ub.blocker_id = ? AND ub.blocked_id = `+userColumn+`)
+			OR (ub.blocker_id = `+userColumn+` AND ub.blocked_id = ?))`,
+			viewerID, viewerID)
+	}
+}
+
+// notMutedBy excludes rows whose userColumn is a user the viewer has an unexpired mute on
+func notMutedBy(viewerID uuid.UUID, userColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`NOT EXISTS (SELECT 1 FROM user_mutes um
+			WHERE um.muter_id = ? AND um.muted_id = `+userColumn+`
+			AND (um.expires_at IS NULL OR um.expires_at > ?))`,
+			viewerID, time.Now())
+	}
+}
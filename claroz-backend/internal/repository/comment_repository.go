@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCommentNotFound is returned when an operation references a comment
+// that does not exist or has been deleted
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrCommentTooDeep is returned when a reply would be nested deeper than models.MaxCommentDepth
+var ErrCommentTooDeep = errors.New("comment is nested too deeply")
+
+// AddComment adds a comment to a post, or a reply to the comment ParentID
+// refers to, and loads its author. It returns ErrPostNotFound if the post
+// does not exist, ErrCommentNotFound if the parent is not a live comment on
+// the post, ErrCommentTooDeep if the parent is at the maximum depth and
+// ErrBlocked if the commenter and the post's author have blocked each other.
+func (r *PostRepository) AddComment(comment *models.Comment) error {
+	blocked, err := r.isBlockedWithPostAuthor(comment.UserID, comment.PostID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&models.Post{}, "id = ?", comment.PostID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPostNotFound
+			}
+			return err
+		}
+
+		comment.Depth = 0
+		if comment.ParentID != nil {
+			// Lock the parent so it cannot be deleted while the reply is added
+			var parent models.Comment
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&parent, "id = ? AND post_id = ? AND deleted_at IS NULL", *comment.ParentID, comment.PostID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCommentNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if parent.Depth >= models.MaxCommentDepth {
+				return ErrCommentTooDeep
+			}
+			comment.Depth = parent.Depth + 1
+		}
+
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if comment.ParentID != nil {
+			if err := adjustCounter(tx, "comments", "reply_count", *comment.ParentID, 1); err != nil {
+				return err
+			}
+		}
+		return adjustCounter(tx, "posts", "comment_count", comment.PostID, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return r.db.First(&comment.User, "id = ?", comment.UserID).Error
+}
+
+// GetComment retrieves a comment by ID with its author
+func (r *PostRepository) GetComment(id uuid.UUID) (*models.Comment, error) {
+	var comment models.Comment
+	if err := r.db.Preload("User").First(&comment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetComments retrieves a post's top-level comments, oldest first, excluding
+// comments by users who have blocked, or been blocked by, the viewer
+func (r *PostRepository) GetComments(postID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Comment, error) {
+	query := r.db.Where("comments.post_id = ? AND comments.parent_id IS NULL", postID)
+	return r.findComments(query, viewerID, cursor, limit)
+}
+
+// GetReplies retrieves the direct replies to a comment, oldest first,
+// excluding replies by users who have blocked, or been blocked by, the viewer
+func (r *PostRepository) GetReplies(commentID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Comment, error) {
+	return r.findComments(r.db.Where("comments.parent_id = ?", commentID), viewerID, cursor, limit)
+}
+
+// DeleteComment deletes a comment and its reactions. A comment with replies
+// is kept as a tombstone, without its content, so its thread stays intact;
+// tombstones are removed once their last reply is deleted.
+func (r *PostRepository) DeleteComment(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var comment models.Comment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCommentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if comment.DeletedAt != nil {
+			return nil
+		}
+
+		if err := adjustCounter(tx, "posts", "comment_count", comment.PostID, -1); err != nil {
+			return err
+		}
+		if comment.ReplyCount > 0 {
+			if err := tx.Delete(&models.Reaction{}, "comment_id = ?", comment.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&comment).Updates(map[string]interface{}{
+				"content":    "",
+				"deleted_at": time.Now(),
+			}).Error
+		}
+		return removeComment(tx, comment)
+	})
+}
+
+// removeComment deletes a comment without replies, then any tombstoned
+// ancestors it leaves without replies, along with their reactions. Reactions
+// have no foreign key to comments outside the migrations, so they are
+// deleted here rather than relying on a cascade.
+func removeComment(tx *gorm.DB, comment models.Comment) error {
+	for {
+		if err := tx.Delete(&models.Reaction{}, "comment_id = ?", comment.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Comment{}, "id = ?", comment.ID).Error; err != nil {
+			return err
+		}
+		if comment.ParentID == nil {
+			return nil
+		}
+		if err := adjustCounter(tx, "comments", "reply_count", *comment.ParentID, -1); err != nil {
+			return err
+		}
+
+		var parent models.Comment
+		if err := tx.First(&parent, "id = ?", *comment.ParentID).Error; err != nil {
+			return err
+		}
+		if parent.DeletedAt == nil || parent.ReplyCount > 0 {
+			return nil
+		}
+		comment = parent
+	}
+}
+
+// findComments pages a comments query by the (created_at, id) keyset, oldest first
+func (r *PostRepository) findComments(query *gorm.DB, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Comment, error) {
+	query = query.Scopes(notBlockedWith(viewerID, "comments.user_id"))
+	if cursor != nil {
+		query = query.Where("(comments.created_at, comments.id) > (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	comments := []models.Comment{}
+	err := query.
+		Preload("User").
+		Order("comments.created_at ASC").
+		Order("comments.id ASC").
+		Limit(limit).
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
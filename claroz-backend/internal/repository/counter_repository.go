@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// counter is a denormalized count of the rows in source whose key column
+// references a row of table and, if filter is set, that match it
+type counter struct {
+	table, column, source, key, filter string
+}
+
+var counters = []counter{
+	{"posts", "like_count", "reactions", "post_id", "s.emoji = '" + models.HeartReaction + "'"},
+	{"posts", "comment_count", "comments", "post_id", "s.deleted_at IS NULL"},
+	{"comments", "reply_count", "comments", "parent_id", ""},
+	{"users", "followers_count", "user_follows", "following_id", ""},
+	{"users", "following_count", "user_follows", "follower_id", ""},
+}
+
+// ReconcileCounters recounts every stored counter and repairs the ones that
+// have drifted, returning what it repaired. Corrections are applied as a
+// delta, so changes committed while the counts were taken are kept.
+func (r *PostRepository) ReconcileCounters() ([]CounterDrift, error) {
+	drifts := []CounterDrift{}
+	for _, c := range counters {
+		var rows []struct {
+			ID     uuid.UUID
+			Stored int64
+			Actual int64
+		}
+		filter := ""
+		if c.filter != "" {
+			filter = " AND " + c.filter
+		}
+		query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = %[1]s.%[2]s + drift.actual - drift.stored
+			FROM (
+				SELECT t.id, t.%[2]s AS stored, (SELECT COUNT(*) FROM %[3]s s WHERE s.%[4]s = t.id%[5]s) AS actual
+				FROM %[1]s t
+			) AS drift
+			WHERE %[1]s.id = drift.id AND drift.stored <> drift.actual
+			RETURNING %[1]s.id, drift.stored, drift.actual`,
+			c.table, c.column, c.source, c.key, filter)
+		if err := r.db.Raw(query).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("reconcile %s.%s: %w", c.table, c.column, err)
+		}
+		for _, row := range rows {
+			drifts = append(drifts, CounterDrift{
+				Counter: c.table + "." + c.column,
+				ID:      row.ID,
+				Stored:  row.Stored,
+				Actual:  row.Actual,
+			})
+		}
+	}
+	return drifts, nil
+}
+
+// adjustCounter atomically adds delta to a counter column, so concurrent
+// writers never read and overwrite each other's counts
+func adjustCounter(tx *gorm.DB, table, column string, id uuid.UUID, delta int) error {
+	return tx.Exec(fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[2]s + ? WHERE id = ?", table, column), delta, id).Error
+}
+
+// adjustFollowCounts adds delta to the follower's following count and the
+// followed user's followers count. The rows are updated in ID order so
+// concurrent follows between the same two users cannot deadlock.
+func adjustFollowCounts(tx *gorm.DB, followerID, followingID uuid.UUID, delta int) error {
+	updates := []struct {
+		id     uuid.UUID
+		column string
+	}{
+		{followerID, "following_count"},
+		{followingID, "followers_count"},
+	}
+	if bytes.Compare(followingID[:], followerID[:]) < 0 {
+		updates[0], updates[1] = updates[1], updates[0]
+	}
+	for _, u := range updates {
+		if err := adjustCounter(tx, "users", u.column, u.id, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFollow creates a follow and updates both users' counts, reporting
+// whether the follow is new
+func addFollow(tx *gorm.DB, follow *models.UserFollow) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(follow)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, adjustFollowCounts(tx, follow.FollowerID, follow.FollowingID, 1)
+}
+
+// removeFollow deletes a follow, if it exists, and updates both users' counts
+func removeFollow(tx *gorm.DB, followerID, followingID uuid.UUID) error {
+	result := tx.Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Delete(&models.UserFollow{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return adjustFollowCounts(tx, followerID, followingID, -1)
+}
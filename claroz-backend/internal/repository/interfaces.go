@@ -9,9 +9,29 @@ type UserRepositoryInterface interface {
 	Create(user *models.User) error
 	GetByID(id uuid.UUID) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
 	Update(user *models.User) error
 	Delete(id uuid.UUID) error
 	FindByHandle(handle string) (*models.User, error)
 	FindByDID(did string) (*models.User, error)
 	GetRemoteUsers() ([]*models.User, error)
+	GetUsersByStatus(status string) ([]*models.User, error)
+	SearchUsers(viewerID uuid.UUID, query, federationType string, cursor *Cursor, limit int) ([]models.UserSummary, error)
+	SetAvatar(userID, mediaID uuid.UUID, variants models.ImageVariants) (*models.User, *ReleasedBlobs, error)
+	SetProfileImage(userID uuid.UUID, kind string, variants models.ImageVariants) (*models.User, *ReleasedBlobs, error)
+}
+
+type InviteRepositoryInterface interface {
+	Create(invite *models.InviteCode) error
+	GetByID(id uuid.UUID) (*models.InviteCode, error)
+	GetByCode(code string) (*models.InviteCode, error)
+	ListByCreator(userID uuid.UUID) ([]*models.InviteCode, error)
+	Delete(id uuid.UUID) error
+	Redeem(code string) (*models.InviteCode, error)
+	Release(id uuid.UUID) error
+}
+
+type MediaRepositoryInterface interface {
+	CreateMedia(media *models.Media) error
+	GetMedia(id, userID uuid.UUID) (*models.Media, error)
 }
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrInviteNotUsable is returned when an invite code does not exist, has expired or has no uses left
+var ErrInviteNotUsable = errors.New("invite code is invalid, expired or used up")
+
+// InviteRepository implements InviteRepositoryInterface
+type InviteRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) InviteRepositoryInterface {
+	return &InviteRepository{db: db}
+}
+
+// Create stores a new invite code, normalizing its case
+func (r *InviteRepository) Create(invite *models.InviteCode) error {
+	invite.Code = models.NormalizeInviteCode(invite.Code)
+	return r.db.Create(invite).Error
+}
+
+// GetByID retrieves an invite code by ID
+func (r *InviteRepository) GetByID(id uuid.UUID) (*models.InviteCode, error) {
+	var invite models.InviteCode
+	err := r.db.First(&invite, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// GetByCode retrieves an invite code by its code string, in any case
+func (r *InviteRepository) GetByCode(code string) (*models.InviteCode, error) {
+	var invite models.InviteCode
+	err := r.db.First(&invite, "code = ?", models.NormalizeInviteCode(code)).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListByCreator retrieves the invite codes created by a user, newest first
+func (r *InviteRepository) ListByCreator(userID uuid.UUID) ([]*models.InviteCode, error) {
+	var invites []*models.InviteCode
+	err := r.db.Where("created_by_id = ?", userID).
+		Order("created_at DESC").
+		Find(&invites).Error
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+// Delete revokes an invite code
+func (r *InviteRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.InviteCode{}, "id = ?", id).Error
+}
+
+// Redeem atomically consumes one use of a code, matched in any case. The
+// conditional update makes concurrent registrations unable to exceed MaxUses.
+func (r *InviteRepository) Redeem(code string) (*models.InviteCode, error) {
+	code = models.NormalizeInviteCode(code)
+	result := r.db.Model(&models.InviteCode{}).
+		Where("code = ? AND uses < max_uses AND (expires_at IS NULL OR expires_at > ?)", code, time.Now()).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInviteNotUsable
+	}
+	return r.GetByCode(code)
+}
+
+// Release gives back a use consumed by Redeem, e.g. when account creation fails
+func (r *InviteRepository) Release(id uuid.UUID) error {
+	return r.db.Model(&models.InviteCode{}).
+		Where("id = ? AND uses > 0", id).
+		UpdateColumn("uses", gorm.Expr("uses - 1")).Error
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/testutils"
+)
+
+func TestInviteRepository_Redeem(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	inviteRepo := NewInviteRepository(db.DB)
+	creator := createTestUser(t, userRepo)
+
+	expired := time.Now().Add(-time.Hour)
+	invites := []*models.InviteCode{
+		{Code: "TWOUSES", CreatedByID: creator.ID, MaxUses: 2},
+		{Code: "EXPIRED", CreatedByID: creator.ID, MaxUses: 1, ExpiresAt: &expired},
+	}
+	for _, invite := range invites {
+		if err := inviteRepo.Create(invite); err != nil {
+			t.Fatalf("Failed to create invite: %v", err)
+		}
+	}
+
+	t.Run("redeem until used up", func(t *testing.T) {
+		for i := 1; i <= 2; i++ {
+			invite, err := inviteRepo.Redeem("TWOUSES")
+			if err != nil {
+				t.Fatalf("Redeem %d failed: %v", i, err)
+			}
+			if invite.Uses != i {
+				t.Errorf("Expected %d uses, got %d", i, invite.Uses)
+			}
+		}
+
+		if _, err := inviteRepo.Redeem("TWOUSES"); err != ErrInviteNotUsable {
+			t.Errorf("Expected ErrInviteNotUsable, got %v", err)
+		}
+	})
+
+	t.Run("release returns a use", func(t *testing.T) {
+		invite, _ := inviteRepo.GetByCode("TWOUSES")
+		if err := inviteRepo.Release(invite.ID); err != nil {
+			t.Fatalf("Failed to release invite: %v", err)
+		}
+		if _, err := inviteRepo.Redeem("TWOUSES"); err != nil {
+			t.Errorf("Expected released use to be redeemable, got %v", err)
+		}
+	})
+
+	t.Run("codes match in any case", func(t *testing.T) {
+		lower := &models.InviteCode{Code: "mixedcase", CreatedByID: creator.ID, MaxUses: 1}
+		if err := inviteRepo.Create(lower); err != nil {
+			t.Fatalf("Failed to create invite: %v", err)
+		}
+		if lower.Code != "MIXEDCASE" {
+			t.Errorf("Expected the code to be stored in upper case, got %q", lower.Code)
+		}
+		if _, err := inviteRepo.Redeem(" MixedCase "); err != nil {
+			t.Errorf("Expected the code to be redeemable in any case, got %v", err)
+		}
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		if _, err := inviteRepo.Redeem("EXPIRED"); err != ErrInviteNotUsable {
+			t.Errorf("Expected ErrInviteNotUsable, got %v", err)
+		}
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		if _, err := inviteRepo.Redeem("NOPE"); err != ErrInviteNotUsable {
+			t.Errorf("Expected ErrInviteNotUsable, got %v", err)
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
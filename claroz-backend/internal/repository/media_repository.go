@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrMediaNotFound is returned for uploaded media that do not exist, belong
+// to another user or have expired
+var ErrMediaNotFound = errors.New("media not found")
+
+// ErrMediaAttached is returned for uploaded media that are already attached
+var ErrMediaAttached = errors.New("media already attached")
+
+// MediaRepository tracks the blobs stored files are kept as. References to
+// blobs are counted by the repositories that store the media referring to
+// them, in the same transactions.
+type MediaRepository struct {
+	db *gorm.DB
+}
+
+func NewMediaRepository(db *gorm.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+// RegisterBlob records a file that is about to be stored. A new blob starts
+// unreferenced, and registering an unreferenced blob again restarts its
+// grace period, so an upload has the grace period to be referenced before
+// the collector deletes it.
+func (r *MediaRepository) RegisterBlob(blob *models.MediaBlob) error {
+	return r.db.Exec(`INSERT INTO media_blobs (key, size, content_type, ref_count, unreferenced_at, created_at)
+		VALUES (?, ?, ?, 0, NOW(), NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			size = EXCLUDED.size,
+			content_type = EXCLUDED.content_type,
+			unreferenced_at = CASE WHEN media_blobs.ref_count = 0 THEN NOW() ELSE media_blobs.unreferenced_at END`,
+		blob.Key, blob.Size, blob.ContentType).Error
+}
+
+// UnreferencedBlobs returns up to limit blobs that have been unreferenced
+// since before, longest unreferenced first
+func (r *MediaRepository) UnreferencedBlobs(before time.Time, limit int) ([]models.MediaBlob, error) {
+	var blobs []models.MediaBlob
+	err := r.db.Where("ref_count = 0 AND unreferenced_at < ?", before).
+		Order("unreferenced_at ASC").
+		Limit(limit).
+		Find(&blobs).Error
+	return blobs, err
+}
+
+// DeleteBlob deletes a blob that is still unreferenced since before, calling
+// remove to delete its file first, and reports whether it was deleted. The
+// blob stays locked while remove runs, so it cannot be referenced or
+// registered again until its file is gone; if remove fails, it is kept.
+func (r *MediaRepository) DeleteBlob(key string, before time.Time, remove func() error) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var blob models.MediaBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ? AND ref_count = 0 AND unreferenced_at < ?", key, before).
+			Take(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := remove(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// DeleteReleasedBlob deletes a blob that has stayed unreferenced since it
+// was released at releasedAt, calling remove to delete its file first, and
+// reports whether it was deleted. Blobs referenced or registered again since
+// are kept, so a file being reused is never deleted. Like DeleteBlob, the
+// blob stays locked while remove runs, and is kept if remove fails.
+func (r *MediaRepository) DeleteReleasedBlob(key string, releasedAt time.Time, remove func() error) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var blob models.MediaBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ? AND ref_count = 0 AND unreferenced_at = ?", key, releasedAt).
+			Take(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := remove(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// CreateMedia records uploaded media as pending, referring to its blobs
+func (r *MediaRepository) CreateMedia(media *models.Media) error {
+	media.Status = models.MediaPending
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(media).Error; err != nil {
+			return err
+		}
+		return referenceBlobs(tx, media.BlobKeys())
+	})
+}
+
+// GetMedia returns media uploaded by a user
+func (r *MediaRepository) GetMedia(id, userID uuid.UUID) (*models.Media, error) {
+	var media models.Media
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).Take(&media).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// ExpireMedia deletes up to limit pending media that expired before now,
+// releasing their blobs to the garbage collector, and returns how many it
+// deleted. Media being attached are skipped.
+func (r *MediaRepository) ExpireMedia(now time.Time, limit int) (int, error) {
+	expired := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var media []models.Media
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at < ?", models.MediaPending, now).
+			Order("expires_at ASC").
+			Limit(limit).
+			Find(&media).Error
+		if err != nil || len(media) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(media))
+		var keys []string
+		for i := range media {
+			ids[i] = media[i].ID
+			keys = append(keys, media[i].BlobKeys()...)
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.Media{}).Error; err != nil {
+			return err
+		}
+		expired = len(media)
+		return releaseBlobs(tx, keys)
+	})
+	return expired, err
+}
+
+// attachMedia marks a user's pending media as attached, returning them in
+// the order of ids. The media's references to their blobs are released, so
+// whatever they are attached to must reference the blobs itself in the same
+// transaction. It returns ErrMediaNotFound if any of the media is missing,
+// expired or another user's, and ErrMediaAttached if any is already attached.
+func attachMedia(tx *gorm.DB, userID uuid.UUID, ids []uuid.UUID) ([]models.Media, error) {
+	var found []models.Media
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND user_id = ?", ids, userID).
+		Order("id").
+		Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]models.Media, len(found))
+	for _, media := range found {
+		byID[media.ID] = media
+	}
+	media := make([]models.Media, len(ids))
+	var keys []string
+	for i, id := range ids {
+		item, ok := byID[id]
+		if !ok || (item.Status == models.MediaPending && item.ExpiresAt.Before(time.Now())) {
+			return nil, ErrMediaNotFound
+		}
+		if item.Status != models.MediaPending {
+			return nil, ErrMediaAttached
+		}
+		media[i] = item
+		keys = append(keys, item.BlobKeys()...)
+	}
+	if len(byID) != len(ids) {
+		// The same media appears more than once
+		return nil, ErrMediaAttached
+	}
+
+	now := time.Now()
+	err = tx.Model(&models.Media{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"status": models.MediaAttached, "attached_at": now}).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range media {
+		media[i].Status = models.MediaAttached
+		media[i].AttachedAt = &now
+	}
+	return media, releaseBlobs(tx, keys)
+}
+
+// referenceBlobs counts a reference to the blob of each key, registering
+// blobs that were stored without being registered
+func referenceBlobs(tx *gorm.DB, keys []string) error {
+	for _, ref := range countKeys(keys) {
+		err := tx.Exec(`INSERT INTO media_blobs (key, ref_count, created_at) VALUES (?, ?, NOW())
+			ON CONFLICT (key) DO UPDATE SET ref_count = media_blobs.ref_count + EXCLUDED.ref_count, unreferenced_at = NULL`,
+			ref.key, ref.count).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseBlobs removes a reference to the blob of each key. Blobs left
+// unreferenced start their grace period.
+func releaseBlobs(tx *gorm.DB, keys []string) error {
+	for _, ref := range countKeys(keys) {
+		err := tx.Exec(`UPDATE media_blobs SET
+				ref_count = GREATEST(ref_count - ?, 0),
+				unreferenced_at = CASE WHEN ref_count - ? <= 0 THEN NOW() ELSE unreferenced_at END
+			WHERE key = ?`,
+			ref.count, ref.count, ref.key).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobRefs is a number of references to a blob
+type blobRefs struct {
+	key   string
+	count int
+}
+
+// countKeys counts the occurrences of each key, in key order so that
+// concurrent transactions lock blobs in the same order
+func countKeys(keys []string) []blobRefs {
+	counts := map[string]int{}
+	for _, key := range keys {
+		counts[key]++
+	}
+	refs := make([]blobRefs, 0, len(counts))
+	for key, count := range counts {
+		refs = append(refs, blobRefs{key: key, count: count})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].key < refs[j].key })
+	return refs
+}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/testutils"
+)
+
+func TestMediaRepository_Blobs(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	mediaRepo := NewMediaRepository(db.DB)
+	user := createTestUser(t, userRepo)
+
+	getBlob := func(t *testing.T, key string) models.MediaBlob {
+		t.Helper()
+		var blob models.MediaBlob
+		if err := db.DB.Where("key = ?", key).Take(&blob).Error; err != nil {
+			t.Fatalf("Failed to get blob %s: %v", key, err)
+		}
+		return blob
+	}
+	createPost := func(t *testing.T) *models.Post {
+		t.Helper()
+		post := &models.Post{
+			UserID:   user.ID,
+			ImageURL: "/uploads/full.jpg",
+			Media: []models.PostMedia{{
+				Position: 0, URL: "/uploads/full.jpg", MimeType: "image/jpeg",
+				Variants: models.ImageVariants{
+					"full":      {URL: "/uploads/full.jpg"},
+					"thumbnail": {URL: "/uploads/thumb.jpg"},
+				},
+			}},
+		}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		return post
+	}
+
+	for _, key := range []string{"full.jpg", "thumb.jpg"} {
+		if err := mediaRepo.RegisterBlob(&models.MediaBlob{Key: key, Size: 100, ContentType: "image/jpeg"}); err != nil {
+			t.Fatalf("Failed to register blob: %v", err)
+		}
+	}
+
+	t.Run("registered blobs start unreferenced", func(t *testing.T) {
+		blob := getBlob(t, "full.jpg")
+		if blob.RefCount != 0 || blob.UnreferencedAt == nil || blob.Size != 100 {
+			t.Errorf("Expected an unreferenced blob, got %+v", blob)
+		}
+	})
+
+	first := createPost(t)
+	second := createPost(t)
+
+	t.Run("media reference their blobs", func(t *testing.T) {
+		for _, key := range []string{"full.jpg", "thumb.jpg"} {
+			if blob := getBlob(t, key); blob.RefCount != 2 || blob.UnreferencedAt != nil {
+				t.Errorf("Expected %s to be referenced by both posts, got %+v", key, blob)
+			}
+		}
+
+		blobs, err := mediaRepo.UnreferencedBlobs(time.Now().Add(time.Hour), 10)
+		if err != nil {
+			t.Fatalf("Failed to list unreferenced blobs: %v", err)
+		}
+		if len(blobs) != 0 {
+			t.Errorf("Expected no unreferenced blobs, got %+v", blobs)
+		}
+	})
+
+	t.Run("deleting posts releases their blobs", func(t *testing.T) {
+		if err := postRepo.DeletePost(first.ID, user.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+		if blob := getBlob(t, "full.jpg"); blob.RefCount != 1 || blob.UnreferencedAt != nil {
+			t.Errorf("Expected the blob to stay referenced by the other post, got %+v", blob)
+		}
+
+		if err := postRepo.DeletePost(second.ID, user.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+		if blob := getBlob(t, "full.jpg"); blob.RefCount != 0 || blob.UnreferencedAt == nil {
+			t.Errorf("Expected the blob to be unreferenced, got %+v", blob)
+		}
+	})
+
+	t.Run("unreferenced blobs are deleted after the grace period", func(t *testing.T) {
+		blobs, err := mediaRepo.UnreferencedBlobs(time.Now().Add(-time.Hour), 10)
+		if err != nil {
+			t.Fatalf("Failed to list unreferenced blobs: %v", err)
+		}
+		if len(blobs) != 0 {
+			t.Errorf("Expected blobs within the grace period to be kept, got %+v", blobs)
+		}
+
+		before := time.Now().Add(time.Hour)
+		blobs, err = mediaRepo.UnreferencedBlobs(before, 10)
+		if err != nil {
+			t.Fatalf("Failed to list unreferenced blobs: %v", err)
+		}
+		if len(blobs) != 2 {
+			t.Fatalf("Expected both blobs to be unreferenced, got %+v", blobs)
+		}
+
+		// A blob whose file fails to delete is kept
+		deleted, err := mediaRepo.DeleteBlob("thumb.jpg", before, func() error { return errors.New("storage unavailable") })
+		if err == nil || deleted {
+			t.Errorf("Expected the failed deletion to be reported, got %v, %v", deleted, err)
+		}
+		getBlob(t, "thumb.jpg")
+
+		removed := false
+		deleted, err = mediaRepo.DeleteBlob("thumb.jpg", before, func() error { removed = true; return nil })
+		if err != nil || !deleted || !removed {
+			t.Fatalf("Expected the blob and its file to be deleted, got %v, %v", deleted, err)
+		}
+	})
+
+	t.Run("referenced blobs are not deleted", func(t *testing.T) {
+		createPost(t)
+		deleted, err := mediaRepo.DeleteBlob("full.jpg", time.Now().Add(time.Hour), func() error {
+			t.Error("Expected the file to be kept")
+			return nil
+		})
+		if err != nil || deleted {
+			t.Errorf("Expected the referenced blob to be kept, got %v, %v", deleted, err)
+		}
+		// Blobs deleted while unreferenced are registered again when referenced
+		if blob := getBlob(t, "thumb.jpg"); blob.RefCount != 1 {
+			t.Errorf("Expected the blob to be referenced again, got %+v", blob)
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
+
+func TestMediaRepository_Uploads(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	mediaRepo := NewMediaRepository(db.DB)
+	user := createTestUser(t, userRepo)
+
+	refCount := func(t *testing.T, key string) int {
+		t.Helper()
+		var blob models.MediaBlob
+		if err := db.DB.Where("key = ?", key).Take(&blob).Error; err != nil {
+			t.Fatalf("Failed to get blob %s: %v", key, err)
+		}
+		return blob.RefCount
+	}
+	upload := func(t *testing.T, name string, expiresAt time.Time) *models.Media {
+		t.Helper()
+		media := &models.Media{
+			UserID:    user.ID,
+			URL:       "/uploads/" + name + "-full.jpg",
+			MimeType:  "image/jpeg",
+			Variants:  models.ImageVariants{"full": {URL: "/uploads/" + name + "-full.jpg"}, "thumbnail": {URL: "/uploads/" + name + "-thumb.jpg"}},
+			ExpiresAt: expiresAt,
+		}
+		if err := mediaRepo.CreateMedia(media); err != nil {
+			t.Fatalf("Failed to create media: %v", err)
+		}
+		return media
+	}
+	later := time.Now().Add(time.Hour)
+
+	t.Run("pending media reference their blobs", func(t *testing.T) {
+		media := upload(t, "pending", later)
+		if media.Status != models.MediaPending || refCount(t, "pending-full.jpg") != 1 || refCount(t, "pending-thumb.jpg") != 1 {
+			t.Errorf("Expected pending media referring to its blobs, got %+v", media)
+		}
+		found, err := mediaRepo.GetMedia(media.ID, user.ID)
+		if err != nil || found.URL != media.URL {
+			t.Errorf("Expected the uploader to get the media, got %+v, %v", found, err)
+		}
+		if _, err := mediaRepo.GetMedia(media.ID, uuid.New()); !errors.Is(err, ErrMediaNotFound) {
+			t.Errorf("Expected other users not to find the media, got %v", err)
+		}
+	})
+
+	t.Run("media are attached to posts once", func(t *testing.T) {
+		first, second := upload(t, "first", later), upload(t, "second", later)
+		post := &models.Post{UserID: user.ID, Caption: "Two-phase", Media: []models.PostMedia{{AltText: "Second"}}}
+		if err := postRepo.CreatePostWithMedia(post, []uuid.UUID{second.ID, first.ID}); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+
+		created, err := postRepo.GetPostByID(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get post: %v", err)
+		}
+		if len(created.Media) != 2 || created.Media[0].URL != second.URL || created.Media[0].AltText != "Second" || created.ImageURL != second.URL {
+			t.Errorf("Expected the media in order with their alt text, got %+v", created.Media)
+		}
+		// The post's references replace the pending media's
+		if refCount(t, "first-full.jpg") != 1 || refCount(t, "second-thumb.jpg") != 1 {
+			t.Error("Expected each blob to be referenced once, by the post")
+		}
+		attached, _ := mediaRepo.GetMedia(first.ID, user.ID)
+		if attached.Status != models.MediaAttached || attached.AttachedAt == nil {
+			t.Errorf("Expected the media to be attached, got %+v", attached)
+		}
+
+		retry := &models.Post{UserID: user.ID}
+		if err := postRepo.CreatePostWithMedia(retry, []uuid.UUID{first.ID}); !errors.Is(err, ErrMediaAttached) {
+			t.Errorf("Expected attached media to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("invalid media create no post", func(t *testing.T) {
+		pending := upload(t, "kept", later)
+		expired := upload(t, "expired", time.Now().Add(-time.Minute))
+		other := createTestUser(t, userRepo)
+		for name, ids := range map[string][]uuid.UUID{
+			"unknown": {pending.ID, uuid.New()},
+			"expired": {pending.ID, expired.ID},
+			"twice":   {pending.ID, pending.ID},
+		} {
+			if err := postRepo.CreatePostWithMedia(&models.Post{UserID: user.ID}, ids); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+		if err := postRepo.CreatePostWithMedia(&models.Post{UserID: other.ID}, []uuid.UUID{pending.ID}); !errors.Is(err, ErrMediaNotFound) {
+			t.Errorf("Expected another user's media to be rejected, got %v", err)
+		}
+		if found, _ := mediaRepo.GetMedia(pending.ID, user.ID); found.Status != models.MediaPending {
+			t.Errorf("Expected the media to stay pending, got %+v", found)
+		}
+	})
+
+	t.Run("expired media release their blobs", func(t *testing.T) {
+		expired, err := mediaRepo.ExpireMedia(time.Now(), 10)
+		if err != nil {
+			t.Fatalf("Failed to expire media: %v", err)
+		}
+		if expired != 1 || refCount(t, "expired-full.jpg") != 0 || refCount(t, "kept-full.jpg") != 1 {
+			t.Errorf("Expected only the expired media to be deleted, got %d", expired)
+		}
+	})
+
+	t.Run("uploaded media become avatars as their cropped variants", func(t *testing.T) {
+		cropped := func(name string) models.ImageVariants {
+			for _, key := range []string{name + "-square.jpg", name + "-square-thumb.jpg"} {
+				if err := mediaRepo.RegisterBlob(&models.MediaBlob{Key: key, ContentType: "image/jpeg"}); err != nil {
+					t.Fatalf("Failed to register blob: %v", err)
+				}
+			}
+			return models.ImageVariants{"full": {URL: "/uploads/" + name + "-square.jpg"}, "thumbnail": {URL: "/uploads/" + name + "-square-thumb.jpg"}}
+		}
+
+		first := upload(t, "avatar", later)
+		updated, released, err := userRepo.SetAvatar(user.ID, first.ID, cropped("avatar"))
+		if err != nil {
+			t.Fatalf("Failed to set avatar: %v", err)
+		}
+		if updated.Avatar != "/uploads/avatar-square.jpg" || len(updated.AvatarVariants) != 2 || len(released.Keys) != 0 {
+			t.Errorf("Expected the cropped avatar, got %+v", updated)
+		}
+		if refCount(t, "avatar-square.jpg") != 1 || refCount(t, "avatar-square-thumb.jpg") != 1 || refCount(t, "avatar-full.jpg") != 0 {
+			t.Error("Expected the avatar to refer to its cropped files and release the upload's")
+		}
+		if found, _ := mediaRepo.GetMedia(first.ID, user.ID); found.Status != models.MediaAttached {
+			t.Errorf("Expected the media to be attached, got %s", found.Status)
+		}
+		if _, _, err := userRepo.SetAvatar(user.ID, first.ID, cropped("again")); !errors.Is(err, ErrMediaAttached) {
+			t.Errorf("Expected ErrMediaAttached, got %v", err)
+		}
+		if refCount(t, "again-square.jpg") != 0 {
+			t.Error("Expected a failed avatar change not to refer to its files")
+		}
+
+		// Replacing the avatar, by upload or by URL, releases the previous one
+		second := upload(t, "replacement", later)
+		updated, released, err = userRepo.SetAvatar(user.ID, second.ID, cropped("replacement"))
+		if err != nil {
+			t.Fatalf("Failed to set avatar: %v", err)
+		}
+		if len(released.Keys) != 2 || refCount(t, "avatar-square.jpg") != 0 || refCount(t, "replacement-square.jpg") != 1 {
+			t.Errorf("Expected the previous avatar to be released, got %+v", released)
+		}
+		updated.Avatar = "https://example.com/avatar.jpg"
+		if err := userRepo.Update(updated); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		if refCount(t, "replacement-square.jpg") != 0 {
+			t.Error("Expected the uploaded avatar to be released")
+		}
+		stored, _ := userRepo.GetByID(user.ID)
+		if len(stored.AvatarVariants) != 0 || stored.Avatar != "https://example.com/avatar.jpg" {
+			t.Errorf("Expected the avatar URL without variants, got %+v", stored)
+		}
+	})
+
+	t.Run("uploaded profile images release the files they replace", func(t *testing.T) {
+		variants := func(name string) models.ImageVariants {
+			for _, key := range []string{name + "-full.jpg", name + "-thumb.jpg"} {
+				if err := mediaRepo.RegisterBlob(&models.MediaBlob{Key: key, ContentType: "image/jpeg"}); err != nil {
+					t.Fatalf("Failed to register blob: %v", err)
+				}
+			}
+			return models.ImageVariants{"full": {URL: "/uploads/" + name + "-full.jpg"}, "thumbnail": {URL: "/uploads/" + name + "-thumb.jpg"}}
+		}
+
+		updated, released, err := userRepo.SetProfileImage(user.ID, models.ProfileBanner, variants("banner"))
+		if err != nil {
+			t.Fatalf("Failed to set banner: %v", err)
+		}
+		if updated.Banner != "/uploads/banner-full.jpg" || len(released.Keys) != 0 || refCount(t, "banner-thumb.jpg") != 1 {
+			t.Errorf("Expected the banner to refer to its files, got %+v", updated)
+		}
+
+		_, released, err = userRepo.SetProfileImage(user.ID, models.ProfileBanner, variants("wide"))
+		if err != nil {
+			t.Fatalf("Failed to set banner: %v", err)
+		}
+		if len(released.Keys) != 2 || refCount(t, "banner-full.jpg") != 0 || refCount(t, "wide-full.jpg") != 1 {
+			t.Errorf("Expected the previous banner to be released, got %+v", released)
+		}
+
+		// An identical upload registers one of the released files again
+		if err := mediaRepo.RegisterBlob(&models.MediaBlob{Key: "banner-thumb.jpg"}); err != nil {
+			t.Fatalf("Failed to register blob: %v", err)
+		}
+		removed := []string{}
+		for _, key := range released.Keys {
+			deleted, err := mediaRepo.DeleteReleasedBlob(key, released.At, func() error {
+				removed = append(removed, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to delete released blob: %v", err)
+			}
+			if deleted != (key == "banner-full.jpg") {
+				t.Errorf("Expected only banner-full.jpg to be deleted, got %s deleted = %v", key, deleted)
+			}
+		}
+		if len(removed) != 1 {
+			t.Errorf("Expected one file to be removed, got %v", removed)
+		}
+
+		stored, _ := userRepo.GetByID(user.ID)
+		if stored.Banner != "/uploads/wide-full.jpg" || len(stored.BannerVariants) != 2 {
+			t.Errorf("Expected the new banner to be stored, got %+v", stored)
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
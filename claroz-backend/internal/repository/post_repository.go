@@ -1,33 +1,159 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrUserNotFound is returned when an operation references a user that does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrPostNotFound is returned when an operation references a post that does not exist
+var ErrPostNotFound = errors.New("post not found")
+
 // PostRepository implements PostRepositoryInterface
 type PostRepository struct {
-	db *gorm.DB
+	db                *gorm.DB
+	timelineListeners []TimelineListener
 }
 
-func NewPostRepository(db *gorm.DB) PostRepositoryInterface {
-	return &PostRepository{db: db}
+// NewPostRepository creates a post repository. Timeline listeners are
+// notified of new and deleted posts and of follow and block changes.
+func NewPostRepository(db *gorm.DB, timelineListeners ...TimelineListener) PostRepositoryInterface {
+	return &PostRepository{db: db, timelineListeners: timelineListeners}
 }
 
-// CreatePost creates a new post
+// CreatePost creates a new post with its media and indexes the hashtags in its
+// caption. Posts that are processing are fanned out to timelines once they are
+// ready.
 func (r *PostRepository) CreatePost(post *models.Post) error {
-	return r.db.Create(post).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return createPost(tx, post)
+	})
+	if err != nil {
+		return err
+	}
+	if post.Status != models.PostReady {
+		return nil
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.PostCreated(post) })
+	return nil
+}
+
+// CreatePostWithMedia creates a post from media its author uploaded
+// beforehand, attaching them in the order of mediaIDs. The post's media items
+// are filled in from the uploaded media, keeping their alt text. It returns
+// ErrMediaNotFound if any of the media is missing, expired or another user's,
+// and ErrMediaAttached if any is already attached, so a retried request
+// cannot create the post twice.
+func (r *PostRepository) CreatePostWithMedia(post *models.Post, mediaIDs []uuid.UUID) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		media, err := attachMedia(tx, post.UserID, mediaIDs)
+		if err != nil {
+			return err
+		}
+		items := make([]models.PostMedia, len(media))
+		for i := range media {
+			var altText string
+			if i < len(post.Media) {
+				altText = post.Media[i].AltText
+			}
+			items[i] = media[i].PostMedia(i, altText)
+		}
+		post.Media = items
+		post.ImageURL = items[0].URL
+		return createPost(tx, post)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.PostCreated(post) })
+	return nil
+}
+
+// createPost inserts a post with its media, referencing their blobs, and
+// indexes its hashtags
+func createPost(tx *gorm.DB, post *models.Post) error {
+	if err := tx.Create(post).Error; err != nil {
+		return err
+	}
+	if err := referenceBlobs(tx, postBlobKeys(post)); err != nil {
+		return err
+	}
+	return syncHashtags(tx, post.ID, post.Caption)
+}
+
+// UpdatePost replaces a post's caption and language, keeping the previous
+// version as a revision and reindexing the caption's hashtags. A post whose
+// caption and language are unchanged is returned as it is.
+func (r *PostRepository) UpdatePost(id uuid.UUID, caption, language string) (*models.Post, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the post so concurrent edits each record the version they replace
+		var post models.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if post.Caption == caption && post.Language == language {
+			return nil
+		}
+
+		now := time.Now()
+		revision := models.PostRevision{
+			PostID:     id,
+			Caption:    post.Caption,
+			Language:   post.Language,
+			CreatedAt:  post.CreatedAt,
+			ReplacedAt: now,
+		}
+		if post.EditedAt != nil {
+			revision.CreatedAt = *post.EditedAt
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&post).Updates(map[string]interface{}{
+			"caption":   caption,
+			"language":  language,
+			"edited_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return syncHashtags(tx, id, caption)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetPostByID(id)
+}
+
+// GetPostRevisions retrieves the previous versions of a post, newest first
+func (r *PostRepository) GetPostRevisions(postID uuid.UUID) ([]models.PostRevision, error) {
+	revisions := []models.PostRevision{}
+	err := r.db.
+		Where("post_id = ?", postID).
+		Order("replaced_at DESC").
+		Order("id DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
 }
 
-// GetPostByID retrieves a post by ID with associated user, comments, and likes
+// GetPostByID retrieves a post by ID with its author and media. Comments and
+// likes are loaded separately with GetPostStats and GetPostInteractions.
 func (r *PostRepository) GetPostByID(id uuid.UUID) (*models.Post, error) {
 	var post models.Post
-	err := r.db.Preload("User").
-		Preload("Comments.User").
-		Preload("Likes.User").
+	err := r.db.Scopes(withAuthorAndMedia).
 		First(&post, "id = ?", id).Error
 	if err != nil {
 		return nil, err
@@ -35,18 +161,24 @@ func (r *PostRepository) GetPostByID(id uuid.UUID) (*models.Post, error) {
 	return &post, nil
 }
 
-// GetPosts retrieves posts with pagination, ordered by creation date
-func (r *PostRepository) GetPosts(page, pageSize int) ([]models.Post, error) {
-	var posts []models.Post
-	offset := (page - 1) * pageSize
+// GetPosts retrieves posts visible to the viewer, newest first. Posts by
+// blocked and muted users are excluded.
+func (r *PostRepository) GetPosts(viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error) {
+	query := r.db.Scopes(visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id"))
+	return r.findPosts(query, cursor, limit)
+}
 
+// GetPostsByOffset is GetPosts with offset pagination.
+//
+// Deprecated: offset pages skip or repeat posts as new posts arrive. It backs
+// the page query parameter for one deprecation cycle; use GetPosts.
+func (r *PostRepository) GetPostsByOffset(viewerID uuid.UUID, offset, limit int) ([]models.Post, error) {
+	var posts []models.Post
 	err := r.db.
-		Preload("User").
-		Preload("Comments.User").
-		Preload("Likes.User").
+		Scopes(ready, visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id"), withAuthorAndMedia).
 		Order("created_at DESC").
 		Offset(offset).
-		Limit(pageSize).
+		Limit(limit).
 		Find(&posts).Error
 
 	if err != nil {
@@ -55,17 +187,21 @@ func (r *PostRepository) GetPosts(page, pageSize int) ([]models.Post, error) {
 	return posts, nil
 }
 
-// DeletePost deletes a post and its associated comments and likes
+// DeletePost deletes a post and its associated comments, reactions, media and
+// hashtag links. Its files are deleted by the media garbage collector once no
+// other media refer to them.
 func (r *PostRepository) DeletePost(id uuid.UUID, userID uuid.UUID) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
 		// Verify post exists and belongs to user
 		var post models.Post
 		if err := tx.First(&post, "id = ? AND user_id = ?", id, userID).Error; err != nil {
 			return err
 		}
 
-		// Delete associated likes
-		if err := tx.Delete(&models.Like{}, "post_id = ?", id).Error; err != nil {
+		// Delete reactions to the post and its comments
+		err := tx.Where("post_id = ? OR comment_id IN (SELECT id FROM comments WHERE post_id = ?)", id, id).
+			Delete(&models.Reaction{}).Error
+		if err != nil {
 			return err
 		}
 
@@ -74,36 +210,75 @@ func (r *PostRepository) DeletePost(id uuid.UUID, userID uuid.UUID) error {
 			return err
 		}
 
-		// Delete the post
+		// Remove the post from hashtag feeds; the soft-deleted row stays out of
+		// search through the partial full-text index and the deleted_at scope
+		if err := tx.Delete(&models.PostHashtag{}, "post_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		// Delete the post's edit history
+		if err := tx.Delete(&models.PostRevision{}, "post_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		// Delete the post's media records, leaving files no longer referenced
+		// to the garbage collector
+		if err := tx.Where("post_id = ?", id).Find(&post.Media).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.PostMedia{}, "post_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := releaseBlobs(tx, postBlobKeys(&post)); err != nil {
+			return err
+		}
+
+		// Delete the post, zeroing its counters to match the removed reactions and comments
+		if err := tx.Exec("UPDATE posts SET like_count = 0, comment_count = 0 WHERE id = ?", id).Error; err != nil {
+			return err
+		}
 		return tx.Delete(&post).Error
 	})
-}
+	if err != nil {
+		return err
+	}
 
-// AddComment adds a comment to a post
-func (r *PostRepository) AddComment(comment *models.Comment) error {
-	return r.db.Create(comment).Error
+	r.notifyTimelines(func(l TimelineListener) { l.PostDeleted(id, userID) })
+	return nil
 }
 
-// DeleteComment deletes a comment
-func (r *PostRepository) DeleteComment(id uuid.UUID, userID uuid.UUID) error {
-	return r.db.Delete(&models.Comment{}, "id = ? AND user_id = ?", id, userID).Error
+// postBlobKeys returns the keys of the blobs a post's media refer to. Posts
+// from before carousels without a media item refer to their image.
+func postBlobKeys(post *models.Post) []string {
+	if len(post.Media) == 0 {
+		if post.ImageURL == "" {
+			return nil
+		}
+		return []string{models.BlobKey(post.ImageURL)}
+	}
+	var keys []string
+	for _, item := range post.Media {
+		keys = append(keys, item.BlobKeys()...)
+	}
+	return keys
 }
 
-// LikePost creates a new like for a post
-func (r *PostRepository) LikePost(like *models.Like) error {
-	return r.db.Create(like).Error
+// LikePost reacts to a post with a heart, returning ErrBlocked if the user
+// and the post's author have blocked each other
+func (r *PostRepository) LikePost(postID, userID uuid.UUID) error {
+	return r.AddReaction(models.PostTarget(postID), userID, models.HeartReaction)
 }
 
-// UnlikePost removes a like from a post
+// UnlikePost removes a user's heart reaction to a post
 func (r *PostRepository) UnlikePost(postID, userID uuid.UUID) error {
-	return r.db.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&models.Like{}).Error
+	return r.RemoveReaction(models.PostTarget(postID), userID, models.HeartReaction)
 }
 
-// HasUserLikedPost checks if a user has already liked a post
+// HasUserLikedPost checks if a user has reacted to a post with a heart
 func (r *PostRepository) HasUserLikedPost(postID, userID uuid.UUID) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.Like{}).
-		Where("post_id = ? AND user_id = ?", postID, userID).
+	err := r.db.Model(&models.Reaction{}).
+		Where("post_id = ? AND user_id = ? AND emoji = ?", postID, userID, models.HeartReaction).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -111,43 +286,103 @@ func (r *PostRepository) HasUserLikedPost(postID, userID uuid.UUID) (bool, error
 // GetPostLikes gets the total number of likes for a post
 func (r *PostRepository) GetPostLikes(postID uuid.UUID) (int64, error) {
 	var count int64
-	err := r.db.Model(&models.Like{}).
-		Where("post_id = ?", postID).
-		Count(&count).Error
+	err := r.db.Model(&models.Post{}).
+		Select("like_count").
+		Where("id = ?", postID).
+		Scan(&count).Error
 	return count, err
 }
 
-// GetUserPosts retrieves all posts for a specific user
-func (r *PostRepository) GetUserPosts(userID uuid.UUID) ([]models.Post, error) {
-	var posts []models.Post
-	err := r.db.
-		Preload("User").
-		Preload("Comments.User").
-		Preload("Likes.User").
+// GetUserPosts retrieves a user's posts, newest first
+func (r *PostRepository) GetUserPosts(userID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error) {
+	return r.findPosts(r.db.Where("posts.user_id = ?", userID), cursor, limit)
+}
+
+// GetUserPostsCount gets the number of posts by a user that are ready
+func (r *PostRepository) GetUserPostsCount(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Post{}).
+		Scopes(ready).
 		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Find(&posts).Error
+		Count(&count).Error
+	return count, err
+}
+
+// FollowUser follows a user, or creates a pending follow request if the
+// account is private, and returns the resulting models.FollowStatus* value.
+// Repeating a follow or request is a no-op. Returns ErrBlocked if either
+// user has blocked the other.
+func (r *PostRepository) FollowUser(followerID, followingID uuid.UUID) (string, error) {
+	var target models.User
+	if err := r.db.Select("id", "is_private").First(&target, "id = ?", followingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
 
+	blocked, err := r.IsBlocked(followerID, followingID)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if blocked {
+		return "", ErrBlocked
+	}
+
+	if target.IsPrivate {
+		following, err := r.IsFollowing(followerID, followingID)
+		if err != nil {
+			return "", err
+		}
+		if following {
+			return models.FollowStatusFollowing, nil
+		}
+
+		request := models.FollowRequest{
+			RequesterID: followerID,
+			TargetID:    followingID,
+		}
+		err = r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&request).Error
+		if err != nil {
+			return "", err
+		}
+		return models.FollowStatusRequested, nil
 	}
-	return posts, nil
-}
 
-// FollowUser creates a new follow relationship
-func (r *PostRepository) FollowUser(followerID, followingID uuid.UUID) error {
 	follow := models.UserFollow{
 		FollowerID:  followerID,
 		FollowingID: followingID,
 		CreatedAt:   time.Now(),
 	}
-	return r.db.Create(&follow).Error
+	var created bool
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		created, err = addFollow(tx, &follow)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if created {
+		r.notifyTimelines(func(l TimelineListener) { l.Followed(followerID, followingID) })
+	}
+	return models.FollowStatusFollowing, nil
 }
 
-// UnfollowUser removes a follow relationship
+// UnfollowUser removes a follow relationship and any pending follow request
 func (r *PostRepository) UnfollowUser(followerID, followingID uuid.UUID) error {
-	return r.db.Where("follower_id = ? AND following_id = ?", followerID, followingID).
-		Delete(&models.UserFollow{}).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("requester_id = ? AND target_id = ?", followerID, followingID).
+			Delete(&models.FollowRequest{}).Error; err != nil {
+			return err
+		}
+		return removeFollow(tx, followerID, followingID)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.Unfollowed(followerID, followingID) })
+	return nil
 }
 
 // IsFollowing checks if a user is following another user
@@ -162,17 +397,215 @@ func (r *PostRepository) IsFollowing(followerID, followingID uuid.UUID) (bool, e
 // GetFollowersCount gets the number of followers for a user
 func (r *PostRepository) GetFollowersCount(userID uuid.UUID) (int64, error) {
 	var count int64
-	err := r.db.Model(&models.UserFollow{}).
-		Where("following_id = ?", userID).
-		Count(&count).Error
+	err := r.db.Model(&models.User{}).
+		Select("followers_count").
+		Where("id = ?", userID).
+		Scan(&count).Error
 	return count, err
 }
 
 // GetFollowingCount gets the number of users a user is following
 func (r *PostRepository) GetFollowingCount(userID uuid.UUID) (int64, error) {
 	var count int64
-	err := r.db.Model(&models.UserFollow{}).
-		Where("follower_id = ?", userID).
-		Count(&count).Error
+	err := r.db.Model(&models.User{}).
+		Select("following_count").
+		Where("id = ?", userID).
+		Scan(&count).Error
 	return count, err
 }
+
+// GetFollowers lists the users following userID, newest follow first, with
+// their relationship to viewerID
+func (r *PostRepository) GetFollowers(userID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.UserSummary, error) {
+	return r.listFollows(userID, viewerID, cursor, limit, "following_id", "follower_id")
+}
+
+// GetFollowing lists the users userID follows, newest follow first, with
+// their relationship to viewerID
+func (r *PostRepository) GetFollowing(userID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.UserSummary, error) {
+	return r.listFollows(userID, viewerID, cursor, limit, "follower_id", "following_id")
+}
+
+// listFollows pages through user_follows rows where matchColumn = userID,
+// returning the users referenced by listColumn
+func (r *PostRepository) listFollows(userID, viewerID uuid.UUID, cursor *Cursor, limit int, matchColumn, listColumn string) ([]models.UserSummary, error) {
+	if err := r.ensureUserExists(userID); err != nil {
+		return nil, err
+	}
+
+	query := r.db.Table("user_follows AS uf").
+		Select(`users.id, users.username, users.full_name, users.avatar, users.handle, users.federation_type,
+			uf.created_at AS followed_at,
+			EXISTS (SELECT 1 FROM user_follows v WHERE v.follower_id = ? AND v.following_id = users.id) AS viewer_follows,
+			EXISTS (SELECT 1 FROM user_follows v WHERE v.follower_id = users.id AND v.following_id = ?) AS follows_viewer`,
+			viewerID, viewerID).
+		Joins("JOIN users ON users.id = uf."+listColumn+" AND users.deleted_at IS NULL").
+		Where("uf."+matchColumn+" = ?", userID)
+
+	if cursor != nil {
+		query = query.Where("(uf.created_at, uf."+listColumn+") < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	users := []models.UserSummary{}
+	err := query.
+		Order("uf.created_at DESC").
+		Order("uf." + listColumn + " DESC").
+		Limit(limit).
+		Scan(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ensureUserExists returns ErrUserNotFound if there is no active user with the given ID
+func (r *PostRepository) ensureUserExists(userID uuid.UUID) error {
+	var count int64
+	if err := r.db.Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// CanViewPosts reports whether the viewer may see the author's posts: the
+// viewer is the author, or neither has blocked the other and the author's
+// account is public or the viewer is an approved follower
+func (r *PostRepository) CanViewPosts(viewerID, authorID uuid.UUID) (bool, error) {
+	if viewerID == authorID {
+		return true, nil
+	}
+
+	var author models.User
+	if err := r.db.Select("id", "is_private").First(&author, "id = ?", authorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+
+	blocked, err := r.IsBlocked(viewerID, authorID)
+	if err != nil || blocked {
+		return false, err
+	}
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	return r.IsFollowing(viewerID, authorID)
+}
+
+// GetFollowRequest retrieves a follow request by ID
+func (r *PostRepository) GetFollowRequest(id uuid.UUID) (*models.FollowRequest, error) {
+	var request models.FollowRequest
+	err := r.db.First(&request, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetIncomingFollowRequests retrieves pending requests to follow a user, oldest first
+func (r *PostRepository) GetIncomingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error) {
+	var requests []models.FollowRequest
+	err := r.db.
+		Preload("Requester").
+		Where("target_id = ?", userID).
+		Order("created_at ASC").
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetOutgoingFollowRequests retrieves a user's pending requests to follow others, newest first
+func (r *PostRepository) GetOutgoingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error) {
+	var requests []models.FollowRequest
+	err := r.db.
+		Preload("Target").
+		Where("requester_id = ?", userID).
+		Order("created_at DESC").
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// ApproveFollowRequest turns a pending request into a follow relationship
+func (r *PostRepository) ApproveFollowRequest(id uuid.UUID) error {
+	var request models.FollowRequest
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&request, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		follow := models.UserFollow{
+			FollowerID:  request.RequesterID,
+			FollowingID: request.TargetID,
+			CreatedAt:   time.Now(),
+		}
+		if _, err := addFollow(tx, &follow); err != nil {
+			return err
+		}
+
+		return tx.Delete(&request).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.Followed(request.RequesterID, request.TargetID) })
+	return nil
+}
+
+// DeleteFollowRequest removes a pending request, used both for rejecting and cancelling
+func (r *PostRepository) DeleteFollowRequest(id uuid.UUID) error {
+	return r.db.Delete(&models.FollowRequest{}, "id = ?", id).Error
+}
+
+// findPosts pages a posts query by the (created_at, id) keyset, newest first,
+// leaving out posts that are not ready
+func (r *PostRepository) findPosts(query *gorm.DB, cursor *Cursor, limit int) ([]models.Post, error) {
+	if cursor != nil {
+		query = query.Where("(posts.created_at, posts.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	posts := []models.Post{}
+	err := query.
+		Scopes(ready, withAuthorAndMedia).
+		Order("posts.created_at DESC").
+		Order("posts.id DESC").
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// withAuthorAndMedia loads each post's author and its media in carousel order
+func withAuthorAndMedia(db *gorm.DB) *gorm.DB {
+	return db.Preload("User").Preload("Media", func(db *gorm.DB) *gorm.DB {
+		return db.Order("post_media.position ASC")
+	})
+}
+
+// ready limits a posts query to posts whose media can be shown, leaving out
+// videos that are processing or failed
+func ready(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ?", models.PostReady)
+}
+
+// visibleTo limits a posts query to posts the viewer may see
+func visibleTo(viewerID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`(posts.user_id = ?
+			OR EXISTS (SELECT 1 FROM users author WHERE author.id = posts.user_id AND NOT author.is_private)
+			OR EXISTS (SELECT 1 FROM user_follows vf WHERE vf.follower_id = ? AND vf.following_id = posts.user_id))`,
+			viewerID, viewerID)
+	}
+}
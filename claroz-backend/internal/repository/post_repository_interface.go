@@ -1,25 +1,113 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 )
 
 type PostRepositoryInterface interface {
 	CreatePost(post *models.Post) error
+	CreatePostWithMedia(post *models.Post, mediaIDs []uuid.UUID) error
 	GetPostByID(id uuid.UUID) (*models.Post, error)
-	GetPosts(page, pageSize int) ([]models.Post, error)
+	UpdatePost(id uuid.UUID, caption, language string) (*models.Post, error)
+	GetPostRevisions(postID uuid.UUID) ([]models.PostRevision, error)
+	GetPosts(viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error)
+	GetPostsByOffset(viewerID uuid.UUID, offset, limit int) ([]models.Post, error)
 	DeletePost(id uuid.UUID, userID uuid.UUID) error
 	AddComment(comment *models.Comment) error
-	DeleteComment(id uuid.UUID, userID uuid.UUID) error
-	LikePost(like *models.Like) error
+	GetComment(id uuid.UUID) (*models.Comment, error)
+	GetComments(postID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Comment, error)
+	GetReplies(commentID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Comment, error)
+	DeleteComment(id uuid.UUID) error
+	LikePost(postID, userID uuid.UUID) error
 	UnlikePost(postID, userID uuid.UUID) error
 	HasUserLikedPost(postID, userID uuid.UUID) (bool, error)
 	GetPostLikes(postID uuid.UUID) (int64, error)
-	GetUserPosts(userID uuid.UUID) ([]models.Post, error)
-	FollowUser(followerID, followingID uuid.UUID) error
+	AddReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error
+	RemoveReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error
+	GetReactions(target models.ReactionTarget, viewerID uuid.UUID, emoji string, cursor *Cursor, limit int) ([]models.Reaction, error)
+	GetCommentReactions(viewerID uuid.UUID, commentIDs []uuid.UUID) (map[uuid.UUID]*ReactionSummary, error)
+	GetPostStats(viewerID uuid.UUID, postIDs []uuid.UUID, previewSize int) (map[uuid.UUID]*PostStats, error)
+	GetPostInteractions(viewerID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID][]models.Comment, map[uuid.UUID][]models.Reaction, error)
+	GetUserPosts(userID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error)
+	GetUserPostsCount(userID uuid.UUID) (int64, error)
+	FollowUser(followerID, followingID uuid.UUID) (string, error)
 	UnfollowUser(followerID, followingID uuid.UUID) error
 	IsFollowing(followerID, followingID uuid.UUID) (bool, error)
 	GetFollowersCount(userID uuid.UUID) (int64, error)
 	GetFollowingCount(userID uuid.UUID) (int64, error)
+	GetFollowers(userID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.UserSummary, error)
+	GetFollowing(userID, viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.UserSummary, error)
+	CanViewPosts(viewerID, authorID uuid.UUID) (bool, error)
+	GetFollowRequest(id uuid.UUID) (*models.FollowRequest, error)
+	GetIncomingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error)
+	GetOutgoingFollowRequests(userID uuid.UUID) ([]models.FollowRequest, error)
+	ApproveFollowRequest(id uuid.UUID) error
+	DeleteFollowRequest(id uuid.UUID) error
+	BlockUser(blockerID, blockedID uuid.UUID) error
+	UnblockUser(blockerID, blockedID uuid.UUID) error
+	GetBlockedUsers(userID uuid.UUID) ([]models.UserBlock, error)
+	IsBlocked(userID, otherID uuid.UUID) (bool, error)
+	MuteUser(muterID, mutedID uuid.UUID, expiresAt *time.Time) error
+	UnmuteUser(muterID, mutedID uuid.UUID) error
+	GetMutedUsers(userID uuid.UUID) ([]models.UserMute, error)
+	SearchPosts(viewerID uuid.UUID, params PostSearchParams, cursor *Cursor, limit int) ([]models.Post, error)
+	SearchPostsByOffset(viewerID uuid.UUID, params PostSearchParams, offset, limit int) ([]models.Post, error)
+	GetHashtagPosts(viewerID uuid.UUID, tag string, cursor *Cursor, limit int) ([]models.Post, error)
+	GetHashtagPostsByOffset(viewerID uuid.UUID, tag string, offset, limit int) ([]models.Post, error)
+	GetHomeTimeline(viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error)
+	GetTimelinePostsByIDs(viewerID uuid.UUID, ids []uuid.UUID) ([]models.Post, error)
+	GetPostsByAuthors(viewerID uuid.UUID, authorIDs []uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error)
+	GetFollowerIDs(userID uuid.UUID) ([]uuid.UUID, error)
+	GetLargeFollowedAccounts(userID uuid.UUID, minFollowers int64) ([]uuid.UUID, error)
+	ReconcileCounters() ([]CounterDrift, error)
+	GetProcessingPostIDs() ([]uuid.UUID, error)
+	GetProcessingVideo(postID uuid.UUID) (*models.PostMedia, error)
+	CompleteVideo(postID uuid.UUID, media models.PostMedia, imageURL string) error
+	FailVideo(postID uuid.UUID) error
+}
+
+// Cursor marks a position in a keyset-paginated list: the creation time and
+// ID of the last item returned. Lists ordered by relevance also carry the
+// last item's rank, which orders before creation time.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float64   `json:"r,omitempty"`
+}
+
+// PostStats summarizes a post's interactions for a viewer
+type PostStats struct {
+	LikeCount       int64
+	CommentCount    int64
+	ViewerHasLiked  bool
+	Reactions       []models.ReactionCount // most used first
+	ViewerReactions []string               // emoji the viewer reacted with
+	RecentComments  []models.Comment       // latest comments, oldest first
+}
+
+// ReactionSummary counts the reactions to a post or comment and lists the viewer's
+type ReactionSummary struct {
+	Reactions       []models.ReactionCount // most used first
+	ViewerReactions []string               // emoji the viewer reacted with
+}
+
+// CounterDrift is a stored counter that no longer matched the rows it counts
+type CounterDrift struct {
+	Counter string    // table and column, e.g. "posts.like_count"
+	ID      uuid.UUID // row the counter is stored on
+	Stored  int64
+	Actual  int64
+}
+
+// PostSearchParams holds a post search query and its optional filters
+type PostSearchParams struct {
+	Query    string
+	Language string     // text search configuration; empty matches each post in its own language
+	AuthorID *uuid.UUID // only posts by this user
+	Hashtag  string     // normalized hashtag the posts must be tagged with
+	From     *time.Time // created at or after
+	To       *time.Time // created before
 }
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -69,6 +71,58 @@ func TestPostRepository_CreatePost(t *testing.T) {
 		}
 	})
 
+	t.Run("create carousel post", func(t *testing.T) {
+		post := &models.Post{
+			UserID:   user.ID,
+			Caption:  "Carousel",
+			ImageURL: "first.jpg",
+			Media: []models.PostMedia{
+				{
+					Position: 0, URL: "first.jpg", Width: 1080, Height: 1350, MimeType: "image/jpeg", AltText: "A sunset",
+					Variants: models.ImageVariants{
+						"full":      {URL: "first.jpg", Width: 1080, Height: 1350, MimeType: "image/jpeg"},
+						"thumbnail": {URL: "first-thumbnail.jpg", Width: 256, Height: 320, MimeType: "image/jpeg"},
+					},
+				},
+				{Position: 1, URL: "second.png", Width: 640, Height: 480, MimeType: "image/png"},
+			},
+		}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+
+		created, err := postRepo.GetPostByID(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get created post: %v", err)
+		}
+		if len(created.Media) != 2 || created.Media[0].URL != "first.jpg" || created.Media[1].URL != "second.png" {
+			t.Fatalf("Expected the media in order, got %+v", created.Media)
+		}
+		if created.Media[0].AltText != "A sunset" || created.Media[1].Width != 640 || created.Media[1].MimeType != "image/png" {
+			t.Errorf("Media not stored as created: %+v", created.Media)
+		}
+		if created.Media[0].Variants["thumbnail"].Width != 256 || len(created.Media[1].Variants) != 0 {
+			t.Errorf("Variants not stored as created: %+v", created.Media)
+		}
+
+		posts, err := postRepo.GetUserPosts(user.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get user posts: %v", err)
+		}
+		if len(posts) != 2 || len(posts[0].Media) != 2 {
+			t.Errorf("Expected listed posts to include their media, got %+v", posts)
+		}
+
+		if err := postRepo.DeletePost(post.ID, user.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+		var remaining int64
+		db.DB.Model(&models.PostMedia{}).Where("post_id = ?", post.ID).Count(&remaining)
+		if remaining != 0 {
+			t.Errorf("Expected the post's media to be deleted, got %d", remaining)
+		}
+	})
+
 	if err := db.CleanupData(); err != nil {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
@@ -103,7 +157,7 @@ func TestPostRepository_GetPosts(t *testing.T) {
 	}
 
 	t.Run("get posts with pagination", func(t *testing.T) {
-		fetchedPosts, err := postRepo.GetPosts(1, 3)
+		fetchedPosts, err := postRepo.GetPosts(user.ID, nil, 3)
 		if err != nil {
 			t.Errorf("Failed to get posts: %v", err)
 		}
@@ -120,6 +174,35 @@ func TestPostRepository_GetPosts(t *testing.T) {
 		}
 	})
 
+	t.Run("continue after cursor", func(t *testing.T) {
+		page1, err := postRepo.GetPosts(user.ID, nil, 3)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+		last := page1[len(page1)-1]
+		page2, err := postRepo.GetPosts(user.ID, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, 3)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+
+		if len(page2) != 2 {
+			t.Fatalf("Expected 2 posts on the second page, got %d", len(page2))
+		}
+		if page2[0].ID != posts[1].ID || page2[1].ID != posts[0].ID {
+			t.Error("Expected the second page to hold the two oldest posts")
+		}
+	})
+
+	t.Run("deprecated offset pagination", func(t *testing.T) {
+		fetchedPosts, err := postRepo.GetPostsByOffset(user.ID, 3, 3)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+		if len(fetchedPosts) != 2 || fetchedPosts[0].ID != posts[1].ID {
+			t.Errorf("Expected the two oldest posts, got %d posts", len(fetchedPosts))
+		}
+	})
+
 	if err := db.CleanupData(); err != nil {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
@@ -160,30 +243,158 @@ func TestPostRepository_Comments(t *testing.T) {
 		}
 
 		// Verify comment was added
-		postWithComments, err := postRepo.GetPostByID(post.ID)
+		stats, err := postRepo.GetPostStats(user.ID, []uuid.UUID{post.ID}, 3)
 		if err != nil {
-			t.Errorf("Failed to get post with comments: %v", err)
+			t.Errorf("Failed to get post stats: %v", err)
 		}
-		if len(postWithComments.Comments) != 1 {
-			t.Errorf("Expected 1 comment, got %d", len(postWithComments.Comments))
+		if stats[post.ID].CommentCount != 1 || len(stats[post.ID].RecentComments) != 1 {
+			t.Errorf("Expected 1 comment, got %d", stats[post.ID].CommentCount)
 		}
 
 		// Delete comment
-		err = postRepo.DeleteComment(comment.ID, user.ID)
+		err = postRepo.DeleteComment(comment.ID)
 		if err != nil {
 			t.Errorf("Failed to delete comment: %v", err)
 		}
 
 		// Verify comment was deleted
-		postWithComments, err = postRepo.GetPostByID(post.ID)
+		stats, err = postRepo.GetPostStats(user.ID, []uuid.UUID{post.ID}, 3)
 		if err != nil {
-			t.Errorf("Failed to get post after comment deletion: %v", err)
+			t.Errorf("Failed to get post stats after comment deletion: %v", err)
 		}
-		if len(postWithComments.Comments) != 0 {
+		if stats[post.ID].CommentCount != 0 {
 			t.Error("Expected no comments after deletion")
 		}
 	})
 
+	t.Run("list comments oldest first", func(t *testing.T) {
+		base := time.Now()
+		for i := 0; i < 3; i++ {
+			comment := &models.Comment{
+				PostID:    post.ID,
+				UserID:    user.ID,
+				Content:   "Comment",
+				CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			}
+			if err := postRepo.AddComment(comment); err != nil {
+				t.Fatalf("Failed to add comment: %v", err)
+			}
+		}
+
+		page1, err := postRepo.GetComments(post.ID, user.ID, nil, 2)
+		if err != nil {
+			t.Fatalf("Failed to get comments: %v", err)
+		}
+		if len(page1) != 2 || page1[0].CreatedAt.After(page1[1].CreatedAt) {
+			t.Fatalf("Expected the 2 oldest comments in order, got %d", len(page1))
+		}
+		if page1[0].User.ID != user.ID {
+			t.Error("Expected comment authors to be loaded")
+		}
+
+		last := page1[len(page1)-1]
+		page2, err := postRepo.GetComments(post.ID, user.ID, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, 2)
+		if err != nil {
+			t.Fatalf("Failed to get comments: %v", err)
+		}
+		if len(page2) != 1 {
+			t.Errorf("Expected 1 comment on the second page, got %d", len(page2))
+		}
+	})
+
+	t.Run("threaded replies", func(t *testing.T) {
+		threadPost := &models.Post{UserID: user.ID, Caption: "Thread", ImageURL: "thread.jpg"}
+		if err := postRepo.CreatePost(threadPost); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		reply := func(parent *models.Comment) *models.Comment {
+			comment := &models.Comment{PostID: threadPost.ID, UserID: user.ID, Content: "Reply"}
+			if parent != nil {
+				comment.ParentID = &parent.ID
+			}
+			if err := postRepo.AddComment(comment); err != nil {
+				t.Fatalf("Failed to add comment: %v", err)
+			}
+			return comment
+		}
+		stats := func() *PostStats {
+			stats, err := postRepo.GetPostStats(user.ID, []uuid.UUID{threadPost.ID}, 3)
+			if err != nil {
+				t.Fatalf("Failed to get post stats: %v", err)
+			}
+			return stats[threadPost.ID]
+		}
+
+		root := reply(nil)
+		chain := []*models.Comment{root}
+		for i := 0; i < models.MaxCommentDepth; i++ {
+			chain = append(chain, reply(chain[len(chain)-1]))
+		}
+		if deepest := chain[len(chain)-1]; deepest.Depth != models.MaxCommentDepth {
+			t.Errorf("Expected depth %d, got %d", models.MaxCommentDepth, deepest.Depth)
+		}
+		tooDeep := &models.Comment{PostID: threadPost.ID, UserID: user.ID, Content: "Reply", ParentID: &chain[len(chain)-1].ID}
+		if err := postRepo.AddComment(tooDeep); !errors.Is(err, ErrCommentTooDeep) {
+			t.Errorf("Expected ErrCommentTooDeep, got %v", err)
+		}
+		elsewhere := &models.Comment{PostID: post.ID, UserID: user.ID, Content: "Reply", ParentID: &root.ID}
+		if err := postRepo.AddComment(elsewhere); !errors.Is(err, ErrCommentNotFound) {
+			t.Errorf("Expected replying across posts to fail with ErrCommentNotFound, got %v", err)
+		}
+
+		if s := stats(); s.CommentCount != 4 || len(s.RecentComments) != 1 {
+			t.Errorf("Expected 4 comments and a preview of the top-level one, got %d and %d", s.CommentCount, len(s.RecentComments))
+		}
+		top, err := postRepo.GetComments(threadPost.ID, user.ID, nil, 10)
+		if err != nil || len(top) != 1 || top[0].ReplyCount != 1 {
+			t.Fatalf("Expected the top-level comment with 1 reply, got %+v (%v)", top, err)
+		}
+		replies, err := postRepo.GetReplies(root.ID, user.ID, nil, 10)
+		if err != nil || len(replies) != 1 || replies[0].ID != chain[1].ID {
+			t.Fatalf("Expected the direct reply, got %+v (%v)", replies, err)
+		}
+
+		// Deleting a comment with replies keeps it as a tombstone
+		if err := postRepo.DeleteComment(chain[1].ID); err != nil {
+			t.Fatalf("Failed to delete comment: %v", err)
+		}
+		tombstone, err := postRepo.GetComment(chain[1].ID)
+		if err != nil || tombstone.DeletedAt == nil || tombstone.Content != "" {
+			t.Fatalf("Expected a tombstone, got %+v (%v)", tombstone, err)
+		}
+		if err := postRepo.AddComment(&models.Comment{PostID: threadPost.ID, UserID: user.ID, Content: "Reply", ParentID: &chain[1].ID}); !errors.Is(err, ErrCommentNotFound) {
+			t.Errorf("Expected replying to a deleted comment to fail, got %v", err)
+		}
+		if s := stats(); s.CommentCount != 3 {
+			t.Errorf("Expected 3 comments after deleting one, got %d", s.CommentCount)
+		}
+
+		// Deleting the last replies removes tombstones left without any
+		if err := postRepo.DeleteComment(chain[3].ID); err != nil {
+			t.Fatalf("Failed to delete comment: %v", err)
+		}
+		if err := postRepo.DeleteComment(chain[2].ID); err != nil {
+			t.Fatalf("Failed to delete comment: %v", err)
+		}
+		if _, err := postRepo.GetComment(chain[1].ID); err == nil {
+			t.Error("Expected the tombstone to be removed with its last reply")
+		}
+		if root, err := postRepo.GetComment(root.ID); err != nil || root.ReplyCount != 0 {
+			t.Errorf("Expected the root comment to have no replies, got %+v (%v)", root, err)
+		}
+		if s := stats(); s.CommentCount != 1 {
+			t.Errorf("Expected 1 comment left, got %d", s.CommentCount)
+		}
+
+		drifts, err := postRepo.ReconcileCounters()
+		if err != nil {
+			t.Fatalf("Failed to reconcile counters: %v", err)
+		}
+		if len(drifts) != 0 {
+			t.Errorf("Expected comment counters to be consistent, got %+v", drifts)
+		}
+	})
+
 	if err := db.CleanupData(); err != nil {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
@@ -212,13 +423,8 @@ func TestPostRepository_Likes(t *testing.T) {
 	}
 
 	t.Run("like and unlike post", func(t *testing.T) {
-		like := &models.Like{
-			PostID: post.ID,
-			UserID: user.ID,
-		}
-
 		// Like post
-		err := postRepo.LikePost(like)
+		err := postRepo.LikePost(post.ID, user.ID)
 		if err != nil {
 			t.Errorf("Failed to like post: %v", err)
 		}
@@ -252,11 +458,7 @@ func TestPostRepository_Likes(t *testing.T) {
 		// Add multiple likes
 		for i := 0; i < 3; i++ {
 			otherUser := createTestUser(t, userRepo)
-			like := &models.Like{
-				PostID: post.ID,
-				UserID: otherUser.ID,
-			}
-			err := postRepo.LikePost(like)
+			err := postRepo.LikePost(post.ID, otherUser.ID)
 			if err != nil {
 				t.Fatalf("Failed to create like: %v", err)
 			}
@@ -271,12 +473,43 @@ func TestPostRepository_Likes(t *testing.T) {
 		}
 	})
 
+	t.Run("list likes newest first", func(t *testing.T) {
+		page1, err := postRepo.GetReactions(models.PostTarget(post.ID), user.ID, models.HeartReaction, nil, 2)
+		if err != nil {
+			t.Fatalf("Failed to get likes: %v", err)
+		}
+		if len(page1) != 2 || page1[0].CreatedAt.Before(page1[1].CreatedAt) {
+			t.Fatalf("Expected the 2 newest likes in order, got %d", len(page1))
+		}
+
+		last := page1[len(page1)-1]
+		page2, err := postRepo.GetReactions(models.PostTarget(post.ID), user.ID, models.HeartReaction, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, 2)
+		if err != nil {
+			t.Fatalf("Failed to get likes: %v", err)
+		}
+		if len(page2) != 1 {
+			t.Errorf("Expected 1 like on the second page, got %d", len(page2))
+		}
+
+		// Likes by blocked users are hidden from the blocker
+		if err := postRepo.BlockUser(user.ID, page1[0].UserID); err != nil {
+			t.Fatalf("Failed to block user: %v", err)
+		}
+		likes, err := postRepo.GetReactions(models.PostTarget(post.ID), user.ID, models.HeartReaction, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get likes: %v", err)
+		}
+		if len(likes) != 2 {
+			t.Errorf("Expected 2 likes after blocking a liker, got %d", len(likes))
+		}
+	})
+
 	if err := db.CleanupData(); err != nil {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
 }
 
-func TestPostRepository_Follow(t *testing.T) {
+func TestPostRepository_Reactions(t *testing.T) {
 	db := testutils.NewTestDB(t)
 	defer func() {
 		if err := db.Cleanup(); err != nil {
@@ -286,57 +519,226 @@ func TestPostRepository_Follow(t *testing.T) {
 
 	userRepo := NewUserRepository(db.DB)
 	postRepo := NewPostRepository(db.DB)
-	user1 := createTestUser(t, userRepo)
-	user2 := createTestUser(t, userRepo)
+	author := createTestUser(t, userRepo)
+	fan := createTestUser(t, userRepo)
+	other := createTestUser(t, userRepo)
 
-	t.Run("follow and unfollow user", func(t *testing.T) {
-		// Follow user
-		err := postRepo.FollowUser(user1.ID, user2.ID)
+	post := &models.Post{UserID: author.ID, Caption: "Test post", ImageURL: "test.jpg"}
+	if err := postRepo.CreatePost(post); err != nil {
+		t.Fatalf("Failed to create test post: %v", err)
+	}
+	comment := &models.Comment{PostID: post.ID, UserID: author.ID, Content: "First"}
+	if err := postRepo.AddComment(comment); err != nil {
+		t.Fatalf("Failed to add comment: %v", err)
+	}
+	postStats := func(viewerID uuid.UUID) *PostStats {
+		stats, err := postRepo.GetPostStats(viewerID, []uuid.UUID{post.ID}, 0)
 		if err != nil {
-			t.Errorf("Failed to follow user: %v", err)
+			t.Fatalf("Failed to get post stats: %v", err)
 		}
+		return stats[post.ID]
+	}
 
-		// Verify following status
-		isFollowing, err := postRepo.IsFollowing(user1.ID, user2.ID)
-		if err != nil {
-			t.Errorf("Failed to check following status: %v", err)
+	t.Run("post reactions", func(t *testing.T) {
+		for _, r := range []struct {
+			user  *models.User
+			emoji string
+		}{{fan, "laugh"}, {fan, models.HeartReaction}, {other, "laugh"}, {fan, "laugh"}} {
+			if err := postRepo.AddReaction(models.PostTarget(post.ID), r.user.ID, r.emoji); err != nil {
+				t.Fatalf("Failed to add reaction: %v", err)
+			}
 		}
-		if !isFollowing {
-			t.Error("Expected user1 to be following user2")
+
+		s := postStats(fan.ID)
+		want := []models.ReactionCount{{Emoji: "laugh", Count: 2}, {Emoji: models.HeartReaction, Count: 1}}
+		if len(s.Reactions) != 2 || s.Reactions[0] != want[0] || s.Reactions[1] != want[1] {
+			t.Errorf("Expected %v, got %v", want, s.Reactions)
+		}
+		if len(s.ViewerReactions) != 2 || !s.ViewerHasLiked || s.LikeCount != 1 {
+			t.Errorf("Expected the fan's heart to count as a like, got %+v", s)
 		}
 
-		// Unfollow user
-		err = postRepo.UnfollowUser(user1.ID, user2.ID)
+		laughs, err := postRepo.GetReactions(models.PostTarget(post.ID), author.ID, "laugh", nil, 10)
+		if err != nil || len(laughs) != 2 || laughs[0].User.ID != other.ID {
+			t.Errorf("Expected the 2 laughs, newest first, got %+v (%v)", laughs, err)
+		}
+
+		if err := postRepo.RemoveReaction(models.PostTarget(post.ID), fan.ID, models.HeartReaction); err != nil {
+			t.Fatalf("Failed to remove reaction: %v", err)
+		}
+		if liked, _ := postRepo.HasUserLikedPost(post.ID, fan.ID); liked || postStats(fan.ID).LikeCount != 0 {
+			t.Error("Expected removing the heart to unlike the post")
+		}
+	})
+
+	t.Run("comment reactions", func(t *testing.T) {
+		if err := postRepo.AddReaction(models.CommentTarget(comment.ID), fan.ID, "clap"); err != nil {
+			t.Fatalf("Failed to add reaction: %v", err)
+		}
+		summaries, err := postRepo.GetCommentReactions(fan.ID, []uuid.UUID{comment.ID})
 		if err != nil {
-			t.Errorf("Failed to unfollow user: %v", err)
+			t.Fatalf("Failed to get comment reactions: %v", err)
+		}
+		if s := summaries[comment.ID]; len(s.Reactions) != 1 || s.Reactions[0].Count != 1 || len(s.ViewerReactions) != 1 {
+			t.Errorf("Expected the clap, got %+v", s)
+		}
+		if postStats(fan.ID).LikeCount != 0 {
+			t.Error("Expected comment reactions not to count as post likes")
 		}
 
-		// Verify following status
-		isFollowing, err = postRepo.IsFollowing(user1.ID, user2.ID)
+		if err := postRepo.AddReaction(models.CommentTarget(uuid.New()), fan.ID, "clap"); !errors.Is(err, ErrCommentNotFound) {
+			t.Errorf("Expected ErrCommentNotFound, got %v", err)
+		}
+		if err := postRepo.AddReaction(models.PostTarget(uuid.New()), fan.ID, "clap"); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+	})
+
+	t.Run("blocked users cannot react", func(t *testing.T) {
+		if err := postRepo.BlockUser(author.ID, other.ID); err != nil {
+			t.Fatalf("Failed to block user: %v", err)
+		}
+		if err := postRepo.AddReaction(models.CommentTarget(comment.ID), other.ID, "clap"); !errors.Is(err, ErrBlocked) {
+			t.Errorf("Expected ErrBlocked, got %v", err)
+		}
+
+		// The post's author blocking them also covers other users' comments
+		fanComment := &models.Comment{PostID: post.ID, UserID: fan.ID, Content: "Second"}
+		if err := postRepo.AddComment(fanComment); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		if err := postRepo.AddReaction(models.CommentTarget(fanComment.ID), other.ID, "clap"); !errors.Is(err, ErrBlocked) {
+			t.Errorf("Expected ErrBlocked reacting to a comment on the blocker's post, got %v", err)
+		}
+	})
+
+	t.Run("reconcile like counts", func(t *testing.T) {
+		if err := postRepo.LikePost(post.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to like post: %v", err)
+		}
+		if err := db.DB.Exec("UPDATE posts SET like_count = 5 WHERE id = ?", post.ID).Error; err != nil {
+			t.Fatalf("Failed to corrupt counter: %v", err)
+		}
+		drifts, err := postRepo.ReconcileCounters()
 		if err != nil {
-			t.Errorf("Failed to check following status: %v", err)
+			t.Fatalf("Failed to reconcile counters: %v", err)
 		}
-		if isFollowing {
-			t.Error("Expected user1 to not be following user2")
+		if len(drifts) != 1 || drifts[0].Counter != "posts.like_count" || drifts[0].Actual != 1 {
+			t.Errorf("Expected the like count to be recounted from hearts, got %+v", drifts)
 		}
 	})
 
-	t.Run("get followers and following count", func(t *testing.T) {
-		// Create multiple followers
-		for i := 0; i < 3; i++ {
-			follower := createTestUser(t, userRepo)
-			err := postRepo.FollowUser(follower.ID, user1.ID)
-			if err != nil {
-				t.Fatalf("Failed to create follow relationship: %v", err)
+	t.Run("deleting comments removes their reactions", func(t *testing.T) {
+		parent := &models.Comment{PostID: post.ID, UserID: author.ID, Content: "Parent"}
+		if err := postRepo.AddComment(parent); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		reply := &models.Comment{PostID: post.ID, UserID: author.ID, Content: "Reply", ParentID: &parent.ID}
+		if err := postRepo.AddComment(reply); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		for _, c := range []*models.Comment{parent, reply} {
+			if err := postRepo.AddReaction(models.CommentTarget(c.ID), fan.ID, "clap"); err != nil {
+				t.Fatalf("Failed to add reaction: %v", err)
 			}
 		}
 
-		followersCount, err := postRepo.GetFollowersCount(user1.ID)
+		// Tombstoning the parent, then deleting its only reply, removes both
+		for _, c := range []*models.Comment{parent, reply} {
+			if err := postRepo.DeleteComment(c.ID); err != nil {
+				t.Fatalf("Failed to delete comment: %v", err)
+			}
+		}
+		var count int64
+		db.DB.Model(&models.Reaction{}).Where("comment_id IN ?", []uuid.UUID{parent.ID, reply.ID}).Count(&count)
+		if count != 0 {
+			t.Errorf("Expected the comments' reactions to be deleted, got %d", count)
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
+
+func TestPostRepository_PostStats(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	viewer := createTestUser(t, userRepo)
+	author := createTestUser(t, userRepo)
+	blocked := createTestUser(t, userRepo)
+
+	busy := &models.Post{UserID: author.ID, Caption: "Busy", ImageURL: "busy.jpg"}
+	quiet := &models.Post{UserID: author.ID, Caption: "Quiet", ImageURL: "quiet.jpg"}
+	for _, post := range []*models.Post{busy, quiet} {
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+	}
+
+	base := time.Now()
+	for i, commenter := range []*models.User{author, author, blocked, author} {
+		comment := &models.Comment{PostID: busy.ID, UserID: commenter.ID, Content: "Comment", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := postRepo.AddComment(comment); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+	}
+	for _, liker := range []*models.User{viewer, author, blocked} {
+		if err := postRepo.LikePost(busy.ID, liker.ID); err != nil {
+			t.Fatalf("Failed to like post: %v", err)
+		}
+	}
+	if err := postRepo.BlockUser(viewer.ID, blocked.ID); err != nil {
+		t.Fatalf("Failed to block user: %v", err)
+	}
+
+	t.Run("counts, viewer state and comment preview", func(t *testing.T) {
+		stats, err := postRepo.GetPostStats(viewer.ID, []uuid.UUID{busy.ID, quiet.ID}, 2)
 		if err != nil {
-			t.Errorf("Failed to get followers count: %v", err)
+			t.Fatalf("Failed to get post stats: %v", err)
 		}
-		if followersCount != 3 {
-			t.Errorf("Expected 3 followers, got %d", followersCount)
+
+		s := stats[busy.ID]
+		if s.LikeCount != 3 || s.CommentCount != 4 {
+			t.Errorf("Expected 3 likes and 4 comments, got %d and %d", s.LikeCount, s.CommentCount)
+		}
+		if !s.ViewerHasLiked {
+			t.Error("Expected the viewer to have liked the post")
+		}
+		if len(s.RecentComments) != 2 {
+			t.Fatalf("Expected 2 preview comments, got %d", len(s.RecentComments))
+		}
+		for _, comment := range s.RecentComments {
+			if comment.UserID == blocked.ID {
+				t.Error("Expected comments by blocked users to be left out of the preview")
+			}
+		}
+		if !s.RecentComments[0].CreatedAt.Before(s.RecentComments[1].CreatedAt) || s.RecentComments[1].User.ID != author.ID {
+			t.Error("Expected the latest comments oldest first with their authors")
+		}
+
+		if q := stats[quiet.ID]; q.LikeCount != 0 || q.CommentCount != 0 || q.ViewerHasLiked || len(q.RecentComments) != 0 {
+			t.Errorf("Expected empty stats for a post without interactions, got %+v", q)
+		}
+	})
+
+	t.Run("expanded interactions", func(t *testing.T) {
+		comments, likes, err := postRepo.GetPostInteractions(viewer.ID, []uuid.UUID{busy.ID})
+		if err != nil {
+			t.Fatalf("Failed to get post interactions: %v", err)
+		}
+		if len(comments[busy.ID]) != 3 {
+			t.Errorf("Expected 3 comments excluding the blocked user, got %d", len(comments[busy.ID]))
+		}
+		if len(likes[busy.ID]) != 2 {
+			t.Errorf("Expected 2 likes excluding the blocked user, got %d", len(likes[busy.ID]))
 		}
 	})
 
@@ -344,3 +746,830 @@ func TestPostRepository_Follow(t *testing.T) {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
 }
+
+func TestPostRepository_Counters(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	author := createTestUser(t, userRepo)
+	fan := createTestUser(t, userRepo)
+
+	post := &models.Post{UserID: author.ID, Caption: "Counted", ImageURL: "counted.jpg"}
+	if err := postRepo.CreatePost(post); err != nil {
+		t.Fatalf("Failed to create test post: %v", err)
+	}
+
+	postCounts := func() (int64, int64) {
+		stats, err := postRepo.GetPostStats(fan.ID, []uuid.UUID{post.ID}, 0)
+		if err != nil {
+			t.Fatalf("Failed to get post stats: %v", err)
+		}
+		return stats[post.ID].LikeCount, stats[post.ID].CommentCount
+	}
+
+	t.Run("likes and comments", func(t *testing.T) {
+		if err := postRepo.LikePost(post.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to like post: %v", err)
+		}
+		if err := postRepo.LikePost(post.ID, fan.ID); err != nil {
+			t.Fatalf("Expected a repeated like to succeed: %v", err)
+		}
+		comment := &models.Comment{PostID: post.ID, UserID: fan.ID, Content: "Nice"}
+		if err := postRepo.AddComment(comment); err != nil {
+			t.Fatalf("Failed to add comment: %v", err)
+		}
+		if likes, comments := postCounts(); likes != 1 || comments != 1 {
+			t.Errorf("Expected a repeated like to count once, got %d likes and %d comments", likes, comments)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := postRepo.UnlikePost(post.ID, fan.ID); err != nil {
+				t.Fatalf("Failed to unlike post: %v", err)
+			}
+		}
+		if err := postRepo.DeleteComment(comment.ID); err != nil {
+			t.Fatalf("Failed to delete comment: %v", err)
+		}
+		if err := postRepo.DeleteComment(comment.ID); !errors.Is(err, ErrCommentNotFound) {
+			t.Errorf("Expected deleting a deleted comment to fail with ErrCommentNotFound, got %v", err)
+		}
+		if likes, comments := postCounts(); likes != 0 || comments != 0 {
+			t.Errorf("Expected repeated removals to count once, got %d likes and %d comments", likes, comments)
+		}
+	})
+
+	t.Run("concurrent likes", func(t *testing.T) {
+		fans := make([]*models.User, 8)
+		for i := range fans {
+			fans[i] = createTestUser(t, userRepo)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, len(fans))
+		for _, f := range fans {
+			wg.Add(1)
+			go func(userID uuid.UUID) {
+				defer wg.Done()
+				errs <- postRepo.LikePost(post.ID, userID)
+			}(f.ID)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Expected concurrent likes to succeed, got %v", err)
+			}
+		}
+		if likes, _ := postCounts(); likes != int64(len(fans)) {
+			t.Errorf("Expected %d likes, got %d", len(fans), likes)
+		}
+
+		for _, f := range fans {
+			if err := postRepo.UnlikePost(post.ID, f.ID); err != nil {
+				t.Fatalf("Failed to unlike post: %v", err)
+			}
+		}
+	})
+
+	t.Run("follows", func(t *testing.T) {
+		if _, err := postRepo.FollowUser(fan.ID, author.ID); err != nil {
+			t.Fatalf("Failed to follow user: %v", err)
+		}
+		if _, err := postRepo.FollowUser(author.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to follow user: %v", err)
+		}
+		if followers, _ := postRepo.GetFollowersCount(author.ID); followers != 1 {
+			t.Errorf("Expected 1 follower, got %d", followers)
+		}
+
+		// Saving a stale copy of the user must not overwrite its counters
+		author.Bio = "Updated"
+		if err := userRepo.Update(author); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		if followers, _ := postRepo.GetFollowersCount(author.ID); followers != 1 {
+			t.Errorf("Expected updating the user to keep its follower count, got %d", followers)
+		}
+
+		if err := postRepo.BlockUser(author.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to block user: %v", err)
+		}
+		for _, user := range []*models.User{author, fan} {
+			followers, _ := postRepo.GetFollowersCount(user.ID)
+			following, _ := postRepo.GetFollowingCount(user.ID)
+			if followers != 0 || following != 0 {
+				t.Errorf("Expected the block to clear both follows, got %d followers and %d following", followers, following)
+			}
+		}
+	})
+
+	t.Run("reconcile repairs drift", func(t *testing.T) {
+		if err := postRepo.UnblockUser(author.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to unblock user: %v", err)
+		}
+		if err := postRepo.LikePost(post.ID, fan.ID); err != nil {
+			t.Fatalf("Failed to like post: %v", err)
+		}
+		db.DB.Exec("UPDATE posts SET like_count = 7 WHERE id = ?", post.ID)
+		db.DB.Exec("UPDATE users SET followers_count = 3 WHERE id = ?", author.ID)
+
+		drifts, err := postRepo.ReconcileCounters()
+		if err != nil {
+			t.Fatalf("Failed to reconcile counters: %v", err)
+		}
+		if len(drifts) != 2 {
+			t.Fatalf("Expected 2 repaired counters, got %+v", drifts)
+		}
+		for _, d := range drifts {
+			if d.Counter == "posts.like_count" && (d.ID != post.ID || d.Stored != 7 || d.Actual != 1) {
+				t.Errorf("Unexpected like count repair: %+v", d)
+			}
+			if d.Counter == "users.followers_count" && (d.ID != author.ID || d.Stored != 3 || d.Actual != 0) {
+				t.Errorf("Unexpected follower count repair: %+v", d)
+			}
+		}
+		if likes, _ := postCounts(); likes != 1 {
+			t.Errorf("Expected the like count to be repaired, got %d", likes)
+		}
+
+		if drifts, _ := postRepo.ReconcileCounters(); len(drifts) != 0 {
+			t.Errorf("Expected nothing left to repair, got %+v", drifts)
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
+
+func TestPostRepository_Follow(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	user1 := createTestUser(t, userRepo)
+	user2 := createTestUser(t, userRepo)
+
+	t.Run("follow and unfollow user", func(t *testing.T) {
+		// Follow user
+		_, err := postRepo.FollowUser(user1.ID, user2.ID)
+		if err != nil {
+			t.Errorf("Failed to follow user: %v", err)
+		}
+
+		// Verify following status
+		isFollowing, err := postRepo.IsFollowing(user1.ID, user2.ID)
+		if err != nil {
+			t.Errorf("Failed to check following status: %v", err)
+		}
+		if !isFollowing {
+			t.Error("Expected user1 to be following user2")
+		}
+
+		// Unfollow user
+		err = postRepo.UnfollowUser(user1.ID, user2.ID)
+		if err != nil {
+			t.Errorf("Failed to unfollow user: %v", err)
+		}
+
+		// Verify following status
+		isFollowing, err = postRepo.IsFollowing(user1.ID, user2.ID)
+		if err != nil {
+			t.Errorf("Failed to check following status: %v", err)
+		}
+		if isFollowing {
+			t.Error("Expected user1 to not be following user2")
+		}
+	})
+
+	t.Run("get followers and following count", func(t *testing.T) {
+		// Create multiple followers
+		for i := 0; i < 3; i++ {
+			follower := createTestUser(t, userRepo)
+			_, err := postRepo.FollowUser(follower.ID, user1.ID)
+			if err != nil {
+				t.Fatalf("Failed to create follow relationship: %v", err)
+			}
+		}
+
+		followersCount, err := postRepo.GetFollowersCount(user1.ID)
+		if err != nil {
+			t.Errorf("Failed to get followers count: %v", err)
+		}
+		if followersCount != 3 {
+			t.Errorf("Expected 3 followers, got %d", followersCount)
+		}
+	})
+
+	t.Run("follow is idempotent", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := postRepo.FollowUser(user2.ID, user1.ID); err != nil {
+				t.Fatalf("Follow attempt %d failed: %v", i+1, err)
+			}
+		}
+
+		followingCount, err := postRepo.GetFollowingCount(user2.ID)
+		if err != nil {
+			t.Errorf("Failed to get following count: %v", err)
+		}
+		if followingCount != 1 {
+			t.Errorf("Expected 1 following, got %d", followingCount)
+		}
+	})
+
+	t.Run("follow nonexistent user", func(t *testing.T) {
+		_, err := postRepo.FollowUser(user1.ID, uuid.New())
+		if err != ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+
+	t.Run("list followers with viewer state", func(t *testing.T) {
+		// user1 has 4 followers; user1 follows user2 back
+		if _, err := postRepo.FollowUser(user1.ID, user2.ID); err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+
+		page1, err := postRepo.GetFollowers(user1.ID, user1.ID, nil, 3)
+		if err != nil {
+			t.Fatalf("Failed to list followers: %v", err)
+		}
+		if len(page1) != 3 {
+			t.Fatalf("Expected 3 followers on first page, got %d", len(page1))
+		}
+
+		last := page1[len(page1)-1]
+		page2, err := postRepo.GetFollowers(user1.ID, user1.ID, &Cursor{CreatedAt: *last.FollowedAt, ID: last.ID}, 3)
+		if err != nil {
+			t.Fatalf("Failed to list second page: %v", err)
+		}
+		if len(page2) != 1 {
+			t.Fatalf("Expected 1 follower on second page, got %d", len(page2))
+		}
+
+		for _, summary := range append(page1, page2...) {
+			if !summary.FollowsViewer {
+				t.Errorf("Expected %s to follow the viewer", summary.Username)
+			}
+			if summary.ID == user2.ID && !summary.ViewerFollows {
+				t.Error("Expected viewer to follow user2")
+			}
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
+
+func TestPostRepository_PrivateAccounts(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	owner := createTestUser(t, userRepo)
+	requester := createTestUser(t, userRepo)
+
+	owner.IsPrivate = true
+	if err := userRepo.Update(owner); err != nil {
+		t.Fatalf("Failed to make account private: %v", err)
+	}
+	post := &models.Post{UserID: owner.ID, Caption: "Private post", ImageURL: "private.jpg"}
+	if err := postRepo.CreatePost(post); err != nil {
+		t.Fatalf("Failed to create test post: %v", err)
+	}
+
+	t.Run("posts hidden from non-followers", func(t *testing.T) {
+		canView, err := postRepo.CanViewPosts(requester.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("Failed to check visibility: %v", err)
+		}
+		if canView {
+			t.Error("Expected private posts to be hidden")
+		}
+
+		posts, err := postRepo.GetPosts(requester.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected no visible posts, got %d", len(posts))
+		}
+	})
+
+	t.Run("follow creates a request", func(t *testing.T) {
+		status, err := postRepo.FollowUser(requester.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+		if status != models.FollowStatusRequested {
+			t.Errorf("Expected status %q, got %q", models.FollowStatusRequested, status)
+		}
+
+		incoming, err := postRepo.GetIncomingFollowRequests(owner.ID)
+		if err != nil {
+			t.Fatalf("Failed to list requests: %v", err)
+		}
+		if len(incoming) != 1 || incoming[0].Requester.ID != requester.ID {
+			t.Fatalf("Expected one request from requester, got %+v", incoming)
+		}
+
+		if err := postRepo.ApproveFollowRequest(incoming[0].ID); err != nil {
+			t.Fatalf("Failed to approve request: %v", err)
+		}
+	})
+
+	t.Run("approved follower can view posts", func(t *testing.T) {
+		posts, err := postRepo.GetPosts(requester.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+		if len(posts) != 1 {
+			t.Errorf("Expected 1 visible post, got %d", len(posts))
+		}
+
+		outgoing, err := postRepo.GetOutgoingFollowRequests(requester.ID)
+		if err != nil {
+			t.Fatalf("Failed to list requests: %v", err)
+		}
+		if len(outgoing) != 0 {
+			t.Errorf("Expected approved request to be removed, got %d", len(outgoing))
+		}
+	})
+}
+
+func TestPostRepository_BlocksAndMutes(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	user1 := createTestUser(t, userRepo)
+	user2 := createTestUser(t, userRepo)
+	user3 := createTestUser(t, userRepo)
+
+	post2 := &models.Post{UserID: user2.ID, Caption: "Post by user2", ImageURL: "user2.jpg"}
+	post3 := &models.Post{UserID: user3.ID, Caption: "Post by user3", ImageURL: "user3.jpg"}
+	for _, post := range []*models.Post{post2, post3} {
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+	}
+
+	feedIDs := func(viewerID uuid.UUID) map[uuid.UUID]bool {
+		posts, err := postRepo.GetPosts(viewerID, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get posts: %v", err)
+		}
+		ids := make(map[uuid.UUID]bool)
+		for _, post := range posts {
+			ids[post.ID] = true
+		}
+		return ids
+	}
+
+	t.Run("block removes follows and prevents interactions", func(t *testing.T) {
+		if _, err := postRepo.FollowUser(user1.ID, user2.ID); err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+		if _, err := postRepo.FollowUser(user2.ID, user1.ID); err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+
+		if err := postRepo.BlockUser(user1.ID, user2.ID); err != nil {
+			t.Fatalf("Failed to block user: %v", err)
+		}
+
+		for _, pair := range [][2]uuid.UUID{{user1.ID, user2.ID}, {user2.ID, user1.ID}} {
+			if following, _ := postRepo.IsFollowing(pair[0], pair[1]); following {
+				t.Error("Expected follows to be removed in both directions")
+			}
+		}
+
+		if _, err := postRepo.FollowUser(user2.ID, user1.ID); err != ErrBlocked {
+			t.Errorf("Expected ErrBlocked on follow, got %v", err)
+		}
+		if err := postRepo.LikePost(post2.ID, user1.ID); err != ErrBlocked {
+			t.Errorf("Expected ErrBlocked on like, got %v", err)
+		}
+		if err := postRepo.AddComment(&models.Comment{PostID: post2.ID, UserID: user1.ID, Content: "Hi"}); err != ErrBlocked {
+			t.Errorf("Expected ErrBlocked on comment, got %v", err)
+		}
+	})
+
+	t.Run("block hides content in both directions", func(t *testing.T) {
+		if feedIDs(user1.ID)[post2.ID] {
+			t.Error("Expected blocked user's post to be hidden from blocker")
+		}
+		if canView, _ := postRepo.CanViewPosts(user2.ID, user1.ID); canView {
+			t.Error("Expected blocker's posts to be hidden from blocked user")
+		}
+	})
+
+	t.Run("unblock", func(t *testing.T) {
+		if err := postRepo.UnblockUser(user1.ID, user2.ID); err != nil {
+			t.Fatalf("Failed to unblock user: %v", err)
+		}
+		if blocked, _ := postRepo.IsBlocked(user2.ID, user1.ID); blocked {
+			t.Error("Expected block to be removed")
+		}
+		if !feedIDs(user1.ID)[post2.ID] {
+			t.Error("Expected post to be visible after unblocking")
+		}
+	})
+
+	t.Run("mute with expiry", func(t *testing.T) {
+		if err := postRepo.MuteUser(user1.ID, user3.ID, nil); err != nil {
+			t.Fatalf("Failed to mute user: %v", err)
+		}
+		if feedIDs(user1.ID)[post3.ID] {
+			t.Error("Expected muted user's post to be hidden from muter")
+		}
+		if !feedIDs(user2.ID)[post3.ID] {
+			t.Error("Expected muted user's post to remain visible to others")
+		}
+
+		// Muting again replaces the expiry
+		expired := time.Now().Add(-time.Minute)
+		if err := postRepo.MuteUser(user1.ID, user3.ID, &expired); err != nil {
+			t.Fatalf("Failed to update mute: %v", err)
+		}
+		if !feedIDs(user1.ID)[post3.ID] {
+			t.Error("Expected expired mute to no longer hide posts")
+		}
+
+		mutes, err := postRepo.GetMutedUsers(user1.ID)
+		if err != nil {
+			t.Fatalf("Failed to list mutes: %v", err)
+		}
+		if len(mutes) != 0 {
+			t.Errorf("Expected expired mute to be omitted, got %d", len(mutes))
+		}
+	})
+}
+
+func TestPostRepository_Search(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	viewer := createTestUser(t, userRepo)
+	author := createTestUser(t, userRepo)
+
+	running := &models.Post{UserID: author.ID, Caption: "Running along the river #Fitness #morning", Language: "english", ImageURL: "run.jpg"}
+	cycling := &models.Post{UserID: author.ID, Caption: "Cycling to work #fitness", ImageURL: "bike.jpg"}
+	viewerPost := &models.Post{UserID: viewer.ID, Caption: "Ran a marathon", Language: "english", ImageURL: "marathon.jpg"}
+	for _, post := range []*models.Post{running, cycling, viewerPost} {
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+	}
+
+	t.Run("hashtags are indexed on create", func(t *testing.T) {
+		var names []string
+		err := db.DB.Table("hashtags").
+			Joins("JOIN post_hashtags ON post_hashtags.hashtag_id = hashtags.id").
+			Where("post_hashtags.post_id = ?", running.ID).
+			Order("hashtags.name").
+			Pluck("hashtags.name", &names).Error
+		if err != nil {
+			t.Fatalf("Failed to load hashtags: %v", err)
+		}
+		if len(names) != 2 || names[0] != "fitness" || names[1] != "morning" {
+			t.Errorf("Expected [fitness morning], got %v", names)
+		}
+
+		posts, err := postRepo.GetHashtagPosts(viewer.ID, "fitness", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get hashtag posts: %v", err)
+		}
+		if len(posts) != 2 {
+			t.Errorf("Expected 2 posts tagged fitness, got %d", len(posts))
+		}
+	})
+
+	t.Run("language-aware full-text search", func(t *testing.T) {
+		// English stemming matches "runs" to "Running"
+		posts, err := postRepo.SearchPosts(viewer.ID, PostSearchParams{Query: "runs"}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(posts) != 1 || posts[0].ID != running.ID {
+			t.Errorf("Expected the running post, got %d posts", len(posts))
+		}
+
+		posts, err = postRepo.SearchPosts(viewer.ID, PostSearchParams{Query: "cycling", Language: "english"}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected posts in other languages to be excluded, got %d", len(posts))
+		}
+	})
+
+	t.Run("search filters", func(t *testing.T) {
+		posts, err := postRepo.SearchPosts(viewer.ID, PostSearchParams{Query: "fitness", AuthorID: &author.ID, Hashtag: "morning"}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(posts) != 1 || posts[0].ID != running.ID {
+			t.Errorf("Expected only the running post, got %d posts", len(posts))
+		}
+
+		future := time.Now().Add(time.Hour)
+		posts, err = postRepo.SearchPosts(viewer.ID, PostSearchParams{Query: "fitness", From: &future}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected no posts after the from date, got %d", len(posts))
+		}
+	})
+
+	t.Run("deleted posts leave search and hashtag feeds", func(t *testing.T) {
+		if err := postRepo.DeletePost(running.ID, author.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+
+		posts, err := postRepo.GetHashtagPosts(viewer.ID, "fitness", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to get hashtag posts: %v", err)
+		}
+		if len(posts) != 1 || posts[0].ID != cycling.ID {
+			t.Errorf("Expected only the cycling post, got %d posts", len(posts))
+		}
+
+		var links int64
+		db.DB.Model(&models.PostHashtag{}).Where("post_id = ?", running.ID).Count(&links)
+		if links != 0 {
+			t.Errorf("Expected hashtag links to be removed, got %d", links)
+		}
+
+		posts, err = postRepo.SearchPosts(viewer.ID, PostSearchParams{Query: "running"}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected deleted post to be excluded from search, got %d", len(posts))
+		}
+	})
+}
+
+func TestPostRepository_UpdatePost(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	author := createTestUser(t, userRepo)
+
+	post := &models.Post{UserID: author.ID, Caption: "Morning swim #swimming", ImageURL: "swim.jpg"}
+	if err := postRepo.CreatePost(post); err != nil {
+		t.Fatalf("Failed to create test post: %v", err)
+	}
+
+	t.Run("unchanged edit is not recorded", func(t *testing.T) {
+		updated, err := postRepo.UpdatePost(post.ID, post.Caption, post.Language)
+		if err != nil {
+			t.Fatalf("Failed to update post: %v", err)
+		}
+		if updated.EditedAt != nil {
+			t.Error("Expected an unchanged post not to be marked as edited")
+		}
+	})
+
+	t.Run("edits are recorded and reindexed", func(t *testing.T) {
+		first, err := postRepo.UpdatePost(post.ID, "Evening swim #swimming #evening", "english")
+		if err != nil {
+			t.Fatalf("Failed to update post: %v", err)
+		}
+		if first.Caption != "Evening swim #swimming #evening" || first.Language != "english" || first.EditedAt == nil {
+			t.Errorf("Expected the edit to be applied, got %+v", first)
+		}
+		if first.User.ID != author.ID {
+			t.Error("Expected the updated post to include its author")
+		}
+
+		if _, err := postRepo.UpdatePost(post.ID, "Night swim #night", "english"); err != nil {
+			t.Fatalf("Failed to update post: %v", err)
+		}
+
+		revisions, err := postRepo.GetPostRevisions(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get revisions: %v", err)
+		}
+		if len(revisions) != 2 {
+			t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+		}
+		if revisions[0].Caption != first.Caption || !revisions[0].CreatedAt.Equal(*first.EditedAt) {
+			t.Errorf("Expected the latest revision to be the first edit, got %+v", revisions[0])
+		}
+		if revisions[1].Caption != "Morning swim #swimming" || revisions[1].Language != models.DefaultPostLanguage {
+			t.Errorf("Expected the oldest revision to be the original post, got %+v", revisions[1])
+		}
+
+		for tag, want := range map[string]int{"swimming": 0, "evening": 0, "night": 1} {
+			posts, err := postRepo.GetHashtagPosts(author.ID, tag, nil, 10)
+			if err != nil {
+				t.Fatalf("Failed to get hashtag posts: %v", err)
+			}
+			if len(posts) != want {
+				t.Errorf("Expected %d posts tagged %s, got %d", want, tag, len(posts))
+			}
+		}
+
+		results, err := postRepo.SearchPosts(author.ID, PostSearchParams{Query: "night"}, nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search posts: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Expected the edited caption to be searchable, got %d results", len(results))
+		}
+	})
+
+	t.Run("deleting a post removes its history", func(t *testing.T) {
+		if err := postRepo.DeletePost(post.ID, author.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+		revisions, err := postRepo.GetPostRevisions(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get revisions: %v", err)
+		}
+		if len(revisions) != 0 {
+			t.Errorf("Expected no revisions after deletion, got %d", len(revisions))
+		}
+	})
+
+	if err := db.CleanupData(); err != nil {
+		t.Errorf("Failed to cleanup test data: %v", err)
+	}
+}
+
+func TestPostRepository_GetHomeTimeline(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	viewer := createTestUser(t, userRepo)
+	followed := createTestUser(t, userRepo)
+	muted := createTestUser(t, userRepo)
+	stranger := createTestUser(t, userRepo)
+
+	for _, id := range []uuid.UUID{followed.ID, muted.ID} {
+		if _, err := postRepo.FollowUser(viewer.ID, id); err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+	}
+	if err := postRepo.MuteUser(viewer.ID, muted.ID, nil); err != nil {
+		t.Fatalf("Failed to mute: %v", err)
+	}
+
+	// Pairs of posts share a timestamp so the ID tiebreaker is exercised
+	base := time.Now().Truncate(time.Second)
+	expected := 0
+	for i := 0; i < 6; i++ {
+		author := followed.ID
+		if i%3 == 0 {
+			author = viewer.ID
+		}
+		post := &models.Post{UserID: author, Caption: "Timeline post", ImageURL: "test.jpg", CreatedAt: base.Add(-time.Duration(i/2) * time.Minute)}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+		expected++
+	}
+	for _, author := range []uuid.UUID{muted.ID, stranger.ID} {
+		post := &models.Post{UserID: author, Caption: "Excluded post", ImageURL: "test.jpg", CreatedAt: base}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	var cursor *Cursor
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Too many pages")
+		}
+		posts, err := postRepo.GetHomeTimeline(viewer.ID, cursor, 4)
+		if err != nil {
+			t.Fatalf("Failed to get timeline: %v", err)
+		}
+		for _, post := range posts {
+			if post.Caption != "Timeline post" {
+				t.Errorf("Unexpected post by %s in timeline", post.User.Username)
+			}
+			if seen[post.ID] {
+				t.Error("Post returned twice")
+			}
+			seen[post.ID] = true
+		}
+		if len(posts) < 4 {
+			break
+		}
+		last := posts[len(posts)-1]
+		cursor = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	if len(seen) != expected {
+		t.Errorf("Expected %d posts across pages, got %d", expected, len(seen))
+	}
+}
+
+func TestPostRepository_TimelineSources(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	viewer := createTestUser(t, userRepo)
+	popular := createTestUser(t, userRepo)
+	friend := createTestUser(t, userRepo)
+	fan := createTestUser(t, userRepo)
+
+	for _, follow := range [][2]uuid.UUID{{viewer.ID, popular.ID}, {fan.ID, popular.ID}, {viewer.ID, friend.ID}} {
+		if _, err := postRepo.FollowUser(follow[0], follow[1]); err != nil {
+			t.Fatalf("Failed to follow: %v", err)
+		}
+	}
+
+	followers, err := postRepo.GetFollowerIDs(popular.ID)
+	if err != nil {
+		t.Fatalf("Failed to get follower IDs: %v", err)
+	}
+	if len(followers) != 2 {
+		t.Errorf("Expected 2 followers, got %d", len(followers))
+	}
+
+	large, err := postRepo.GetLargeFollowedAccounts(viewer.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get large followed accounts: %v", err)
+	}
+	if len(large) != 1 || large[0] != popular.ID {
+		t.Errorf("Expected only the account with 2 followers, got %v", large)
+	}
+
+	var posts []*models.Post
+	for _, author := range []*models.User{popular, friend, fan} {
+		post := &models.Post{UserID: author.ID, Caption: "Source post", ImageURL: "test.jpg"}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create test post: %v", err)
+		}
+		posts = append(posts, post)
+	}
+
+	byAuthors, err := postRepo.GetPostsByAuthors(viewer.ID, []uuid.UUID{popular.ID, fan.ID}, nil, 10)
+	if err != nil {
+		t.Fatalf("Failed to get posts by authors: %v", err)
+	}
+	if len(byAuthors) != 2 || byAuthors[0].ID != posts[2].ID {
+		t.Errorf("Expected both authors' posts, newest first, got %d posts", len(byAuthors))
+	}
+
+	// The fan is not followed, so their post does not hydrate into the viewer's timeline
+	hydrated, err := postRepo.GetTimelinePostsByIDs(viewer.ID, []uuid.UUID{posts[0].ID, posts[1].ID, posts[2].ID})
+	if err != nil {
+		t.Fatalf("Failed to get timeline posts: %v", err)
+	}
+	if len(hydrated) != 2 || hydrated[0].ID != posts[1].ID || hydrated[1].ID != posts[0].ID {
+		t.Errorf("Expected the followed accounts' posts, newest first, got %d posts", len(hydrated))
+	}
+}
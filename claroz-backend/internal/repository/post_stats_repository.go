@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+// GetPostStats retrieves each post's like, reaction and comment counts, the
+// viewer's reactions to it and up to previewSize of its latest comments, oldest
+// first. Counts include every like and every comment that has not been
+// deleted, replies included; the preview only has top-level comments and
+// leaves out deleted ones and those by users who have blocked, or been
+// blocked by, the viewer. The result has an entry for every requested post.
+func (r *PostRepository) GetPostStats(viewerID uuid.UUID, postIDs []uuid.UUID, previewSize int) (map[uuid.UUID]*PostStats, error) {
+	stats := make(map[uuid.UUID]*PostStats, len(postIDs))
+	for _, id := range postIDs {
+		stats[id] = &PostStats{
+			Reactions:       []models.ReactionCount{},
+			ViewerReactions: []string{},
+			RecentComments:  []models.Comment{},
+		}
+	}
+	if len(postIDs) == 0 {
+		return stats, nil
+	}
+
+	var counts []models.Post
+	err := r.db.Unscoped().
+		Select("id", "like_count", "comment_count").
+		Where("id IN ?", postIDs).
+		Find(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range counts {
+		if s := stats[post.ID]; s != nil {
+			s.LikeCount = post.LikeCount
+			s.CommentCount = post.CommentCount
+		}
+	}
+
+	reactions, err := r.reactionSummaries("post_id", viewerID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, summary := range reactions {
+		s := stats[id]
+		s.Reactions, s.ViewerReactions = summary.Reactions, summary.ViewerReactions
+		for _, emoji := range summary.ViewerReactions {
+			if emoji == models.HeartReaction {
+				s.ViewerHasLiked = true
+			}
+		}
+	}
+
+	if previewSize > 0 {
+		ranked := r.db.Model(&models.Comment{}).
+			Select(`comments.*, ROW_NUMBER() OVER (
+				PARTITION BY comments.post_id ORDER BY comments.created_at DESC, comments.id DESC
+			) AS position`).
+			Scopes(notBlockedWith(viewerID, "comments.user_id")).
+			Where("comments.post_id IN ? AND comments.parent_id IS NULL AND comments.deleted_at IS NULL", postIDs)
+
+		var comments []models.Comment
+		err := r.db.Table("(?) AS comments", ranked).
+			Where("position <= ?", previewSize).
+			Preload("User").
+			Order("created_at ASC").
+			Order("id ASC").
+			Find(&comments).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			stats[comment.PostID].RecentComments = append(stats[comment.PostID].RecentComments, comment)
+		}
+	}
+
+	return stats, nil
+}
+
+// GetPostInteractions retrieves all comments, oldest first, and all likes,
+// the heart reactions, newest first, on each post, excluding those by users who have blocked, or
+// been blocked by, the viewer
+func (r *PostRepository) GetPostInteractions(viewerID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID][]models.Comment, map[uuid.UUID][]models.Reaction, error) {
+	comments := make(map[uuid.UUID][]models.Comment, len(postIDs))
+	likes := make(map[uuid.UUID][]models.Reaction, len(postIDs))
+	if len(postIDs) == 0 {
+		return comments, likes, nil
+	}
+
+	var allComments []models.Comment
+	err := r.db.
+		Scopes(notBlockedWith(viewerID, "comments.user_id")).
+		Where("comments.post_id IN ?", postIDs).
+		Preload("User").
+		Order("comments.created_at ASC").
+		Order("comments.id ASC").
+		Find(&allComments).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, comment := range allComments {
+		comments[comment.PostID] = append(comments[comment.PostID], comment)
+	}
+
+	var allLikes []models.Reaction
+	err = r.db.
+		Scopes(notBlockedWith(viewerID, "reactions.user_id")).
+		Where("reactions.post_id IN ? AND reactions.emoji = ?", postIDs, models.HeartReaction).
+		Preload("User").
+		Order("reactions.created_at DESC").
+		Order("reactions.id DESC").
+		Find(&allLikes).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, like := range allLikes {
+		likes[*like.PostID] = append(likes[*like.PostID], like)
+	}
+
+	return comments, likes, nil
+}
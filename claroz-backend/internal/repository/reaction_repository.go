@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddReaction reacts to a post or comment with an emoji. Reacting again with
+// the same emoji has no effect. It returns ErrPostNotFound or
+// ErrCommentNotFound if the target does not exist or has been deleted, and
+// ErrBlocked if the user and the target's author, or the author of the post
+// a comment is on, have blocked each other.
+func (r *PostRepository) AddReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		authorIDs, err := lockReactionTarget(tx, target)
+		if err != nil {
+			return err
+		}
+		for _, authorID := range authorIDs {
+			blocked, err := r.IsBlocked(userID, authorID)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return ErrBlocked
+			}
+		}
+
+		reaction := &models.Reaction{UserID: userID, Emoji: emoji}
+		if target.Kind == models.ReactionOnComment {
+			reaction.CommentID = &target.ID
+		} else {
+			reaction.PostID = &target.ID
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return adjustLikeCount(tx, target, emoji, 1)
+	})
+}
+
+// RemoveReaction removes a user's emoji reaction to a post or comment, if it exists
+func (r *PostRepository) RemoveReaction(target models.ReactionTarget, userID uuid.UUID, emoji string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(reactionColumn(target.Kind)+" = ? AND user_id = ? AND emoji = ?", target.ID, userID, emoji).
+			Delete(&models.Reaction{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return adjustLikeCount(tx, target, emoji, -1)
+	})
+}
+
+// GetReactions retrieves the reactions to a post or comment, newest first,
+// excluding reactions by users who have blocked, or been blocked by, the
+// viewer. A non-empty emoji only lists reactions with that emoji.
+func (r *PostRepository) GetReactions(target models.ReactionTarget, viewerID uuid.UUID, emoji string, cursor *Cursor, limit int) ([]models.Reaction, error) {
+	query := r.db.
+		Scopes(notBlockedWith(viewerID, "reactions.user_id")).
+		Where("reactions."+reactionColumn(target.Kind)+" = ?", target.ID)
+	if emoji != "" {
+		query = query.Where("reactions.emoji = ?", emoji)
+	}
+	if cursor != nil {
+		query = query.Where("(reactions.created_at, reactions.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	reactions := []models.Reaction{}
+	err := query.
+		Preload("User").
+		Order("reactions.created_at DESC").
+		Order("reactions.id DESC").
+		Limit(limit).
+		Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// GetCommentReactions summarizes the reactions to each comment for the
+// viewer. The result has an entry for every requested comment.
+func (r *PostRepository) GetCommentReactions(viewerID uuid.UUID, commentIDs []uuid.UUID) (map[uuid.UUID]*ReactionSummary, error) {
+	return r.reactionSummaries("comment_id", viewerID, commentIDs)
+}
+
+// reactionSummaries counts the reactions to each target referenced by column
+// and lists the viewer's. Counts include every reaction, as like counts do.
+func (r *PostRepository) reactionSummaries(column string, viewerID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*ReactionSummary, error) {
+	summaries := make(map[uuid.UUID]*ReactionSummary, len(ids))
+	for _, id := range ids {
+		summaries[id] = &ReactionSummary{Reactions: []models.ReactionCount{}, ViewerReactions: []string{}}
+	}
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+
+	var counts []struct {
+		TargetID uuid.UUID
+		Emoji    string
+		Count    int64
+	}
+	err := r.db.Model(&models.Reaction{}).
+		Select(column+" AS target_id, emoji, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column + ", emoji").
+		Order("count DESC").
+		Order("emoji ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		s := summaries[c.TargetID]
+		s.Reactions = append(s.Reactions, models.ReactionCount{Emoji: c.Emoji, Count: c.Count})
+	}
+
+	var own []struct {
+		TargetID uuid.UUID
+		Emoji    string
+	}
+	err = r.db.Model(&models.Reaction{}).
+		Select(column+" AS target_id, emoji").
+		Where("user_id = ? AND "+column+" IN ?", viewerID, ids).
+		Order("emoji ASC").
+		Scan(&own).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range own {
+		s := summaries[o.TargetID]
+		s.ViewerReactions = append(s.ViewerReactions, o.Emoji)
+	}
+
+	return summaries, nil
+}
+
+// lockReactionTarget locks a post or live comment against deletion while a
+// reaction to it is added, returning its author and, for a comment, the
+// author of its post. The lock is FOR NO KEY
+// UPDATE rather than FOR SHARE because the like count on the locked post is
+// updated in the same transaction: two shared locks upgraded at once deadlock.
+func lockReactionTarget(tx *gorm.DB, target models.ReactionTarget) ([]uuid.UUID, error) {
+	if target.Kind == models.ReactionOnComment {
+		var authors struct {
+			CommentAuthorID uuid.UUID
+			PostAuthorID    uuid.UUID
+		}
+		err := tx.Model(&models.Comment{}).
+			Clauses(clause.Locking{Strength: "NO KEY UPDATE", Table: clause.Table{Name: "comments"}}).
+			Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+			Select("comments.user_id AS comment_author_id, posts.user_id AS post_author_id").
+			Where("comments.id = ? AND comments.deleted_at IS NULL", target.ID).
+			Take(&authors).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return []uuid.UUID{authors.CommentAuthorID, authors.PostAuthorID}, err
+	}
+
+	var post models.Post
+	err := tx.Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).
+		Select("user_id").
+		First(&post, "id = ?", target.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostNotFound
+	}
+	return []uuid.UUID{post.UserID}, err
+}
+
+// adjustLikeCount keeps posts.like_count in step with the heart reactions to a post
+func adjustLikeCount(tx *gorm.DB, target models.ReactionTarget, emoji string, delta int) error {
+	if target.Kind != models.ReactionOnPost || emoji != models.HeartReaction {
+		return nil
+	}
+	return adjustCounter(tx, "posts", "like_count", target.ID, delta)
+}
+
+// reactionColumn returns the reactions column referencing targets of a kind
+func reactionColumn(kind string) string {
+	if kind == models.ReactionOnComment {
+		return "comment_id"
+	}
+	return "post_id"
+}
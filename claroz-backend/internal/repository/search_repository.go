@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchPosts runs a full-text search over the captions of posts visible to
+// the viewer, best match first. Without a language, each caption is matched
+// using its own text search configuration; with one, only posts in that
+// language are searched, which lets the search use the full-text index.
+// Posts by users who have blocked, or been blocked by, the viewer are excluded.
+// Results are paged by (rank, created_at, id), and each post's SearchRank is
+// set for building the next cursor.
+func (r *PostRepository) SearchPosts(viewerID uuid.UUID, params PostSearchParams, cursor *Cursor, limit int) ([]models.Post, error) {
+	query, rank := r.postSearchQuery(viewerID, params)
+	if cursor != nil {
+		query = query.Where("(? < ? OR (? = ? AND (posts.created_at, posts.id) < (?, ?)))",
+			rank, cursor.Rank, rank, cursor.Rank, cursor.CreatedAt, cursor.ID)
+	}
+
+	posts := []models.Post{}
+	err := query.
+		Select("posts.*, ? AS search_rank", rank).
+		Scopes(withAuthorAndMedia).
+		Order("search_rank DESC").
+		Order("posts.created_at DESC").
+		Order("posts.id DESC").
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SearchPostsByOffset is SearchPosts with offset pagination.
+//
+// Deprecated: offset pages skip or repeat posts as new posts arrive. It backs
+// the page query parameter for one deprecation cycle; use SearchPosts.
+func (r *PostRepository) SearchPostsByOffset(viewerID uuid.UUID, params PostSearchParams, offset, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	query, rank := r.postSearchQuery(viewerID, params)
+	err := query.
+		Scopes(withAuthorAndMedia).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "? DESC", Vars: []interface{}{rank}, WithoutParentheses: true}}).
+		Order("posts.created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// postSearchQuery builds the filtered posts query for a search and the
+// expression ranking each match
+func (r *PostRepository) postSearchQuery(viewerID uuid.UUID, params PostSearchParams) (*gorm.DB, clause.Expr) {
+	tsQuery := clause.Expr{SQL: "websearch_to_tsquery(posts.language, ?)", Vars: []interface{}{params.Query}}
+	query := r.db.Scopes(ready, visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"))
+	if params.Language != "" {
+		tsQuery = clause.Expr{SQL: "websearch_to_tsquery(?::regconfig, ?)", Vars: []interface{}{params.Language, params.Query}}
+		query = query.Where("posts.language = ?::regconfig", params.Language)
+	}
+	query = query.Where("posts.search_vector @@ ?", tsQuery)
+
+	if params.AuthorID != nil {
+		query = query.Where("posts.user_id = ?", *params.AuthorID)
+	}
+	if params.From != nil {
+		query = query.Where("posts.created_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("posts.created_at < ?", *params.To)
+	}
+	if params.Hashtag != "" {
+		query = query.Scopes(taggedWith(params.Hashtag))
+	}
+
+	// ts_rank_cd returns a real; casting keeps cursor comparisons exact
+	rank := clause.Expr{SQL: "ts_rank_cd(posts.search_vector, ?)::float8", Vars: []interface{}{tsQuery}}
+	return query, rank
+}
+
+// GetHashtagPosts retrieves posts tagged with a normalized hashtag that are
+// visible to the viewer, newest first. Posts by blocked and muted users are excluded.
+func (r *PostRepository) GetHashtagPosts(viewerID uuid.UUID, tag string, cursor *Cursor, limit int) ([]models.Post, error) {
+	query := r.db.Scopes(taggedWith(tag), visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id"))
+	return r.findPosts(query, cursor, limit)
+}
+
+// GetHashtagPostsByOffset is GetHashtagPosts with offset pagination.
+//
+// Deprecated: offset pages skip or repeat posts as new posts arrive. It backs
+// the page query parameter for one deprecation cycle; use GetHashtagPosts.
+func (r *PostRepository) GetHashtagPostsByOffset(viewerID uuid.UUID, tag string, offset, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.
+		Scopes(ready, taggedWith(tag), visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id"), withAuthorAndMedia).
+		Order("posts.created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// syncHashtags replaces a post's hashtag links with the hashtags in its caption
+func syncHashtags(tx *gorm.DB, postID uuid.UUID, caption string) error {
+	if err := tx.Delete(&models.PostHashtag{}, "post_id = ?", postID).Error; err != nil {
+		return err
+	}
+
+	names := utils.ExtractHashtags(caption)
+	if len(names) == 0 {
+		return nil
+	}
+
+	hashtags := make([]models.Hashtag, len(names))
+	for i, name := range names {
+		hashtags[i] = models.Hashtag{Name: name}
+	}
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(&hashtags).Error
+	if err != nil {
+		return err
+	}
+
+	// Hashtags that already existed keep their original IDs
+	var ids []uuid.UUID
+	if err := tx.Model(&models.Hashtag{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	links := make([]models.PostHashtag, len(ids))
+	for i, id := range ids {
+		links[i] = models.PostHashtag{PostID: postID, HashtagID: id}
+	}
+	return tx.Create(&links).Error
+}
+
+// taggedWith limits a posts query to posts linked to a normalized hashtag
+func taggedWith(tag string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`EXISTS (SELECT 1 FROM post_hashtags ph
+			JOIN hashtags h ON h.id = ph.hashtag_id
+			WHERE ph.post_id = posts.id AND h.name = ?)`, tag)
+	}
+}
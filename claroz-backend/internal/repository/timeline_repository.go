@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// TimelineListener is notified of changes that affect materialized home
+// timelines. Calls are made after the change has been committed and must not
+// block on slow work.
+type TimelineListener interface {
+	PostCreated(post *models.Post)
+	PostDeleted(postID, authorID uuid.UUID)
+	Followed(followerID, followingID uuid.UUID)
+	Unfollowed(followerID, followingID uuid.UUID)
+	Blocked(blockerID, blockedID uuid.UUID)
+}
+
+// GetHomeTimeline retrieves posts by the viewer and the users they follow,
+// newest first, excluding blocked and muted users. Paging uses the
+// (created_at, id) keyset, which the idx_posts_user_created index serves per
+// author without scanning posts the viewer will never see.
+func (r *PostRepository) GetHomeTimeline(viewerID uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error) {
+	return r.findPosts(r.db.Scopes(homeTimelineOf(viewerID)), cursor, limit)
+}
+
+// GetTimelinePostsByIDs retrieves the posts with the given IDs that still
+// belong in the viewer's home timeline, newest first. It hydrates
+// materialized timeline entries, dropping deleted posts and posts by users
+// who have since been unfollowed, blocked or muted.
+func (r *PostRepository) GetTimelinePostsByIDs(viewerID uuid.UUID, ids []uuid.UUID) ([]models.Post, error) {
+	if len(ids) == 0 {
+		return []models.Post{}, nil
+	}
+	return r.findPosts(r.db.Scopes(homeTimelineOf(viewerID)).Where("posts.id IN ?", ids), nil, len(ids))
+}
+
+// GetPostsByAuthors retrieves posts by the given authors that are visible to
+// the viewer, newest first, with the same keyset paging as GetHomeTimeline
+func (r *PostRepository) GetPostsByAuthors(viewerID uuid.UUID, authorIDs []uuid.UUID, cursor *Cursor, limit int) ([]models.Post, error) {
+	if len(authorIDs) == 0 {
+		return []models.Post{}, nil
+	}
+	query := r.db.
+		Scopes(visibleTo(viewerID), notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id")).
+		Where("posts.user_id IN ?", authorIDs)
+	return r.findPosts(query, cursor, limit)
+}
+
+// GetFollowerIDs retrieves the IDs of a user's followers
+func (r *PostRepository) GetFollowerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	err := r.db.Model(&models.UserFollow{}).
+		Where("following_id = ?", userID).
+		Pluck("follower_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetLargeFollowedAccounts retrieves the IDs of the accounts a user follows
+// that have more than minFollowers followers
+func (r *PostRepository) GetLargeFollowedAccounts(userID uuid.UUID, minFollowers int64) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	err := r.db.Raw(`SELECT f.following_id FROM user_follows f
+		JOIN users u ON u.id = f.following_id
+		WHERE f.follower_id = ? AND u.followers_count > ?`,
+		userID, minFollowers).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// homeTimelineOf limits a posts query to posts by the viewer and the users
+// they follow, excluding blocked and muted users
+func homeTimelineOf(viewerID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Scopes(notBlockedWith(viewerID, "posts.user_id"), notMutedBy(viewerID, "posts.user_id")).
+			Where("(posts.user_id = ? OR posts.user_id IN (SELECT following_id FROM user_follows WHERE follower_id = ?))",
+				viewerID, viewerID)
+	}
+}
+
+// notifyTimelines passes a committed change to each timeline listener
+func (r *PostRepository) notifyTimelines(notify func(TimelineListener)) {
+	for _, listener := range r.timelineListeners {
+		notify(listener)
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUploadNotFound is returned for resumable uploads that do not exist,
+// belong to another user or have expired
+var ErrUploadNotFound = errors.New("upload not found")
+
+// ErrUploadQuotaExceeded is returned when a user's unfinished resumable
+// uploads would declare or hold more bytes than their quota
+var ErrUploadQuotaExceeded = errors.New("upload quota exceeded")
+
+// UploadRepository tracks resumable uploads and their stored parts
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// CreateUpload records a new resumable upload. It returns
+// ErrUploadQuotaExceeded if the user's unfinished uploads, including this
+// one, would count more than quota bytes.
+func (r *UploadRepository) CreateUpload(upload *models.ResumableUpload, quota int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		usage, err := lockUploadUsage(tx, upload.UserID)
+		if err != nil {
+			return err
+		}
+		if usage+uploadUsage(upload.Length, upload.Offset) > quota {
+			return ErrUploadQuotaExceeded
+		}
+		return tx.Create(upload).Error
+	})
+}
+
+// GetUpload returns a user's unexpired resumable upload
+func (r *UploadRepository) GetUpload(id, userID uuid.UUID) (*models.ResumableUpload, error) {
+	var upload models.ResumableUpload
+	err := r.db.Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, time.Now()).Take(&upload).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// UploadUsage returns the bytes a user's unfinished uploads count against
+// their quota: the declared size of each, or the bytes received while its
+// size is deferred
+func (r *UploadRepository) UploadUsage(userID uuid.UUID) (int64, error) {
+	return sumUploadUsage(r.db, userID)
+}
+
+// AppendPart records a part stored at offset, declaring the upload's length
+// if it was deferred and pushing back its expiry. It reports false if the
+// upload is no longer at offset, such as when a concurrent request appended
+// a part first, or has completed or expired. It returns
+// ErrUploadQuotaExceeded if the part would take the user's unfinished
+// uploads over quota bytes.
+func (r *UploadRepository) AppendPart(upload *models.ResumableUpload, part models.UploadPart, length *int64, expiresAt time.Time, quota int64) (bool, error) {
+	appended := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		usage, err := lockUploadUsage(tx, upload.UserID)
+		if err != nil {
+			return err
+		}
+		if length == nil {
+			length = upload.Length
+		}
+		// The upload counted what it had received, or its declared size
+		usage += uploadUsage(length, upload.Offset+part.Size) - uploadUsage(upload.Length, upload.Offset)
+		if usage > quota {
+			return ErrUploadQuotaExceeded
+		}
+
+		parts, err := json.Marshal(models.UploadParts{part})
+		if err != nil {
+			return err
+		}
+		if part.Size == 0 {
+			parts = []byte("[]")
+		}
+		result := tx.Exec(`UPDATE resumable_uploads SET
+				upload_offset = upload_offset + ?,
+				parts = parts || ?::jsonb,
+				upload_length = COALESCE(upload_length, ?),
+				expires_at = ?,
+				updated_at = ?
+			WHERE id = ? AND upload_offset = ? AND media_id IS NULL AND expires_at > ?`,
+			part.Size, string(parts), length, expiresAt, time.Now(), upload.ID, upload.Offset, time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		appended = result.RowsAffected == 1
+		return nil
+	})
+	return appended, err
+}
+
+// CompleteUpload records the media a complete upload became and forgets its
+// parts. It reports false if another request completed the upload first.
+func (r *UploadRepository) CompleteUpload(id, mediaID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.ResumableUpload{}).
+		Where("id = ? AND media_id IS NULL", id).
+		Updates(map[string]interface{}{"media_id": mediaID, "parts": models.UploadParts{}})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteUpload deletes a resumable upload, calling remove to delete its
+// stored parts first. The upload stays locked while remove runs; if remove
+// fails, it is kept.
+func (r *UploadRepository) DeleteUpload(id uuid.UUID, remove func(upload *models.ResumableUpload) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var upload models.ResumableUpload
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&upload, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUploadNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := remove(&upload); err != nil {
+			return err
+		}
+		return tx.Delete(&upload).Error
+	})
+}
+
+// ExpiredUploads returns up to limit resumable uploads that expired before
+// now, longest expired first
+func (r *UploadRepository) ExpiredUploads(now time.Time, limit int) ([]models.ResumableUpload, error) {
+	var uploads []models.ResumableUpload
+	err := r.db.Where("expires_at <= ?", now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// lockUploadUsage locks a user's resumable uploads against concurrent quota
+// checks and returns the bytes their unfinished uploads count
+func lockUploadUsage(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	if err := tx.Exec("SELECT 1 FROM users WHERE id = ? FOR UPDATE", userID).Error; err != nil {
+		return 0, err
+	}
+	return sumUploadUsage(tx, userID)
+}
+
+// sumUploadUsage returns the bytes a user's unfinished uploads count
+func sumUploadUsage(db *gorm.DB, userID uuid.UUID) (int64, error) {
+	var usage int64
+	err := db.Model(&models.ResumableUpload{}).
+		Select("COALESCE(SUM(COALESCE(upload_length, upload_offset)), 0)").
+		Where("user_id = ? AND media_id IS NULL AND expires_at > ?", userID, time.Now()).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// uploadUsage returns the bytes an unfinished upload counts against its
+// user's quota
+func uploadUsage(length *int64, offset int64) int64 {
+	if length != nil {
+		return *length
+	}
+	return offset
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/testutils"
+)
+
+func TestUploadRepository(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	uploadRepo := NewUploadRepository(db.DB)
+	user := createTestUser(t, userRepo)
+	later := time.Now().Add(time.Hour)
+	length := func(n int64) *int64 { return &n }
+
+	t.Run("parts are appended at the upload's offset", func(t *testing.T) {
+		upload := &models.ResumableUpload{UserID: user.ID, Length: length(10), Metadata: models.UploadMetadata{"filename": "photo.jpg"}, ExpiresAt: later}
+		if err := uploadRepo.CreateUpload(upload, 100); err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+
+		appended, err := uploadRepo.AppendPart(upload, models.UploadPart{Key: "a.part", Size: 4}, nil, later, 100)
+		if err != nil || !appended {
+			t.Fatalf("Expected the part to be appended, got %v, %v", appended, err)
+		}
+		// A request that read the upload before the append is stale
+		appended, err = uploadRepo.AppendPart(upload, models.UploadPart{Key: "b.part", Size: 4}, nil, later, 100)
+		if err != nil || appended {
+			t.Errorf("Expected a part at a stale offset not to be appended, got %v, %v", appended, err)
+		}
+
+		found, err := uploadRepo.GetUpload(upload.ID, user.ID)
+		if err != nil {
+			t.Fatalf("GetUpload() error = %v", err)
+		}
+		if found.Offset != 4 || len(found.Parts) != 1 || found.Parts[0].Key != "a.part" || found.Metadata["filename"] != "photo.jpg" {
+			t.Errorf("Expected one part at offset 4, got %+v", found)
+		}
+		if _, err := uploadRepo.GetUpload(upload.ID, uuid.New()); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("Expected other users not to find the upload, got %v", err)
+		}
+
+		mediaID := uuid.New()
+		if completed, err := uploadRepo.CompleteUpload(upload.ID, mediaID); err != nil || !completed {
+			t.Fatalf("Expected the upload to complete, got %v, %v", completed, err)
+		}
+		if completed, _ := uploadRepo.CompleteUpload(upload.ID, uuid.New()); completed {
+			t.Error("Expected an upload to complete only once")
+		}
+		found, _ = uploadRepo.GetUpload(upload.ID, user.ID)
+		if found.MediaID == nil || *found.MediaID != mediaID || len(found.Parts) != 0 {
+			t.Errorf("Expected the media to be recorded and the parts forgotten, got %+v", found)
+		}
+	})
+
+	t.Run("deferred lengths are declared once", func(t *testing.T) {
+		upload := &models.ResumableUpload{UserID: user.ID, ExpiresAt: later}
+		if err := uploadRepo.CreateUpload(upload, 100); err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if appended, err := uploadRepo.AppendPart(upload, models.UploadPart{}, length(8), later, 100); err != nil || !appended {
+			t.Fatalf("Expected an empty part to declare the length, got %v, %v", appended, err)
+		}
+		found, _ := uploadRepo.GetUpload(upload.ID, user.ID)
+		if found.Length == nil || *found.Length != 8 || found.Offset != 0 || len(found.Parts) != 0 {
+			t.Errorf("Expected an empty upload of 8 bytes, got %+v", found)
+		}
+	})
+
+	t.Run("quotas count declared and received bytes", func(t *testing.T) {
+		other := createTestUser(t, userRepo)
+		declared := &models.ResumableUpload{UserID: other.ID, Length: length(6), ExpiresAt: later}
+		if err := uploadRepo.CreateUpload(declared, 10); err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if err := uploadRepo.CreateUpload(&models.ResumableUpload{UserID: other.ID, Length: length(5), ExpiresAt: later}, 10); !errors.Is(err, ErrUploadQuotaExceeded) {
+			t.Errorf("Expected an upload over the quota to be rejected, got %v", err)
+		}
+
+		deferred := &models.ResumableUpload{UserID: other.ID, ExpiresAt: later}
+		if err := uploadRepo.CreateUpload(deferred, 10); err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if _, err := uploadRepo.AppendPart(deferred, models.UploadPart{Key: "c.part", Size: 5}, nil, later, 10); !errors.Is(err, ErrUploadQuotaExceeded) {
+			t.Errorf("Expected a part over the quota to be rejected, got %v", err)
+		}
+		if _, err := uploadRepo.AppendPart(deferred, models.UploadPart{Key: "c.part", Size: 4}, nil, later, 10); err != nil {
+			t.Errorf("Expected a part within the quota to be appended, got %v", err)
+		}
+		if usage, err := uploadRepo.UploadUsage(other.ID); err != nil || usage != 10 {
+			t.Errorf("Expected a usage of 10 bytes, got %d, %v", usage, err)
+		}
+	})
+
+	t.Run("expired uploads are deleted with their parts", func(t *testing.T) {
+		expired := &models.ResumableUpload{UserID: user.ID, Length: length(10), ExpiresAt: time.Now().Add(-time.Minute)}
+		if err := uploadRepo.CreateUpload(expired, 100); err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if _, err := uploadRepo.GetUpload(expired.ID, user.ID); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("Expected an expired upload not to be found, got %v", err)
+		}
+
+		uploads, err := uploadRepo.ExpiredUploads(time.Now(), 10)
+		if err != nil || len(uploads) != 1 || uploads[0].ID != expired.ID {
+			t.Fatalf("Expected the expired upload, got %v, %v", uploads, err)
+		}
+
+		if err := uploadRepo.DeleteUpload(expired.ID, func(*models.ResumableUpload) error { return errors.New("storage unavailable") }); err == nil {
+			t.Error("Expected a failed removal to keep the upload")
+		}
+		removed := false
+		if err := uploadRepo.DeleteUpload(expired.ID, func(*models.ResumableUpload) error { removed = true; return nil }); err != nil || !removed {
+			t.Fatalf("Expected the upload to be deleted, got %v", err)
+		}
+		if err := uploadRepo.DeleteUpload(expired.ID, func(*models.ResumableUpload) error { return nil }); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("Expected a deleted upload not to be found, got %v", err)
+		}
+	})
+}
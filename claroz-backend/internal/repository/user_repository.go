@@ -1,11 +1,25 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lukelittle/claroz/claroz-backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrUsernameTaken is returned when creating a user whose username another
+// user has, in any case
+var ErrUsernameTaken = errors.New("username already taken")
+
+// ErrEmailTaken is returned when creating a user whose email another user has
+var ErrEmailTaken = errors.New("email already registered")
+
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -14,8 +28,20 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// Create creates a user, returning ErrUsernameTaken or ErrEmailTaken if a
+// unique index rejects it, such as when a concurrent registration wins
 func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+	err := r.db.Create(user).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch {
+		case strings.Contains(pgErr.ConstraintName, "username"):
+			return ErrUsernameTaken
+		case strings.Contains(pgErr.ConstraintName, "email"):
+			return ErrEmailTaken
+		}
+	}
+	return err
 }
 
 func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
@@ -36,8 +62,128 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetByUsername looks a user up by username, ignoring case
+func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+	var user models.User
+	err := r.db.First(&user, "LOWER(username) = LOWER(?)", username).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update saves a user. Replacing an avatar that was uploaded releases its
+// file to the garbage collector.
 func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stored models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "avatar", "avatar_variants").Take(&stored, "id = ?", user.ID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if len(stored.AvatarVariants) > 0 && stored.Avatar != user.Avatar {
+			if err := releaseAvatar(tx, &stored); err != nil {
+				return err
+			}
+			user.AvatarVariants, user.AvatarMediaID = models.ImageVariants{}, nil
+		}
+		return tx.Save(user).Error
+	})
+}
+
+// ReleasedBlobs are blobs a change stopped referring to, released at At
+type ReleasedBlobs struct {
+	Keys []string
+	At   time.Time // when the blobs were recorded as unreferenced, if they were left so
+}
+
+// SetProfileImage makes uploaded variants a user's avatar or banner, as
+// named by kind, referring to their blobs. It returns the user and the blobs
+// of the image replaced, which were released.
+func (r *UserRepository) SetProfileImage(userID uuid.UUID, kind string, variants models.ImageVariants) (*models.User, *ReleasedBlobs, error) {
+	var user *models.User
+	var released *ReleasedBlobs
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, released, err = setProfileImage(tx, userID, kind, variants)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, released, nil
+}
+
+// SetAvatar makes variants cropped from media the user uploaded beforehand
+// their avatar like SetProfileImage, attaching the media so it is not used
+// again. It returns ErrMediaNotFound if the media is missing, expired or
+// another user's, and ErrMediaAttached if it is already attached.
+func (r *UserRepository) SetAvatar(userID, mediaID uuid.UUID, variants models.ImageVariants) (*models.User, *ReleasedBlobs, error) {
+	var user *models.User
+	var released *ReleasedBlobs
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, released, err = setProfileImage(tx, userID, models.ProfileAvatar, variants); err != nil {
+			return err
+		}
+		_, err = attachMedia(tx, userID, []uuid.UUID{mediaID})
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, released, nil
+}
+
+// setProfileImage makes variants a user's profile image of the given kind
+// within a transaction
+func setProfileImage(tx *gorm.DB, userID uuid.UUID, kind string, variants models.ImageVariants) (*models.User, *ReleasedBlobs, error) {
+	var user models.User
+	released := &ReleasedBlobs{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&user, "id = ?", userID).Error; err != nil {
+		return nil, nil, err
+	}
+	// Refer to the new files before releasing the old, which may share them
+	if err := referenceBlobs(tx, variants.BlobKeys()); err != nil {
+		return nil, nil, err
+	}
+
+	url := variants[models.VariantFull].URL
+	var update string
+	switch kind {
+	case models.ProfileAvatar:
+		released.Keys = user.AvatarVariants.BlobKeys()
+		user.Avatar, user.AvatarVariants, user.AvatarMediaID = url, variants, nil
+		update = "UPDATE users SET avatar = ?, avatar_variants = ?, avatar_media_id = NULL WHERE id = ?"
+	case models.ProfileBanner:
+		released.Keys = user.BannerVariants.BlobKeys()
+		user.Banner, user.BannerVariants = url, variants
+		update = "UPDATE users SET banner = ?, banner_variants = ? WHERE id = ?"
+	default:
+		return nil, nil, fmt.Errorf("unknown profile image %q", kind)
+	}
+	if err := releaseBlobs(tx, released.Keys); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Raw("SELECT NOW()").Scan(&released.At).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Exec(update, url, variants, userID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &user, released, nil
+}
+
+// releaseAvatar releases the files of a user's uploaded avatar and forgets
+// them, and the media they came from
+func releaseAvatar(tx *gorm.DB, user *models.User) error {
+	if len(user.AvatarVariants) == 0 {
+		return nil
+	}
+	if err := releaseBlobs(tx, user.AvatarVariants.BlobKeys()); err != nil {
+		return err
+	}
+	return tx.Exec("UPDATE users SET avatar_variants = '{}', avatar_media_id = NULL WHERE id = ?", user.ID).Error
 }
 
 func (r *UserRepository) Delete(id uuid.UUID) error {
@@ -70,3 +216,74 @@ func (r *UserRepository) GetRemoteUsers() ([]*models.User, error) {
 	}
 	return users, nil
 }
+
+// GetUsersByStatus returns local users with the given account status, oldest first
+func (r *UserRepository) GetUsersByStatus(status string) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("status = ?", status).Order("created_at ASC").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Search expressions, matching the indexes created by migration 000006
+const (
+	searchUsername = "LOWER(users.username)"
+	searchHandle   = "LTRIM(LOWER(users.handle), '@')"
+	searchFullName = "LOWER(users.full_name)"
+	searchDocument = "to_tsvector('simple', COALESCE(users.full_name, '') || ' ' || COALESCE(users.bio, ''))"
+)
+
+// SearchUsers finds active users whose username, handle or full name
+// fuzzily or prefix-matches the query, or whose name or bio contains its
+// words. Exact username or handle matches rank first, followed by accounts
+// the viewer follows. Users who have blocked, or been blocked by, the viewer
+// are excluded. An empty federationType matches users of every type.
+func (r *UserRepository) SearchUsers(viewerID uuid.UUID, query, federationType string, cursor *Cursor, limit int) ([]models.UserSummary, error) {
+	term := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	prefix := escapeLike(term) + "%"
+
+	ranked := r.db.Model(&models.User{}).
+		Select(`users.id, users.username, users.full_name, users.avatar, users.handle, users.federation_type,
+			EXISTS (SELECT 1 FROM user_follows v WHERE v.follower_id = ? AND v.following_id = users.id) AS viewer_follows,
+			EXISTS (SELECT 1 FROM user_follows v WHERE v.follower_id = users.id AND v.following_id = ?) AS follows_viewer,
+			(GREATEST(similarity(`+searchUsername+`, ?), similarity(`+searchHandle+`, ?), similarity(`+searchFullName+`, ?))
+				+ ts_rank(`+searchDocument+`, plainto_tsquery('simple', ?))
+				+ CASE WHEN `+searchUsername+` = ? OR `+searchHandle+` = ? THEN 10 ELSE 0 END
+				+ CASE WHEN `+searchUsername+` LIKE ? OR `+searchHandle+` LIKE ? THEN 1 ELSE 0 END
+				+ CASE WHEN EXISTS (SELECT 1 FROM user_follows v WHERE v.follower_id = ? AND v.following_id = users.id) THEN 2 ELSE 0 END
+			)::float8 AS search_rank`,
+			viewerID, viewerID, term, term, term, term, term, term, prefix, prefix, viewerID).
+		Where("("+searchUsername+" % ? OR "+searchHandle+" % ? OR "+searchFullName+" % ? OR "+
+			searchUsername+" LIKE ? OR "+searchHandle+" LIKE ? OR "+
+			searchDocument+" @@ plainto_tsquery('simple', ?))",
+			term, term, term, prefix, prefix, term).
+		Where("users.status = ?", models.StatusActive).
+		Scopes(notBlockedWith(viewerID, "users.id"))
+
+	if federationType != "" {
+		ranked = ranked.Where("users.federation_type = ?", federationType)
+	}
+
+	results := r.db.Table("(?) AS ranked", ranked)
+	if cursor != nil {
+		results = results.Where("(search_rank < ? OR (search_rank = ? AND id > ?))", cursor.Rank, cursor.Rank, cursor.ID)
+	}
+
+	users := []models.UserSummary{}
+	err := results.
+		Order("search_rank DESC").
+		Order("id ASC").
+		Limit(limit).
+		Scan(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied string
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
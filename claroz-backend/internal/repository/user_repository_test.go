@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -70,8 +71,20 @@ func TestUserRepository_Create(t *testing.T) {
 			DID:      "did:plc:user2",
 		}
 		err = repo.Create(user2)
-		if err == nil {
-			t.Error("Expected error when creating user with duplicate email")
+		if !errors.Is(err, ErrEmailTaken) {
+			t.Errorf("Expected ErrEmailTaken, got %v", err)
+		}
+	})
+
+	t.Run("usernames are unique regardless of case", func(t *testing.T) {
+		user1 := &models.User{Username: "Alice", Email: "alice1@example.com", Password: "password123", Handle: "@alice1", DID: "did:plc:alice1"}
+		if err := repo.Create(user1); err != nil {
+			t.Fatalf("Failed to create first user: %v", err)
+		}
+
+		user2 := &models.User{Username: "alice", Email: "alice2@example.com", Password: "password123", Handle: "@alice2", DID: "did:plc:alice2"}
+		if err := repo.Create(user2); !errors.Is(err, ErrUsernameTaken) {
+			t.Errorf("Expected ErrUsernameTaken, got %v", err)
 		}
 	})
 
@@ -386,3 +399,123 @@ func TestUserRepository_Federation(t *testing.T) {
 		t.Errorf("Failed to cleanup test data: %v", err)
 	}
 }
+
+func TestUserRepository_SearchUsers(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	repo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+
+	newUser := func(username, fullName, bio, federationType string) *models.User {
+		user := &models.User{
+			Username:       username,
+			Email:          username + "@example.com",
+			Password:       "password123",
+			FullName:       fullName,
+			Bio:            bio,
+			Handle:         "@" + username,
+			DID:            "did:plc:" + username,
+			FederationType: federationType,
+		}
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+		return user
+	}
+
+	viewer := newUser("viewer", "", "", "local")
+	exact := newUser("marie", "Marie Curie", "", "local")
+	followed := newUser("mariel", "Mariel Hemingway", "", "local")
+	prefix := newUser("marianne", "Marianne North", "", "local")
+	bio := newUser("physicist", "Lise Meitner", "worked with marie on radium", "local")
+	remote := newUser("marie_remote", "Marie Remote", "", "remote")
+	newUser("unrelated", "Someone Else", "", "local")
+
+	if _, err := postRepo.FollowUser(viewer.ID, followed.ID); err != nil {
+		t.Fatalf("Failed to follow: %v", err)
+	}
+
+	t.Run("ranks exact matches then followed accounts", func(t *testing.T) {
+		results, err := repo.SearchUsers(viewer.ID, "Marie", "local", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search users: %v", err)
+		}
+		if len(results) < 3 {
+			t.Fatalf("Expected at least 3 results, got %d", len(results))
+		}
+		if results[0].ID != exact.ID {
+			t.Errorf("Expected exact match first, got %s", results[0].Username)
+		}
+		if results[1].ID != followed.ID || !results[1].ViewerFollows {
+			t.Errorf("Expected followed account second, got %s", results[1].Username)
+		}
+
+		found := make(map[uuid.UUID]bool)
+		for _, result := range results {
+			found[result.ID] = true
+		}
+		if !found[prefix.ID] || !found[bio.ID] {
+			t.Error("Expected prefix and bio matches to be included")
+		}
+		if found[remote.ID] {
+			t.Error("Expected remote user to be excluded from local search")
+		}
+	})
+
+	t.Run("handle prefix", func(t *testing.T) {
+		results, err := repo.SearchUsers(viewer.ID, "@mari", "local", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search users: %v", err)
+		}
+		if len(results) != 3 {
+			t.Errorf("Expected 3 prefix matches, got %d", len(results))
+		}
+	})
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		all, err := repo.SearchUsers(viewer.ID, "marie", "", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search users: %v", err)
+		}
+
+		page1, err := repo.SearchUsers(viewer.ID, "marie", "", nil, 2)
+		if err != nil {
+			t.Fatalf("Failed to search users: %v", err)
+		}
+		last := page1[len(page1)-1]
+		page2, err := repo.SearchUsers(viewer.ID, "marie", "", &Cursor{Rank: last.SearchRank, ID: last.ID}, 10)
+		if err != nil {
+			t.Fatalf("Failed to search second page: %v", err)
+		}
+
+		combined := append(page1, page2...)
+		if len(combined) != len(all) {
+			t.Fatalf("Expected %d results across pages, got %d", len(all), len(combined))
+		}
+		for i := range all {
+			if combined[i].ID != all[i].ID {
+				t.Errorf("Result %d differs between paged and unpaged search", i)
+			}
+		}
+	})
+
+	t.Run("excludes blocked users", func(t *testing.T) {
+		if err := postRepo.BlockUser(exact.ID, viewer.ID); err != nil {
+			t.Fatalf("Failed to block: %v", err)
+		}
+		results, err := repo.SearchUsers(viewer.ID, "marie", "local", nil, 10)
+		if err != nil {
+			t.Fatalf("Failed to search users: %v", err)
+		}
+		for _, result := range results {
+			if result.ID == exact.ID {
+				t.Error("Expected blocking user to be excluded")
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetProcessingPostIDs retrieves the IDs of posts whose videos are waiting
+// to be transcoded, oldest first
+func (r *PostRepository) GetProcessingPostIDs() ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	err := r.db.Model(&models.Post{}).
+		Where("status = ?", models.PostProcessing).
+		Order("created_at ASC").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetProcessingVideo retrieves the video of a post that is processing,
+// returning ErrPostNotFound if the post is deleted or no longer processing
+func (r *PostRepository) GetProcessingVideo(postID uuid.UUID) (*models.PostMedia, error) {
+	var media models.PostMedia
+	err := r.db.
+		Joins("JOIN posts ON posts.id = post_media.post_id AND posts.deleted_at IS NULL").
+		Where("post_media.post_id = ? AND posts.status = ?", postID, models.PostProcessing).
+		Order("post_media.position ASC").
+		Take(&media).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// CompleteVideo replaces a processing post's video with its transcoded
+// renditions and marks the post ready, with imageURL as its image. The
+// renditions' blobs are referenced and the upload's released. The post is
+// dated from when it became ready, so it is fanned out to timelines and
+// listed in feeds as a new post. It returns ErrPostNotFound if the post is
+// deleted or no longer processing.
+func (r *PostRepository) CompleteVideo(postID uuid.UUID, media models.PostMedia, imageURL string) error {
+	var post models.Post
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&post, "id = ? AND status = ?", postID, models.PostProcessing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var item models.PostMedia
+		if err := tx.Where("post_id = ?", postID).Order("position ASC").Take(&item).Error; err != nil {
+			return err
+		}
+		if err := referenceBlobs(tx, media.BlobKeys()); err != nil {
+			return err
+		}
+		if err := releaseBlobs(tx, item.BlobKeys()); err != nil {
+			return err
+		}
+		err = tx.Model(&item).Updates(map[string]interface{}{
+			"url":       media.URL,
+			"width":     media.Width,
+			"height":    media.Height,
+			"mime_type": media.MimeType,
+			"duration":  media.Duration,
+			"variants":  media.Variants,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		post.ImageURL = imageURL
+		post.Status = models.PostReady
+		post.CreatedAt = time.Now()
+		return tx.Model(&post).Updates(map[string]interface{}{
+			"image_url":  post.ImageURL,
+			"status":     post.Status,
+			"created_at": post.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.notifyTimelines(func(l TimelineListener) { l.PostCreated(&post) })
+	return nil
+}
+
+// FailVideo marks a processing post failed, keeping its upload until the
+// post is deleted. It returns ErrPostNotFound if the post is deleted or no
+// longer processing.
+func (r *PostRepository) FailVideo(postID uuid.UUID) error {
+	result := r.db.Model(&models.Post{}).
+		Where("id = ? AND status = ?", postID, models.PostProcessing).
+		Update("status", models.PostFailed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
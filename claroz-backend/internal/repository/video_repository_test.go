@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/testutils"
+)
+
+func TestPostRepository_Videos(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := NewUserRepository(db.DB)
+	postRepo := NewPostRepository(db.DB)
+	mediaRepo := NewMediaRepository(db.DB)
+	user := createTestUser(t, userRepo)
+
+	refCount := func(t *testing.T, key string) int {
+		t.Helper()
+		var blob models.MediaBlob
+		if err := db.DB.Where("key = ?", key).Take(&blob).Error; err != nil {
+			t.Fatalf("Failed to get blob %s: %v", key, err)
+		}
+		return blob.RefCount
+	}
+	createVideoPost := func(t *testing.T) *models.Post {
+		t.Helper()
+		post := &models.Post{
+			UserID:   user.ID,
+			Status:   models.PostProcessing,
+			ImageURL: "/uploads/upload.source",
+			Media: []models.PostMedia{{
+				Position: 0, URL: "/uploads/upload.source", MimeType: "video/mp4", Duration: 2.5, AltText: "The sea",
+				Variants: models.ImageVariants{"source": {URL: "/uploads/upload.source"}},
+			}},
+		}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		return post
+	}
+
+	for _, key := range []string{"upload.source", "video.mp4", "poster.jpg"} {
+		if err := mediaRepo.RegisterBlob(&models.MediaBlob{Key: key}); err != nil {
+			t.Fatalf("Failed to register blob: %v", err)
+		}
+	}
+
+	t.Run("processing posts are left out of counts until ready", func(t *testing.T) {
+		post := createVideoPost(t)
+
+		ids, err := postRepo.GetProcessingPostIDs()
+		if err != nil {
+			t.Fatalf("Failed to get processing posts: %v", err)
+		}
+		if len(ids) != 1 || ids[0] != post.ID {
+			t.Errorf("Expected the post to be processing, got %v", ids)
+		}
+		if count, _ := postRepo.GetUserPostsCount(user.ID); count != 0 {
+			t.Errorf("Expected processing posts not to be counted, got %d", count)
+		}
+
+		media, err := postRepo.GetProcessingVideo(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get processing video: %v", err)
+		}
+		if media.URL != "/uploads/upload.source" {
+			t.Errorf("Expected the upload, got %s", media.URL)
+		}
+
+		completed := models.PostMedia{
+			URL: "/uploads/video.mp4", Width: 160, Height: 120, MimeType: "video/mp4", Duration: 2,
+			Variants: models.ImageVariants{
+				"mp4":    {URL: "/uploads/video.mp4", Width: 160, Height: 120, MimeType: "video/mp4"},
+				"poster": {URL: "/uploads/poster.jpg", Width: 160, Height: 120, MimeType: "image/jpeg"},
+			},
+		}
+		if err := postRepo.CompleteVideo(post.ID, completed, "/uploads/poster.jpg"); err != nil {
+			t.Fatalf("Failed to complete video: %v", err)
+		}
+
+		found, err := postRepo.GetPostByID(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get post: %v", err)
+		}
+		if found.Status != models.PostReady || found.ImageURL != "/uploads/poster.jpg" {
+			t.Errorf("Expected a ready post with the poster as its image, got %s %s", found.Status, found.ImageURL)
+		}
+		if len(found.Media) != 1 || found.Media[0].URL != "/uploads/video.mp4" || found.Media[0].AltText != "The sea" || found.Media[0].Duration != 2 {
+			t.Errorf("Expected the transcoded video with its alt text, got %+v", found.Media)
+		}
+		if count, _ := postRepo.GetUserPostsCount(user.ID); count != 1 {
+			t.Errorf("Expected the ready post to be counted, got %d", count)
+		}
+		if refCount(t, "upload.source") != 0 || refCount(t, "video.mp4") != 1 || refCount(t, "poster.jpg") != 1 {
+			t.Error("Expected the renditions to replace the upload's reference")
+		}
+
+		if err := postRepo.CompleteVideo(post.ID, completed, "/uploads/poster.jpg"); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected ready posts not to be completed again, got %v", err)
+		}
+	})
+
+	t.Run("failed posts keep their upload", func(t *testing.T) {
+		post := createVideoPost(t)
+		if err := postRepo.FailVideo(post.ID); err != nil {
+			t.Fatalf("Failed to fail video: %v", err)
+		}
+
+		found, err := postRepo.GetPostByID(post.ID)
+		if err != nil {
+			t.Fatalf("Failed to get post: %v", err)
+		}
+		if found.Status != models.PostFailed || found.Media[0].URL != "/uploads/upload.source" {
+			t.Errorf("Expected a failed post with its upload, got %s %+v", found.Status, found.Media)
+		}
+		if refCount(t, "upload.source") != 1 {
+			t.Error("Expected the upload to stay referenced")
+		}
+		if _, err := postRepo.GetProcessingVideo(post.ID); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected failed posts not to be processing, got %v", err)
+		}
+		if err := postRepo.FailVideo(post.ID); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected failed posts not to be failed again, got %v", err)
+		}
+	})
+
+	t.Run("deleted posts are no longer processing", func(t *testing.T) {
+		post := createVideoPost(t)
+		if err := postRepo.DeletePost(post.ID, user.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+		if _, err := postRepo.GetProcessingVideo(post.ID); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+		if err := postRepo.CompleteVideo(post.ID, models.PostMedia{}, ""); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+	})
+}
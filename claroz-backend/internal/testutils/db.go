@@ -43,13 +43,15 @@ func NewTestDB(t *testing.T) *TestDB {
 	}
 
 	// Drop all tables and recreate them
-	err = db.Exec(`DROP TABLE IF EXISTS likes, comments, posts, user_follows, users CASCADE`).Error
+	err = db.Exec(`DROP TABLE IF EXISTS resumable_uploads, media, media_blobs, post_media, post_revisions, timeline_entries, post_hashtags, hashtags, user_mutes, user_blocks, follow_requests, invite_codes, reactions, likes, comments, posts, user_follows, users CASCADE`).Error
 	if err != nil {
 		t.Fatalf("Failed to drop tables: %v", err)
 	}
 
 	// Run migrations
 	err = db.Exec(`
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 		CREATE TABLE IF NOT EXISTS users (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			username TEXT NOT NULL UNIQUE,
@@ -58,9 +60,18 @@ func NewTestDB(t *testing.T) *TestDB {
 			full_name TEXT,
 			bio TEXT,
 			avatar TEXT,
+			avatar_variants JSONB NOT NULL DEFAULT '{}',
+			avatar_media_id UUID,
+			banner TEXT NOT NULL DEFAULT '',
+			banner_variants JSONB NOT NULL DEFAULT '{}',
 			d_id TEXT UNIQUE,
 			handle TEXT UNIQUE,
 			federation_type TEXT DEFAULT 'local',
+			role TEXT NOT NULL DEFAULT 'user',
+			status TEXT NOT NULL DEFAULT 'active',
+			is_private BOOLEAN NOT NULL DEFAULT FALSE,
+			followers_count BIGINT NOT NULL DEFAULT 0,
+			following_count BIGINT NOT NULL DEFAULT 0,
 			last_federation_sync TIMESTAMP WITH TIME ZONE,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
@@ -71,27 +82,98 @@ func NewTestDB(t *testing.T) *TestDB {
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
 			caption TEXT,
+			language REGCONFIG NOT NULL DEFAULT 'simple',
+			search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector(language, COALESCE(caption, ''))) STORED,
 			image_url TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'ready',
+			like_count BIGINT NOT NULL DEFAULT 0,
+			comment_count BIGINT NOT NULL DEFAULT 0,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			edited_at TIMESTAMP WITH TIME ZONE,
 			deleted_at TIMESTAMP WITH TIME ZONE
 		);
 
-		CREATE TABLE IF NOT EXISTS comments (
+		CREATE TABLE IF NOT EXISTS post_revisions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+			caption TEXT,
+			language REGCONFIG NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			replaced_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS post_media (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			mime_type TEXT NOT NULL,
+			alt_text TEXT NOT NULL DEFAULT '',
+			duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+			variants JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS media (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			content TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			url TEXT NOT NULL,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			mime_type TEXT NOT NULL,
+			variants JSONB NOT NULL DEFAULT '{}',
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			attached_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS resumable_uploads (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			upload_length BIGINT,
+			upload_offset BIGINT NOT NULL DEFAULT 0,
+			metadata JSONB NOT NULL DEFAULT '{}',
+			parts JSONB NOT NULL DEFAULT '[]',
+			media_id UUID,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);
 
-		CREATE TABLE IF NOT EXISTS likes (
+		CREATE TABLE IF NOT EXISTS media_blobs (
+			key VARCHAR(255) PRIMARY KEY,
+			size BIGINT NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL DEFAULT '',
+			ref_count INTEGER NOT NULL DEFAULT 0,
+			unreferenced_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS comments (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
 			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			parent_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+			depth INTEGER NOT NULL DEFAULT 0,
+			content TEXT NOT NULL,
+			reply_count BIGINT NOT NULL DEFAULT 0,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(post_id, user_id)
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE TABLE IF NOT EXISTS reactions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
+			comment_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			emoji VARCHAR(32) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			CHECK ((post_id IS NULL) <> (comment_id IS NULL))
 		);
 
 		CREATE TABLE IF NOT EXISTS user_follows (
@@ -101,10 +183,91 @@ func NewTestDB(t *testing.T) *TestDB {
 			PRIMARY KEY (follower_id, following_id)
 		);
 
-		CREATE INDEX IF NOT EXISTS idx_likes_post_id ON likes(post_id);
-		CREATE INDEX IF NOT EXISTS idx_likes_user_id ON likes(user_id);
+		CREATE TABLE IF NOT EXISTS invite_codes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			code TEXT NOT NULL UNIQUE,
+			created_by_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			max_uses INTEGER NOT NULL DEFAULT 1,
+			uses INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS follow_requests (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(requester_id, target_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS hashtags (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS post_hashtags (
+			post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+			hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+			PRIMARY KEY (post_id, hashtag_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS user_blocks (
+			blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (blocker_id, blocked_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS user_mutes (
+			muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (muter_id, muted_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS timeline_entries (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+			author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, post_id)
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_post_user_emoji ON reactions(post_id, user_id, emoji) WHERE post_id IS NOT NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_comment_user_emoji ON reactions(comment_id, user_id, emoji) WHERE comment_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions(user_id);
 		CREATE INDEX IF NOT EXISTS idx_user_follows_follower_id ON user_follows(follower_id);
 		CREATE INDEX IF NOT EXISTS idx_user_follows_following_id ON user_follows(following_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
+		CREATE INDEX IF NOT EXISTS idx_users_status ON users(status) WHERE status <> 'active';
+		CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (LOWER(username) gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS idx_users_handle_trgm ON users USING gin (LTRIM(LOWER(handle), '@') gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING gin (LOWER(full_name) gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS idx_users_search_document ON users
+			USING gin (to_tsvector('simple', COALESCE(full_name, '') || ' ' || COALESCE(bio, '')));
+		CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions(post_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_post_media_post_position ON post_media(post_id, position);
+		CREATE INDEX IF NOT EXISTS idx_media_blobs_unreferenced_at ON media_blobs(unreferenced_at);
+		CREATE INDEX IF NOT EXISTS idx_media_user_id ON media(user_id);
+		CREATE INDEX IF NOT EXISTS idx_media_expires_at ON media(expires_at);
+		CREATE INDEX IF NOT EXISTS idx_resumable_uploads_user_id ON resumable_uploads(user_id);
+		CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires_at ON resumable_uploads(expires_at);
+		CREATE INDEX IF NOT EXISTS idx_post_hashtags_hashtag_id ON post_hashtags(hashtag_id);
+		CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector) WHERE deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts (user_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_posts_created ON posts (created_at DESC, id DESC) WHERE deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_posts_processing ON posts (created_at) WHERE status = 'processing' AND deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_comments_post_created ON comments (post_id, created_at, id);
+		CREATE INDEX IF NOT EXISTS idx_comments_parent_created ON comments (parent_id, created_at, id) WHERE parent_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_comments_post_top_level ON comments (post_id, created_at, id) WHERE parent_id IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_reactions_post_created ON reactions (post_id, created_at DESC, id DESC) WHERE post_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_reactions_comment_created ON reactions (comment_id, created_at DESC, id DESC) WHERE comment_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_created ON timeline_entries (user_id, created_at DESC, post_id DESC);
+		CREATE INDEX IF NOT EXISTS idx_timeline_entries_post_id ON timeline_entries(post_id);
+		CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_author ON timeline_entries(user_id, author_id);
 	`).Error
 	if err != nil {
 		t.Fatalf("Failed to run migrations: %v", err)
@@ -138,7 +301,42 @@ func (tdb *TestDB) Cleanup() error {
 // CleanupData removes all data from the test tables
 func (tdb *TestDB) CleanupData() error {
 	// Delete all records from tables in reverse order of dependencies
-	err := tdb.DB.Exec("DELETE FROM likes").Error
+	err := tdb.DB.Exec("DELETE FROM timeline_entries").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM post_hashtags").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM hashtags").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM user_mutes").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM user_blocks").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM follow_requests").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM invite_codes").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM reactions").Error
 	if err != nil {
 		return err
 	}
@@ -148,6 +346,31 @@ func (tdb *TestDB) CleanupData() error {
 		return err
 	}
 
+	err = tdb.DB.Exec("DELETE FROM post_revisions").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM post_media").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM resumable_uploads").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM media").Error
+	if err != nil {
+		return err
+	}
+
+	err = tdb.DB.Exec("DELETE FROM media_blobs").Error
+	if err != nil {
+		return err
+	}
+
 	err = tdb.DB.Exec("DELETE FROM posts").Error
 	if err != nil {
 		return err
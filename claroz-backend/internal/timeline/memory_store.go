@@ -0,0 +1,103 @@
+package timeline
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// MemoryStore keeps timelines in process memory. Timelines are lost on
+// restart and not shared between instances, so it is meant for tests and
+// single-instance development.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	timelines map[uuid.UUID][]models.TimelineEntry // newest first
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{timelines: make(map[uuid.UUID][]models.TimelineEntry)}
+}
+
+// Add inserts entries, ignoring any that are already present
+func (s *MemoryStore) Add(entries []models.TimelineEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		timeline := s.timelines[entry.UserID]
+		i := sort.Search(len(timeline), func(i int) bool { return !entryBefore(entry, timeline[i]) })
+		if i < len(timeline) && timeline[i].PostID == entry.PostID {
+			continue
+		}
+		timeline = append(timeline, models.TimelineEntry{})
+		copy(timeline[i+1:], timeline[i:])
+		timeline[i] = entry
+		s.timelines[entry.UserID] = timeline
+	}
+	return nil
+}
+
+// Range retrieves up to limit of a user's entries older than cursor, or the newest if cursor is nil
+func (s *MemoryStore) Range(userID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.TimelineEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	timeline := s.timelines[userID]
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(timeline), func(i int) bool { return olderThan(timeline[i], cursor) })
+	}
+	end := start + limit
+	if end > len(timeline) {
+		end = len(timeline)
+	}
+	return append([]models.TimelineEntry{}, timeline[start:end]...), nil
+}
+
+// RemovePost removes a post from every timeline
+func (s *MemoryStore) RemovePost(postID uuid.UUID) error {
+	s.removeWhere(func(userID uuid.UUID, entry models.TimelineEntry) bool {
+		return entry.PostID == postID
+	})
+	return nil
+}
+
+// RemoveAuthor removes an author's posts from a user's timeline
+func (s *MemoryStore) RemoveAuthor(userID, authorID uuid.UUID) error {
+	s.removeWhere(func(owner uuid.UUID, entry models.TimelineEntry) bool {
+		return owner == userID && entry.AuthorID == authorID
+	})
+	return nil
+}
+
+// Trim drops all but the newest maxEntries entries of every timeline
+func (s *MemoryStore) Trim(maxEntries int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, timeline := range s.timelines {
+		if len(timeline) > maxEntries {
+			s.timelines[userID] = timeline[:maxEntries:maxEntries]
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) removeWhere(match func(userID uuid.UUID, entry models.TimelineEntry) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, timeline := range s.timelines {
+		kept := make([]models.TimelineEntry, 0, len(timeline))
+		for _, entry := range timeline {
+			if !match(userID, entry) {
+				kept = append(kept, entry)
+			}
+		}
+		s.timelines[userID] = kept
+	}
+}
@@ -0,0 +1,75 @@
+package timeline
+
+import (
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// addBatchSize bounds the rows in a single insert when fanning out to many followers
+const addBatchSize = 500
+
+// PostgresStore keeps timelines in the timeline_entries table
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a store backed by the timeline_entries table
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Add inserts entries, ignoring any that are already present
+func (s *PostgresStore) Add(entries []models.TimelineEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&entries, addBatchSize).Error
+}
+
+// Range retrieves up to limit of a user's entries older than cursor, or the newest if cursor is nil
+func (s *PostgresStore) Range(userID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.TimelineEntry, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if cursor != nil {
+		query = query.Where("(created_at, post_id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	entries := []models.TimelineEntry{}
+	err := query.
+		Order("created_at DESC").
+		Order("post_id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RemovePost removes a post from every timeline
+func (s *PostgresStore) RemovePost(postID uuid.UUID) error {
+	return s.db.Where("post_id = ?", postID).Delete(&models.TimelineEntry{}).Error
+}
+
+// RemoveAuthor removes an author's posts from a user's timeline
+func (s *PostgresStore) RemoveAuthor(userID, authorID uuid.UUID) error {
+	return s.db.Where("user_id = ? AND author_id = ?", userID, authorID).
+		Delete(&models.TimelineEntry{}).Error
+}
+
+// Trim drops all but the newest maxEntries entries of every timeline
+func (s *PostgresStore) Trim(maxEntries int) error {
+	return s.db.Exec(`DELETE FROM timeline_entries t USING (
+			SELECT user_id, post_id FROM (
+				SELECT user_id, post_id, ROW_NUMBER() OVER (
+					PARTITION BY user_id ORDER BY created_at DESC, post_id DESC
+				) AS position
+				FROM timeline_entries
+			) ranked
+			WHERE position > ?
+		) old
+		WHERE t.user_id = old.user_id AND t.post_id = old.post_id`, maxEntries).Error
+}
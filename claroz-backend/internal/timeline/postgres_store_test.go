@@ -0,0 +1,115 @@
+package timeline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/testutils"
+)
+
+func TestPostgresStore_WithRepository(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	defer func() {
+		if err := db.Cleanup(); err != nil {
+			t.Errorf("Failed to cleanup test database: %v", err)
+		}
+	}()
+
+	userRepo := repository.NewUserRepository(db.DB)
+	var users []*models.User
+	for i := 0; i < 3; i++ {
+		user := &models.User{
+			Username:       fmt.Sprintf("timelineuser%d", i),
+			Email:          fmt.Sprintf("timeline%d@example.com", i),
+			Password:       "password123",
+			FederationType: "local",
+		}
+		if err := userRepo.Create(user); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+		users = append(users, user)
+	}
+	viewer, author, blocked := users[0], users[1], users[2]
+
+	store := NewPostgresStore(db.DB)
+	cfg := &config.TimelineConfig{FanoutThreshold: 10, MaxEntries: 2, BackfillLimit: 10, QueueSize: 16, Workers: 1}
+
+	// run sends the events triggered by fn through a service and waits for
+	// its workers to finish
+	run := func(fn func(postRepo repository.PostRepositoryInterface)) {
+		service := NewService(store, repository.NewPostRepository(db.DB), cfg)
+		service.Start()
+		fn(repository.NewPostRepository(db.DB, service))
+		service.Stop()
+	}
+
+	var posts []*models.Post
+	run(func(postRepo repository.PostRepositoryInterface) {
+		for _, author := range []*models.User{author, blocked} {
+			post := &models.Post{UserID: author.ID, Caption: "Backfilled", ImageURL: "test.jpg"}
+			if err := postRepo.CreatePost(post); err != nil {
+				t.Fatalf("Failed to create post: %v", err)
+			}
+			posts = append(posts, post)
+		}
+	})
+	run(func(postRepo repository.PostRepositoryInterface) {
+		for _, following := range []*models.User{author, blocked} {
+			if _, err := postRepo.FollowUser(viewer.ID, following.ID); err != nil {
+				t.Fatalf("Failed to follow: %v", err)
+			}
+		}
+	})
+	run(func(postRepo repository.PostRepositoryInterface) {
+		post := &models.Post{UserID: author.ID, Caption: "Fanned out", ImageURL: "test.jpg"}
+		if err := postRepo.CreatePost(post); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		posts = append(posts, post)
+	})
+
+	entries, err := store.Range(viewer.ID, nil, 10)
+	if err != nil {
+		t.Fatalf("Failed to read store: %v", err)
+	}
+	if len(entries) != 3 || entries[0].PostID != posts[2].ID {
+		t.Fatalf("Expected 2 backfilled and 1 fanned out entry, newest first, got %v", entries)
+	}
+
+	// Trimming keeps the newest entries of each timeline
+	if err := store.Trim(2); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if entries, _ := store.Range(viewer.ID, nil, 10); len(entries) != 2 {
+		t.Errorf("Expected 2 entries after trimming, got %d", len(entries))
+	}
+	if entries, _ := store.Range(author.ID, nil, 10); len(entries) != 2 {
+		t.Errorf("Expected the author's 2 entries to be kept, got %d", len(entries))
+	}
+
+	run(func(postRepo repository.PostRepositoryInterface) {
+		if err := postRepo.BlockUser(viewer.ID, blocked.ID); err != nil {
+			t.Fatalf("Failed to block: %v", err)
+		}
+		if err := postRepo.DeletePost(posts[0].ID, author.ID); err != nil {
+			t.Fatalf("Failed to delete post: %v", err)
+		}
+	})
+
+	entries, _ = store.Range(viewer.ID, nil, 10)
+	if len(entries) != 1 || entries[0].PostID != posts[2].ID {
+		t.Errorf("Expected only the remaining followed post after pruning, got %v", entries)
+	}
+
+	service := NewService(store, repository.NewPostRepository(db.DB), cfg)
+	feed, err := service.GetHomeTimeline(viewer.ID, nil, 10)
+	if err != nil {
+		t.Fatalf("Failed to read timeline: %v", err)
+	}
+	if len(feed) != 1 || feed[0].ID != posts[2].ID {
+		t.Errorf("Expected the followed author's remaining post, got %d posts", len(feed))
+	}
+}
@@ -0,0 +1,324 @@
+package timeline
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// maxHydrationRounds bounds how many store pages a read hydrates before
+// giving up on stale entries and reading the rest from the posts table
+const maxHydrationRounds = 5
+
+// ServiceInterface reads home timelines
+type ServiceInterface interface {
+	GetHomeTimeline(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error)
+}
+
+// PostSource provides the relationships and posts a Service materializes.
+// It is implemented by repository.PostRepositoryInterface.
+type PostSource interface {
+	GetFollowersCount(userID uuid.UUID) (int64, error)
+	GetFollowerIDs(userID uuid.UUID) ([]uuid.UUID, error)
+	GetLargeFollowedAccounts(userID uuid.UUID, minFollowers int64) ([]uuid.UUID, error)
+	GetPostsByAuthors(viewerID uuid.UUID, authorIDs []uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error)
+	GetTimelinePostsByIDs(viewerID uuid.UUID, ids []uuid.UUID) ([]models.Post, error)
+	GetHomeTimeline(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error)
+}
+
+// Service materializes home timelines. New posts are fanned out to their
+// followers' timelines by background workers; posts by accounts with more
+// than FanoutThreshold followers are instead read at request time and merged
+// in. Entries for deleted posts, unfollows and blocks are pruned, and reads
+// hydrate entries through the posts table, so anything pruned late is still
+// filtered out. Past the end of the materialized entries, reads fall back to
+// the posts table. Events arriving while the queue is full are dropped and
+// logged rather than stalling the writers that raise them; a dropped
+// fan-out leaves the post out of its followers' stored timelines.
+type Service struct {
+	store  Store
+	posts  PostSource
+	config *config.TimelineConfig
+
+	mu      sync.Mutex
+	stopped bool
+
+	jobs chan job
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// job is a unit of background timeline work
+type job struct {
+	name string
+	run  func() error
+}
+
+// NewService creates a timeline service. Call Start before the service
+// receives events.
+func NewService(store Store, posts PostSource, cfg *config.TimelineConfig) *Service {
+	return &Service{
+		store:  store,
+		posts:  posts,
+		config: cfg,
+		jobs:   make(chan job, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the fan-out workers and the periodic trim
+func (s *Service) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for j := range s.jobs {
+				if err := j.run(); err != nil {
+					log.Printf("timeline: %s failed: %v", j.name, err)
+				}
+			}
+		}()
+	}
+
+	if s.config.TrimIntervalMins > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			ticker := time.NewTicker(time.Duration(s.config.TrimIntervalMins) * time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := s.store.Trim(s.config.MaxEntries); err != nil {
+						log.Printf("timeline: trim failed: %v", err)
+					}
+				case <-s.done:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop finishes the queued jobs and stops the workers. Events received
+// afterwards are dropped.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	close(s.jobs)
+	s.mu.Unlock()
+	close(s.done)
+	s.wg.Wait()
+}
+
+// enqueue queues background work without waiting, dropping it if the queue
+// is full or the service has stopped
+func (s *Service) enqueue(name string, run func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		log.Printf("timeline: dropped %s: service stopped", name)
+		return
+	}
+	select {
+	case s.jobs <- job{name: name, run: run}:
+	default:
+		log.Printf("timeline: dropped %s: queue full", name)
+	}
+}
+
+// PostCreated fans a new post out to its author's and followers' timelines
+func (s *Service) PostCreated(post *models.Post) {
+	authorID, postID, createdAt := post.UserID, post.ID, post.CreatedAt
+	s.enqueue("fan out post "+postID.String(), func() error {
+		return s.fanOut(authorID, postID, createdAt)
+	})
+}
+
+// PostDeleted removes a post from every timeline
+func (s *Service) PostDeleted(postID, authorID uuid.UUID) {
+	s.enqueue("remove post "+postID.String(), func() error {
+		return s.store.RemovePost(postID)
+	})
+}
+
+// Followed backfills the followed account's recent posts into the follower's timeline
+func (s *Service) Followed(followerID, followingID uuid.UUID) {
+	s.enqueue("backfill "+followingID.String()+" into "+followerID.String(), func() error {
+		return s.backfill(followerID, followingID)
+	})
+}
+
+// Unfollowed removes the unfollowed account's posts from the follower's timeline
+func (s *Service) Unfollowed(followerID, followingID uuid.UUID) {
+	s.enqueue("remove "+followingID.String()+" from "+followerID.String(), func() error {
+		return s.store.RemoveAuthor(followerID, followingID)
+	})
+}
+
+// Blocked removes each user's posts from the other's timeline
+func (s *Service) Blocked(blockerID, blockedID uuid.UUID) {
+	s.enqueue("prune block of "+blockedID.String()+" by "+blockerID.String(), func() error {
+		if err := s.store.RemoveAuthor(blockerID, blockedID); err != nil {
+			return err
+		}
+		return s.store.RemoveAuthor(blockedID, blockerID)
+	})
+}
+
+// fanOut writes a post into its author's timeline and, unless the author
+// has more than FanoutThreshold followers, into each follower's timeline
+func (s *Service) fanOut(authorID, postID uuid.UUID, createdAt time.Time) error {
+	entries := []models.TimelineEntry{{UserID: authorID, PostID: postID, AuthorID: authorID, CreatedAt: createdAt}}
+
+	large, err := s.isLarge(authorID)
+	if err != nil {
+		return err
+	}
+	if !large {
+		followers, err := s.posts.GetFollowerIDs(authorID)
+		if err != nil {
+			return err
+		}
+		for _, followerID := range followers {
+			entries = append(entries, models.TimelineEntry{UserID: followerID, PostID: postID, AuthorID: authorID, CreatedAt: createdAt})
+		}
+	}
+
+	return s.store.Add(entries)
+}
+
+// backfill copies a newly followed account's recent posts into the follower's timeline
+func (s *Service) backfill(followerID, followingID uuid.UUID) error {
+	large, err := s.isLarge(followingID)
+	if err != nil || large {
+		// Large accounts are merged at read time
+		return err
+	}
+
+	posts, err := s.posts.GetPostsByAuthors(followerID, []uuid.UUID{followingID}, nil, s.config.BackfillLimit)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]models.TimelineEntry, 0, len(posts))
+	for _, post := range posts {
+		entries = append(entries, models.TimelineEntry{UserID: followerID, PostID: post.ID, AuthorID: post.UserID, CreatedAt: post.CreatedAt})
+	}
+	return s.store.Add(entries)
+}
+
+// isLarge reports whether an account has too many followers to fan out to
+func (s *Service) isLarge(userID uuid.UUID) (bool, error) {
+	count, err := s.posts.GetFollowersCount(userID)
+	return count > s.config.FanoutThreshold, err
+}
+
+// GetHomeTimeline retrieves a page of the viewer's home timeline, newest first
+func (s *Service) GetHomeTimeline(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	largeAccounts, err := s.posts.GetLargeFollowedAccounts(viewerID, s.config.FanoutThreshold)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := s.posts.GetPostsByAuthors(viewerID, largeAccounts, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	materialized, boundary, exhausted, err := s.readMaterialized(viewerID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	if !exhausted {
+		return mergePosts(materialized, pulled, limit), nil
+	}
+
+	// The store has nothing past boundary, either because the timeline was
+	// trimmed or never built. Everything older is read from the posts table,
+	// which also covers the large accounts.
+	newer := make([]models.Post, 0, len(pulled))
+	for _, post := range pulled {
+		if boundary != nil && postAfter(post, boundary) {
+			newer = append(newer, post)
+		}
+	}
+	posts := mergePosts(materialized, newer, limit)
+	if len(posts) == limit {
+		return posts, nil
+	}
+
+	rest, err := s.posts.GetHomeTimeline(viewerID, boundary, limit-len(posts))
+	if err != nil {
+		return nil, err
+	}
+	return append(posts, rest...), nil
+}
+
+// readMaterialized hydrates up to limit posts from the viewer's stored
+// timeline, skipping entries that no longer belong in it. It returns the
+// position of the last entry read and whether the store ran out of entries.
+func (s *Service) readMaterialized(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, *repository.Cursor, bool, error) {
+	posts := []models.Post{}
+	boundary := cursor
+	for round := 0; round < maxHydrationRounds; round++ {
+		entries, err := s.store.Range(viewerID, boundary, limit)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if len(entries) == 0 {
+			return posts, boundary, true, nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.PostID)
+		}
+		hydrated, err := s.posts.GetTimelinePostsByIDs(viewerID, ids)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		posts = append(posts, hydrated...)
+
+		last := entries[len(entries)-1]
+		boundary = &repository.Cursor{CreatedAt: last.CreatedAt, ID: last.PostID}
+		if len(posts) >= limit {
+			return posts[:limit], boundary, false, nil
+		}
+		if len(entries) < limit {
+			return posts, boundary, true, nil
+		}
+	}
+
+	// Mostly stale entries; read the rest directly rather than keep hydrating
+	return posts, boundary, true, nil
+}
+
+// mergePosts merges two newest-first post lists, dropping duplicates, up to limit posts
+func mergePosts(a, b []models.Post, limit int) []models.Post {
+	merged := make([]models.Post, 0, limit)
+	seen := make(map[uuid.UUID]bool, limit)
+	for len(merged) < limit && (len(a) > 0 || len(b) > 0) {
+		var next models.Post
+		if len(b) == 0 || (len(a) > 0 && !postAfter(b[0], &repository.Cursor{CreatedAt: a[0].CreatedAt, ID: a[0].ID})) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+		if !seen[next.ID] {
+			seen[next.ID] = true
+			merged = append(merged, next)
+		}
+	}
+	return merged
+}
+
+// postAfter reports whether a post is newer than a feed position
+func postAfter(post models.Post, position *repository.Cursor) bool {
+	return entryBefore(models.TimelineEntry{CreatedAt: position.CreatedAt, PostID: position.ID},
+		models.TimelineEntry{CreatedAt: post.CreatedAt, PostID: post.ID})
+}
@@ -0,0 +1,374 @@
+package timeline
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+)
+
+// fakeSource is an in-memory PostSource that records how timelines are read
+type fakeSource struct {
+	posts     map[uuid.UUID]models.Post
+	follows   map[uuid.UUID]map[uuid.UUID]bool // followerID -> followingID
+	fallbacks int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		posts:   make(map[uuid.UUID]models.Post),
+		follows: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+}
+
+func (f *fakeSource) follow(followerID, followingID uuid.UUID) {
+	if f.follows[followerID] == nil {
+		f.follows[followerID] = make(map[uuid.UUID]bool)
+	}
+	f.follows[followerID][followingID] = true
+}
+
+func (f *fakeSource) post(authorID uuid.UUID, createdAt time.Time) *models.Post {
+	post := models.Post{ID: uuid.New(), UserID: authorID, CreatedAt: createdAt}
+	f.posts[post.ID] = post
+	return &post
+}
+
+func (f *fakeSource) inTimeline(viewerID uuid.UUID, post models.Post) bool {
+	return post.UserID == viewerID || f.follows[viewerID][post.UserID]
+}
+
+func (f *fakeSource) GetFollowersCount(userID uuid.UUID) (int64, error) {
+	ids, _ := f.GetFollowerIDs(userID)
+	return int64(len(ids)), nil
+}
+
+func (f *fakeSource) GetFollowerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for followerID, following := range f.follows {
+		if following[userID] {
+			ids = append(ids, followerID)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeSource) GetLargeFollowedAccounts(userID uuid.UUID, minFollowers int64) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for followingID := range f.follows[userID] {
+		if count, _ := f.GetFollowersCount(followingID); count > minFollowers {
+			ids = append(ids, followingID)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeSource) GetPostsByAuthors(viewerID uuid.UUID, authorIDs []uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	authors := make(map[uuid.UUID]bool)
+	for _, id := range authorIDs {
+		authors[id] = true
+	}
+	return f.page(func(post models.Post) bool { return authors[post.UserID] }, cursor, limit), nil
+}
+
+func (f *fakeSource) GetTimelinePostsByIDs(viewerID uuid.UUID, ids []uuid.UUID) ([]models.Post, error) {
+	wanted := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return f.page(func(post models.Post) bool { return wanted[post.ID] && f.inTimeline(viewerID, post) }, nil, len(ids)), nil
+}
+
+func (f *fakeSource) GetHomeTimeline(viewerID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.Post, error) {
+	f.fallbacks++
+	return f.page(func(post models.Post) bool { return f.inTimeline(viewerID, post) }, cursor, limit), nil
+}
+
+func (f *fakeSource) page(match func(models.Post) bool, cursor *repository.Cursor, limit int) []models.Post {
+	posts := []models.Post{}
+	for _, post := range f.posts {
+		if match(post) && (cursor == nil || !postAfter(post, cursor) && post.ID != cursor.ID) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return bytes.Compare(posts[i].ID[:], posts[j].ID[:]) > 0
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts
+}
+
+// newTestService starts a service; Stop drains its queue so the store can be inspected
+func newTestService(source *fakeSource, store Store, threshold int64) *Service {
+	service := NewService(store, source, &config.TimelineConfig{
+		FanoutThreshold: threshold,
+		MaxEntries:      100,
+		BackfillLimit:   2,
+		QueueSize:       16,
+		Workers:         2,
+	})
+	service.Start()
+	return service
+}
+
+// readAll pages through a viewer's timeline and returns the post IDs in order
+func readAll(t *testing.T, service *Service, viewerID uuid.UUID, limit int) []uuid.UUID {
+	var ids []uuid.UUID
+	var cursor *repository.Cursor
+	for pages := 0; pages < 20; pages++ {
+		posts, err := service.GetHomeTimeline(viewerID, cursor, limit)
+		if err != nil {
+			t.Fatalf("Failed to read timeline: %v", err)
+		}
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		if len(posts) < limit {
+			return ids
+		}
+		last := posts[len(posts)-1]
+		cursor = &repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	t.Fatal("Too many pages")
+	return nil
+}
+
+func TestService_FanOut(t *testing.T) {
+	source := newFakeSource()
+	store := NewMemoryStore()
+	service := newTestService(source, store, 10)
+
+	author, follower, stranger := uuid.New(), uuid.New(), uuid.New()
+	source.follow(follower, author)
+
+	post := source.post(author, time.Now())
+	service.PostCreated(post)
+	service.Stop()
+
+	for _, test := range []struct {
+		name   string
+		userID uuid.UUID
+		want   int
+	}{
+		{"author", author, 1},
+		{"follower", follower, 1},
+		{"stranger", stranger, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := store.Range(test.userID, nil, 10)
+			if err != nil {
+				t.Fatalf("Failed to read store: %v", err)
+			}
+			if len(entries) != test.want {
+				t.Errorf("Expected %d entries, got %d", test.want, len(entries))
+			}
+		})
+	}
+
+	posts, err := service.GetHomeTimeline(follower, nil, 1)
+	if err != nil {
+		t.Fatalf("Failed to read timeline: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ID != post.ID {
+		t.Errorf("Expected the fanned out post, got %v", posts)
+	}
+	if source.fallbacks != 0 {
+		t.Errorf("Expected a full page from the store, got %d fallback reads", source.fallbacks)
+	}
+}
+
+func TestService_Pruning(t *testing.T) {
+	source := newFakeSource()
+	store := NewMemoryStore()
+	service := newTestService(source, store, 10)
+
+	viewer, author, other := uuid.New(), uuid.New(), uuid.New()
+	source.follow(viewer, author)
+	source.follow(viewer, other)
+
+	now := time.Now()
+	deleted := source.post(author, now)
+	kept := source.post(author, now.Add(-time.Minute))
+	blockedPost := source.post(other, now.Add(-2*time.Minute))
+	service.PostCreated(deleted)
+	service.PostCreated(kept)
+	service.PostCreated(blockedPost)
+	service.Stop()
+
+	// Pruning jobs are independent, so a single service may run them in any order
+	service = newTestService(source, store, 10)
+	service.PostDeleted(deleted.ID, author)
+	service.Blocked(viewer, other)
+	service.Stop()
+
+	entries, _ := store.Range(viewer, nil, 10)
+	if len(entries) != 1 || entries[0].PostID != kept.ID {
+		t.Errorf("Expected only the kept post after pruning, got %v", entries)
+	}
+	if entries, _ := store.Range(other, nil, 10); len(entries) != 1 || entries[0].PostID != blockedPost.ID {
+		t.Errorf("Expected the blocked user's timeline to keep only their own post, got %v", entries)
+	}
+
+	service = newTestService(source, store, 10)
+	service.Unfollowed(viewer, author)
+	service.Stop()
+
+	if entries, _ := store.Range(viewer, nil, 10); len(entries) != 0 {
+		t.Errorf("Expected an empty timeline after unfollowing, got %v", entries)
+	}
+}
+
+func TestService_EventsNeverBlock(t *testing.T) {
+	source := newFakeSource()
+	store := NewMemoryStore()
+	// Without workers nothing drains the queue
+	service := NewService(store, source, &config.TimelineConfig{QueueSize: 1})
+
+	author := uuid.New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			service.PostCreated(source.post(author, time.Now()))
+		}
+		service.Stop()
+		// Events after Stop are dropped rather than sent on the closed queue
+		service.PostCreated(source.post(author, time.Now()))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected events to be dropped when the queue is full, not to block")
+	}
+}
+
+func TestService_Backfill(t *testing.T) {
+	source := newFakeSource()
+	store := NewMemoryStore()
+	service := newTestService(source, store, 10)
+
+	viewer, author := uuid.New(), uuid.New()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		source.post(author, now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	source.follow(viewer, author)
+	service.Followed(viewer, author)
+	service.Stop()
+
+	entries, _ := store.Range(viewer, nil, 10)
+	if len(entries) != 2 {
+		t.Errorf("Expected the backfill limit of 2 entries, got %d", len(entries))
+	}
+
+	// The oldest post was not backfilled and is read from the posts table
+	ids := readAll(t, service, viewer, 2)
+	if len(ids) != 3 {
+		t.Errorf("Expected 3 posts across pages, got %d", len(ids))
+	}
+}
+
+func TestService_LargeAccountsMergedAtRead(t *testing.T) {
+	source := newFakeSource()
+	store := NewMemoryStore()
+	service := newTestService(source, store, 1)
+
+	viewer, celebrity, friend := uuid.New(), uuid.New(), uuid.New()
+	source.follow(viewer, celebrity)
+	source.follow(uuid.New(), celebrity)
+	source.follow(viewer, friend)
+
+	now := time.Now()
+	var want []uuid.UUID
+	for i := 0; i < 6; i++ {
+		author := friend
+		if i%2 == 0 {
+			author = celebrity
+		}
+		post := source.post(author, now.Add(-time.Duration(i)*time.Minute))
+		service.PostCreated(post)
+		want = append(want, post.ID)
+	}
+	service.Stop()
+
+	if entries, _ := store.Range(viewer, nil, 10); len(entries) != 3 {
+		t.Errorf("Expected only the friend's 3 posts to be fanned out, got %d", len(entries))
+	}
+
+	got := readAll(t, service, viewer, 2)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d posts, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Post %d out of order", i)
+		}
+	}
+}
+
+func TestService_EmptyStoreFallsBack(t *testing.T) {
+	source := newFakeSource()
+	service := newTestService(source, NewMemoryStore(), 10)
+	service.Stop()
+
+	viewer, author := uuid.New(), uuid.New()
+	source.follow(viewer, author)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		source.post(author, now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	if ids := readAll(t, service, viewer, 2); len(ids) != 5 {
+		t.Errorf("Expected 5 posts from the posts table, got %d", len(ids))
+	}
+	if source.fallbacks == 0 {
+		t.Error("Expected reads to fall back to the posts table")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	userID, authorID := uuid.New(), uuid.New()
+	now := time.Now()
+
+	var entries []models.TimelineEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, models.TimelineEntry{UserID: userID, PostID: uuid.New(), AuthorID: authorID, CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	// Entries arrive out of order and duplicated
+	if err := store.Add(entries[2:]); err != nil {
+		t.Fatalf("Failed to add entries: %v", err)
+	}
+	if err := store.Add(entries); err != nil {
+		t.Fatalf("Failed to add entries: %v", err)
+	}
+
+	page, _ := store.Range(userID, nil, 3)
+	if len(page) != 3 || page[0].PostID != entries[4].PostID || page[2].PostID != entries[2].PostID {
+		t.Errorf("Expected the newest 3 entries, newest first, got %v", page)
+	}
+	last := page[len(page)-1]
+	page, _ = store.Range(userID, &repository.Cursor{CreatedAt: last.CreatedAt, ID: last.PostID}, 3)
+	if len(page) != 2 || page[0].PostID != entries[1].PostID {
+		t.Errorf("Expected the 2 oldest entries after the cursor, got %v", page)
+	}
+
+	if err := store.Trim(2); err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	if page, _ := store.Range(userID, nil, 10); len(page) != 2 || page[1].PostID != entries[3].PostID {
+		t.Errorf("Expected the newest 2 entries after trimming, got %v", page)
+	}
+}
@@ -0,0 +1,53 @@
+package timeline
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Store holds materialized home timeline entries. Entries are ordered by
+// creation time and then post ID, newest first, matching repository.Cursor.
+type Store interface {
+	// Add inserts entries, ignoring any that are already present
+	Add(entries []models.TimelineEntry) error
+	// Range retrieves up to limit of a user's entries older than cursor, or the newest if cursor is nil
+	Range(userID uuid.UUID, cursor *repository.Cursor, limit int) ([]models.TimelineEntry, error)
+	// RemovePost removes a post from every timeline
+	RemovePost(postID uuid.UUID) error
+	// RemoveAuthor removes an author's posts from a user's timeline
+	RemoveAuthor(userID, authorID uuid.UUID) error
+	// Trim drops all but the newest maxEntries entries of every timeline
+	Trim(maxEntries int) error
+}
+
+// NewStore creates the store selected by the timeline configuration
+func NewStore(cfg *config.TimelineConfig, db *gorm.DB) (Store, error) {
+	switch cfg.Store {
+	case config.TimelineStorePostgres:
+		return NewPostgresStore(db), nil
+	case config.TimelineStoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown timeline store: %s", cfg.Store)
+	}
+}
+
+// entryBefore reports whether entry a sorts after entry b, that is, whether
+// it is older in (created_at, post_id) order
+func entryBefore(a, b models.TimelineEntry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return bytes.Compare(a.PostID[:], b.PostID[:]) < 0
+}
+
+// olderThan reports whether an entry is past a cursor
+func olderThan(entry models.TimelineEntry, cursor *repository.Cursor) bool {
+	return entryBefore(entry, models.TimelineEntry{CreatedAt: cursor.CreatedAt, PostID: cursor.ID})
+}
@@ -0,0 +1,461 @@
+package tus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+const (
+	// Version is the version of the tus protocol the service implements
+	Version = "1.0.0"
+	// Extensions are the tus extensions the service supports
+	Extensions = "creation,creation-defer-length,termination,expiration"
+)
+
+var (
+	// ErrOffsetMismatch is returned for parts sent at an offset other than the
+	// upload's
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrTooLarge is returned for uploads over the maximum file size or their
+	// declared length
+	ErrTooLarge = errors.New("upload is too large")
+	// ErrLengthMismatch is returned for a length that contradicts the one
+	// already declared, or the bytes already received
+	ErrLengthMismatch = errors.New("upload length mismatch")
+)
+
+// Store tracks resumable uploads. It is implemented by
+// *repository.UploadRepository, whose errors it returns.
+type Store interface {
+	CreateUpload(upload *models.ResumableUpload, quota int64) error
+	GetUpload(id, userID uuid.UUID) (*models.ResumableUpload, error)
+	UploadUsage(userID uuid.UUID) (int64, error)
+	AppendPart(upload *models.ResumableUpload, part models.UploadPart, length *int64, expiresAt time.Time, quota int64) (bool, error)
+	CompleteUpload(id, mediaID uuid.UUID) (bool, error)
+	DeleteUpload(id uuid.UUID, remove func(upload *models.ResumableUpload) error) error
+	ExpiredUploads(now time.Time, limit int) ([]models.ResumableUpload, error)
+}
+
+// MediaStore records the media completed uploads become. It is implemented
+// by *repository.MediaRepository.
+type MediaStore interface {
+	CreateMedia(media *models.Media) error
+}
+
+// ServiceInterface creates, resumes and terminates resumable uploads
+type ServiceInterface interface {
+	// MaxSize returns the largest upload accepted, in bytes
+	MaxSize() int64
+	// Create starts an upload of length bytes, or of a size declared later if
+	// length is nil
+	Create(userID uuid.UUID, length *int64, metadata models.UploadMetadata) (*models.ResumableUpload, error)
+	// Get returns a user's unexpired upload
+	Get(id, userID uuid.UUID) (*models.ResumableUpload, error)
+	// Write appends body to an upload at offset, declaring its length if it
+	// was deferred and length is not nil
+	Write(ctx context.Context, upload *models.ResumableUpload, offset int64, length *int64, body io.Reader) (*models.ResumableUpload, error)
+	// Terminate deletes an upload and its stored parts
+	Terminate(upload *models.ResumableUpload) error
+}
+
+// Service implements the tus protocol on top of file storage. Each request's
+// body is stored as a part of its own, so an interrupted request keeps the
+// bytes it received and the client resumes from there. Once every byte is
+// received the parts are processed into pending media, like an image posted
+// in one request, and deleted. Uploads are checked against the maximum file
+// size and the user's quota as their parts arrive, rather than once they are
+// complete.
+type Service struct {
+	store  Store
+	media  MediaStore
+	files  utils.FileStorageInterface
+	images utils.ImageStorageInterface
+
+	maxSize     int64
+	mediaExpiry time.Duration
+	config      *config.TusConfig
+
+	mu   sync.Mutex // serializes cleanups
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewService creates a tus service storing parts in files and processing
+// completed uploads of up to maxSize bytes with images. The media they
+// become expire after mediaExpiry unless they are attached. Call Start to
+// delete expired uploads periodically.
+func NewService(store Store, media MediaStore, files utils.FileStorageInterface, images utils.ImageStorageInterface, maxSize int64, mediaExpiry time.Duration, cfg *config.TusConfig) *Service {
+	return &Service{
+		store:       store,
+		media:       media,
+		files:       files,
+		images:      images,
+		maxSize:     maxSize,
+		mediaExpiry: mediaExpiry,
+		config:      cfg,
+		done:        make(chan struct{}),
+	}
+}
+
+// MaxSize returns the largest upload accepted, in bytes
+func (s *Service) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Create starts an upload of length bytes, or of a size declared later if
+// length is nil. It returns ErrTooLarge if length is over the maximum file
+// size, or repository.ErrUploadQuotaExceeded if it would take the user's
+// unfinished uploads over their quota.
+func (s *Service) Create(userID uuid.UUID, length *int64, metadata models.UploadMetadata) (*models.ResumableUpload, error) {
+	if length != nil && *length > s.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	upload := &models.ResumableUpload{
+		UserID:    userID,
+		Length:    length,
+		Metadata:  metadata,
+		Parts:     models.UploadParts{},
+		ExpiresAt: s.expiresAt(),
+	}
+	if err := s.store.CreateUpload(upload, s.config.UserQuotaBytes); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get returns a user's unexpired upload, or repository.ErrUploadNotFound. An
+// upload that received every byte but failed to become media, such as when
+// the server stopped while processing it, is processed again.
+func (s *Service) Get(id, userID uuid.UUID) (*models.ResumableUpload, error) {
+	upload, err := s.store.GetUpload(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Complete() && upload.MediaID == nil {
+		return s.finish(context.Background(), upload)
+	}
+	return upload, nil
+}
+
+// Write appends body to an upload at offset, declaring its length if it was
+// deferred and length is not nil, and returns the upload as it now stands.
+// If the client goes away mid-request, the bytes received so far are kept.
+// The upload becomes media once every byte is received. It returns
+// ErrOffsetMismatch if the upload is not at offset, ErrLengthMismatch if
+// length contradicts the upload, ErrTooLarge if body runs past the upload's
+// length or the maximum file size, repository.ErrUploadQuotaExceeded if it
+// would take the user's unfinished uploads over their quota, or the errors of
+// ImageStorageInterface.SaveImage if the completed upload is not an image it
+// accepts, in which case the upload is deleted.
+func (s *Service) Write(ctx context.Context, upload *models.ResumableUpload, offset int64, length *int64, body io.Reader) (*models.ResumableUpload, error) {
+	if offset != upload.Offset {
+		return nil, ErrOffsetMismatch
+	}
+	if length != nil {
+		if upload.Length != nil && *length != *upload.Length || *length < upload.Offset {
+			return nil, ErrLengthMismatch
+		}
+		if *length > s.maxSize {
+			return nil, ErrTooLarge
+		}
+	}
+
+	if upload.MediaID != nil {
+		// Completed already; accept only an empty request, such as a retry
+		if err := drainEmpty(body); err != nil {
+			return nil, err
+		}
+		return upload, nil
+	}
+
+	declared := upload.Length
+	if declared == nil {
+		declared = length
+	}
+	limit, tooLarge := s.maxSize-offset, ErrTooLarge
+	if declared != nil {
+		limit = *declared - offset
+	} else {
+		// Bytes sent while the size is deferred count against the quota as
+		// they arrive, so stop reading once it is used up
+		usage, err := s.store.UploadUsage(upload.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if left := s.config.UserQuotaBytes - usage; left < limit {
+			limit, tooLarge = max(left, 0), repository.ErrUploadQuotaExceeded
+		}
+	}
+
+	part, err := s.savePart(ctx, upload, body, limit)
+	if errors.Is(err, ErrTooLarge) {
+		return nil, tooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	appended, err := s.store.AppendPart(upload, part, length, s.expiresAt(), s.config.UserQuotaBytes)
+	if err != nil || !appended {
+		s.removeParts(models.UploadParts{part})
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrOffsetMismatch
+	}
+
+	upload.Offset += part.Size
+	if upload.Length == nil {
+		upload.Length = length
+	}
+	if part.Size > 0 {
+		upload.Parts = append(upload.Parts, part)
+	}
+	upload.ExpiresAt = s.expiresAt()
+	if upload.Complete() {
+		return s.finish(ctx, upload)
+	}
+	return upload, nil
+}
+
+// Terminate deletes an upload and its stored parts. The media a completed
+// upload became are kept.
+func (s *Service) Terminate(upload *models.ResumableUpload) error {
+	return s.store.DeleteUpload(upload.ID, func(upload *models.ResumableUpload) error {
+		return s.removeParts(upload.Parts)
+	})
+}
+
+// Start deletes expired uploads once in the background and then every
+// CleanupIntervalMins, unless the interval is 0
+func (s *Service) Start() {
+	if s.config.CleanupIntervalMins <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.config.CleanupIntervalMins) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Run(); err != nil {
+				log.Printf("tus: cleanup failed: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for a running cleanup to finish and stops the job
+func (s *Service) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Run deletes up to BatchSize expired uploads and their stored parts,
+// returning how many it deleted. Uploads whose parts fail to delete are kept,
+// to be retried by the next run.
+func (s *Service) Run() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploads, err := s.store.ExpiredUploads(time.Now(), s.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := range uploads {
+		if err := s.Terminate(&uploads[i]); err != nil {
+			log.Printf("tus: failed to delete expired upload %s: %v", uploads[i].ID, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Printf("tus: deleted %d expired uploads", deleted)
+	}
+	return deleted, nil
+}
+
+// savePart stores what body holds of an upload's next part, reading at most
+// limit bytes. The part is stored under a key of its own, so concurrent
+// requests for the same offset never overwrite each other; only one is
+// appended. An empty body stores nothing.
+func (s *Service) savePart(ctx context.Context, upload *models.ResumableUpload, body io.Reader, limit int64) (models.UploadPart, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return models.UploadPart{}, err
+	}
+	key := fmt.Sprintf("upload-%s-%d-%s.part", upload.ID, upload.Offset, hex.EncodeToString(nonce))
+
+	// Keep storing what was received even if the request is cancelled
+	r := &partReader{r: body, remaining: limit}
+	_, err := s.files.Save(context.WithoutCancel(ctx), r, utils.ObjectMeta{Key: key, ContentType: "application/octet-stream", Size: -1})
+	if r.tooLarge {
+		return models.UploadPart{}, ErrTooLarge
+	}
+	if err != nil {
+		return models.UploadPart{}, fmt.Errorf("failed to store upload part: %w", err)
+	}
+	if r.n == 0 {
+		s.removeParts(models.UploadParts{{Key: key}})
+		return models.UploadPart{}, nil
+	}
+	return models.UploadPart{Key: key, Size: r.n}, nil
+}
+
+// finish processes a complete upload into pending media and deletes its
+// parts. An upload that is not an image the storage accepts is deleted.
+func (s *Service) finish(ctx context.Context, upload *models.ResumableUpload) (*models.ResumableUpload, error) {
+	r := &partsReader{ctx: ctx, files: s.files, parts: upload.Parts}
+	defer r.Close()
+
+	stored, err := s.images.SaveImage(ctx, r)
+	if err != nil {
+		if errors.Is(err, imaging.ErrInvalidImage) || errors.Is(err, imaging.ErrImageTooLarge) || errors.Is(err, utils.ErrFileTooLarge) {
+			if err := s.Terminate(upload); err != nil {
+				log.Printf("tus: failed to delete rejected upload %s: %v", upload.ID, err)
+			}
+		}
+		return nil, err
+	}
+
+	media := stored.Media(upload.UserID, time.Now().Add(s.mediaExpiry))
+	if err := s.media.CreateMedia(media); err != nil {
+		return nil, err
+	}
+	completed, err := s.store.CompleteUpload(upload.ID, media.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		// Another request finished the upload first; the media created here
+		// expire unattached
+		return s.store.GetUpload(upload.ID, upload.UserID)
+	}
+
+	if err := s.removeParts(upload.Parts); err != nil {
+		log.Printf("tus: failed to delete parts of upload %s: %v", upload.ID, err)
+	}
+	upload.MediaID = &media.ID
+	upload.Parts = models.UploadParts{}
+	return upload, nil
+}
+
+// removeParts deletes stored parts, returning the first error
+func (s *Service) removeParts(parts models.UploadParts) error {
+	var firstErr error
+	for _, part := range parts {
+		if err := s.files.Delete(context.Background(), part.Key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// expiresAt returns when an upload that received a part now expires
+func (s *Service) expiresAt() time.Time {
+	return time.Now().Add(time.Duration(s.config.ExpiryMins) * time.Minute)
+}
+
+// drainEmpty returns ErrTooLarge if body is not empty
+func drainEmpty(body io.Reader) error {
+	var b [1]byte
+	if n, _ := io.ReadFull(body, b[:]); n > 0 {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// partReader reads a request body as a part of at most remaining bytes. It
+// fails once the body runs past the limit, and treats an interrupted body as
+// ending, so the bytes received before it are kept.
+type partReader struct {
+	r         io.Reader
+	remaining int64
+	n         int64
+	tooLarge  bool
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	// Read a byte past the limit to tell bodies at the limit from larger ones
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.r.Read(p)
+	if int64(n) > r.remaining {
+		r.tooLarge = true
+		return 0, ErrTooLarge
+	}
+	r.remaining -= int64(n)
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// partsReader reads an upload's stored parts in order, opening each as it is
+// reached
+type partsReader struct {
+	ctx     context.Context
+	files   utils.FileStorageInterface
+	parts   models.UploadParts
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			f, err := r.files.Open(r.ctx, r.parts[0].Key)
+			if err != nil {
+				return 0, fmt.Errorf("failed to open upload part: %w", err)
+			}
+			r.current = f
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
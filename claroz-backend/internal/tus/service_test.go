@@ -0,0 +1,377 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// fakeStore keeps uploads in memory, checking quotas and offsets like
+// *repository.UploadRepository
+type fakeStore struct {
+	uploads map[uuid.UUID]*models.ResumableUpload
+}
+
+func (f *fakeStore) usage(userID uuid.UUID) int64 {
+	var usage int64
+	for _, upload := range f.uploads {
+		if upload.UserID == userID && upload.MediaID == nil {
+			if upload.Length != nil {
+				usage += *upload.Length
+			} else {
+				usage += upload.Offset
+			}
+		}
+	}
+	return usage
+}
+
+func (f *fakeStore) CreateUpload(upload *models.ResumableUpload, quota int64) error {
+	if upload.Length != nil && f.usage(upload.UserID)+*upload.Length > quota {
+		return repository.ErrUploadQuotaExceeded
+	}
+	upload.ID = uuid.New()
+	stored := *upload
+	f.uploads[upload.ID] = &stored
+	return nil
+}
+
+func (f *fakeStore) GetUpload(id, userID uuid.UUID) (*models.ResumableUpload, error) {
+	upload, ok := f.uploads[id]
+	if !ok || upload.UserID != userID || !upload.ExpiresAt.After(time.Now()) {
+		return nil, repository.ErrUploadNotFound
+	}
+	found := *upload
+	found.Parts = append(models.UploadParts{}, upload.Parts...)
+	return &found, nil
+}
+
+func (f *fakeStore) UploadUsage(userID uuid.UUID) (int64, error) {
+	return f.usage(userID), nil
+}
+
+func (f *fakeStore) AppendPart(upload *models.ResumableUpload, part models.UploadPart, length *int64, expiresAt time.Time, quota int64) (bool, error) {
+	stored, ok := f.uploads[upload.ID]
+	if !ok || stored.Offset != upload.Offset || stored.MediaID != nil {
+		return false, nil
+	}
+	if stored.Length == nil && length == nil && f.usage(upload.UserID)+part.Size > quota {
+		return false, repository.ErrUploadQuotaExceeded
+	}
+	stored.Offset += part.Size
+	if part.Size > 0 {
+		stored.Parts = append(stored.Parts, part)
+	}
+	if stored.Length == nil {
+		stored.Length = length
+	}
+	stored.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (f *fakeStore) CompleteUpload(id, mediaID uuid.UUID) (bool, error) {
+	stored, ok := f.uploads[id]
+	if !ok || stored.MediaID != nil {
+		return false, nil
+	}
+	stored.MediaID = &mediaID
+	stored.Parts = models.UploadParts{}
+	return true, nil
+}
+
+func (f *fakeStore) DeleteUpload(id uuid.UUID, remove func(upload *models.ResumableUpload) error) error {
+	stored, ok := f.uploads[id]
+	if !ok {
+		return repository.ErrUploadNotFound
+	}
+	if err := remove(stored); err != nil {
+		return err
+	}
+	delete(f.uploads, id)
+	return nil
+}
+
+func (f *fakeStore) ExpiredUploads(now time.Time, limit int) ([]models.ResumableUpload, error) {
+	uploads := []models.ResumableUpload{}
+	for _, upload := range f.uploads {
+		if !upload.ExpiresAt.After(now) && len(uploads) < limit {
+			uploads = append(uploads, *upload)
+		}
+	}
+	return uploads, nil
+}
+
+// fakeMedia records created media
+type fakeMedia struct {
+	media []*models.Media
+}
+
+func (f *fakeMedia) CreateMedia(media *models.Media) error {
+	media.ID = uuid.New()
+	f.media = append(f.media, media)
+	return nil
+}
+
+// fakeImages accepts anything starting with "IMG" as an image, recording
+// what it was given
+type fakeImages struct {
+	saved [][]byte
+}
+
+func (f *fakeImages) SaveImage(ctx context.Context, r io.Reader) (*utils.StoredImage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("IMG")) {
+		return nil, imaging.ErrInvalidImage
+	}
+	f.saved = append(f.saved, data)
+	return &utils.StoredImage{URL: "/uploads/full.jpg", Width: 1, Height: 1, MimeType: "image/jpeg"}, nil
+}
+
+// interruptedReader returns its data and then fails, like a request body
+// whose client went away
+type interruptedReader struct {
+	data []byte
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+type testService struct {
+	*Service
+	store  *fakeStore
+	media  *fakeMedia
+	images *fakeImages
+	dir    string
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := utils.NewLocalStorage(&config.StorageConfig{LocalPath: dir})
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	store := &fakeStore{uploads: map[uuid.UUID]*models.ResumableUpload{}}
+	media := &fakeMedia{}
+	images := &fakeImages{}
+	cfg := &config.TusConfig{UserQuotaBytes: 20, ExpiryMins: 60, BatchSize: 10}
+	service := NewService(store, media, files, images, 16, time.Hour, cfg)
+	return &testService{Service: service, store: store, media: media, images: images, dir: dir}
+}
+
+// storedParts returns the names of the parts left in storage
+func (s *testService) storedParts(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func TestService_Write(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("upload resumes after an interruption and becomes media", func(t *testing.T) {
+		s := newTestService(t)
+		upload, err := s.Create(userID, int64Ptr(10), models.UploadMetadata{"filename": "photo.jpg"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		upload, err = s.Write(ctx, upload, 0, nil, &interruptedReader{data: []byte("IMG12")})
+		if err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if upload.Offset != 5 || upload.MediaID != nil {
+			t.Fatalf("Expected the received bytes to be kept, got offset %d", upload.Offset)
+		}
+
+		// The client asks where to resume and sends the rest
+		upload, err = s.Get(upload.ID, userID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 2, nil, strings.NewReader("12345")); !errors.Is(err, ErrOffsetMismatch) {
+			t.Errorf("Expected an offset mismatch, got %v", err)
+		}
+		upload, err = s.Write(ctx, upload, 5, nil, strings.NewReader("34567"))
+		if err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+
+		if upload.MediaID == nil || len(s.media.media) != 1 || *upload.MediaID != s.media.media[0].ID {
+			t.Fatalf("Expected the upload to become media, got %+v", upload)
+		}
+		if got := s.media.media[0]; got.UserID != userID || got.URL != "/uploads/full.jpg" || !got.ExpiresAt.After(time.Now()) {
+			t.Errorf("Expected pending media for the user, got %+v", got)
+		}
+		if len(s.images.saved) != 1 || string(s.images.saved[0]) != "IMG1234567" {
+			t.Errorf("Expected the parts to be processed in order, got %q", s.images.saved)
+		}
+		if parts := s.storedParts(t); len(parts) != 0 {
+			t.Errorf("Expected the parts to be deleted, got %v", parts)
+		}
+
+		// Retrying the last request is harmless
+		again, err := s.Write(ctx, upload, 10, nil, strings.NewReader(""))
+		if err != nil || *again.MediaID != *upload.MediaID {
+			t.Errorf("Expected an empty retry to succeed, got %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 10, nil, strings.NewReader("x")); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Expected bytes past the end to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("sizes are enforced as bytes arrive", func(t *testing.T) {
+		s := newTestService(t)
+		if _, err := s.Create(userID, int64Ptr(17), nil); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Expected an upload over the maximum size to be rejected, got %v", err)
+		}
+
+		upload, err := s.Create(userID, int64Ptr(4), nil)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 0, nil, strings.NewReader("IMG12")); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Expected bytes past the declared length to be rejected, got %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 0, int64Ptr(5), strings.NewReader("IMG1")); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected a changed length to be rejected, got %v", err)
+		}
+		if stored, _ := s.store.GetUpload(upload.ID, userID); stored.Offset != 0 {
+			t.Errorf("Expected nothing to be appended, got offset %d", stored.Offset)
+		}
+		if parts := s.storedParts(t); len(parts) != 0 {
+			t.Errorf("Expected rejected parts to be deleted, got %v", parts)
+		}
+	})
+
+	t.Run("deferred length", func(t *testing.T) {
+		s := newTestService(t)
+		upload, err := s.Create(userID, nil, nil)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if upload, err = s.Write(ctx, upload, 0, nil, strings.NewReader("IMG")); err != nil || upload.Offset != 3 || upload.Length != nil {
+			t.Fatalf("Expected 3 bytes of unknown length, got %+v, %v", upload, err)
+		}
+		if upload, err = s.Write(ctx, upload, 3, int64Ptr(5), strings.NewReader("45")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if upload.MediaID == nil || *upload.Length != 5 {
+			t.Errorf("Expected the declared upload to complete, got %+v", upload)
+		}
+	})
+
+	t.Run("deferred uploads stop at the quota", func(t *testing.T) {
+		s := newTestService(t)
+		if _, err := s.Create(userID, int64Ptr(12), nil); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		upload, err := s.Create(userID, nil, nil)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if upload, err = s.Write(ctx, upload, 0, nil, strings.NewReader("IMG12345")); err != nil {
+			t.Fatalf("Expected bytes within the quota to be kept, got %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 8, nil, strings.NewReader("6")); !errors.Is(err, repository.ErrUploadQuotaExceeded) {
+			t.Errorf("Expected bytes over the quota to be rejected, got %v", err)
+		}
+		if _, err := s.Create(userID, int64Ptr(1), nil); !errors.Is(err, repository.ErrUploadQuotaExceeded) {
+			t.Errorf("Expected a new upload over the quota to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("uploads that are not images are deleted", func(t *testing.T) {
+		s := newTestService(t)
+		upload, err := s.Create(userID, int64Ptr(4), nil)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := s.Write(ctx, upload, 0, nil, strings.NewReader("<svg")); !errors.Is(err, imaging.ErrInvalidImage) {
+			t.Errorf("Expected an invalid image, got %v", err)
+		}
+		if _, err := s.Get(upload.ID, userID); !errors.Is(err, repository.ErrUploadNotFound) {
+			t.Errorf("Expected the upload to be deleted, got %v", err)
+		}
+		if parts := s.storedParts(t); len(parts) != 0 {
+			t.Errorf("Expected its parts to be deleted, got %v", parts)
+		}
+	})
+}
+
+func TestService_Run(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	s := newTestService(t)
+
+	expired, err := s.Create(userID, int64Ptr(10), nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := s.Write(ctx, expired, 0, nil, strings.NewReader("IMG")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	s.store.uploads[expired.ID].ExpiresAt = time.Now().Add(-time.Minute)
+
+	active, err := s.Create(userID, int64Ptr(10), nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := s.Write(ctx, active, 0, nil, strings.NewReader("IMG")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	deleted, err := s.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 upload to be deleted, got %d", deleted)
+	}
+	if _, ok := s.store.uploads[expired.ID]; ok {
+		t.Error("Expected the expired upload to be deleted")
+	}
+	if parts := s.storedParts(t); len(parts) != 1 || parts[0] != s.store.uploads[active.ID].Parts[0].Key {
+		t.Errorf("Expected only the active upload's part to be left, got %v", parts)
+	}
+}
+
+func TestService_StartDisabled(t *testing.T) {
+	s := newTestService(t)
+	s.config.CleanupIntervalMins = 0
+	s.Start()
+	s.Stop()
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+func TestCursorCodec(t *testing.T) {
+	codec := NewCursorCodec("test-secret")
+	want := testCursor{CreatedAt: time.Date(2024, 1, 26, 0, 35, 27, 123456000, time.UTC), ID: "abc"}
+	encoded := codec.Encode(want)
+
+	var got testCursor
+	if err := codec.Decode(encoded, &got); err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	payload, signature, _ := strings.Cut(encoded, ".")
+	forged := NewCursorCodec("other-secret").Encode(testCursor{ID: "xyz"})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"unsigned", payload},
+		{"other key", forged},
+		{"tampered payload", forgedPayload + "." + signature},
+		{"truncated signature", payload + "." + signature[:10]},
+		{"not base64", "!!!." + signature},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := codec.Decode(tt.value, &got); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCursorCodec_DerivedKey(t *testing.T) {
+	encoded := NewCursorCodec("").Encode(testCursor{ID: "abc"})
+	var got testCursor
+	if err := NewCursorCodec("").Decode(encoded, &got); err != nil || got.ID != "abc" {
+		t.Errorf("Expected codecs with derived keys to accept each other's cursors, got %+v (%v)", got, err)
+	}
+	if err := NewCursorCodec(string(jwtSecret)).Decode(encoded, &got); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected the derived key to differ from the JWT signing key, got %v", err)
+	}
+}
@@ -26,10 +26,144 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 	}
 
 	// Auto Migrate the schema
-	err = db.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{})
+	err = db.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{}, &models.Reaction{}, &models.InviteCode{}, &models.FollowRequest{}, &models.UserBlock{}, &models.UserMute{}, &models.Hashtag{}, &models.PostHashtag{}, &models.TimelineEntry{}, &models.PostRevision{}, &models.PostMedia{}, &models.MediaBlob{}, &models.Media{}, &models.ResumableUpload{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Usernames are unique regardless of case, which GORM's unique index does not enforce
+	if err := db.Exec(registrationSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to create registration indexes: %w", err)
+	}
+
+	// Search indexes use an extension, expressions and a generated column that AutoMigrate cannot create
+	if err := db.Exec(searchSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to create search indexes: %w", err)
+	}
+
+	// Reactions need partial unique indexes, and likes from before reactions are converted
+	if err := db.Exec(reactionSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to create reaction indexes: %w", err)
+	}
+
+	// Posts from before carousels get their single image as their first media item
+	if err := db.Exec(mediaSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill post media: %w", err)
+	}
+
+	// Files stored before blobs were tracked are referenced by their media
+	if err := db.Exec(blobSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill media blobs: %w", err)
+	}
+
+	// Avatars attached as media before profile images kept their variants refer to their file
+	if err := db.Exec(profileImageSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill avatar variants: %w", err)
+	}
+
+	// Video posts waiting to be transcoded are found when the transcoder starts
+	if err := db.Exec(videoSchema).Error; err != nil {
+		return nil, fmt.Errorf("failed to create video indexes: %w", err)
+	}
+
+	// Keyset pagination over posts and timelines needs composite, ordered indexes
+	if err := db.Exec(feedIndexes).Error; err != nil {
+		return nil, fmt.Errorf("failed to create feed indexes: %w", err)
+	}
+
 	return db, nil
 }
+
+// registrationSchema mirrors the indexes in migration 000003_add_registration_modes
+const registrationSchema = `
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username));
+	CREATE INDEX IF NOT EXISTS idx_users_status ON users (status) WHERE status <> 'active';
+`
+
+// searchSchema mirrors migrations 000006_add_user_search and 000007_add_post_search
+const searchSchema = `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (LOWER(username) gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_users_handle_trgm ON users USING gin (LTRIM(LOWER(handle), '@') gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING gin (LOWER(full_name) gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_users_search_document ON users
+		USING gin (to_tsvector('simple', COALESCE(full_name, '') || ' ' || COALESCE(bio, '')));
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector(language, COALESCE(caption, ''))) STORED;
+	CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector) WHERE deleted_at IS NULL;
+`
+
+// reactionSchema mirrors migration 000014_add_reactions
+const reactionSchema = `
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_post_user_emoji ON reactions (post_id, user_id, emoji) WHERE post_id IS NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_comment_user_emoji ON reactions (comment_id, user_id, emoji) WHERE comment_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_reactions_post_created ON reactions (post_id, created_at DESC, id DESC) WHERE post_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_reactions_comment_created ON reactions (comment_id, created_at DESC, id DESC) WHERE comment_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions (user_id);
+	DO $$ BEGIN
+		IF to_regclass('likes') IS NOT NULL THEN
+			INSERT INTO reactions (id, post_id, user_id, emoji, created_at)
+			SELECT id, post_id, user_id, 'heart', created_at FROM likes
+			ON CONFLICT DO NOTHING;
+			DROP TABLE likes;
+		END IF;
+	END $$;
+`
+
+// mediaSchema mirrors the backfill in migration 000015_add_post_media
+const mediaSchema = `
+	INSERT INTO post_media (post_id, position, url, mime_type, created_at)
+	SELECT id, 0, image_url,
+		CASE
+			WHEN LOWER(image_url) LIKE '%.png' THEN 'image/png'
+			WHEN LOWER(image_url) LIKE '%.gif' THEN 'image/gif'
+			ELSE 'image/jpeg'
+		END,
+		created_at
+	FROM posts
+	WHERE deleted_at IS NULL
+	ON CONFLICT DO NOTHING;
+`
+
+// blobSchema mirrors the backfill in migration 000017_add_media_blobs. It only
+// runs before any blob is registered, so it scans the media once.
+const blobSchema = `
+	INSERT INTO media_blobs (key, ref_count, created_at)
+	SELECT regexp_replace(files.url, '^.*/', ''), COUNT(*), NOW()
+	FROM post_media m
+	CROSS JOIN LATERAL (
+		SELECT m.url
+		UNION
+		SELECT v.value->>'url' FROM jsonb_each(m.variants) v
+	) files
+	WHERE files.url IS NOT NULL AND files.url <> ''
+		AND NOT EXISTS (SELECT 1 FROM media_blobs)
+	GROUP BY 1
+	ON CONFLICT (key) DO NOTHING;
+`
+
+// profileImageSchema mirrors the backfill in migration 000020_add_profile_images
+const profileImageSchema = `
+	UPDATE users u
+	SET avatar_variants = jsonb_build_object('full', jsonb_build_object(
+		'url', u.avatar, 'width', m.width, 'height', m.height, 'mime_type', m.mime_type))
+	FROM media m
+	WHERE m.id = u.avatar_media_id AND u.avatar_variants = '{}';
+`
+
+// videoSchema mirrors migration 000021_add_video_posts
+const videoSchema = `
+	CREATE INDEX IF NOT EXISTS idx_posts_processing ON posts (created_at) WHERE status = 'processing' AND deleted_at IS NULL;
+`
+
+// feedIndexes mirrors migrations 000008_add_feed_indexes, 000009_add_timeline_entries,
+// 000010_add_pagination_indexes and 000013_add_comment_threads
+const feedIndexes = `
+	CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts (user_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_posts_created ON posts (created_at DESC, id DESC) WHERE deleted_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_comments_post_created ON comments (post_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_comments_parent_created ON comments (parent_id, created_at, id) WHERE parent_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_comments_post_top_level ON comments (post_id, created_at, id) WHERE parent_id IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_created ON timeline_entries (user_id, created_at DESC, post_id DESC);
+	CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_author ON timeline_entries(user_id, author_id);
+`
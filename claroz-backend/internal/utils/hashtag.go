@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hashtagPattern matches a # that starts a word, followed by letters, numbers and underscores
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{M}\p{N}_]+)`)
+
+const (
+	maxHashtagLength   = 100
+	maxHashtagsPerPost = 30
+)
+
+// ExtractHashtags returns the distinct normalized hashtags in a caption, in
+// order of first appearance and without the leading #. At most 30 hashtags
+// are returned.
+func ExtractHashtags(caption string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(caption, -1) {
+		tag, ok := NormalizeHashtag(match[1])
+		if !ok || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == maxHashtagsPerPost {
+			break
+		}
+	}
+	return tags
+}
+
+// NormalizeHashtag lowercases a hashtag and strips a leading #. It reports
+// false if the result is empty, too long, contains characters other than
+// letters, numbers and underscores, or has no letters.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > maxHashtagLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsNumber(r), unicode.Is(unicode.M, r), r == '_':
+		default:
+			return "", false
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+	return tag, true
+}
+
+// searchLanguages are the Postgres text search configurations posts may be indexed with
+var searchLanguages = map[string]bool{
+	"simple":     true,
+	"danish":     true,
+	"dutch":      true,
+	"english":    true,
+	"finnish":    true,
+	"french":     true,
+	"german":     true,
+	"hungarian":  true,
+	"italian":    true,
+	"norwegian":  true,
+	"portuguese": true,
+	"romanian":   true,
+	"russian":    true,
+	"spanish":    true,
+	"swedish":    true,
+	"turkish":    true,
+}
+
+// IsSearchLanguage reports whether language names a supported text search configuration
+func IsSearchLanguage(language string) bool {
+	return searchLanguages[language]
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name    string
+		caption string
+		want    []string
+	}{
+		{
+			name:    "no hashtags",
+			caption: "Just a sunset",
+			want:    []string{},
+		},
+		{
+			name:    "normalizes case and removes duplicates",
+			caption: "#Sunset at the beach #sunset #BEACH",
+			want:    []string{"sunset", "beach"},
+		},
+		{
+			name:    "stops at punctuation",
+			caption: "Great day (#travel), #food!",
+			want:    []string{"travel", "food"},
+		},
+		{
+			name:    "unicode letters",
+			caption: "#café #東京",
+			want:    []string{"café", "東京"},
+		},
+		{
+			name:    "ignores mid-word and numeric tags",
+			caption: "email@x.com a#b issue #42 #2024trip",
+			want:    []string{"2024trip"},
+		},
+		{
+			name:    "ignores html entities",
+			caption: "&#39; quoted",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractHashtags(tt.caption); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractHashtags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("limits hashtags per post", func(t *testing.T) {
+		var caption strings.Builder
+		for i := 0; i < 40; i++ {
+			caption.WriteString(" #tag" + strings.Repeat("a", i+1))
+		}
+		if got := ExtractHashtags(caption.String()); len(got) != maxHashtagsPerPost {
+			t.Errorf("Expected %d hashtags, got %d", maxHashtagsPerPost, len(got))
+		}
+	})
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   string
+		wantOK bool
+	}{
+		{"#Travel", "travel", true},
+		{"travel_2024", "travel_2024", true},
+		{"", "", false},
+		{"#", "", false},
+		{"123", "", false},
+		{"two words", "", false},
+		{strings.Repeat("a", 101), "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeHashtag(tt.tag)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeHashtag(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
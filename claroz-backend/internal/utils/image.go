@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+)
+
+// ReadImageInfo identifies an uploaded image by its content and reads its
+// dimensions without decoding the pixels. The client's Content-Type and file
+// name are ignored. It returns an error wrapping imaging.ErrInvalidImage if
+// the file is not an image in a supported format.
+func ReadImageInfo(file *multipart.FileHeader) (*imaging.Info, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	return imaging.Inspect(data)
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+// ErrFileTooLarge is returned for uploads over the maximum file size
+var ErrFileTooLarge = errors.New("file is too large")
+
+// BlobRegistry records the blobs stored files are kept as. It is implemented
+// by *repository.MediaRepository.
+type BlobRegistry interface {
+	RegisterBlob(blob *models.MediaBlob) error
+}
+
+// ImageStorage processes uploaded images and stores their variants
+type ImageStorage struct {
+	files       FileStorageInterface
+	blobs       BlobRegistry
+	images      *imaging.Pipeline
+	maxFileSize int64
+}
+
+// NewImageStorage creates an ImageStorage keeping variants in files and
+// registering them in blobs. Uploads are processed by the pipeline, and are
+// rejected over maxFileSize bytes.
+func NewImageStorage(files FileStorageInterface, blobs BlobRegistry, images *imaging.Pipeline, maxFileSize int64) *ImageStorage {
+	return &ImageStorage{files: files, blobs: blobs, images: images, maxFileSize: maxFileSize}
+}
+
+// StoredImage is an uploaded image, stored as its processed variants
+type StoredImage struct {
+	URL      string // the full-size variant
+	Width    int    // of the full-size variant
+	Height   int    // of the full-size variant
+	MimeType string // of the full-size variant
+	Variants models.ImageVariants
+}
+
+// Media returns the image as pending media uploaded by a user, expiring at
+// expiresAt unless it is attached
+func (i *StoredImage) Media(userID uuid.UUID, expiresAt time.Time) *models.Media {
+	return &models.Media{
+		UserID:    userID,
+		URL:       i.URL,
+		Width:     i.Width,
+		Height:    i.Height,
+		MimeType:  i.MimeType,
+		Variants:  i.Variants,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// SaveImage processes an image and stores its variants. The original upload,
+// with its metadata, is not kept. Uploads are identified by their content,
+// and each variant is stored under the SHA-256 of its encoding, with the
+// extension of its format, so identical uploads share their files. Variants
+// start unreferenced, and are deleted by the garbage collector unless media
+// refer to them within its grace period, including those stored before a
+// later one fails. It returns an error wrapping ErrFileTooLarge if the upload
+// is over the maximum file size, imaging.ErrInvalidImage if it is not an
+// image, or imaging.ErrImageTooLarge if it exceeds the pixel limits.
+func (s *ImageStorage) SaveImage(ctx context.Context, r io.Reader) (*StoredImage, error) {
+	return s.save(ctx, r, s.images.Process)
+}
+
+// SaveAvatar stores an image like SaveImage, as square avatar variants
+func (s *ImageStorage) SaveAvatar(ctx context.Context, r io.Reader) (*StoredImage, error) {
+	return s.save(ctx, r, s.images.ProcessAvatar)
+}
+
+// SaveStoredAvatar stores an image that is already stored, such as the
+// full-size variant of uploaded media at url, as avatar variants like
+// SaveAvatar
+func (s *ImageStorage) SaveStoredAvatar(ctx context.Context, url string) (*StoredImage, error) {
+	r, err := s.files.Open(ctx, models.BlobKey(url))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer r.Close()
+	return s.save(ctx, r, s.images.ProcessAvatar)
+}
+
+// SaveBanner stores an image like SaveImage, as wide profile banner variants
+func (s *ImageStorage) SaveBanner(ctx context.Context, r io.Reader) (*StoredImage, error) {
+	return s.save(ctx, r, s.images.ProcessBanner)
+}
+
+// save reads an upload, generates its variants with process and stores them
+func (s *ImageStorage) save(ctx context.Context, r io.Reader, process func([]byte) (*imaging.Result, error)) (*StoredImage, error) {
+	// Read a byte past the limit to tell uploads at the limit from larger ones
+	data, err := io.ReadAll(io.LimitReader(r, s.maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > s.maxFileSize {
+		return nil, fmt.Errorf("%w: maximum is %d bytes", ErrFileTooLarge, s.maxFileSize)
+	}
+
+	result, err := process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := models.ImageVariants{}
+	for _, variant := range result.Variants {
+		url, err := s.saveBlob(ctx, variant.Data, variant.Ext, variant.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		variants[variant.Name] = models.ImageVariant{
+			URL:      url,
+			Width:    variant.Width,
+			Height:   variant.Height,
+			MimeType: variant.MimeType,
+		}
+	}
+
+	full := variants[models.VariantFull]
+	return &StoredImage{
+		URL:      full.URL,
+		Width:    full.Width,
+		Height:   full.Height,
+		MimeType: full.MimeType,
+		Variants: variants,
+	}, nil
+}
+
+// saveBlob stores a file under the SHA-256 of its content unless it is
+// already stored, returning its URL. The blob is registered first, which
+// keeps the collector from deleting a file that is being reused.
+func (s *ImageStorage) saveBlob(ctx context.Context, data []byte, ext, contentType string) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:]) + ext
+	if err := s.blobs.RegisterBlob(&models.MediaBlob{Key: key, Size: int64(len(data)), ContentType: contentType}); err != nil {
+		return "", fmt.Errorf("failed to register file: %w", err)
+	}
+
+	if ref, err := s.files.Stat(ctx, key); err == nil {
+		return ref.URL, nil
+	} else if !errors.Is(err, ErrObjectNotFound) {
+		return "", err
+	}
+	ref, err := s.files.Save(ctx, bytes.NewReader(data), ObjectMeta{Key: key, ContentType: contentType, Size: int64(len(data))})
+	if err != nil {
+		return "", err
+	}
+	return ref.URL, nil
+}
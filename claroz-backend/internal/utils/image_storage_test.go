@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+)
+
+// failingStorage fails to save once it has saved failAfter files
+type failingStorage struct {
+	FileStorageInterface
+	failAfter int
+}
+
+func (s *failingStorage) Save(ctx context.Context, r io.Reader, meta ObjectMeta) (ObjectRef, error) {
+	if s.failAfter == 0 {
+		return ObjectRef{}, errors.New("disk full")
+	}
+	s.failAfter--
+	return s.FileStorageInterface.Save(ctx, r, meta)
+}
+
+// fakeRegistry records registered blobs by key
+type fakeRegistry struct {
+	blobs map[string]models.MediaBlob
+}
+
+func (r *fakeRegistry) RegisterBlob(blob *models.MediaBlob) error {
+	if r.blobs == nil {
+		r.blobs = map[string]models.MediaBlob{}
+	}
+	r.blobs[blob.Key] = *blob
+	return nil
+}
+
+func TestImageStorage_SaveImage(t *testing.T) {
+	ctx := context.Background()
+	images := imaging.NewPipeline(&config.ImageConfig{
+		Workers: 1, ThumbnailSize: 8, FeedSize: 16, FullSize: 32, JPEGQuality: 80, MaxEdge: 100, MaxPixels: 10000,
+		AvatarSize: 16, AvatarThumbnailSize: 4, BannerWidth: 30, BannerHeight: 10, BannerThumbnailWidth: 15,
+	})
+	images.Start()
+	defer images.Stop()
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+
+	newStorage := func(t *testing.T) (*LocalStorage, string) {
+		dir := t.TempDir()
+		files, err := NewLocalStorage(&config.StorageConfig{LocalPath: dir})
+		if err != nil {
+			t.Fatalf("NewLocalStorage() error = %v", err)
+		}
+		return files, dir
+	}
+
+	t.Run("variants are stored by content", func(t *testing.T) {
+		files, dir := newStorage(t)
+		registry := &fakeRegistry{}
+		storage := NewImageStorage(files, registry, images, 1<<20)
+
+		stored, err := storage.SaveImage(ctx, bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		if len(stored.Variants) != 3 || stored.URL != stored.Variants[models.VariantFull].URL || stored.Width != 32 || stored.Height != 16 {
+			t.Fatalf("Expected 3 variants with the full size described, got %+v", stored)
+		}
+		for name, variant := range stored.Variants {
+			key, _ := KeyOf(variant.URL)
+			ref, err := files.Stat(ctx, key)
+			if err != nil {
+				t.Fatalf("Expected the %s variant to be stored, got %v", name, err)
+			}
+			blob, ok := registry.blobs[key]
+			if !ok || blob.Size != ref.Size || blob.ContentType != variant.MimeType || blob.RefCount != 0 {
+				t.Errorf("Expected the %s variant to be registered unreferenced, got %+v", name, blob)
+			}
+		}
+
+		// Identical uploads share their files
+		again, err := storage.SaveImage(ctx, bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		for name, variant := range again.Variants {
+			if variant.URL != stored.Variants[name].URL {
+				t.Errorf("Expected the %s variant to be reused, got %s and %s", name, stored.Variants[name].URL, variant.URL)
+			}
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 3 || len(registry.blobs) != 3 {
+			t.Errorf("Expected 3 files, got %d stored and %d registered", len(entries), len(registry.blobs))
+		}
+	})
+
+	t.Run("profile images are cropped", func(t *testing.T) {
+		files, _ := newStorage(t)
+		registry := &fakeRegistry{}
+		storage := NewImageStorage(files, registry, images, 1<<20)
+
+		avatar, err := storage.SaveAvatar(ctx, bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("SaveAvatar() error = %v", err)
+		}
+		if avatar.Width != 16 || avatar.Height != 16 || avatar.Variants[models.VariantThumbnail].Width != 4 || len(avatar.Variants) != 2 {
+			t.Errorf("Expected square avatar variants, got %+v", avatar)
+		}
+		banner, err := storage.SaveBanner(ctx, bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("SaveBanner() error = %v", err)
+		}
+		if banner.Width != 30 || banner.Height != 10 || banner.Variants[models.VariantThumbnail].Height != 5 || len(banner.Variants) != 2 {
+			t.Errorf("Expected wide banner variants, got %+v", banner)
+		}
+		if len(registry.blobs) != 4 {
+			t.Errorf("Expected 4 registered files, got %d", len(registry.blobs))
+		}
+
+		// Uploaded media become avatars from their stored full-size variant
+		stored, err := storage.SaveImage(ctx, bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		fromMedia, err := storage.SaveStoredAvatar(ctx, stored.URL)
+		if err != nil {
+			t.Fatalf("SaveStoredAvatar() error = %v", err)
+		}
+		if fromMedia.Width != fromMedia.Height || fromMedia.Variants[models.VariantThumbnail].Width != 4 || len(fromMedia.Variants) != 2 {
+			t.Errorf("Expected square avatar variants, got %+v", fromMedia)
+		}
+		if _, err := storage.SaveStoredAvatar(ctx, "/uploads/missing.jpg"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Expected a missing image to be reported, got %v", err)
+		}
+		if _, err := storage.SaveAvatar(ctx, bytes.NewReader([]byte("<html></html>"))); !errors.Is(err, imaging.ErrInvalidImage) {
+			t.Errorf("Expected an invalid avatar to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("rejected uploads", func(t *testing.T) {
+		files, _ := newStorage(t)
+		tests := []struct {
+			name        string
+			maxFileSize int64
+			data        []byte
+			want        error
+		}{
+			{"over the maximum file size", int64(encoded.Len() - 1), encoded.Bytes(), ErrFileTooLarge},
+			{"not an image", 1 << 20, []byte("<html></html>"), imaging.ErrInvalidImage},
+		}
+		for _, tt := range tests {
+			registry := &fakeRegistry{}
+			storage := NewImageStorage(files, registry, images, tt.maxFileSize)
+			if _, err := storage.SaveImage(ctx, bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+			}
+			if len(registry.blobs) != 0 {
+				t.Errorf("%s: expected nothing to be registered, got %v", tt.name, registry.blobs)
+			}
+		}
+
+		// Uploads exactly at the limit are accepted
+		storage := NewImageStorage(files, &fakeRegistry{}, images, int64(encoded.Len()))
+		if _, err := storage.SaveImage(ctx, bytes.NewReader(encoded.Bytes())); err != nil {
+			t.Errorf("Expected an upload at the limit to be stored, got %v", err)
+		}
+	})
+
+	t.Run("stored variants are left to the collector when one fails", func(t *testing.T) {
+		files, dir := newStorage(t)
+		registry := &fakeRegistry{}
+		storage := NewImageStorage(&failingStorage{FileStorageInterface: files, failAfter: 2}, registry, images, 1<<20)
+
+		if _, err := storage.SaveImage(ctx, bytes.NewReader(encoded.Bytes())); err == nil {
+			t.Fatal("Expected an error")
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if _, ok := registry.blobs[entry.Name()]; !ok {
+				t.Errorf("Expected %s to be registered for collection", entry.Name())
+			}
+		}
+		if len(entries) != 2 {
+			t.Errorf("Expected the 2 stored variants to be left, got %v", entries)
+		}
+	})
+}
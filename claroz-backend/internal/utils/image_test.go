@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/imaging"
+)
+
+// uploadedFile returns the header of a file uploaded in a multipart form
+func uploadedFile(t *testing.T, contentType string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="image"; filename="upload"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("Failed to read form: %v", err)
+	}
+	return form.File["image"][0]
+}
+
+func TestReadImageInfo(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+
+	// The client's Content-Type is ignored in favour of the content
+	info, err := ReadImageInfo(uploadedFile(t, "application/octet-stream", encoded.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadImageInfo() error = %v", err)
+	}
+	if *info != (imaging.Info{Width: 3, Height: 2, MimeType: "image/png"}) {
+		t.Errorf("Expected a 3x2 PNG, got %+v", info)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{"html labelled as an image", "image/png", []byte("<html><script>alert(1)</script></html>")},
+		{"not an image", "image/png", []byte("test image content")},
+		{"truncated", "image/png", encoded.Bytes()[:10]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadImageInfo(uploadedFile(t, tt.contentType, tt.data)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
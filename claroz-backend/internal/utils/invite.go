@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// inviteAlphabet omits characters that are easily confused (0/O, 1/I/L)
+const inviteAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+const inviteCodeLength = 10
+
+// GenerateInviteCode returns a random, human-friendly invite code in upper
+// case. Each character is drawn uniformly from the alphabet.
+func GenerateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate invite code: %w", err)
+		}
+		buf[i] = inviteAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
@@ -1,9 +1,19 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// HashPassword hashes a password with bcrypt at the default cost
 func HashPassword(password string) (string, error) {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -12,6 +22,144 @@ func HashPassword(password string) (string, error) {
 	return string(hashedBytes), nil
 }
 
+// ComparePasswords checks a password against a bcrypt hash
 func ComparePasswords(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownHashFormat   = errors.New("unknown password hash format")
+	ErrInvalidArgon2idHash = errors.New("invalid argon2id hash")
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	argon2idPrefix   = "$argon2id$"
+)
+
+// PasswordHasher hashes passwords with the configured algorithm and verifies
+// both argon2id and bcrypt hashes, so accounts created before argon2id was
+// introduced keep working and can be upgraded on their next login.
+type PasswordHasher struct {
+	config *config.PasswordConfig
+}
+
+// NewPasswordHasher creates a new PasswordHasher instance
+func NewPasswordHasher(cfg *config.PasswordConfig) *PasswordHasher {
+	return &PasswordHasher{config: cfg}
+}
+
+// Hash hashes a password with the configured algorithm
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch h.config.Algorithm {
+	case config.HashArgon2id:
+		return h.hashArgon2id(password)
+	case config.HashBcrypt:
+		hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashedBytes), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm: %s", h.config.Algorithm)
+	}
+}
+
+// Verify checks a password against a stored hash. needsRehash reports whether
+// the hash was produced by another algorithm or weaker parameters than the
+// current configuration and should be replaced.
+func (h *PasswordHasher) Verify(encoded, password string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		return h.config.Algorithm != config.HashArgon2id ||
+			params.memory < h.config.Argon2Memory ||
+			params.iterations < h.config.Argon2Iterations ||
+			params.parallelism < h.config.Argon2Parallelism, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrPasswordMismatch
+			}
+			return false, err
+		}
+		if h.config.Algorithm != config.HashBcrypt {
+			return true, nil
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, err
+		}
+		return cost < h.config.BcryptCost, nil
+
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// hashArgon2id encodes the hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (h *PasswordHasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.config.Argon2Iterations, h.config.Argon2Memory, h.config.Argon2Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.config.Argon2Memory,
+		h.config.Argon2Iterations,
+		h.config.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(encoded string) (*argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, ErrInvalidArgon2idHash
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidArgon2idHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidArgon2idHash
+	}
+
+	return params, salt, key, nil
+}
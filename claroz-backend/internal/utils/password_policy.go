@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach; choose a different one")
+	ErrPasswordSimilar  = errors.New("password is too similar to your username or email")
+)
+
+// BreachedPasswordChecker reports whether a password is known to be compromised
+type BreachedPasswordChecker interface {
+	IsBreached(password string) bool
+}
+
+// hashPrefixLength is the length of the SHA-1 prefix used to bucket hashes,
+// matching the range size used by the Pwned Passwords k-anonymity API
+const hashPrefixLength = 5
+
+// BreachedPasswordList is an offline list of breached password SHA-1 hashes.
+// Hashes are bucketed by prefix so a lookup only ever compares against the
+// suffixes sharing the candidate's prefix, mirroring the k-anonymity range
+// model without sending anything over the network.
+type BreachedPasswordList struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswordList reads a file with one upper- or lower-case hex
+// SHA-1 hash per line, optionally followed by ":COUNT" as in the Pwned
+// Passwords downloads. Blank lines and lines starting with # are ignored.
+func LoadBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachedPasswordList{ranges: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid hash on line %d of breached password list", lineNumber)
+		}
+		list.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return list, nil
+}
+
+func (l *BreachedPasswordList) add(hash string) {
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+	bucket, exists := l.ranges[prefix]
+	if !exists {
+		bucket = make(map[string]struct{})
+		l.ranges[prefix] = bucket
+	}
+	bucket[suffix] = struct{}{}
+}
+
+// IsBreached reports whether the password's SHA-1 hash is in the list
+func (l *BreachedPasswordList) IsBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	bucket, exists := l.ranges[hash[:hashPrefixLength]]
+	if !exists {
+		return false
+	}
+	_, found := bucket[hash[hashPrefixLength:]]
+	return found
+}
+
+// PasswordPolicy validates new passwords against the configured rules
+type PasswordPolicy struct {
+	config   *config.PasswordConfig
+	breached BreachedPasswordChecker
+}
+
+// NewPasswordPolicy creates a new PasswordPolicy. breached may be nil to skip the breach check.
+func NewPasswordPolicy(cfg *config.PasswordConfig, breached BreachedPasswordChecker) *PasswordPolicy {
+	return &PasswordPolicy{
+		config:   cfg,
+		breached: breached,
+	}
+}
+
+// Validate checks a password for an account with the given username and email
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.config.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, p.config.MinLength)
+	}
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPasswordTooLong, p.config.MaxLength)
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, identifier := range []string{username, localPart} {
+		if isSimilar(password, identifier) {
+			return ErrPasswordSimilar
+		}
+	}
+
+	if p.breached != nil && p.breached.IsBreached(password) {
+		return ErrPasswordBreached
+	}
+
+	return nil
+}
+
+// isSimilar reports whether a password contains the identifier (forwards or
+// reversed) or is within a small edit distance of it
+func isSimilar(password, identifier string) bool {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	if utf8.RuneCountInString(identifier) < 3 {
+		return false
+	}
+	password = strings.ToLower(password)
+
+	if strings.Contains(password, identifier) || strings.Contains(password, reverse(identifier)) {
+		return true
+	}
+
+	return levenshtein(password, identifier) <= 3
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
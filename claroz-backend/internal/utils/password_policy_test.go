@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+func TestLoadBreachedPasswordList(t *testing.T) {
+	// SHA-1 of "password" and "123456", in the Pwned Passwords download format
+	content := strings.Join([]string{
+		"# test list",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+		"7c4a8d09ca3762af61e59520943dc26494f8941b",
+		"",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test list: %v", err)
+	}
+
+	list, err := LoadBreachedPasswordList(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswordList() error = %v", err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "password", want: true},
+		{password: "123456", want: true},
+		{password: "Password", want: false},
+		{password: "correct-horse-battery", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := list.IsBreached(tt.password); got != tt.want {
+			t.Errorf("IsBreached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	t.Run("invalid line", func(t *testing.T) {
+		badPath := filepath.Join(t.TempDir(), "bad.txt")
+		os.WriteFile(badPath, []byte("not-a-hash\n"), 0644)
+		if _, err := LoadBreachedPasswordList(badPath); err == nil {
+			t.Error("LoadBreachedPasswordList() expected error for invalid hash")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadBreachedPasswordList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+			t.Error("LoadBreachedPasswordList() expected error for missing file")
+		}
+	})
+}
+
+type fakeBreachedList map[string]bool
+
+func (l fakeBreachedList) IsBreached(password string) bool {
+	return l[password]
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	cfg := config.NewConfig().Password
+	policy := NewPasswordPolicy(&cfg, fakeBreachedList{"iloveyou2024": true})
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		wantErr  error
+	}{
+		{
+			name:     "valid password",
+			password: "correct-horse-battery",
+			username: "johndoe",
+			email:    "john@example.com",
+		},
+		{
+			name:     "too short",
+			password: "abc12",
+			username: "johndoe",
+			email:    "john@example.com",
+			wantErr:  ErrPasswordTooShort,
+		},
+		{
+			name:     "too long",
+			password: strings.Repeat("x", cfg.MaxLength+1),
+			username: "johndoe",
+			email:    "john@example.com",
+			wantErr:  ErrPasswordTooLong,
+		},
+		{
+			name:     "breached",
+			password: "iloveyou2024",
+			username: "johndoe",
+			email:    "john@example.com",
+			wantErr:  ErrPasswordBreached,
+		},
+		{
+			name:     "contains username",
+			password: "JohnDoe1234",
+			username: "johndoe",
+			email:    "john@example.com",
+			wantErr:  ErrPasswordSimilar,
+		},
+		{
+			name:     "reversed username",
+			password: "eodnhoj!!",
+			username: "johndoe",
+			email:    "john@example.com",
+			wantErr:  ErrPasswordSimilar,
+		},
+		{
+			name:     "close to email local part",
+			password: "jonathan99",
+			username: "johndoe",
+			email:    "jonathan9@example.com",
+			wantErr:  ErrPasswordSimilar,
+		},
+		{
+			name:     "nil breach checker",
+			password: "iloveyou2024",
+			username: "johndoe",
+			email:    "john@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			if tt.name == "nil breach checker" {
+				p = NewPasswordPolicy(&cfg, nil)
+			}
+			err := p.Validate(tt.password, tt.username, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
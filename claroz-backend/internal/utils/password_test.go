@@ -3,6 +3,9 @@ package utils
 import (
 	"strings"
 	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -149,3 +152,131 @@ func TestPasswordRoundTrip(t *testing.T) {
 		t.Error("ComparePasswords() succeeded with wrong password")
 	}
 }
+
+func testPasswordConfig(algorithm string) *config.PasswordConfig {
+	cfg := config.NewConfig().Password
+	cfg.Algorithm = algorithm
+	// Keep argon2id cheap in tests
+	cfg.Argon2Memory = 8 * 1024
+	cfg.Argon2Iterations = 1
+	cfg.Argon2Parallelism = 1
+	cfg.BcryptCost = bcrypt.MinCost
+	return &cfg
+}
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	hasher := NewPasswordHasher(testPasswordConfig(config.HashArgon2id))
+
+	hash, err := hasher.Hash("mySecurePassword123")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("Hash() = %q, want argon2id PHC string", hash)
+	}
+
+	other, _ := hasher.Hash("mySecurePassword123")
+	if hash == other {
+		t.Error("Hash() should use a random salt")
+	}
+
+	needsRehash, err := hasher.Verify(hash, "mySecurePassword123")
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if needsRehash {
+		t.Error("Verify() reported rehash for a current hash")
+	}
+
+	if _, err := hasher.Verify(hash, "wrongPassword"); err != ErrPasswordMismatch {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+
+	// Passwords beyond bcrypt's 72 byte limit are supported
+	long := strings.Repeat("a", 100)
+	longHash, err := hasher.Hash(long)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if _, err := hasher.Verify(longHash, strings.Repeat("a", 99)); err != ErrPasswordMismatch {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+}
+
+func TestPasswordHasher_MigrateFromBcrypt(t *testing.T) {
+	hasher := NewPasswordHasher(testPasswordConfig(config.HashArgon2id))
+
+	// Existing hashes were produced by HashPassword with bcrypt
+	legacyHash, err := HashPassword("mySecurePassword123")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	needsRehash, err := hasher.Verify(legacyHash, "mySecurePassword123")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !needsRehash {
+		t.Error("Verify() should request a rehash for bcrypt hashes")
+	}
+
+	if _, err := hasher.Verify(legacyHash, "wrongPassword"); err != ErrPasswordMismatch {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+
+	upgraded, err := hasher.Hash("mySecurePassword123")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	needsRehash, err = hasher.Verify(upgraded, "mySecurePassword123")
+	if err != nil || needsRehash {
+		t.Errorf("Verify() of upgraded hash = (%v, %v), want (false, nil)", needsRehash, err)
+	}
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	weak := NewPasswordHasher(testPasswordConfig(config.HashArgon2id))
+	weakHash, _ := weak.Hash("mySecurePassword123")
+
+	strongerCfg := testPasswordConfig(config.HashArgon2id)
+	strongerCfg.Argon2Iterations = 2
+	stronger := NewPasswordHasher(strongerCfg)
+
+	needsRehash, err := stronger.Verify(weakHash, "mySecurePassword123")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !needsRehash {
+		t.Error("Verify() should request a rehash when parameters were raised")
+	}
+
+	bcryptHasher := NewPasswordHasher(testPasswordConfig(config.HashBcrypt))
+	bcryptHash, _ := bcryptHasher.Hash("mySecurePassword123")
+	needsRehash, err = bcryptHasher.Verify(bcryptHash, "mySecurePassword123")
+	if err != nil || needsRehash {
+		t.Errorf("Verify() = (%v, %v), want (false, nil) when bcrypt is configured", needsRehash, err)
+	}
+}
+
+func TestPasswordHasher_InvalidHashes(t *testing.T) {
+	hasher := NewPasswordHasher(testPasswordConfig(config.HashArgon2id))
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+	}{
+		{name: "empty hash", hash: "", wantErr: ErrUnknownHashFormat},
+		{name: "plaintext", hash: "password123", wantErr: ErrUnknownHashFormat},
+		{name: "truncated argon2id", hash: "$argon2id$v=19$m=8192,t=1,p=1$c2FsdA", wantErr: ErrInvalidArgon2idHash},
+		{name: "bad parameters", hash: "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5", wantErr: ErrInvalidArgon2idHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := hasher.Verify(tt.hash, "password123"); err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// testFileStorage is the conformance suite every FileStorageInterface
+// implementation must pass. newStorage returns a storage with nothing in it.
+func testFileStorage(t *testing.T, newStorage func(t *testing.T) FileStorageInterface) {
+	ctx := context.Background()
+
+	// read returns the contents of a stored file
+	read := func(t *testing.T, storage FileStorageInterface, key string) []byte {
+		t.Helper()
+		file, err := storage.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open(%q) error = %v", key, err)
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			t.Fatalf("Failed to read %q: %v", key, err)
+		}
+		return data
+	}
+
+	t.Run("save, stat and open", func(t *testing.T) {
+		storage := newStorage(t)
+		ref, err := storage.Save(ctx, strings.NewReader("jpeg data"), ObjectMeta{Key: "photo.jpg", ContentType: "image/jpeg", Size: 9})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if ref.Key != "photo.jpg" || ref.Size != 9 || ref.ContentType != "image/jpeg" || ref.URL == "" {
+			t.Errorf("Expected a reference to the stored file, got %+v", ref)
+		}
+
+		stat, err := storage.Stat(ctx, "photo.jpg")
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		if stat != ref {
+			t.Errorf("Expected Stat() to match Save(), got %+v and %+v", stat, ref)
+		}
+		if data := read(t, storage, "photo.jpg"); string(data) != "jpeg data" {
+			t.Errorf("Expected the saved contents, got %q", data)
+		}
+		if url, err := storage.URL(ctx, "photo.jpg"); err != nil || url == "" {
+			t.Errorf("Expected a URL, got %q and %v", url, err)
+		}
+	})
+
+	t.Run("files of unknown size are streamed", func(t *testing.T) {
+		storage := newStorage(t)
+		data := bytes.Repeat([]byte("0123456789abcdef"), 6*1024*1024/16)
+		// Hide the reader's length, as a network stream would
+		r := struct{ io.Reader }{bytes.NewReader(data)}
+		ref, err := storage.Save(ctx, r, ObjectMeta{Key: "stream.webp", ContentType: "image/webp", Size: -1})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if ref.Size != int64(len(data)) {
+			t.Errorf("Expected size %d, got %d", len(data), ref.Size)
+		}
+		if stored := read(t, storage, "stream.webp"); !bytes.Equal(stored, data) {
+			t.Errorf("Expected the streamed contents, got %d of %d bytes", len(stored), len(data))
+		}
+	})
+
+	t.Run("saving again replaces the file", func(t *testing.T) {
+		storage := newStorage(t)
+		for _, content := range []string{"first", "second"} {
+			if _, err := storage.Save(ctx, strings.NewReader(content), ObjectMeta{Key: "photo.jpg", ContentType: "image/jpeg", Size: int64(len(content))}); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+		}
+		if data := read(t, storage, "photo.jpg"); string(data) != "second" {
+			t.Errorf("Expected the latest contents, got %q", data)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		storage := newStorage(t)
+		if _, err := storage.Save(ctx, strings.NewReader("data"), ObjectMeta{Key: "photo.jpg", ContentType: "image/jpeg", Size: 4}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if err := storage.Delete(ctx, "photo.jpg"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := storage.Stat(ctx, "photo.jpg"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Expected ErrObjectNotFound after deleting, got %v", err)
+		}
+		if err := storage.Delete(ctx, "photo.jpg"); err != nil {
+			t.Errorf("Expected deleting a missing file to succeed, got %v", err)
+		}
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		storage := newStorage(t)
+		if _, err := storage.Open(ctx, "missing.jpg"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Expected Open() to return ErrObjectNotFound, got %v", err)
+		}
+		if _, err := storage.Stat(ctx, "missing.jpg"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Expected Stat() to return ErrObjectNotFound, got %v", err)
+		}
+	})
+
+	t.Run("failed saves store nothing", func(t *testing.T) {
+		storage := newStorage(t)
+		broken := io.MultiReader(strings.NewReader("partial data"), iotest.ErrReader(errors.New("connection reset")))
+		if _, err := storage.Save(ctx, broken, ObjectMeta{Key: "broken.jpg", ContentType: "image/jpeg", Size: -1}); err == nil {
+			t.Error("Expected a failed read to fail the save")
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := storage.Save(cancelled, strings.NewReader("data"), ObjectMeta{Key: "cancelled.jpg", ContentType: "image/jpeg", Size: 4}); err == nil {
+			t.Error("Expected a cancelled context to fail the save")
+		}
+
+		for _, key := range []string{"broken.jpg", "cancelled.jpg"} {
+			if _, err := storage.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+				t.Errorf("Expected nothing stored under %s, got %v", key, err)
+			}
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		storage := newStorage(t)
+		for _, key := range []string{"", ".", "..", "../escape.jpg", "nested/photo.jpg", `nested\photo.jpg`} {
+			if _, err := storage.Save(ctx, strings.NewReader("data"), ObjectMeta{Key: key, Size: 4}); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Save(%q): expected ErrInvalidKey, got %v", key, err)
+			}
+			if _, err := storage.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Open(%q): expected ErrInvalidKey, got %v", key, err)
+			}
+			if _, err := storage.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Stat(%q): expected ErrInvalidKey, got %v", key, err)
+			}
+			if err := storage.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Delete(%q): expected ErrInvalidKey, got %v", key, err)
+			}
+			if _, err := storage.URL(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("URL(%q): expected ErrInvalidKey, got %v", key, err)
+			}
+		}
+	})
+}
@@ -1,8 +1,62 @@
 package utils
 
-import "mime/multipart"
+import (
+	"context"
+	"errors"
+	"io"
+)
 
+var (
+	// ErrObjectNotFound is returned for keys nothing is stored under
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for keys that are empty or not a plain file name
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// ObjectMeta describes a file being stored
+type ObjectMeta struct {
+	Key         string // a plain file name, such as "<sha256>.jpg"
+	ContentType string
+	Size        int64 // in bytes, or -1 if unknown
+}
+
+// ObjectRef describes a stored file
+type ObjectRef struct {
+	Key         string
+	URL         string // the file's permanent URL, to be kept with whatever refers to it
+	Size        int64  // in bytes
+	ContentType string
+}
+
+// FileStorageInterface stores files by key. Implementations must pass the
+// conformance suite in storage_conformance_test.go.
 type FileStorageInterface interface {
-	SaveFile(file *multipart.FileHeader) (string, error)
-	DeleteFile(path string) error
+	// Save streams a file into storage under meta.Key, replacing any file
+	// already stored there. Nothing is stored if reading r or ctx fails.
+	Save(ctx context.Context, r io.Reader, meta ObjectMeta) (ObjectRef, error)
+	// Open returns the contents of the file stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat describes the file stored under key
+	Stat(ctx context.Context, key string) (ObjectRef, error)
+	// Delete removes the file stored under key. Deleting a file that is not
+	// stored is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns a URL clients can fetch the file stored under key from,
+	// which expires if the file is not publicly readable
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// ImageStorageInterface stores uploaded images as their processed variants.
+// Files are never deleted directly, since identical uploads share them; the
+// media garbage collector deletes those no longer referenced.
+type ImageStorageInterface interface {
+	SaveImage(ctx context.Context, r io.Reader) (*StoredImage, error)
+}
+
+// ProfileImageStorageInterface stores uploaded profile images, cropped to
+// their standard shapes, like ImageStorageInterface stores other images
+type ProfileImageStorageInterface interface {
+	SaveAvatar(ctx context.Context, r io.Reader) (*StoredImage, error)
+	SaveStoredAvatar(ctx context.Context, url string) (*StoredImage, error)
+	SaveBanner(ctx context.Context, r io.Reader) (*StoredImage, error)
 }
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+// LocalStorage keeps files in a directory served at /uploads
+type LocalStorage struct {
+	path string
+}
+
+// NewLocalStorage creates a LocalStorage in the configured directory,
+// creating it if it does not exist
+func NewLocalStorage(cfg *config.StorageConfig) (*LocalStorage, error) {
+	if err := os.MkdirAll(cfg.LocalPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &LocalStorage{path: cfg.LocalPath}, nil
+}
+
+// Save writes a file to a temporary file and renames it into place, so a
+// file is never seen half written and nothing is left behind on failure
+func (s *LocalStorage) Save(ctx context.Context, r io.Reader, meta ObjectMeta) (ObjectRef, error) {
+	if err := validateKey(meta.Key); err != nil {
+		return ObjectRef{}, err
+	}
+
+	tmp, err := os.CreateTemp(s.path, ".upload-*")
+	if err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.path, meta.Key)); err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return ObjectRef{Key: meta.Key, URL: uploadsPath(meta.Key), Size: size, ContentType: contentTypeOf(meta.Key)}, nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(s.path, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectRef, error) {
+	if err := validateKey(key); err != nil {
+		return ObjectRef{}, err
+	}
+	info, err := os.Stat(filepath.Join(s.path, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectRef{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return ObjectRef{Key: key, URL: uploadsPath(key), Size: info.Size(), ContentType: contentTypeOf(key)}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.path, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// URL returns the file's permanent URL, since local files are served publicly
+func (s *LocalStorage) URL(ctx context.Context, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return uploadsPath(key), nil
+}
+
+// uploadsPath returns the path a file is served at under /uploads
+func uploadsPath(key string) string {
+	return fmt.Sprintf("/uploads/%s", key)
+}
+
+// contentTypeOf returns the content type of a local file from its extension,
+// since local storage keeps no metadata
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
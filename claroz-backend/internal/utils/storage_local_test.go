@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+func TestLocalStorage(t *testing.T) {
+	testFileStorage(t, func(t *testing.T) FileStorageInterface {
+		storage, err := NewLocalStorage(&config.StorageConfig{Provider: "local", LocalPath: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewLocalStorage() error = %v", err)
+		}
+		return storage
+	})
+}
+
+func TestLocalStorage_Files(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewLocalStorage(&config.StorageConfig{Provider: "local", LocalPath: dir})
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+
+	ref, err := storage.Save(context.Background(), strings.NewReader("data"), ObjectMeta{Key: "photo.jpg", ContentType: "image/jpeg", Size: 4})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if ref.URL != "/uploads/photo.jpg" {
+		t.Errorf("Expected the file served from /uploads, got %s", ref.URL)
+	}
+
+	// Only the file itself is left, readable by the web server
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "photo.jpg" {
+		t.Fatalf("Expected only photo.jpg in the directory, got %v", entries)
+	}
+	if info, _ := entries[0].Info(); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage keeps files in a bucket of AWS S3 or an S3-compatible service
+// such as MinIO. Files are served from the bucket's public URL if it has one,
+// and otherwise from /uploads, which redirects to presigned URLs.
+type S3Storage struct {
+	client        *minio.Client
+	bucket        string
+	publicURL     string
+	presignExpiry time.Duration
+	partSize      uint64
+}
+
+// NewS3Storage creates an S3Storage for the configured bucket
+func NewS3Storage(cfg *config.StorageConfig) (*S3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+
+	endpoint, secure := "s3.amazonaws.com", true
+	if cfg.S3Endpoint != "" {
+		u, err := url.Parse(cfg.S3Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid S3 endpoint: %s", cfg.S3Endpoint)
+		}
+		endpoint, secure = u.Host, u.Scheme == "https"
+	}
+
+	// Credentials in the config take precedence over those in the environment
+	creds := credentials.NewStaticV4(cfg.S3AccessKeyID, cfg.S3SecretAccessKey, "")
+	if cfg.S3AccessKeyID == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+
+	lookup := minio.BucketLookupDNS
+	if cfg.S3PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       cfg.S3Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3Storage{
+		client:        client,
+		bucket:        cfg.S3Bucket,
+		publicURL:     strings.TrimSuffix(cfg.S3PublicURL, "/"),
+		presignExpiry: time.Duration(cfg.S3PresignExpiryMins) * time.Minute,
+		partSize:      cfg.S3PartSize,
+	}, nil
+}
+
+// Save streams a file to the bucket, in parts of partSize if it is larger or
+// its size is unknown
+func (s *S3Storage) Save(ctx context.Context, r io.Reader, meta ObjectMeta) (ObjectRef, error) {
+	if err := validateKey(meta.Key); err != nil {
+		return ObjectRef{}, err
+	}
+
+	info, err := s.client.PutObject(ctx, s.bucket, meta.Key, r, meta.Size, minio.PutObjectOptions{
+		ContentType: meta.ContentType,
+		// Keys are hashes of their content, so a stored file never changes
+		CacheControl: "public, max-age=31536000, immutable",
+		PartSize:     s.partSize,
+	})
+	if err != nil {
+		return ObjectRef{}, fmt.Errorf("failed to upload file: %w", err)
+	}
+	return ObjectRef{Key: meta.Key, URL: s.permanentURL(meta.Key), Size: info.Size, ContentType: meta.ContentType}, nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.objectError(err, key)
+	}
+	// GetObject is lazy; stat the object so a missing key fails here
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s.objectError(err, key)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (ObjectRef, error) {
+	if err := validateKey(key); err != nil {
+		return ObjectRef{}, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectRef{}, s.objectError(err, key)
+	}
+	return ObjectRef{Key: key, URL: s.permanentURL(key), Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// URL returns the file's public URL, or a presigned GET URL if the bucket is
+// private
+func (s *S3Storage) URL(ctx context.Context, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	if s.publicURL != "" {
+		return s.permanentURL(key), nil
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.presignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// permanentURL returns the URL a file is served at for good: its public URL,
+// or the /uploads path that redirects to a presigned URL
+func (s *S3Storage) permanentURL(key string) string {
+	if s.publicURL != "" {
+		return s.publicURL + "/" + key
+	}
+	return uploadsPath(key)
+}
+
+// objectError wraps an error from reading an object, as ErrObjectNotFound if
+// the object does not exist
+func (s *S3Storage) objectError(err error, key string) error {
+	if response := minio.ToErrorResponse(err); response.Code == "NoSuchKey" || response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return fmt.Errorf("failed to read file: %w", err)
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+// fakeS3 is a stand-in for an S3-compatible service addressed path-style,
+// supporting the calls the storage backend makes
+type fakeS3 struct {
+	mu         sync.Mutex
+	objects    map[string][]byte // by bucket/key
+	types      map[string]string // content types, by bucket/key or upload ID
+	parts      map[string]map[int][]byte
+	multiparts int    // multipart uploads completed
+	authKey    string // access key ID of the last request
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	s3 := &fakeS3{
+		objects: map[string][]byte{},
+		types:   map[string]string{},
+		parts:   map[string]map[int][]byte{},
+	}
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+	return s3, server
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Authorization: AWS4-HMAC-SHA256 Credential=<key>/<date>/<region>/s3/aws4_request, ...
+	if _, credential, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+		s.authKey, _, _ = strings.Cut(credential, "/")
+	}
+
+	object := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(s.parts)+1)
+		s.parts[uploadID] = map[int][]byte{}
+		s.types[uploadID] = r.Header.Get("Content-Type")
+		bucket, key, _ := strings.Cut(object, "/")
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, key, uploadID)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data := readBody(r)
+		s.parts[query.Get("uploadId")][number] = data
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		uploadID := query.Get("uploadId")
+		parts := s.parts[uploadID]
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, number := range numbers {
+			data = append(data, parts[number]...)
+		}
+		s.objects[object] = data
+		s.types[object] = s.types[uploadID]
+		delete(s.parts, uploadID)
+		s.multiparts++
+		bucket, key, _ := strings.Cut(object, "/")
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`, bucket, key)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.parts, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		s.objects[object] = readBody(r)
+		s.types[object] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := s.objects[object]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>%s</Key></Error>`, object)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", s.types[object])
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(s.objects, object)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// readBody reads a request body, decoding it if it was sent in signed chunks
+func readBody(r *http.Request) []byte {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, _ := io.ReadAll(r.Body)
+		return data
+	}
+
+	// Each chunk is "<hex size>;chunk-signature=<signature>\r\n<data>\r\n"
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return data
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 {
+			return data
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return data
+		}
+		data = append(data, chunk...)
+		reader.Discard(2)
+	}
+}
+
+func (s *fakeS3) object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+func s3Config(endpoint string) *config.StorageConfig {
+	return &config.StorageConfig{
+		Provider:            "s3",
+		S3Bucket:            "media",
+		S3Region:            "us-east-1",
+		MaxFileSize:         1 << 20,
+		S3Endpoint:          endpoint,
+		S3PathStyle:         true,
+		S3AccessKeyID:       "config-key",
+		S3SecretAccessKey:   "config-secret",
+		S3PresignExpiryMins: 60,
+		S3PartSize:          5 * 1024 * 1024,
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	testFileStorage(t, func(t *testing.T) FileStorageInterface {
+		_, server := newFakeS3(t)
+		storage, err := NewS3Storage(s3Config(server.URL))
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+		return storage
+	})
+}
+
+func TestS3Storage_Bucket(t *testing.T) {
+	fake, server := newFakeS3(t)
+	ctx := context.Background()
+
+	t.Run("objects are stored path-style with their content type", func(t *testing.T) {
+		storage, err := NewS3Storage(s3Config(server.URL))
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+
+		ref, err := storage.Save(ctx, strings.NewReader("jpeg data"), ObjectMeta{Key: "photo.jpg", ContentType: "image/jpeg", Size: 9})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if ref.URL != "/uploads/photo.jpg" {
+			t.Errorf("Expected a private object to be served from /uploads, got %s", ref.URL)
+		}
+		if data, ok := fake.object("media/photo.jpg"); !ok || string(data) != "jpeg data" {
+			t.Errorf("Expected the object in the bucket, got %q", data)
+		}
+		if fake.types["media/photo.jpg"] != "image/jpeg" {
+			t.Errorf("Expected content type image/jpeg, got %s", fake.types["media/photo.jpg"])
+		}
+		if fake.authKey != "config-key" {
+			t.Errorf("Expected the request signed with the configured key, got %q", fake.authKey)
+		}
+	})
+
+	t.Run("large files are uploaded in parts", func(t *testing.T) {
+		storage, err := NewS3Storage(s3Config(server.URL))
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+
+		multiparts := fake.multiparts
+		data := bytes.Repeat([]byte("0123456789abcdef"), 11*1024*1024/16)
+		if _, err := storage.Save(ctx, bytes.NewReader(data), ObjectMeta{Key: "large.jpg", ContentType: "image/jpeg", Size: int64(len(data))}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if fake.multiparts != multiparts+1 {
+			t.Errorf("Expected a multipart upload, got %d", fake.multiparts-multiparts)
+		}
+		if stored, _ := fake.object("media/large.jpg"); !bytes.Equal(stored, data) {
+			t.Errorf("Expected the parts to add up to the file, got %d of %d bytes", len(stored), len(data))
+		}
+	})
+
+	t.Run("credentials from the environment", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+		cfg := s3Config(server.URL)
+		cfg.S3AccessKeyID, cfg.S3SecretAccessKey = "", ""
+		storage, err := NewS3Storage(cfg)
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+
+		if _, err := storage.Save(ctx, strings.NewReader("data"), ObjectMeta{Key: "env.jpg", Size: 4}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if fake.authKey != "env-key" {
+			t.Errorf("Expected the request signed with the environment's key, got %q", fake.authKey)
+		}
+	})
+
+	t.Run("public bucket", func(t *testing.T) {
+		cfg := s3Config(server.URL)
+		cfg.S3PublicURL = "https://cdn.example.com/media/"
+		storage, err := NewS3Storage(cfg)
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+
+		ref, err := storage.Save(ctx, strings.NewReader("data"), ObjectMeta{Key: "public.jpg", Size: 4})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if ref.URL != "https://cdn.example.com/media/public.jpg" {
+			t.Errorf("Expected the public URL, got %s", ref.URL)
+		}
+		if url, _ := storage.URL(ctx, "public.jpg"); url != ref.URL {
+			t.Errorf("Expected public files not to be presigned, got %s", url)
+		}
+	})
+
+	t.Run("presigned URLs for private buckets", func(t *testing.T) {
+		storage, err := NewS3Storage(s3Config(server.URL))
+		if err != nil {
+			t.Fatalf("NewS3Storage() error = %v", err)
+		}
+
+		signed, err := storage.URL(ctx, "photo.jpg")
+		if err != nil {
+			t.Fatalf("URL() error = %v", err)
+		}
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatalf("Invalid presigned URL %s: %v", signed, err)
+		}
+		if u.Host != strings.TrimPrefix(server.URL, "http://") || u.Path != "/media/photo.jpg" {
+			t.Errorf("Expected a path-style URL for the object, got %s", signed)
+		}
+		if u.Query().Get("X-Amz-Signature") == "" || u.Query().Get("X-Amz-Expires") != "3600" {
+			t.Errorf("Expected a signature valid for an hour, got %s", signed)
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		for _, cfg := range []*config.StorageConfig{
+			{Provider: "s3", S3Endpoint: server.URL},
+			{Provider: "s3", S3Bucket: "media", S3Endpoint: "localhost:9000"},
+			{Provider: "s3", S3Bucket: "media", S3Endpoint: "ftp://localhost:9000"},
+		} {
+			if _, err := NewS3Storage(cfg); err == nil {
+				t.Errorf("Expected an error for %+v", cfg)
+			}
+		}
+	})
+}
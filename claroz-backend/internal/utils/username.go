@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+)
+
+var (
+	ErrUsernameLength   = errors.New("username must be between 3 and 30 characters")
+	ErrUsernameFormat   = errors.New("username may only contain letters, numbers and underscores")
+	ErrUsernameReserved = errors.New("username is reserved")
+)
+
+// ValidateUsername checks a username's length, allowed characters and the reserved name list.
+// Reserved names are compared case-insensitively.
+func ValidateUsername(username string, reserved []string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return ErrUsernameLength
+	}
+	if !usernamePattern.MatchString(username) {
+		return ErrUsernameFormat
+	}
+	if strings.HasPrefix(username, "_") || strings.HasSuffix(username, "_") {
+		return ErrUsernameFormat
+	}
+	for _, name := range reserved {
+		if strings.EqualFold(username, name) {
+			return ErrUsernameReserved
+		}
+	}
+	return nil
+}
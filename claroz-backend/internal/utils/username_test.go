@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	reserved := []string{"admin", "support"}
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  error
+	}{
+		{
+			name:     "valid username",
+			username: "john_doe42",
+			wantErr:  nil,
+		},
+		{
+			name:     "too short",
+			username: "jo",
+			wantErr:  ErrUsernameLength,
+		},
+		{
+			name:     "too long",
+			username: strings.Repeat("a", 31),
+			wantErr:  ErrUsernameLength,
+		},
+		{
+			name:     "invalid characters",
+			username: "john.doe",
+			wantErr:  ErrUsernameFormat,
+		},
+		{
+			name:     "leading underscore",
+			username: "_johndoe",
+			wantErr:  ErrUsernameFormat,
+		},
+		{
+			name:     "reserved name",
+			username: "admin",
+			wantErr:  ErrUsernameReserved,
+		},
+		{
+			name:     "reserved name with different case",
+			username: "Support",
+			wantErr:  ErrUsernameReserved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUsername(tt.username, reserved)
+			if err != tt.wantErr {
+				t.Errorf("ValidateUsername(%q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateInviteCode(t *testing.T) {
+	code, err := GenerateInviteCode()
+	if err != nil {
+		t.Fatalf("GenerateInviteCode() error = %v", err)
+	}
+	if len(code) != inviteCodeLength {
+		t.Errorf("GenerateInviteCode() length = %d, want %d", len(code), inviteCodeLength)
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(inviteAlphabet, r) {
+			t.Errorf("GenerateInviteCode() contains unexpected character %q", r)
+		}
+	}
+
+	other, _ := GenerateInviteCode()
+	if code == other {
+		t.Error("GenerateInviteCode() returned the same code twice")
+	}
+}
@@ -0,0 +1,270 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+// Rendition names
+const (
+	RenditionSource = "source" // the upload as received, kept until it is transcoded
+	RenditionPoster = "poster" // a JPEG frame to show before the video plays
+	RenditionMP4    = "mp4"    // H.264 and AAC in an MP4 file that can start playing while it downloads
+	RenditionHLS    = "hls"    // the master playlist of the HLS renditions
+)
+
+// Errors
+var (
+	// ErrInvalidVideo is returned for uploads ffprobe cannot read a video stream from
+	ErrInvalidVideo = errors.New("invalid video")
+	// ErrVideoTooLong is returned for videos over the maximum duration
+	ErrVideoTooLong = errors.New("video is too long")
+	// ErrVideoTooLarge is returned for videos over the maximum edge
+	ErrVideoTooLarge = errors.New("video dimensions are too large")
+)
+
+// Info describes a probed video
+type Info struct {
+	Width    int     // as displayed, after rotation
+	Height   int     // as displayed, after rotation
+	Duration float64 // in seconds
+	HasAudio bool
+}
+
+// File is a file a transcode wrote
+type File struct {
+	Name     string // a Rendition* name, or the name of an HLS rendition or its segments
+	Path     string
+	Width    int
+	Height   int
+	MimeType string
+	Ext      string // file extension, such as ".mp4"
+}
+
+// Stream is an HLS rendition: a media playlist and the single file its
+// segments are byte ranges of
+type Stream struct {
+	Playlist File
+	Segments File
+}
+
+// Output is the files a transcode wrote
+type Output struct {
+	Poster  File
+	MP4     File
+	Streams []Stream // smallest first
+}
+
+// Transcoder probes and transcodes videos with the ffprobe and ffmpeg
+// binaries. Renditions are H.264 and AAC, scaled so their shorter edge is at
+// most the configured size, rotated upright and stripped of their metadata.
+type Transcoder struct {
+	config *config.VideoConfig
+}
+
+// NewTranscoder creates a transcoder running the configured binaries
+func NewTranscoder(cfg *config.VideoConfig) *Transcoder {
+	return &Transcoder{config: cfg}
+}
+
+// Probe reads a video's dimensions and duration, returning an error wrapping
+// ErrInvalidVideo if the file has no video stream ffprobe can read
+func (t *Transcoder) Probe(ctx context.Context, path string) (*Info, error) {
+	cmd := exec.CommandContext(ctx, t.config.FFprobePath,
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVideo, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	return parseProbe(out)
+}
+
+// probeOutput is the part of ffprobe's JSON output Probe reads
+type probeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		Tags      struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// parseProbe reads a video's description from ffprobe's JSON output
+func parseProbe(data []byte) (*Info, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVideo, err)
+	}
+
+	info := &Info{}
+	found := false
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if found || stream.Width <= 0 || stream.Height <= 0 {
+				continue
+			}
+			found = true
+			info.Width, info.Height = stream.Width, stream.Height
+
+			// Phones record portrait video as landscape frames with a rotation,
+			// which ffmpeg applies when transcoding
+			rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+			for _, side := range stream.SideDataList {
+				if side.Rotation != 0 {
+					rotation = side.Rotation
+				}
+			}
+			if int(math.Abs(rotation))%180 == 90 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case "audio":
+			info.HasAudio = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no video stream", ErrInvalidVideo)
+	}
+
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("%w: unknown duration", ErrInvalidVideo)
+	}
+	info.Duration = duration
+	return info, nil
+}
+
+// Transcode writes the renditions of the video at src, described by info,
+// into dir: an MP4 file, an HLS rendition for each configured size up to the
+// video's own, and a poster frame
+func (t *Transcoder) Transcode(ctx context.Context, src string, info *Info, dir string) (*Output, error) {
+	width, height := scaled(info.Width, info.Height, t.config.MP4Edge)
+	output := &Output{
+		Poster: File{Name: RenditionPoster, Path: filepath.Join(dir, "poster.jpg"), Width: width, Height: height, MimeType: "image/jpeg", Ext: ".jpg"},
+		MP4:    File{Name: RenditionMP4, Path: filepath.Join(dir, "video.mp4"), Width: width, Height: height, MimeType: "video/mp4", Ext: ".mp4"},
+	}
+
+	args := []string{"-nostdin", "-v", "error", "-y", "-i", src}
+	args = append(args, t.encode(info, width, height)...)
+	args = append(args, "-movflags", "+faststart", output.MP4.Path)
+
+	for _, edge := range t.streamEdges(info) {
+		width, height := scaled(info.Width, info.Height, edge)
+		name := fmt.Sprintf("%s-%dp", RenditionHLS, edge)
+		stream := Stream{
+			Playlist: File{Name: name, Path: filepath.Join(dir, name+".m3u8"), Width: width, Height: height, MimeType: "application/vnd.apple.mpegurl", Ext: ".m3u8"},
+			// ffmpeg names a single-file rendition's segments after its playlist
+			Segments: File{Name: name + "-segments", Path: filepath.Join(dir, name+".ts"), Width: width, Height: height, MimeType: "video/mp2t", Ext: ".ts"},
+		}
+		segment := strconv.Itoa(t.config.HLSSegmentSecs)
+		args = append(args, t.encode(info, width, height)...)
+		args = append(args,
+			"-force_key_frames", "expr:gte(t,n_forced*"+segment+")",
+			"-f", "hls", "-hls_time", segment, "-hls_playlist_type", "vod", "-hls_flags", "single_file",
+			stream.Playlist.Path)
+		output.Streams = append(output.Streams, stream)
+	}
+	if err := t.run(ctx, args); err != nil {
+		return nil, err
+	}
+
+	// Take the poster a second in, past fades from black, unless the video is shorter
+	at := math.Min(1, info.Duration/2)
+	err := t.run(ctx, []string{
+		"-nostdin", "-v", "error", "-y",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", src,
+		"-frames:v", "1", "-update", "1", "-vf", fmt.Sprintf("scale=%d:%d", width, height), "-q:v", "3",
+		"-map_metadata", "-1", output.Poster.Path,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// encode returns the ffmpeg output options encoding the first video stream
+// at width by height, with the first audio stream if there is one
+func (t *Transcoder) encode(info *Info, width, height int) []string {
+	args := []string{
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=%d:%d", width, height),
+		"-c:v", "libx264", "-preset", t.config.Preset, "-crf", strconv.Itoa(t.config.CRF), "-pix_fmt", "yuv420p",
+		"-map_metadata", "-1",
+	}
+	if info.HasAudio {
+		args = append(args, "-map", "0:a:0", "-c:a", "aac", "-b:a", t.config.AudioBitrate, "-ac", "2")
+	}
+	return args
+}
+
+// streamEdges returns the configured HLS sizes a video is transcoded to:
+// those no larger than the video, or the smallest if the video is smaller
+func (t *Transcoder) streamEdges(info *Info) []int {
+	shorter := min(info.Width, info.Height)
+	var edges []int
+	for _, edge := range t.config.HLSEdges {
+		if edge <= shorter || len(edges) == 0 {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// run runs ffmpeg, returning its error output if it fails
+func (t *Transcoder) run(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, t.config.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// scaled returns the dimensions of a width by height video scaled so its
+// shorter edge is at most edge, keeping its aspect ratio. Dimensions are
+// rounded to even numbers, which H.264's chroma subsampling needs.
+func scaled(width, height, edge int) (int, int) {
+	if shorter := min(width, height); shorter > edge {
+		scale := float64(edge) / float64(shorter)
+		width = int(math.Round(float64(width) * scale))
+		height = int(math.Round(float64(height) * scale))
+	}
+	return even(width), even(height)
+}
+
+// even rounds a dimension down to an even number of at least 2
+func even(n int) int {
+	return max(n&^1, 2)
+}
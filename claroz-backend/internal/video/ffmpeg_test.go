@@ -0,0 +1,223 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+)
+
+// testConfig returns a configuration producing small renditions quickly
+func testConfig() *config.VideoConfig {
+	return &config.VideoConfig{
+		FFmpegPath:      "ffmpeg",
+		FFprobePath:     "ffprobe",
+		MaxFileSize:     1024 * 1024,
+		MaxDurationSecs: 5,
+		MaxEdge:         640,
+		Workers:         1,
+		QueueSize:       10,
+		TimeoutMins:     1,
+		MP4Edge:         120,
+		HLSEdges:        []int{120, 480},
+		HLSSegmentSecs:  1,
+		Preset:          "ultrafast",
+		CRF:             30,
+		AudioBitrate:    "64k",
+	}
+}
+
+// requireFFmpeg skips tests that run ffmpeg where it is not installed
+func requireFFmpeg(t *testing.T) {
+	t.Helper()
+	for _, binary := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(binary); err != nil {
+			t.Skipf("%s is not installed", binary)
+		}
+	}
+}
+
+// generateClip writes a tiny test pattern clip of the given size and length,
+// with a tone if audio is set, returning its path
+func generateClip(t *testing.T, size string, seconds string, audio bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	args := []string{"-nostdin", "-v", "error", "-f", "lavfi", "-i", "testsrc=size=" + size + ":rate=10"}
+	if audio {
+		args = append(args, "-f", "lavfi", "-i", "sine=frequency=440")
+	}
+	args = append(args, "-t", seconds, "-pix_fmt", "yuv420p", "-c:v", "libx264", "-preset", "ultrafast", path)
+	if out, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to generate clip: %v: %s", err, out)
+	}
+	return path
+}
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    Info
+		wantErr bool
+	}{
+		{
+			name:   "video with audio",
+			output: `{"streams": [{"codec_type": "video", "width": 1920, "height": 1080}, {"codec_type": "audio"}], "format": {"duration": "12.500000"}}`,
+			want:   Info{Width: 1920, Height: 1080, Duration: 12.5, HasAudio: true},
+		},
+		{
+			name:   "rotated by a tag",
+			output: `{"streams": [{"codec_type": "video", "width": 1920, "height": 1080, "tags": {"rotate": "90"}}], "format": {"duration": "3"}}`,
+			want:   Info{Width: 1080, Height: 1920, Duration: 3},
+		},
+		{
+			name:   "rotated by side data",
+			output: `{"streams": [{"codec_type": "video", "width": 1920, "height": 1080, "side_data_list": [{"rotation": -90}]}], "format": {"duration": "3"}}`,
+			want:   Info{Width: 1080, Height: 1920, Duration: 3},
+		},
+		{
+			name:   "upside down",
+			output: `{"streams": [{"codec_type": "video", "width": 1920, "height": 1080, "side_data_list": [{"rotation": 180}]}], "format": {"duration": "3"}}`,
+			want:   Info{Width: 1920, Height: 1080, Duration: 3},
+		},
+		{
+			name:    "audio only",
+			output:  `{"streams": [{"codec_type": "audio"}], "format": {"duration": "3"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown duration",
+			output:  `{"streams": [{"codec_type": "video", "width": 640, "height": 360}], "format": {}}`,
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			output:  `garbage`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseProbe([]byte(tt.output))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVideo) {
+					t.Errorf("Expected ErrInvalidVideo, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProbe() error = %v", err)
+			}
+			if *info != tt.want {
+				t.Errorf("parseProbe() = %+v, want %+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaled(t *testing.T) {
+	tests := []struct {
+		width, height, edge   int
+		wantWidth, wantHeight int
+	}{
+		{1920, 1080, 720, 1280, 720},
+		{1080, 1920, 720, 720, 1280},
+		{640, 360, 720, 640, 360}, // never enlarged
+		{641, 361, 720, 640, 360}, // odd dimensions are made even
+		{1000, 1000, 360, 360, 360},
+		{1, 1, 360, 2, 2},
+	}
+	for _, tt := range tests {
+		width, height := scaled(tt.width, tt.height, tt.edge)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("scaled(%d, %d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.edge, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestTranscoder_StreamEdges(t *testing.T) {
+	transcoder := NewTranscoder(&config.VideoConfig{HLSEdges: []int{360, 720, 1080}})
+	tests := []struct {
+		info Info
+		want []int
+	}{
+		{Info{Width: 1920, Height: 1080}, []int{360, 720, 1080}},
+		{Info{Width: 720, Height: 1280}, []int{360, 720}},
+		{Info{Width: 320, Height: 240}, []int{360}}, // small videos get the smallest rendition
+	}
+	for _, tt := range tests {
+		got := transcoder.streamEdges(&tt.info)
+		if len(got) != len(tt.want) || got[len(got)-1] != tt.want[len(tt.want)-1] {
+			t.Errorf("streamEdges(%dx%d) = %v, want %v", tt.info.Width, tt.info.Height, got, tt.want)
+		}
+	}
+}
+
+func TestTranscoder(t *testing.T) {
+	requireFFmpeg(t)
+	transcoder := NewTranscoder(testConfig())
+	ctx := context.Background()
+
+	t.Run("clips are probed", func(t *testing.T) {
+		info, err := transcoder.Probe(ctx, generateClip(t, "320x240", "1", true))
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		if info.Width != 320 || info.Height != 240 || !info.HasAudio || info.Duration < 0.9 || info.Duration > 1.2 {
+			t.Errorf("Expected a 1 second 320x240 clip with audio, got %+v", info)
+		}
+	})
+
+	t.Run("other files are invalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		if err := os.WriteFile(path, []byte("not a video"), 0644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if _, err := transcoder.Probe(ctx, path); !errors.Is(err, ErrInvalidVideo) {
+			t.Errorf("Expected ErrInvalidVideo, got %v", err)
+		}
+	})
+
+	t.Run("clips are transcoded to their renditions", func(t *testing.T) {
+		src := generateClip(t, "320x240", "2", false)
+		info, err := transcoder.Probe(ctx, src)
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		dir := t.TempDir()
+		output, err := transcoder.Transcode(ctx, src, info, dir)
+		if err != nil {
+			t.Fatalf("Transcode() error = %v", err)
+		}
+
+		// The 480p rendition is larger than the clip
+		if len(output.Streams) != 1 || output.MP4.Width != 160 || output.MP4.Height != 120 {
+			t.Fatalf("Expected a 160x120 MP4 and one HLS rendition, got %+v", output)
+		}
+		for _, file := range []File{output.Poster, output.MP4, output.Streams[0].Playlist, output.Streams[0].Segments} {
+			if stat, err := os.Stat(file.Path); err != nil || stat.Size() == 0 {
+				t.Errorf("Expected %s to be written, got %v", file.Name, err)
+			}
+		}
+
+		mp4, err := transcoder.Probe(ctx, output.MP4.Path)
+		if err != nil || mp4.Width != 160 || mp4.Height != 120 {
+			t.Errorf("Expected a 160x120 MP4, got %+v, %v", mp4, err)
+		}
+		playlist, err := os.ReadFile(output.Streams[0].Playlist.Path)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if _, _, _, err := rewritePlaylist(playlist, output.Streams[0].Segments, "segments.ts"); err != nil {
+			t.Errorf("Expected a single-file playlist, got %v:\n%s", err, playlist)
+		}
+		if !strings.Contains(string(playlist), "#EXT-X-BYTERANGE") {
+			t.Errorf("Expected segments to be byte ranges, got:\n%s", playlist)
+		}
+	})
+}
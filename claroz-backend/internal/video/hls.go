@@ -0,0 +1,75 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// StoredStream is an HLS rendition whose files are stored
+type StoredStream struct {
+	Playlist         string // the stored media playlist's key
+	Width            int
+	Height           int
+	Bandwidth        int // peak bitrate of a segment, in bits per second
+	AverageBandwidth int // in bits per second
+}
+
+// rewritePlaylist points a single-file media playlist ffmpeg wrote at the
+// key its segments are stored under, returning it with the rendition's peak
+// and average bitrates read from its byte ranges. Segments are referred to
+// by key, relative to the playlist, so playlists work whichever URL uploads
+// are served from.
+func rewritePlaylist(data []byte, segments File, key string) ([]byte, int, int, error) {
+	var out bytes.Buffer
+	var duration, total, peak float64
+	var extinf, length float64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			extinf, _ = strconv.ParseFloat(value, 64)
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"), "@")
+			length, _ = strconv.ParseFloat(value, 64)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			if line != filepath.Base(segments.Path) {
+				return nil, 0, 0, fmt.Errorf("playlist refers to unexpected segment %q", line)
+			}
+			line = key
+			duration += extinf
+			total += length
+			if extinf > 0 {
+				peak = math.Max(peak, length*8/extinf)
+			}
+			extinf, length = 0, 0
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	if duration == 0 {
+		return nil, 0, 0, fmt.Errorf("playlist has no segments")
+	}
+	return out.Bytes(), int(math.Ceil(peak)), int(math.Ceil(total * 8 / duration)), nil
+}
+
+// masterPlaylist returns the HLS master playlist offering streams, whose
+// media playlists are referred to by key
+func masterPlaylist(streams []StoredStream) []byte {
+	var out bytes.Buffer
+	out.WriteString("#EXTM3U\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, stream := range streams {
+		fmt.Fprintf(&out, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%dx%d\n%s\n",
+			stream.Bandwidth, stream.AverageBandwidth, stream.Width, stream.Height, stream.Playlist)
+	}
+	return out.Bytes()
+}
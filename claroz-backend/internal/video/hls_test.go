@@ -0,0 +1,73 @@
+package video
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPlaylist = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:4.000000,
+#EXT-X-BYTERANGE:100000@0
+hls-360p.ts
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:25000@100000
+hls-360p.ts
+#EXT-X-ENDLIST
+`
+
+func TestRewritePlaylist(t *testing.T) {
+	segments := File{Name: "hls-360p-segments", Path: "/tmp/work/hls-360p.ts"}
+
+	t.Run("segments refer to the stored file", func(t *testing.T) {
+		data, peak, average, err := rewritePlaylist([]byte(testPlaylist), segments, "abc123.ts")
+		if err != nil {
+			t.Fatalf("rewritePlaylist() error = %v", err)
+		}
+		playlist := string(data)
+		if strings.Contains(playlist, "hls-360p.ts") || strings.Count(playlist, "\nabc123.ts\n") != 2 {
+			t.Errorf("Expected both segments to refer to the stored file, got:\n%s", playlist)
+		}
+		if !strings.Contains(playlist, "#EXT-X-BYTERANGE:25000@100000") || !strings.HasSuffix(playlist, "#EXT-X-ENDLIST\n") {
+			t.Errorf("Expected the tags to be kept, got:\n%s", playlist)
+		}
+		// 100000 bytes over 4 seconds peaks at 200kbps; 125000 bytes over 6 seconds averages 166.7kbps
+		if peak != 200000 || average != 166667 {
+			t.Errorf("Expected bandwidths of 200000 and 166667, got %d and %d", peak, average)
+		}
+	})
+
+	t.Run("unexpected segments are rejected", func(t *testing.T) {
+		playlist := strings.Replace(testPlaylist, "hls-360p.ts", "../other.ts", 1)
+		if _, _, _, err := rewritePlaylist([]byte(playlist), segments, "abc123.ts"); err == nil {
+			t.Error("Expected a playlist referring to another file to be rejected")
+		}
+	})
+
+	t.Run("empty playlists are rejected", func(t *testing.T) {
+		if _, _, _, err := rewritePlaylist([]byte("#EXTM3U\n#EXT-X-ENDLIST\n"), segments, "abc123.ts"); err == nil {
+			t.Error("Expected a playlist without segments to be rejected")
+		}
+	})
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	playlist := string(masterPlaylist([]StoredStream{
+		{Playlist: "small.m3u8", Width: 640, Height: 360, Bandwidth: 800000, AverageBandwidth: 600000},
+		{Playlist: "large.m3u8", Width: 1280, Height: 720, Bandwidth: 2400000, AverageBandwidth: 1800000},
+	}))
+
+	want := `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=800000,AVERAGE-BANDWIDTH=600000,RESOLUTION=640x360
+small.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,AVERAGE-BANDWIDTH=1800000,RESOLUTION=1280x720
+large.m3u8
+`
+	if playlist != want {
+		t.Errorf("masterPlaylist() =\n%s\nwant:\n%s", playlist, want)
+	}
+}
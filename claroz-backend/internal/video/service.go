@@ -0,0 +1,418 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// sourceExt is the extension uploads are stored with until they are
+// transcoded. Uploads are only served as their renditions.
+const sourceExt = ".source"
+
+// Store records the transcoding of video posts. It is implemented by
+// repository.PostRepositoryInterface, whose errors it returns.
+type Store interface {
+	GetProcessingPostIDs() ([]uuid.UUID, error)
+	GetProcessingVideo(postID uuid.UUID) (*models.PostMedia, error)
+	CompleteVideo(postID uuid.UUID, media models.PostMedia, imageURL string) error
+	FailVideo(postID uuid.UUID) error
+}
+
+// ServiceInterface stores uploaded videos and transcodes them in the background
+type ServiceInterface interface {
+	// Save stores an uploaded video as the media item of a post that is
+	// processing
+	Save(ctx context.Context, r io.Reader) (*models.PostMedia, error)
+	// Enqueue queues a processing post's video to be transcoded without
+	// waiting, reporting whether the queue had room for it; if not, the post
+	// is queued by a later sweep
+	Enqueue(postID uuid.UUID) bool
+}
+
+// Service transcodes the videos of posts that are processing. Uploads are
+// probed and checked against the limits as they are received, then stored as
+// they are. A queue of background workers transcodes them into their
+// renditions and marks their posts ready, or failed if ffmpeg cannot
+// transcode them. Posts are also queued by a periodic sweep, which picks up
+// those the queue had no room for, and those still processing when the
+// service last stopped.
+type Service struct {
+	store      Store
+	files      utils.FileStorageInterface
+	blobs      utils.BlobRegistry
+	transcoder *Transcoder
+	config     *config.VideoConfig
+
+	mu     sync.Mutex
+	queued map[uuid.UUID]bool // posts queued or being transcoded
+
+	jobs   chan uuid.UUID
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewService creates a video service storing files in files and
+// registering them in blobs. Call Start before enqueuing posts.
+func NewService(store Store, files utils.FileStorageInterface, blobs utils.BlobRegistry, cfg *config.VideoConfig) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		store:      store,
+		files:      files,
+		blobs:      blobs,
+		transcoder: NewTranscoder(cfg),
+		config:     cfg,
+		queued:     make(map[uuid.UUID]bool),
+		jobs:       make(chan uuid.UUID, cfg.QueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start launches the workers and sweeps the posts left processing, then
+// every SweepMins unless it is 0
+func (s *Service) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for {
+				select {
+				case postID := <-s.jobs:
+					s.run(postID)
+				case <-s.done:
+					return
+				}
+			}
+		}()
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sweep()
+		if s.config.SweepMins <= 0 {
+			return
+		}
+		ticker := time.NewTicker(time.Duration(s.config.SweepMins) * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sweep()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// sweep queues the posts that are processing, oldest first, until the queue
+// is full
+func (s *Service) sweep() {
+	ids, err := s.store.GetProcessingPostIDs()
+	if err != nil {
+		log.Printf("video: failed to list processing posts: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if !s.Enqueue(id) {
+			return
+		}
+	}
+}
+
+// Stop stops the workers and sweeps, interrupting the videos being
+// transcoded. Their posts, and those still queued, stay processing until the
+// service next starts.
+func (s *Service) Stop() {
+	close(s.done)
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Enqueue queues a post's video to be transcoded, reporting whether the
+// queue had room for it. Posts already queued are not queued again. Posts
+// the queue has no room for stay processing, and are queued by a later
+// sweep, so uploads never wait for the workers.
+func (s *Service) Enqueue(postID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued[postID] {
+		return true
+	}
+	select {
+	case s.jobs <- postID:
+		// Workers forget the post once it is transcoded, which waits for the lock
+		s.queued[postID] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// run transcodes a queued post's video, marking the post failed unless the
+// service is stopping
+func (s *Service) run(postID uuid.UUID) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.queued, postID)
+		s.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.config.TimeoutMins)*time.Minute)
+	defer cancel()
+	err := s.process(ctx, postID)
+	if err == nil || s.ctx.Err() != nil {
+		return
+	}
+
+	log.Printf("video: failed to transcode post %s: %v", postID, err)
+	if err := s.store.FailVideo(postID); err != nil && !errors.Is(err, repository.ErrPostNotFound) {
+		log.Printf("video: failed to mark post %s failed: %v", postID, err)
+	}
+}
+
+// process transcodes a post's video and completes the post with its
+// renditions. Posts deleted or no longer processing are skipped; renditions
+// stored for a post deleted meanwhile are left to the garbage collector.
+func (s *Service) process(ctx context.Context, postID uuid.UUID) error {
+	media, err := s.store.GetProcessingVideo(postID)
+	if errors.Is(err, repository.ErrPostNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "claroz-video-*")
+	if err != nil {
+		return fmt.Errorf("failed to create working directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "source")
+	if err := s.download(ctx, models.BlobKey(media.Variants[RenditionSource].URL), src); err != nil {
+		return err
+	}
+	info, err := s.transcoder.Probe(ctx, src)
+	if err != nil {
+		return err
+	}
+	output, err := s.transcoder.Transcode(ctx, src, info, dir)
+	if err != nil {
+		return err
+	}
+
+	variants, err := s.storeOutput(ctx, output)
+	if err != nil {
+		return err
+	}
+	mp4 := variants[RenditionMP4]
+	completed := models.PostMedia{
+		URL:      mp4.URL,
+		Width:    mp4.Width,
+		Height:   mp4.Height,
+		MimeType: mp4.MimeType,
+		Duration: info.Duration,
+		Variants: variants,
+	}
+	err = s.store.CompleteVideo(postID, completed, variants[RenditionPoster].URL)
+	if errors.Is(err, repository.ErrPostNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Save spools an upload to a temporary file, probes it and stores it as the
+// source of a processing post's media item. It returns an error wrapping
+// utils.ErrFileTooLarge if the upload is over the maximum file size,
+// ErrInvalidVideo if it is not a video, ErrVideoTooLong if it is over the
+// maximum duration, or ErrVideoTooLarge if it is over the maximum edge.
+func (s *Service) Save(ctx context.Context, r io.Reader) (*models.PostMedia, error) {
+	file, err := os.CreateTemp("", "claroz-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// Read a byte past the limit to tell uploads at the limit from larger ones
+	size, err := io.Copy(file, io.LimitReader(r, s.config.MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read video: %w", err)
+	}
+	if size > s.config.MaxFileSize {
+		return nil, fmt.Errorf("%w: maximum is %d bytes", utils.ErrFileTooLarge, s.config.MaxFileSize)
+	}
+
+	info, err := s.transcoder.Probe(ctx, file.Name())
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration > float64(s.config.MaxDurationSecs) {
+		return nil, fmt.Errorf("%w: maximum is %d seconds", ErrVideoTooLong, s.config.MaxDurationSecs)
+	}
+	if max(info.Width, info.Height) > s.config.MaxEdge {
+		return nil, fmt.Errorf("%w: maximum edge is %d pixels", ErrVideoTooLarge, s.config.MaxEdge)
+	}
+
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read video: %w", err)
+	}
+	mimeType := http.DetectContentType(head[:n])
+
+	ref, err := s.storeFile(ctx, file.Name(), sourceExt, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PostMedia{
+		URL:      ref.URL,
+		Width:    info.Width,
+		Height:   info.Height,
+		MimeType: mimeType,
+		Duration: info.Duration,
+		Variants: models.ImageVariants{
+			RenditionSource: {URL: ref.URL, Width: info.Width, Height: info.Height, MimeType: mimeType},
+		},
+	}, nil
+}
+
+// download copies a stored file to path
+func (s *Service) download(ctx context.Context, key, path string) error {
+	src, err := s.files.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to open video: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to read video: %w", err)
+	}
+	return dst.Close()
+}
+
+// storeOutput stores a transcode's files, returning them as variants. HLS
+// playlists are rewritten to refer to the files as they are stored, and a
+// master playlist is generated to offer the renditions.
+func (s *Service) storeOutput(ctx context.Context, output *Output) (models.ImageVariants, error) {
+	variants := models.ImageVariants{}
+	for _, file := range []File{output.Poster, output.MP4} {
+		ref, err := s.storeFile(ctx, file.Path, file.Ext, file.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		variants[file.Name] = variant(file, ref)
+	}
+
+	var streams []StoredStream
+	for _, stream := range output.Streams {
+		segments, err := s.storeFile(ctx, stream.Segments.Path, stream.Segments.Ext, stream.Segments.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		variants[stream.Segments.Name] = variant(stream.Segments, segments)
+
+		data, err := os.ReadFile(stream.Playlist.Path)
+		if err != nil {
+			return nil, err
+		}
+		data, peak, average, err := rewritePlaylist(data, stream.Segments, segments.Key)
+		if err != nil {
+			return nil, err
+		}
+		playlist, err := s.storeData(ctx, data, stream.Playlist.Ext, stream.Playlist.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		variants[stream.Playlist.Name] = variant(stream.Playlist, playlist)
+		streams = append(streams, StoredStream{
+			Playlist:         playlist.Key,
+			Width:            stream.Playlist.Width,
+			Height:           stream.Playlist.Height,
+			Bandwidth:        peak,
+			AverageBandwidth: average,
+		})
+	}
+
+	master := File{Name: RenditionHLS, Width: output.MP4.Width, Height: output.MP4.Height, MimeType: "application/vnd.apple.mpegurl", Ext: ".m3u8"}
+	ref, err := s.storeData(ctx, masterPlaylist(streams), master.Ext, master.MimeType)
+	if err != nil {
+		return nil, err
+	}
+	variants[master.Name] = variant(master, ref)
+	return variants, nil
+}
+
+// variant returns a stored file as a variant
+func variant(file File, ref utils.ObjectRef) models.ImageVariant {
+	return models.ImageVariant{URL: ref.URL, Width: file.Width, Height: file.Height, MimeType: file.MimeType}
+}
+
+// storeFile stores the file at path under the SHA-256 of its content
+func (s *Service) storeFile(ctx context.Context, path, ext, contentType string) (utils.ObjectRef, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return utils.ObjectRef{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return utils.ObjectRef{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return utils.ObjectRef{}, err
+	}
+	return s.storeBlob(ctx, file, hex.EncodeToString(hash.Sum(nil))+ext, size, contentType)
+}
+
+// storeData stores data under the SHA-256 of its content
+func (s *Service) storeData(ctx context.Context, data []byte, ext, contentType string) (utils.ObjectRef, error) {
+	sum := sha256.Sum256(data)
+	return s.storeBlob(ctx, bytes.NewReader(data), hex.EncodeToString(sum[:])+ext, int64(len(data)), contentType)
+}
+
+// storeBlob stores a file under key unless it is already stored, like
+// utils.ImageStorage does. The blob is registered first, which keeps the
+// collector from deleting a file that is being reused, and starts
+// unreferenced until the post refers to it.
+func (s *Service) storeBlob(ctx context.Context, r io.Reader, key string, size int64, contentType string) (utils.ObjectRef, error) {
+	if err := s.blobs.RegisterBlob(&models.MediaBlob{Key: key, Size: size, ContentType: contentType}); err != nil {
+		return utils.ObjectRef{}, fmt.Errorf("failed to register file: %w", err)
+	}
+
+	if ref, err := s.files.Stat(ctx, key); err == nil {
+		return ref, nil
+	} else if !errors.Is(err, utils.ErrObjectNotFound) {
+		return utils.ObjectRef{}, err
+	}
+	return s.files.Save(ctx, r, utils.ObjectMeta{Key: key, ContentType: contentType, Size: size})
+}
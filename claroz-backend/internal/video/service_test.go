@@ -0,0 +1,365 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lukelittle/claroz/claroz-backend/internal/config"
+	"github.com/lukelittle/claroz/claroz-backend/internal/models"
+	"github.com/lukelittle/claroz/claroz-backend/internal/repository"
+	"github.com/lukelittle/claroz/claroz-backend/internal/utils"
+)
+
+// fakeStore keeps posts in memory, completing and failing only those that
+// are processing like *repository.PostRepository
+type fakeStore struct {
+	mu    sync.Mutex
+	posts map[uuid.UUID]*models.Post
+}
+
+func (f *fakeStore) add(status string, media models.PostMedia) uuid.UUID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := uuid.New()
+	f.posts[id] = &models.Post{ID: id, Status: status, ImageURL: media.URL, Media: []models.PostMedia{media}}
+	return id
+}
+
+func (f *fakeStore) get(id uuid.UUID) models.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return *f.posts[id]
+}
+
+func (f *fakeStore) GetProcessingPostIDs() ([]uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := []uuid.UUID{}
+	for id, post := range f.posts {
+		if post.Status == models.PostProcessing {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeStore) GetProcessingVideo(postID uuid.UUID) (*models.PostMedia, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postID]
+	if !ok || post.Status != models.PostProcessing {
+		return nil, repository.ErrPostNotFound
+	}
+	media := post.Media[0]
+	return &media, nil
+}
+
+func (f *fakeStore) CompleteVideo(postID uuid.UUID, media models.PostMedia, imageURL string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postID]
+	if !ok || post.Status != models.PostProcessing {
+		return repository.ErrPostNotFound
+	}
+	post.Media = []models.PostMedia{media}
+	post.ImageURL = imageURL
+	post.Status = models.PostReady
+	return nil
+}
+
+func (f *fakeStore) FailVideo(postID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postID]
+	if !ok || post.Status != models.PostProcessing {
+		return repository.ErrPostNotFound
+	}
+	post.Status = models.PostFailed
+	return nil
+}
+
+// fakeBlobs records registered blobs
+type fakeBlobs struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (f *fakeBlobs) RegisterBlob(blob *models.MediaBlob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[blob.Key] = true
+	return nil
+}
+
+type testService struct {
+	*Service
+	store *fakeStore
+	blobs *fakeBlobs
+	files utils.FileStorageInterface
+}
+
+func newTestService(t *testing.T, cfg *config.VideoConfig) *testService {
+	t.Helper()
+	files, err := utils.NewLocalStorage(&config.StorageConfig{LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	store := &fakeStore{posts: map[uuid.UUID]*models.Post{}}
+	blobs := &fakeBlobs{keys: map[string]bool{}}
+	return &testService{Service: NewService(store, files, blobs, cfg), store: store, blobs: blobs, files: files}
+}
+
+// addUpload stores data as a processing post's upload, returning the post's ID
+func (s *testService) addUpload(t *testing.T, data []byte) uuid.UUID {
+	t.Helper()
+	ref, err := s.files.Save(context.Background(), bytes.NewReader(data), utils.ObjectMeta{Key: "upload" + sourceExt, Size: int64(len(data))})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	return s.store.add(models.PostProcessing, models.PostMedia{
+		URL:      ref.URL,
+		Variants: models.ImageVariants{RenditionSource: {URL: ref.URL}},
+	})
+}
+
+// read returns the contents of a stored file
+func (s *testService) read(t *testing.T, url string) string {
+	t.Helper()
+	r, err := s.files.Open(context.Background(), models.BlobKey(url))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return string(data)
+}
+
+// waitForStatus waits for a post to leave processing, returning its status
+func (s *testService) waitForStatus(t *testing.T, id uuid.UUID, timeout time.Duration) string {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if status := s.store.get(id).Status; status != models.PostProcessing {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return models.PostProcessing
+}
+
+// writeScript writes an executable shell script standing in for ffprobe or ffmpeg
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestService_Save(t *testing.T) {
+	t.Run("uploads over the maximum size are rejected", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.MaxFileSize = 4
+		service := newTestService(t, cfg)
+		if _, err := service.Save(context.Background(), strings.NewReader("12345")); !errors.Is(err, utils.ErrFileTooLarge) {
+			t.Errorf("Expected ErrFileTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("uploads ffprobe rejects are invalid", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.FFprobePath = writeScript(t, "echo 'Invalid data found when processing input' >&2; exit 1")
+		service := newTestService(t, cfg)
+		if _, err := service.Save(context.Background(), strings.NewReader("not a video")); !errors.Is(err, ErrInvalidVideo) {
+			t.Errorf("Expected ErrInvalidVideo, got %v", err)
+		}
+		if len(service.blobs.keys) != 0 {
+			t.Errorf("Expected nothing to be stored, got %v", service.blobs.keys)
+		}
+	})
+
+	t.Run("videos over the limits are rejected", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			probe string
+			want  error
+		}{
+			{"too long", `{"streams": [{"codec_type": "video", "width": 320, "height": 240}], "format": {"duration": "5.5"}}`, ErrVideoTooLong},
+			{"too large", `{"streams": [{"codec_type": "video", "width": 1280, "height": 720}], "format": {"duration": "1"}}`, ErrVideoTooLarge},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				cfg := testConfig()
+				cfg.FFprobePath = writeScript(t, "echo '"+tt.probe+"'")
+				service := newTestService(t, cfg)
+				if _, err := service.Save(context.Background(), strings.NewReader("video")); !errors.Is(err, tt.want) {
+					t.Errorf("Expected %v, got %v", tt.want, err)
+				}
+			})
+		}
+	})
+
+	t.Run("videos are stored as uploaded", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.FFprobePath = writeScript(t, `echo '{"streams": [{"codec_type": "video", "width": 320, "height": 240}], "format": {"duration": "2.5"}}'`)
+		service := newTestService(t, cfg)
+		media, err := service.Save(context.Background(), strings.NewReader("video"))
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		source := media.Variants[RenditionSource]
+		if source.URL != media.URL || !strings.HasSuffix(media.URL, sourceExt) || media.Duration != 2.5 || media.Width != 320 {
+			t.Errorf("Expected the upload as the media's source, got %+v", media)
+		}
+		if !service.blobs.keys[models.BlobKey(media.URL)] || service.read(t, media.URL) != "video" {
+			t.Errorf("Expected the upload to be stored and registered, got %v", service.blobs.keys)
+		}
+	})
+}
+
+func TestService_Queue(t *testing.T) {
+	t.Run("posts left processing are transcoded on start and fail if ffmpeg cannot read them", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.FFprobePath = writeScript(t, "exit 1")
+		service := newTestService(t, cfg)
+		id := service.addUpload(t, []byte("not a video"))
+		ready := service.store.add(models.PostReady, models.PostMedia{URL: "/uploads/photo.jpg"})
+
+		service.Start()
+		defer service.Stop()
+		if status := service.waitForStatus(t, id, 5*time.Second); status != models.PostFailed {
+			t.Errorf("Expected the post to fail, got %s", status)
+		}
+		if status := service.store.get(ready).Status; status != models.PostReady {
+			t.Errorf("Expected ready posts to be left alone, got %s", status)
+		}
+	})
+
+	t.Run("enqueuing never waits for a full queue, which later sweeps catch up with", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.QueueSize = 1
+		cfg.FFprobePath = writeScript(t, "exit 1")
+		service := newTestService(t, cfg)
+		ids := []uuid.UUID{service.addUpload(t, []byte("first")), service.addUpload(t, []byte("second")), service.addUpload(t, []byte("third"))}
+
+		queued := make(chan []bool)
+		go func() {
+			results := []bool{}
+			for _, id := range ids {
+				results = append(results, service.Enqueue(id))
+			}
+			queued <- results
+		}()
+		select {
+		case results := <-queued:
+			if !results[0] || results[1] || results[2] {
+				t.Errorf("Expected only the first post to fit in the queue, got %v", results)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Enqueue not to wait for room in the queue")
+		}
+
+		service.Start()
+		defer service.Stop()
+		deadline := time.Now().Add(5 * time.Second)
+		for _, id := range ids {
+			for service.store.get(id).Status == models.PostProcessing && time.Now().Before(deadline) {
+				service.sweep()
+				time.Sleep(10 * time.Millisecond)
+			}
+			if status := service.store.get(id).Status; status != models.PostFailed {
+				t.Errorf("Expected every post to be transcoded, got %s", status)
+			}
+		}
+	})
+
+	t.Run("stopping interrupts transcoding without failing the post", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.FFprobePath = writeScript(t, "exec sleep 30")
+		service := newTestService(t, cfg)
+		id := service.addUpload(t, []byte("video"))
+
+		service.Start()
+		time.Sleep(100 * time.Millisecond)
+		stopped := make(chan struct{})
+		go func() {
+			service.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Stop to interrupt ffprobe")
+		}
+		if status := service.store.get(id).Status; status != models.PostProcessing {
+			t.Errorf("Expected the post to stay processing until the next start, got %s", status)
+		}
+	})
+}
+
+func TestService_Transcode(t *testing.T) {
+	requireFFmpeg(t)
+	service := newTestService(t, testConfig())
+
+	clip, err := os.ReadFile(generateClip(t, "320x240", "2", true))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	media, err := service.Save(context.Background(), bytes.NewReader(clip))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	id := service.store.add(models.PostProcessing, *media)
+
+	service.Start()
+	defer service.Stop()
+	if status := service.waitForStatus(t, id, time.Minute); status != models.PostReady {
+		t.Fatalf("Expected the post to be ready, got %s", status)
+	}
+
+	post := service.store.get(id)
+	completed := post.Media[0]
+	variants := completed.Variants
+	for _, name := range []string{RenditionPoster, RenditionMP4, RenditionHLS, "hls-120p", "hls-120p-segments"} {
+		if _, ok := variants[name]; !ok {
+			t.Errorf("Expected a %s rendition, got %v", name, variants)
+		}
+	}
+	if _, ok := variants[RenditionSource]; ok {
+		t.Error("Expected the upload to be replaced by its renditions")
+	}
+	if completed.URL != variants[RenditionMP4].URL || completed.MimeType != "video/mp4" || completed.Width != 160 || completed.Duration < 1.9 {
+		t.Errorf("Expected the media to be the 160x120 MP4, got %+v", completed)
+	}
+	if post.ImageURL != variants[RenditionPoster].URL {
+		t.Errorf("Expected the post's image to be the poster, got %q", post.ImageURL)
+	}
+	for _, variant := range variants {
+		if !service.blobs.keys[models.BlobKey(variant.URL)] {
+			t.Errorf("Expected %s to be registered", variant.URL)
+		}
+	}
+
+	// Playlists refer to the stored files by key
+	master := service.read(t, variants[RenditionHLS].URL)
+	if !strings.Contains(master, "RESOLUTION=160x120\n"+models.BlobKey(variants["hls-120p"].URL)+"\n") {
+		t.Errorf("Expected the master playlist to offer the rendition, got:\n%s", master)
+	}
+	playlist := service.read(t, variants["hls-120p"].URL)
+	if !strings.Contains(playlist, "\n"+models.BlobKey(variants["hls-120p-segments"].URL)+"\n") {
+		t.Errorf("Expected the playlist to refer to its segments, got:\n%s", playlist)
+	}
+}
@@ -0,0 +1,12 @@
+-- Drop invite codes
+DROP INDEX IF EXISTS idx_invite_codes_created_by_id;
+DROP TABLE IF EXISTS invite_codes;
+
+-- Drop user indexes
+DROP INDEX IF EXISTS idx_users_status;
+DROP INDEX IF EXISTS idx_users_username_lower;
+
+-- Remove role and status columns
+ALTER TABLE users
+DROP COLUMN IF EXISTS role,
+DROP COLUMN IF EXISTS status;
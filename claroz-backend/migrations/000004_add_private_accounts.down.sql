@@ -0,0 +1,7 @@
+-- Drop follow requests
+DROP INDEX IF EXISTS idx_follow_requests_target_id;
+DROP TABLE IF EXISTS follow_requests;
+
+-- Remove private account flag
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_private;
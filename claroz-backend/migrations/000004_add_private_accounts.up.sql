@@ -0,0 +1,15 @@
+-- Add private account flag to users
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create follow requests table
+CREATE TABLE IF NOT EXISTS follow_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(requester_id, target_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_follow_requests_target_id ON follow_requests(target_id);
@@ -0,0 +1,6 @@
+-- Drop user mutes
+DROP TABLE IF EXISTS user_mutes;
+
+-- Drop user blocks
+DROP INDEX IF EXISTS idx_user_blocks_blocked_id;
+DROP TABLE IF EXISTS user_blocks;
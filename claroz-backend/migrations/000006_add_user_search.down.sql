@@ -0,0 +1,7 @@
+-- Drop search indexes
+DROP INDEX IF EXISTS idx_users_search_document;
+DROP INDEX IF EXISTS idx_users_full_name_trgm;
+DROP INDEX IF EXISTS idx_users_handle_trgm;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+
+-- The pg_trgm extension is left installed as other database objects may depend on it
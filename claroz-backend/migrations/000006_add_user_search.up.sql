@@ -0,0 +1,11 @@
+-- Enable trigram matching
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Create trigram indexes for fuzzy and prefix matching
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (LOWER(username) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_handle_trgm ON users USING gin (LTRIM(LOWER(handle), '@') gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING gin (LOWER(full_name) gin_trgm_ops);
+
+-- Create full-text index over name and bio
+CREATE INDEX IF NOT EXISTS idx_users_search_document ON users
+USING gin (to_tsvector('simple', COALESCE(full_name, '') || ' ' || COALESCE(bio, '')));
@@ -0,0 +1,12 @@
+-- Drop hashtags
+DROP INDEX IF EXISTS idx_post_hashtags_hashtag_id;
+DROP TABLE IF EXISTS post_hashtags;
+DROP TABLE IF EXISTS hashtags;
+
+-- Remove post search columns
+DROP INDEX IF EXISTS idx_posts_search_vector;
+ALTER TABLE posts
+DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS language;
@@ -0,0 +1,25 @@
+-- Add caption language and a generated full-text search vector to posts
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS language REGCONFIG NOT NULL DEFAULT 'simple';
+
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+GENERATED ALWAYS AS (to_tsvector(language, COALESCE(caption, ''))) STORED;
+
+-- Create hashtags table
+CREATE TABLE IF NOT EXISTS hashtags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create post hashtags table
+CREATE TABLE IF NOT EXISTS post_hashtags (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, hashtag_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_hashtag_id ON post_hashtags(hashtag_id);
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+-- Drop feed indexes
+DROP INDEX IF EXISTS idx_posts_user_created;
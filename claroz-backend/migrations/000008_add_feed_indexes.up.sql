@@ -0,0 +1,2 @@
+-- Serve per-author keyset pagination on (created_at, id) for feeds
+CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts (user_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+-- Drop materialized home timeline entries
+DROP INDEX IF EXISTS idx_timeline_entries_user_author;
+DROP INDEX IF EXISTS idx_timeline_entries_post_id;
+DROP INDEX IF EXISTS idx_timeline_entries_user_created;
+DROP TABLE IF EXISTS timeline_entries;
@@ -0,0 +1,13 @@
+-- Create materialized home timeline entries
+CREATE TABLE IF NOT EXISTS timeline_entries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_created ON timeline_entries (user_id, created_at DESC, post_id DESC);
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_post_id ON timeline_entries(post_id);
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_author ON timeline_entries(user_id, author_id);
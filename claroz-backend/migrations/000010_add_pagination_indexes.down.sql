@@ -0,0 +1,4 @@
+-- Drop pagination indexes
+DROP INDEX IF EXISTS idx_likes_post_created;
+DROP INDEX IF EXISTS idx_comments_post_created;
+DROP INDEX IF EXISTS idx_posts_created;
@@ -0,0 +1,4 @@
+-- Serve keyset pagination on (created_at, id) for posts, comments and likes
+CREATE INDEX IF NOT EXISTS idx_posts_created ON posts (created_at DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_post_created ON comments (post_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_likes_post_created ON likes (post_id, created_at DESC, id DESC);
@@ -0,0 +1,5 @@
+-- Drop engagement counters
+ALTER TABLE users DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS comment_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS like_count;
@@ -0,0 +1,13 @@
+-- Store like, comment and follow counts instead of counting rows on every read
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS like_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count BIGINT NOT NULL DEFAULT 0;
+
+-- Backfill the counters from existing rows
+UPDATE posts SET
+    like_count = (SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id),
+    comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id);
+UPDATE users SET
+    followers_count = (SELECT COUNT(*) FROM user_follows WHERE user_follows.following_id = users.id),
+    following_count = (SELECT COUNT(*) FROM user_follows WHERE user_follows.follower_id = users.id);
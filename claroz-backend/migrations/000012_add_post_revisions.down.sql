@@ -0,0 +1,3 @@
+-- Drop post revisions
+DROP TABLE IF EXISTS post_revisions;
+ALTER TABLE posts DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,8 @@
+-- Drop comment threads
+DROP INDEX IF EXISTS idx_comments_post_top_level;
+DROP INDEX IF EXISTS idx_comments_parent_created;
+DELETE FROM comments WHERE parent_id IS NOT NULL OR deleted_at IS NOT NULL;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS reply_count;
+ALTER TABLE comments DROP COLUMN IF EXISTS depth;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,9 @@
+-- Let comments reply to other comments, and keep deleted comments with replies as tombstones
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES comments(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS reply_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Page through a comment's replies and a post's top-level comments
+CREATE INDEX IF NOT EXISTS idx_comments_parent_created ON comments(parent_id, created_at, id) WHERE parent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_post_top_level ON comments(post_id, created_at, id) WHERE parent_id IS NULL;
@@ -0,0 +1,19 @@
+-- Restore likes from heart reactions on posts
+CREATE TABLE IF NOT EXISTS likes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(post_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_likes_post_id ON likes(post_id);
+CREATE INDEX IF NOT EXISTS idx_likes_user_id ON likes(user_id);
+CREATE INDEX IF NOT EXISTS idx_likes_post_created ON likes (post_id, created_at DESC, id DESC);
+
+INSERT INTO likes (id, post_id, user_id, created_at)
+SELECT id, post_id, user_id, created_at FROM reactions
+WHERE post_id IS NOT NULL AND emoji = 'heart'
+ON CONFLICT DO NOTHING;
+
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,26 @@
+-- Store emoji reactions to posts and comments; each reaction is on exactly one of them
+CREATE TABLE IF NOT EXISTS reactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+-- A user reacts with each emoji at most once per post or comment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_post_user_emoji ON reactions(post_id, user_id, emoji) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_comment_user_emoji ON reactions(comment_id, user_id, emoji) WHERE comment_id IS NOT NULL;
+
+-- Page through who reacted, newest first
+CREATE INDEX IF NOT EXISTS idx_reactions_post_created ON reactions(post_id, created_at DESC, id DESC) WHERE post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_reactions_comment_created ON reactions(comment_id, created_at DESC, id DESC) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions(user_id);
+
+-- Likes become heart reactions; posts.like_count keeps counting them
+INSERT INTO reactions (id, post_id, user_id, emoji, created_at)
+SELECT id, post_id, user_id, 'heart', created_at FROM likes
+ON CONFLICT DO NOTHING;
+
+DROP TABLE IF EXISTS likes;
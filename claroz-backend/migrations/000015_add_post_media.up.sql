@@ -0,0 +1,27 @@
+-- Store up to a configured number of ordered images per post; posts.image_url keeps the first one's URL
+CREATE TABLE IF NOT EXISTS post_media (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    mime_type TEXT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_media_post_position ON post_media(post_id, position);
+
+-- Existing posts get their single image as the first item; dimensions are unknown
+INSERT INTO post_media (post_id, position, url, mime_type, created_at)
+SELECT id, 0, image_url,
+    CASE
+        WHEN LOWER(image_url) LIKE '%.png' THEN 'image/png'
+        WHEN LOWER(image_url) LIKE '%.gif' THEN 'image/gif'
+        ELSE 'image/jpeg'
+    END,
+    created_at
+FROM posts
+WHERE deleted_at IS NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE post_media DROP COLUMN IF EXISTS variants;
@@ -0,0 +1,3 @@
+-- Record the resized variants the image pipeline stores for each media item;
+-- media uploaded before the pipeline have none and are served from their URL
+ALTER TABLE post_media ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '{}';
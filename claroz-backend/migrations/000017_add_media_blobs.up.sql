@@ -0,0 +1,25 @@
+-- Track stored files as blobs keyed by their storage key, counting the media
+-- items referring to each; files unreferenced for the grace period are deleted
+CREATE TABLE IF NOT EXISTS media_blobs (
+    key VARCHAR(255) PRIMARY KEY,
+    size BIGINT NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    unreferenced_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_blobs_unreferenced_at ON media_blobs(unreferenced_at);
+
+-- Existing media refer to their URL and the URLs of their variants; sizes are unknown
+INSERT INTO media_blobs (key, ref_count, created_at)
+SELECT regexp_replace(files.url, '^.*/', ''), COUNT(*), NOW()
+FROM post_media m
+CROSS JOIN LATERAL (
+    SELECT m.url
+    UNION
+    SELECT v.value->>'url' FROM jsonb_each(m.variants) v
+) files
+WHERE files.url IS NOT NULL AND files.url <> ''
+GROUP BY 1
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_media_id;
+DROP TABLE IF EXISTS media;
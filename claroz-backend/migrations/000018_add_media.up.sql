@@ -0,0 +1,21 @@
+-- Images uploaded ahead of the posts and profiles they are attached to; pending
+-- media hold references to their blobs until they are attached or expire
+CREATE TABLE IF NOT EXISTS media (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    url TEXT NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    mime_type TEXT NOT NULL,
+    variants JSONB NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attached_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media(user_id);
+CREATE INDEX IF NOT EXISTS idx_media_expires_at ON media(expires_at);
+
+-- The uploaded media a user's avatar came from, whose file the avatar keeps
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_media_id UUID;
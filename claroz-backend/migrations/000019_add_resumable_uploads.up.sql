@@ -0,0 +1,17 @@
+-- Images uploaded in parts with the tus protocol; each part is stored as its
+-- own file until the upload is complete and becomes media
+CREATE TABLE IF NOT EXISTS resumable_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    upload_length BIGINT,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    parts JSONB NOT NULL DEFAULT '[]',
+    media_id UUID,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_resumable_uploads_user_id ON resumable_uploads(user_id);
+CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires_at ON resumable_uploads(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS banner_variants;
+ALTER TABLE users DROP COLUMN IF EXISTS banner;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_variants;
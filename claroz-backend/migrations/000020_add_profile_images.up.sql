@@ -0,0 +1,11 @@
+-- Uploaded avatars and banners keep the variants whose blobs they refer to
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_variants JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banner TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banner_variants JSONB NOT NULL DEFAULT '{}';
+
+-- Avatars attached as media refer to their full-size file
+UPDATE users u
+SET avatar_variants = jsonb_build_object('full', jsonb_build_object(
+    'url', u.avatar, 'width', m.width, 'height', m.height, 'mime_type', m.mime_type))
+FROM media m
+WHERE m.id = u.avatar_media_id AND u.avatar_variants = '{}';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_processing;
+ALTER TABLE post_media DROP COLUMN IF EXISTS duration;
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
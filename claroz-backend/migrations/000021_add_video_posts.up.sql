@@ -0,0 +1,6 @@
+-- Posts with a video are hidden from feeds until it is transcoded
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ready';
+ALTER TABLE post_media ADD COLUMN IF NOT EXISTS duration DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Posts left processing are queued again when the transcoder starts
+CREATE INDEX IF NOT EXISTS idx_posts_processing ON posts (created_at) WHERE status = 'processing' AND deleted_at IS NULL;